@@ -0,0 +1,56 @@
+//go:build ceph_preview
+
+package cephfs
+
+import (
+	"context"
+
+	"github.com/ceph/go-ceph/internal/ctxutil"
+)
+
+// MountContext mounts the file system like Mount, but gives up waiting once
+// ctx is done. In that case ctx.Err() is returned and the mount attempt is
+// left to finish in the background.
+//
+// The MountInfo must not be used after a cancelled MountContext, not even to
+// call Unmount or Release: it is unmounted and released from a background
+// goroutine once the mount attempt finishes, which would race with any use
+// by the caller. Only if ctx was already done when MountContext was called,
+// no attempt is made and the MountInfo remains usable.
+func (mount *MountInfo) MountContext(ctx context.Context) error {
+	return ctxutil.Do(ctx, mount.Mount, func(err error) {
+		if err == nil {
+			_ = mount.Unmount()
+		}
+		_ = mount.Release()
+	})
+}
+
+// ReadAtContext reads data from the file starting at the given offset like
+// ReadAt, but gives up waiting once ctx is done, returning ctx.Err(). The read
+// is performed into a private buffer that is only copied into buf if the read
+// finishes in time, so buf may be reused as soon as ReadAtContext returns.
+func (f *File) ReadAtContext(ctx context.Context, buf []byte, offset int64) (int, error) {
+	if offset < 0 {
+		return 0, errInvalid
+	}
+	if err := f.validate(); err != nil {
+		return 0, err
+	}
+	var (
+		n   int
+		tmp = make([]byte, len(buf))
+	)
+	err := ctxutil.Do(ctx, func() error {
+		var err error
+		n, err = f.read(tmp, offset)
+		return err
+	}, nil)
+	if err != nil {
+		if err == ctx.Err() {
+			return 0, err
+		}
+		return n, err
+	}
+	return copy(buf, tmp[:n]), nil
+}
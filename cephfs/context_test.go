@@ -0,0 +1,75 @@
+//go:build ceph_preview
+
+package cephfs
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMountContext(t *testing.T) {
+	t.Run("mount", func(t *testing.T) {
+		mount, err := CreateMount()
+		require.NoError(t, err)
+		require.NoError(t, mount.ReadDefaultConfigFile())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		err = mount.MountContext(ctx)
+		require.NoError(t, err)
+		fsDisconnect(t, mount)
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		mount, err := CreateMount()
+		require.NoError(t, err)
+		require.NoError(t, mount.ReadDefaultConfigFile())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err = mount.MountContext(ctx)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.False(t, mount.IsMounted())
+		assert.NoError(t, mount.Release())
+	})
+}
+
+func TestFileReadAtContext(t *testing.T) {
+	mount := fsConnect(t)
+	defer fsDisconnect(t, mount)
+	fname := "TestFileReadAtContext.txt"
+
+	f1, err := mount.Open(fname, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, mount.Unlink(fname)) }()
+	defer func() { assert.NoError(t, f1.Close()) }()
+	_, err = f1.WriteAt([]byte("foobar"), 0)
+	require.NoError(t, err)
+
+	t.Run("readAt", func(t *testing.T) {
+		buf := make([]byte, 3)
+		n, err := f1.ReadAtContext(context.Background(), buf, 3)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, n)
+		assert.Equal(t, "bar", string(buf))
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		buf := make([]byte, 3)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := f1.ReadAtContext(ctx, buf, 0)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("invalidOffset", func(t *testing.T) {
+		buf := make([]byte, 3)
+		_, err := f1.ReadAtContext(context.Background(), buf, -1)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,25 @@
+//go:build ceph_preview
+
+package auth
+
+import (
+	ccom "github.com/ceph/go-ceph/common/commands"
+)
+
+// Commander interface supports sending commands to Ceph.
+type Commander interface {
+	ccom.MonCommander
+	ccom.MonBufferCommander
+}
+
+// Admin is used to administer cephx entities.
+type Admin struct {
+	conn Commander
+}
+
+// NewFromConn creates an new management object from a preexisting
+// rados connection. The existing connection can be rados.Conn or any
+// type implementing the Commander interface.
+func NewFromConn(conn Commander) *Admin {
+	return &Admin{conn}
+}
@@ -0,0 +1,102 @@
+//go:build ceph_preview
+
+package auth
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tsuite "github.com/stretchr/testify/suite"
+
+	"github.com/ceph/go-ceph/internal/admintest"
+)
+
+func TestAuthAdmin(t *testing.T) {
+	tsuite.Run(t, new(AuthAdminSuite))
+}
+
+// AuthAdminSuite is a suite of tests for the auth admin package.
+type AuthAdminSuite struct {
+	tsuite.Suite
+
+	vconn *admintest.Connector
+}
+
+func (suite *AuthAdminSuite) SetupSuite() {
+	suite.vconn = admintest.NewConnector()
+}
+
+func (suite *AuthAdminSuite) entityName() string {
+	return fmt.Sprintf("client.go-ceph-%d", time.Now().UnixNano())
+}
+
+func (suite *AuthAdminSuite) TestEntityLifecycle() {
+	t := suite.T()
+	aa := NewFromConn(suite.vconn.Get(t))
+	name := suite.entityName()
+	caps := Caps{
+		DaemonMon: "allow r",
+		DaemonOSD: "allow rw pool=foo namespace=bar",
+	}
+
+	e, err := aa.Create(name, caps)
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, aa.Remove(name)) }()
+	assert.Equal(t, name, e.Name)
+	assert.NotEmpty(t, e.Key)
+	eq, err := e.Caps.Equal(caps)
+	assert.NoError(t, err)
+	assert.True(t, eq)
+
+	e2, err := aa.GetOrCreate(name, caps)
+	require.NoError(t, err)
+	assert.Equal(t, e.Key, e2.Key)
+
+	err = aa.SetDaemonCaps(name, DaemonMgr, "profile rbd pool=foo")
+	require.NoError(t, err)
+	e2, err = aa.Get(name)
+	require.NoError(t, err)
+	assert.Equal(t, "profile rbd pool=foo", e2.Caps[DaemonMgr])
+	assert.Equal(t, "allow r", e2.Caps[DaemonMon])
+
+	entities, err := aa.List()
+	require.NoError(t, err)
+	found := false
+	for _, en := range entities {
+		found = found || en.Name == name
+	}
+	assert.True(t, found)
+
+	keyring, err := aa.Export(name)
+	require.NoError(t, err)
+	assert.Contains(t, string(keyring), "["+name+"]")
+	assert.Contains(t, string(keyring), e.Key)
+}
+
+func (suite *AuthAdminSuite) TestImport() {
+	t := suite.T()
+	aa := NewFromConn(suite.vconn.Get(t))
+	name := suite.entityName()
+
+	keyring := Keyring(Entity{
+		Name: name,
+		Key:  "AQBwNjNmAAAAABAAL0wH9d2AYpVLSdwqyaD6Ug==",
+		Caps: Caps{DaemonMon: "allow r"},
+	})
+	require.NoError(t, aa.Import(keyring))
+	defer func() { assert.NoError(t, aa.Remove(name)) }()
+
+	e, err := aa.Get(name)
+	require.NoError(t, err)
+	assert.Equal(t, "AQBwNjNmAAAAABAAL0wH9d2AYpVLSdwqyaD6Ug==", e.Key)
+	assert.True(t, strings.HasPrefix(e.Caps[DaemonMon], "allow r"))
+
+	require.NoError(t, aa.Remove(name))
+	_, err = aa.Get(name)
+	assert.Error(t, err)
+	require.NoError(t, aa.Import(keyring))
+}
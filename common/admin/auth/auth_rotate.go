@@ -0,0 +1,23 @@
+//go:build !(octopus || pacific || quincy) && ceph_preview
+
+package auth
+
+import (
+	"github.com/ceph/go-ceph/internal/commands"
+)
+
+// Rotate replaces the key of the entity with a newly generated key and
+// returns the entity with the new key. Clients using the old key can no
+// longer authenticate.
+//
+// Similar To:
+//
+//	ceph auth rotate <entity>
+func (aa *Admin) Rotate(entity string) (*Entity, error) {
+	m := map[string]string{
+		"prefix": "auth rotate",
+		"entity": entity,
+		"format": "json",
+	}
+	return parseEntity(commands.MarshalMonCommand(aa.conn, m))
+}
@@ -0,0 +1,24 @@
+//go:build !(octopus || pacific || quincy) && ceph_preview
+
+package auth
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (suite *AuthAdminSuite) TestRotate() {
+	t := suite.T()
+	aa := NewFromConn(suite.vconn.Get(t))
+	name := suite.entityName()
+
+	e, err := aa.GetOrCreate(name, Caps{DaemonMon: "allow r"})
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, aa.Remove(name)) }()
+
+	e2, err := aa.Rotate(name)
+	require.NoError(t, err)
+	assert.Equal(t, name, e2.Name)
+	assert.NotEqual(t, e.Key, e2.Key)
+	assert.Equal(t, e.Caps, e2.Caps)
+}
@@ -0,0 +1,548 @@
+//go:build ceph_preview
+
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// DaemonType is the type of daemon a capability applies to.
+type DaemonType string
+
+const (
+	// DaemonMon is the type of the monitors.
+	DaemonMon = DaemonType("mon")
+	// DaemonOSD is the type of the OSDs.
+	DaemonOSD = DaemonType("osd")
+	// DaemonMDS is the type of the metadata servers.
+	DaemonMDS = DaemonType("mds")
+	// DaemonMgr is the type of the managers.
+	DaemonMgr = DaemonType("mgr")
+)
+
+// ErrInvalidCap is returned if a capability string can not be parsed or is
+// not valid for the daemon type.
+var ErrInvalidCap = errors.New("invalid capability")
+
+// Match keys restricting a Grant.
+const (
+	MatchFSName       = "fsname"
+	MatchPool         = "pool"
+	MatchTag          = "tag"
+	MatchNamespace    = "namespace"
+	MatchObjectPrefix = "object_prefix"
+	MatchPath         = "path"
+	MatchRootSquash   = "root_squash"
+	MatchUID          = "uid"
+	MatchGIDs         = "gids"
+	MatchNetwork      = "network"
+)
+
+// matchOrder is the order the matches are rendered in, which is the order
+// expected by the capability parsers of the daemons.
+var matchOrder = []string{
+	MatchFSName,
+	MatchPool,
+	MatchTag,
+	MatchNamespace,
+	MatchObjectPrefix,
+	MatchPath,
+	MatchRootSquash,
+	MatchUID,
+	MatchGIDs,
+	MatchNetwork,
+}
+
+// daemonRules describe the capability grammar of a daemon type.
+type daemonRules struct {
+	perms      string
+	classPerms bool
+	service    bool
+	module     bool
+	command    bool
+	matches    []string
+}
+
+var capRules = map[DaemonType]daemonRules{
+	DaemonMon: {
+		perms:   "rwx",
+		service: true,
+		command: true,
+		matches: []string{MatchNetwork},
+	},
+	DaemonOSD: {
+		perms:      "rwx",
+		classPerms: true,
+		matches: []string{
+			MatchPool, MatchTag, MatchNamespace, MatchObjectPrefix,
+			MatchNetwork,
+		},
+	},
+	DaemonMDS: {
+		perms: "rwps",
+		matches: []string{
+			MatchFSName, MatchPath, MatchRootSquash, MatchUID, MatchGIDs,
+			MatchNetwork,
+		},
+	},
+	DaemonMgr: {
+		perms:   "rwx",
+		service: true,
+		module:  true,
+		command: true,
+		matches: []string{MatchPool, MatchNamespace, MatchNetwork},
+	},
+}
+
+// permOrder is the order of the permission letters in canonical form.
+const permOrder = "rwxps"
+
+// Match is a key value pair, restricting a Grant or matching an argument
+// of a command.
+type Match struct {
+	Key string
+	// Value is empty for flags like root_squash. For tags it is the
+	// application followed by the key value pair, for example
+	// "cephfs data=fs".
+	Value string
+}
+
+// Grant is a single clause of a capability, for example
+// "allow rw pool=foo namespace=bar" or "profile rbd".
+type Grant struct {
+	// Profile is the name of the granted profile, for example "rbd". If set
+	// only Match may be set in addition.
+	Profile string
+	// Perm are the granted permissions, for example "rw" or "*".
+	Perm string
+	// ClassPerms are the granted OSD class permissions, "class-read" and
+	// "class-write".
+	ClassPerms []string
+	// Service, Module and Command restrict the grant to a service, a
+	// manager module or a command.
+	Service string
+	Module  string
+	Command string
+	// Args restrict a module or command grant to arguments.
+	Args []Match
+	// Match restricts the grant, for example to a pool.
+	Match []Match
+}
+
+// Cap is a parsed capability string of a daemon type.
+type Cap struct {
+	Grants []Grant
+}
+
+// ParseCap parses the capability string s for the given daemon type. Grants
+// may be separated by commas or semicolons.
+func ParseCap(daemon DaemonType, s string) (Cap, error) {
+	rules, ok := capRules[daemon]
+	if !ok {
+		return Cap{}, fmt.Errorf("%w: unknown daemon type %q", ErrInvalidCap, daemon)
+	}
+	toks, err := lexCap(s)
+	if err != nil {
+		return Cap{}, err
+	}
+	var c Cap
+	for len(toks) > 0 {
+		n := slices.IndexFunc(toks, func(t capToken) bool { return t.sep })
+		if n < 0 {
+			n = len(toks)
+		}
+		if n > 0 {
+			g, err := parseGrant(toks[:n])
+			if err != nil {
+				return Cap{}, err
+			}
+			if err := rules.validate(&g); err != nil {
+				return Cap{}, err
+			}
+			c.Grants = append(c.Grants, g)
+		}
+		toks = toks[min(n+1, len(toks)):]
+	}
+	if len(c.Grants) == 0 {
+		return Cap{}, fmt.Errorf("%w: no grants", ErrInvalidCap)
+	}
+	return c, nil
+}
+
+// String returns the capability in canonical form.
+func (c Cap) String() string {
+	grants := make([]string, len(c.Grants))
+	for i := range c.Grants {
+		grants[i] = c.Grants[i].String()
+	}
+	return strings.Join(grants, ", ")
+}
+
+// Equal returns true if both capabilities grant the same, regardless of the
+// order of their grants and matches.
+func (c Cap) Equal(o Cap) bool {
+	a := c.grantStrings()
+	b := o.grantStrings()
+	return slices.Equal(a, b)
+}
+
+func (c Cap) grantStrings() []string {
+	s := make([]string, len(c.Grants))
+	for i := range c.Grants {
+		s[i] = c.Grants[i].String()
+	}
+	slices.Sort(s)
+	return slices.Compact(s)
+}
+
+// String returns the grant in canonical form.
+func (g Grant) String() string {
+	var parts []string
+	if g.Profile != "" {
+		parts = append(parts, "profile", quoteCapValue(g.Profile))
+	} else {
+		parts = append(parts, "allow")
+		if g.Service != "" {
+			parts = append(parts, "service", quoteCapValue(g.Service))
+		}
+		if g.Module != "" {
+			parts = append(parts, "module", quoteCapValue(g.Module))
+		}
+		if g.Command != "" {
+			parts = append(parts, "command", `"`+g.Command+`"`)
+		}
+		if len(g.Args) > 0 {
+			parts = append(parts, "with")
+			for _, a := range g.Args {
+				parts = append(parts, a.Key+"="+quoteCapValue(a.Value))
+			}
+		}
+		if g.Perm != "" {
+			parts = append(parts, g.Perm)
+		}
+		parts = append(parts, g.ClassPerms...)
+	}
+	for _, key := range matchOrder {
+		for _, m := range g.Match {
+			if m.Key != key {
+				continue
+			}
+			switch key {
+			case MatchRootSquash:
+				parts = append(parts, key)
+			case MatchTag, MatchObjectPrefix, MatchNetwork:
+				parts = append(parts, key, m.Value)
+			case MatchGIDs:
+				parts = append(parts, key+"="+m.Value)
+			default:
+				parts = append(parts, key+"="+quoteCapValue(m.Value))
+			}
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func quoteCapValue(s string) string {
+	if s == "" || strings.ContainsAny(s, " \t,;=\"'") {
+		return `"` + s + `"`
+	}
+	return s
+}
+
+func (r daemonRules) validate(g *Grant) error {
+	if g.Profile == "" && g.Perm == "" && len(g.ClassPerms) == 0 && g.Command == "" {
+		return fmt.Errorf("%w: grant %q has no permissions", ErrInvalidCap, g)
+	}
+	for _, p := range g.Perm {
+		if p != '*' && !strings.ContainsRune(r.perms, p) {
+			return fmt.Errorf("%w: invalid permission %q", ErrInvalidCap, p)
+		}
+	}
+	switch {
+	case len(g.ClassPerms) > 0 && !r.classPerms:
+		return fmt.Errorf("%w: class permissions not supported", ErrInvalidCap)
+	case g.Service != "" && !r.service:
+		return fmt.Errorf("%w: service grants not supported", ErrInvalidCap)
+	case g.Module != "" && !r.module:
+		return fmt.Errorf("%w: module grants not supported", ErrInvalidCap)
+	case g.Command != "" && !r.command:
+		return fmt.Errorf("%w: command grants not supported", ErrInvalidCap)
+	}
+	for _, m := range g.Match {
+		if !slices.Contains(r.matches, m.Key) {
+			return fmt.Errorf("%w: %s not supported", ErrInvalidCap, m.Key)
+		}
+	}
+	return nil
+}
+
+// capToken is a word, a key value pair or a separator of grants.
+type capToken struct {
+	sep   bool
+	word  string
+	kv    bool
+	value string
+}
+
+func lexCap(s string) ([]capToken, error) {
+	var toks []capToken
+	for i := 0; i < len(s); {
+		switch c := s[i]; c {
+		case ' ', '\t', '\n':
+			i++
+		case ',', ';':
+			toks = append(toks, capToken{sep: true})
+			i++
+		default:
+			word, n, err := lexCapWord(s[i:], "")
+			if err != nil {
+				return nil, err
+			}
+			i += n
+			t := capToken{word: word}
+			if i < len(s) && s[i] == '=' {
+				i++
+				value, n, err := lexCapWord(s[i:], word)
+				if err != nil {
+					return nil, err
+				}
+				i += n
+				t.kv = true
+				t.value = value
+			}
+			toks = append(toks, t)
+		}
+	}
+	return toks, nil
+}
+
+// lexCapWord returns the possibly quoted word at the start of s and its
+// length. The values of gids may contain commas.
+func lexCapWord(s, key string) (string, int, error) {
+	if s == "" {
+		return "", 0, nil
+	}
+	if q := s[0]; q == '"' || q == '\'' {
+		end := strings.IndexByte(s[1:], q)
+		if end < 0 {
+			return "", 0, fmt.Errorf("%w: unterminated quote", ErrInvalidCap)
+		}
+		return s[1 : end+1], end + 2, nil
+	}
+	n := 0
+	for n < len(s) {
+		c := s[n]
+		if c == ',' && key == MatchGIDs && n+1 < len(s) &&
+			s[n+1] >= '0' && s[n+1] <= '9' {
+			n++
+			continue
+		}
+		if strings.IndexByte(" \t\n,;", c) >= 0 || (key == "" && c == '=') {
+			break
+		}
+		n++
+	}
+	return s[:n], n, nil
+}
+
+// spacedKeys may be followed by their value separated by a space instead of
+// an equals sign.
+var spacedKeys = []string{
+	"service", "module", "command", MatchPool, MatchNamespace,
+	MatchObjectPrefix, MatchNetwork, MatchPath,
+}
+
+func parseGrant(toks []capToken) (Grant, error) {
+	var g Grant
+	i := 0
+	next := func() (string, error) {
+		if i >= len(toks) || toks[i].kv {
+			return "", fmt.Errorf("%w: missing value", ErrInvalidCap)
+		}
+		i++
+		return toks[i-1].word, nil
+	}
+	if toks[0].word == "allow" && !toks[0].kv {
+		i++
+	} else if toks[0].word != "profile" {
+		return g, fmt.Errorf("%w: grant must start with allow or profile", ErrInvalidCap)
+	}
+	withArgs := false
+	for i < len(toks) {
+		t := toks[i]
+		i++
+		if t.kv {
+			if withArgs {
+				g.Args = append(g.Args, Match{Key: t.word, Value: t.value})
+				continue
+			}
+			if err := g.set(t.word, t.value); err != nil {
+				return g, err
+			}
+			continue
+		}
+		withArgs = false
+		switch w := t.word; {
+		case w == "profile":
+			v, err := next()
+			if err != nil {
+				return g, err
+			}
+			g.Profile = v
+		case w == "with":
+			if g.Command == "" && g.Module == "" {
+				return g, fmt.Errorf("%w: with requires a command or module", ErrInvalidCap)
+			}
+			withArgs = true
+		case w == "class-read" || w == "class-write":
+			g.ClassPerms = append(g.ClassPerms, w)
+		case w == MatchRootSquash:
+			g.Match = append(g.Match, Match{Key: w})
+		case w == MatchTag:
+			app, err := next()
+			if err != nil {
+				return g, err
+			}
+			if i >= len(toks) || !toks[i].kv {
+				return g, fmt.Errorf("%w: tag requires key=value", ErrInvalidCap)
+			}
+			g.Match = append(g.Match, Match{
+				Key:   MatchTag,
+				Value: app + " " + toks[i].word + "=" + toks[i].value,
+			})
+			i++
+		case slices.Contains(spacedKeys, w):
+			v, err := next()
+			if err != nil {
+				return g, err
+			}
+			if err := g.set(w, v); err != nil {
+				return g, err
+			}
+		case isPerm(w):
+			if g.Perm != "" {
+				return g, fmt.Errorf("%w: duplicate permissions %q", ErrInvalidCap, w)
+			}
+			g.Perm = canonicalPerm(w)
+		default:
+			return g, fmt.Errorf("%w: unexpected %q", ErrInvalidCap, w)
+		}
+	}
+	slices.Sort(g.ClassPerms)
+	g.ClassPerms = slices.Compact(g.ClassPerms)
+	if g.Profile != "" && (g.Perm != "" || len(g.ClassPerms) > 0 ||
+		g.Service != "" || g.Module != "" || g.Command != "") {
+		return g, fmt.Errorf("%w: profiles can not be combined with permissions", ErrInvalidCap)
+	}
+	return g, nil
+}
+
+func (g *Grant) set(key, value string) error {
+	switch key {
+	case "service":
+		g.Service = value
+	case "module":
+		g.Module = value
+	case "command":
+		g.Command = value
+	default:
+		if !slices.Contains(matchOrder, key) || key == MatchRootSquash || key == MatchTag {
+			return fmt.Errorf("%w: unexpected %q", ErrInvalidCap, key)
+		}
+		g.Match = append(g.Match, Match{Key: key, Value: value})
+	}
+	return nil
+}
+
+func isPerm(w string) bool {
+	if w == "*" || w == "all" {
+		return true
+	}
+	for _, c := range w {
+		if !strings.ContainsRune(permOrder, c) {
+			return false
+		}
+	}
+	return w != ""
+}
+
+func canonicalPerm(w string) string {
+	if w == "*" || w == "all" {
+		return "*"
+	}
+	var b strings.Builder
+	for _, c := range permOrder {
+		if strings.ContainsRune(w, c) {
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// Caps maps daemon types to the capability strings of an entity.
+type Caps map[DaemonType]string
+
+// Parse parses the capability strings of all daemon types.
+func (c Caps) Parse() (map[DaemonType]Cap, error) {
+	parsed := make(map[DaemonType]Cap, len(c))
+	for daemon, s := range c {
+		p, err := ParseCap(daemon, s)
+		if err != nil {
+			return nil, fmt.Errorf("%s caps: %w", daemon, err)
+		}
+		parsed[daemon] = p
+	}
+	return parsed, nil
+}
+
+// Validate returns an error if any of the capability strings is invalid.
+func (c Caps) Validate() error {
+	_, err := c.Parse()
+	return err
+}
+
+// Equal returns true if both sets of capabilities grant the same. An error
+// is returned if any of the capability strings is invalid.
+func (c Caps) Equal(o Caps) (bool, error) {
+	a, err := c.Parse()
+	if err != nil {
+		return false, err
+	}
+	b, err := o.Parse()
+	if err != nil {
+		return false, err
+	}
+	if len(a) != len(b) {
+		return false, nil
+	}
+	for daemon, ca := range a {
+		cb, ok := b[daemon]
+		if !ok || !ca.Equal(cb) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// daemons returns the daemon types in sorted order.
+func (c Caps) daemons() []DaemonType {
+	daemons := make([]DaemonType, 0, len(c))
+	for daemon := range c {
+		daemons = append(daemons, daemon)
+	}
+	slices.Sort(daemons)
+	return daemons
+}
+
+// args returns the capabilities as used by the auth commands, a list of
+// alternating daemon types and capability strings.
+func (c Caps) args() []string {
+	args := make([]string, 0, 2*len(c))
+	for _, daemon := range c.daemons() {
+		args = append(args, string(daemon), c[daemon])
+	}
+	return args
+}
@@ -0,0 +1,206 @@
+//go:build ceph_preview
+
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCap(t *testing.T) {
+	tests := []struct {
+		daemon    DaemonType
+		cap       string
+		canonical string
+		grants    []Grant
+	}{
+		{
+			daemon:    DaemonOSD,
+			cap:       "allow rw pool=foo namespace=bar",
+			canonical: "allow rw pool=foo namespace=bar",
+			grants: []Grant{{
+				Perm: "rw",
+				Match: []Match{
+					{Key: MatchPool, Value: "foo"},
+					{Key: MatchNamespace, Value: "bar"},
+				},
+			}},
+		},
+		{
+			daemon:    DaemonOSD,
+			cap:       "allow pool foo wr, allow class-read object_prefix rbd_children; profile rbd pool=images",
+			canonical: "allow rw pool=foo, allow class-read object_prefix rbd_children, profile rbd pool=images",
+			grants: []Grant{
+				{Perm: "rw", Match: []Match{{Key: MatchPool, Value: "foo"}}},
+				{
+					ClassPerms: []string{"class-read"},
+					Match:      []Match{{Key: MatchObjectPrefix, Value: "rbd_children"}},
+				},
+				{Profile: "rbd", Match: []Match{{Key: MatchPool, Value: "images"}}},
+			},
+		},
+		{
+			daemon:    DaemonOSD,
+			cap:       "allow rw tag cephfs data=fs",
+			canonical: "allow rw tag cephfs data=fs",
+			grants: []Grant{{
+				Perm:  "rw",
+				Match: []Match{{Key: MatchTag, Value: "cephfs data=fs"}},
+			}},
+		},
+		{
+			daemon:    DaemonOSD,
+			cap:       "allow all namespace=\"a b\" pool=x network 10.0.0.0/8",
+			canonical: "allow * pool=x namespace=\"a b\" network 10.0.0.0/8",
+		},
+		{
+			daemon:    DaemonMon,
+			cap:       "allow profile rbd",
+			canonical: "profile rbd",
+			grants:    []Grant{{Profile: "rbd"}},
+		},
+		{
+			daemon:    DaemonMon,
+			cap:       `allow command "osd blocklist" with blocklistop=add addr="1.2.3.4:0/1", allow service=mon r`,
+			canonical: `allow command "osd blocklist" with blocklistop=add addr=1.2.3.4:0/1, allow service mon r`,
+			grants: []Grant{
+				{
+					Command: "osd blocklist",
+					Args: []Match{
+						{Key: "blocklistop", Value: "add"},
+						{Key: "addr", Value: "1.2.3.4:0/1"},
+					},
+				},
+				{Service: "mon", Perm: "r"},
+			},
+		},
+		{
+			daemon:    DaemonMgr,
+			cap:       "allow module foo with a=b rw",
+			canonical: "allow module foo with a=b rw",
+		},
+		{
+			daemon:    DaemonMDS,
+			cap:       "allow rwps fsname=a path=/dir uid=1000 gids=1000,1001 root_squash",
+			canonical: "allow rwps fsname=a path=/dir root_squash uid=1000 gids=1000,1001",
+			grants: []Grant{{
+				Perm: "rwps",
+				Match: []Match{
+					{Key: MatchFSName, Value: "a"},
+					{Key: MatchPath, Value: "/dir"},
+					{Key: MatchUID, Value: "1000"},
+					{Key: MatchGIDs, Value: "1000,1001"},
+					{Key: MatchRootSquash},
+				},
+			}},
+		},
+		{
+			daemon:    DaemonMDS,
+			cap:       "allow r, allow rw path=/x",
+			canonical: "allow r, allow rw path=/x",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.cap, func(t *testing.T) {
+			c, err := ParseCap(tc.daemon, tc.cap)
+			require.NoError(t, err)
+			assert.Equal(t, tc.canonical, c.String())
+			if tc.grants != nil {
+				assert.Equal(t, tc.grants, c.Grants)
+			}
+			// the canonical form parses to the same capability
+			c2, err := ParseCap(tc.daemon, c.String())
+			require.NoError(t, err)
+			assert.True(t, c.Equal(c2))
+			assert.Equal(t, c.String(), c2.String())
+		})
+	}
+}
+
+func TestParseCapErrors(t *testing.T) {
+	tests := []struct {
+		daemon DaemonType
+		cap    string
+	}{
+		{DaemonOSD, ""},
+		{DaemonOSD, "  ,; "},
+		{DaemonOSD, "deny rw"},
+		{DaemonOSD, "allow"},
+		{DaemonOSD, "allow rq"},
+		{DaemonOSD, "allow rw rw"},
+		{DaemonOSD, "allow rw path=/x"},
+		{DaemonOSD, "allow rw pool"},
+		{DaemonOSD, "allow rw pool=\"foo"},
+		{DaemonOSD, "allow rw tag cephfs"},
+		{DaemonOSD, "allow rw frobnicate"},
+		{DaemonOSD, "allow rw bogus=1"},
+		{DaemonOSD, "allow command \"osd ls\""},
+		{DaemonOSD, "profile rbd rw"},
+		{DaemonMon, "allow class-read"},
+		{DaemonMon, "allow r pool=foo"},
+		{DaemonMon, "allow r with a=b"},
+		{DaemonMDS, "allow rwx"},
+		{DaemonMDS, "allow service mds r"},
+		{"rgw", "allow rw"},
+	}
+	for _, tc := range tests {
+		t.Run(string(tc.daemon)+" "+tc.cap, func(t *testing.T) {
+			_, err := ParseCap(tc.daemon, tc.cap)
+			assert.ErrorIs(t, err, ErrInvalidCap)
+		})
+	}
+}
+
+func TestCapEqual(t *testing.T) {
+	a, err := ParseCap(DaemonOSD, "allow rw namespace=b pool=a, profile rbd")
+	require.NoError(t, err)
+	b, err := ParseCap(DaemonOSD, "profile rbd; allow wr pool=a namespace=b")
+	require.NoError(t, err)
+	c, err := ParseCap(DaemonOSD, "allow rw pool=a")
+	require.NoError(t, err)
+	assert.True(t, a.Equal(b))
+	assert.False(t, a.Equal(c))
+}
+
+func TestCaps(t *testing.T) {
+	a := Caps{
+		DaemonMon: "allow r",
+		DaemonOSD: "allow rw pool=foo namespace=bar",
+	}
+	b := Caps{
+		DaemonMon: "allow  r",
+		DaemonOSD: "allow wr namespace=bar pool=foo",
+	}
+	eq, err := a.Equal(b)
+	assert.NoError(t, err)
+	assert.True(t, eq)
+
+	b[DaemonMgr] = "allow r"
+	eq, err = a.Equal(b)
+	assert.NoError(t, err)
+	assert.False(t, eq)
+
+	b = Caps{DaemonMon: "allow r", DaemonOSD: "allow r pool=foo"}
+	eq, err = a.Equal(b)
+	assert.NoError(t, err)
+	assert.False(t, eq)
+
+	b[DaemonOSD] = "allow qq"
+	_, err = a.Equal(b)
+	assert.ErrorIs(t, err, ErrInvalidCap)
+	_, err = b.Equal(a)
+	assert.ErrorIs(t, err, ErrInvalidCap)
+	assert.ErrorIs(t, b.Validate(), ErrInvalidCap)
+
+	parsed, err := a.Parse()
+	require.NoError(t, err)
+	assert.Len(t, parsed, 2)
+	assert.Equal(t, "rw", parsed[DaemonOSD].Grants[0].Perm)
+
+	assert.Equal(t, []string{
+		"mon", "allow r",
+		"osd", "allow rw pool=foo namespace=bar",
+	}, a.args())
+}
@@ -0,0 +1,11 @@
+//go:build ceph_preview
+
+/*
+Package auth from common/admin contains a set of APIs to manage cephx
+entities, their keys and capabilities.
+
+Capability strings can be parsed into structured values with ParseCap, so
+that capabilities can be validated and compared independent of their
+formatting.
+*/
+package auth
@@ -0,0 +1,214 @@
+//go:build ceph_preview
+
+package auth
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ceph/go-ceph/internal/commands"
+)
+
+// ErrNoEntity is returned if the monitors did not return the requested
+// entity.
+var ErrNoEntity = errors.New("entity not returned")
+
+// Entity is a cephx entity, for example "client.admin", with its key and
+// capabilities.
+type Entity struct {
+	Name string `json:"entity"`
+	Key  string `json:"key"`
+	Caps Caps   `json:"caps"`
+}
+
+type authDump struct {
+	Entities []Entity `json:"auth_dump"`
+}
+
+func parseEntity(res commands.Response) (*Entity, error) {
+	var entities []Entity
+	if err := res.Unmarshal(&entities).End(); err != nil {
+		return nil, err
+	}
+	if len(entities) == 0 {
+		return nil, ErrNoEntity
+	}
+	return &entities[0], nil
+}
+
+// Get returns the entity with the given name.
+//
+// Similar To:
+//
+//	ceph auth get <entity>
+func (aa *Admin) Get(entity string) (*Entity, error) {
+	m := map[string]string{
+		"prefix": "auth get",
+		"entity": entity,
+		"format": "json",
+	}
+	return parseEntity(commands.MarshalMonCommand(aa.conn, m))
+}
+
+// GetOrCreate returns the entity with the given name. If the entity does not
+// exist it is created with the given capabilities. If it does exist, its
+// capabilities must match the given ones.
+//
+// Similar To:
+//
+//	ceph auth get-or-create <entity> [<daemon> <caps>...]
+func (aa *Admin) GetOrCreate(entity string, caps Caps) (*Entity, error) {
+	if err := caps.Validate(); err != nil {
+		return nil, err
+	}
+	m := map[string]any{
+		"prefix": "auth get-or-create",
+		"entity": entity,
+		"caps":   caps.args(),
+		"format": "json",
+	}
+	return parseEntity(commands.MarshalMonCommand(aa.conn, m))
+}
+
+// Create creates a new entity with a generated key and the given
+// capabilities. It fails if an entity with different capabilities exists.
+//
+// Similar To:
+//
+//	ceph auth add <entity> [<daemon> <caps>...]
+func (aa *Admin) Create(entity string, caps Caps) (*Entity, error) {
+	if err := caps.Validate(); err != nil {
+		return nil, err
+	}
+	m := map[string]any{
+		"prefix": "auth add",
+		"entity": entity,
+		"caps":   caps.args(),
+		"format": "json",
+	}
+	if err := commands.MarshalMonCommand(aa.conn, m).End(); err != nil {
+		return nil, err
+	}
+	return aa.Get(entity)
+}
+
+// List returns all entities.
+//
+// Similar To:
+//
+//	ceph auth ls
+func (aa *Admin) List() ([]Entity, error) {
+	m := map[string]string{
+		"prefix": "auth ls",
+		"format": "json",
+	}
+	var dump authDump
+	res := commands.MarshalMonCommand(aa.conn, m)
+	if err := res.Unmarshal(&dump).End(); err != nil {
+		return nil, err
+	}
+	return dump.Entities, nil
+}
+
+// Remove removes the entity with the given name.
+//
+// Similar To:
+//
+//	ceph auth rm <entity>
+func (aa *Admin) Remove(entity string) error {
+	m := map[string]string{
+		"prefix": "auth rm",
+		"entity": entity,
+	}
+	return commands.MarshalMonCommand(aa.conn, m).NoBody().End()
+}
+
+// SetCaps replaces all capabilities of the entity.
+//
+// Similar To:
+//
+//	ceph auth caps <entity> <daemon> <caps> [<daemon> <caps>...]
+func (aa *Admin) SetCaps(entity string, caps Caps) error {
+	if err := caps.Validate(); err != nil {
+		return err
+	}
+	m := map[string]any{
+		"prefix": "auth caps",
+		"entity": entity,
+		"caps":   caps.args(),
+	}
+	return commands.MarshalMonCommand(aa.conn, m).NoBody().End()
+}
+
+// SetDaemonCaps replaces the capabilities of the entity for one daemon type
+// and keeps the capabilities for the other daemon types. An empty capStr
+// removes the capabilities for the daemon type.
+//
+// Similar To:
+//
+//	ceph auth caps <entity> <daemon> <caps> [<daemon> <caps>...]
+func (aa *Admin) SetDaemonCaps(entity string, daemon DaemonType, capStr string) error {
+	e, err := aa.Get(entity)
+	if err != nil {
+		return err
+	}
+	caps := Caps{}
+	for d, c := range e.Caps {
+		caps[d] = c
+	}
+	if capStr == "" {
+		delete(caps, daemon)
+	} else {
+		caps[daemon] = capStr
+	}
+	return aa.SetCaps(entity, caps)
+}
+
+// Export returns the keyring of the entity with the given name. If entity is
+// empty the keyring of all entities is returned.
+//
+// Similar To:
+//
+//	ceph auth export [<entity>]
+func (aa *Admin) Export(entity string) ([]byte, error) {
+	m := map[string]string{
+		"prefix": "auth export",
+	}
+	if entity != "" {
+		m["entity"] = entity
+	}
+	res := commands.MarshalMonCommand(aa.conn, m)
+	if err := res.End(); err != nil {
+		return nil, err
+	}
+	return res.Body(), nil
+}
+
+// Import adds the entities of the keyring, or updates their keys and
+// capabilities if they exist.
+//
+// Similar To:
+//
+//	ceph auth import -i <keyring>
+func (aa *Admin) Import(keyring []byte) error {
+	m := map[string]string{
+		"prefix": "auth import",
+	}
+	return commands.MarshalMonCommandWithBuffer(aa.conn, m, keyring).NoBody().End()
+}
+
+// Keyring renders the entities in the format of a keyring file.
+func Keyring(entities ...Entity) []byte {
+	var b bytes.Buffer
+	for _, e := range entities {
+		fmt.Fprintf(&b, "[%s]\n", e.Name)
+		fmt.Fprintf(&b, "\tkey = %s\n", e.Key)
+		for _, daemon := range e.Caps.daemons() {
+			c := strings.ReplaceAll(e.Caps[daemon], `"`, `\"`)
+			fmt.Fprintf(&b, "\tcaps %s = \"%s\"\n", daemon, c)
+		}
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,151 @@
+//go:build ceph_preview
+
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeConn records the commands it receives and replies with the given
+// bodies.
+type fakeConn struct {
+	cmds    []map[string]any
+	input   []byte
+	replies []string
+	err     error
+}
+
+func (f *fakeConn) reply(buf []byte) ([]byte, string, error) {
+	var cmd map[string]any
+	if err := json.Unmarshal(buf, &cmd); err != nil {
+		return nil, "", err
+	}
+	f.cmds = append(f.cmds, cmd)
+	if f.err != nil {
+		return nil, "", f.err
+	}
+	if len(f.replies) == 0 {
+		return nil, "", nil
+	}
+	r := f.replies[0]
+	f.replies = f.replies[1:]
+	return []byte(r), "", nil
+}
+
+func (f *fakeConn) MonCommand(buf []byte) ([]byte, string, error) {
+	return f.reply(buf)
+}
+
+func (f *fakeConn) MonCommandWithInputBuffer(buf, input []byte) ([]byte, string, error) {
+	f.input = input
+	return f.reply(buf)
+}
+
+const fooEntity = `[{"entity":"client.foo","key":"AQBwNjNmAAAAABAAL0wH9d2AYpVLSdwqyaD6Ug==","caps":{"mon":"allow r","osd":"allow rw pool=foo"}}]`
+
+func TestGetOrCreate(t *testing.T) {
+	conn := &fakeConn{replies: []string{fooEntity}}
+	aa := NewFromConn(conn)
+	e, err := aa.GetOrCreate("client.foo", Caps{
+		DaemonOSD: "allow rw pool=foo",
+		DaemonMon: "allow r",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "client.foo", e.Name)
+	assert.Equal(t, "AQBwNjNmAAAAABAAL0wH9d2AYpVLSdwqyaD6Ug==", e.Key)
+	assert.Equal(t, Caps{DaemonMon: "allow r", DaemonOSD: "allow rw pool=foo"}, e.Caps)
+	assert.Equal(t, map[string]any{
+		"prefix": "auth get-or-create",
+		"entity": "client.foo",
+		"caps":   []any{"mon", "allow r", "osd", "allow rw pool=foo"},
+		"format": "json",
+	}, conn.cmds[0])
+
+	// invalid caps are not sent
+	_, err = aa.GetOrCreate("client.foo", Caps{DaemonOSD: "allow rw poool=foo"})
+	assert.ErrorIs(t, err, ErrInvalidCap)
+	assert.Len(t, conn.cmds, 1)
+
+	conn.replies = []string{"[]"}
+	_, err = aa.Get("client.foo")
+	assert.ErrorIs(t, err, ErrNoEntity)
+}
+
+func TestSetDaemonCaps(t *testing.T) {
+	conn := &fakeConn{replies: []string{fooEntity}}
+	aa := NewFromConn(conn)
+	require.NoError(t, aa.SetDaemonCaps("client.foo", DaemonMgr, "profile rbd"))
+	require.Len(t, conn.cmds, 2)
+	assert.Equal(t, "auth get", conn.cmds[0]["prefix"])
+	assert.Equal(t, map[string]any{
+		"prefix": "auth caps",
+		"entity": "client.foo",
+		"caps": []any{
+			"mgr", "profile rbd",
+			"mon", "allow r",
+			"osd", "allow rw pool=foo",
+		},
+	}, conn.cmds[1])
+
+	conn.replies = []string{fooEntity}
+	require.NoError(t, aa.SetDaemonCaps("client.foo", DaemonOSD, ""))
+	assert.Equal(t, []any{"mon", "allow r"}, conn.cmds[3]["caps"])
+
+	conn.err = errors.New("no monitors")
+	assert.Error(t, aa.SetDaemonCaps("client.foo", DaemonOSD, ""))
+}
+
+func TestListImportExport(t *testing.T) {
+	conn := &fakeConn{replies: []string{
+		`{"auth_dump":[{"entity":"osd.0","key":"AQA=","caps":{"mgr":"allow profile osd","mon":"allow profile osd","osd":"allow *"}},{"entity":"client.admin","key":"AQB=","caps":{"mds":"allow *","mgr":"allow *","mon":"allow *","osd":"allow *"}}]}`,
+	}}
+	aa := NewFromConn(conn)
+	entities, err := aa.List()
+	require.NoError(t, err)
+	require.Len(t, entities, 2)
+	assert.Equal(t, "osd.0", entities[0].Name)
+	assert.Equal(t, "allow profile osd", entities[0].Caps[DaemonMon])
+
+	keyring := Keyring(entities[1])
+	require.NoError(t, aa.Import(keyring))
+	assert.Equal(t, keyring, conn.input)
+	assert.Equal(t, "auth import", conn.cmds[1]["prefix"])
+
+	conn.replies = []string{string(keyring)}
+	out, err := aa.Export("client.admin")
+	require.NoError(t, err)
+	assert.Equal(t, keyring, out)
+	assert.Equal(t, map[string]any{
+		"prefix": "auth export",
+		"entity": "client.admin",
+	}, conn.cmds[2])
+
+	require.NoError(t, aa.Remove("client.admin"))
+	assert.Equal(t, "auth rm", conn.cmds[3]["prefix"])
+}
+
+func TestKeyring(t *testing.T) {
+	k := Keyring(
+		Entity{
+			Name: "client.foo",
+			Key:  "AQA=",
+			Caps: Caps{
+				DaemonOSD: "allow rw pool=foo",
+				DaemonMon: `allow command "auth get"`,
+			},
+		},
+		Entity{Name: "client.bar", Key: "AQB="},
+	)
+	assert.Equal(t, `[client.foo]
+	key = AQA=
+	caps mon = "allow command \"auth get\""
+	caps osd = "allow rw pool=foo"
+[client.bar]
+	key = AQB=
+`, string(k))
+}
@@ -0,0 +1,33 @@
+//go:build ceph_preview
+
+package cluster
+
+import (
+	ccom "github.com/ceph/go-ceph/common/commands"
+	"github.com/ceph/go-ceph/internal/commands"
+)
+
+// Admin is used to query the maps and the status of a Ceph cluster.
+type Admin struct {
+	conn ccom.MonCommander
+}
+
+// NewFromConn creates an new management object from a preexisting
+// rados connection. The existing connection can be rados.Conn or any
+// type implementing the MonCommander interface.
+func NewFromConn(conn ccom.MonCommander) *Admin {
+	return &Admin{conn}
+}
+
+// monCommand sends a JSON formatted command to the monitors and unmarshals
+// the reply into v.
+func (a *Admin) monCommand(cmd map[string]any, v any) error {
+	cmd["format"] = "json"
+	return commands.MarshalMonCommand(a.conn, cmd).Unmarshal(v).End()
+}
+
+// monCommandNoReply sends a command to the monitors that does not reply
+// with data.
+func (a *Admin) monCommandNoReply(cmd map[string]any) error {
+	return commands.MarshalMonCommand(a.conn, cmd).NoBody().End()
+}
@@ -0,0 +1,189 @@
+//go:build ceph_preview
+
+package cluster
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tsuite "github.com/stretchr/testify/suite"
+
+	"github.com/ceph/go-ceph/internal/admintest"
+)
+
+var fixtureReleases = []string{"nautilus", "quincy", "squid"}
+
+// fixtureConn replies to mon commands with the JSON files of a release in
+// testdata, named after the command prefix.
+type fixtureConn struct {
+	t       *testing.T
+	release string
+}
+
+func (f *fixtureConn) MonCommand(buf []byte) ([]byte, string, error) {
+	var cmd map[string]any
+	if err := json.Unmarshal(buf, &cmd); err != nil {
+		return nil, "", err
+	}
+	assert.Equal(f.t, "json", cmd["format"])
+	prefix, _ := cmd["prefix"].(string)
+	if cmd["detail"] == "detail" {
+		prefix += " detail"
+	}
+	name := strings.ReplaceAll(prefix, " ", "_") + ".json"
+	b, err := os.ReadFile(filepath.Join("testdata", f.release, name))
+	if err != nil {
+		return nil, "", err
+	}
+	return b, "", nil
+}
+
+func fixtureAdmin(t *testing.T, release string) *Admin {
+	return NewFromConn(&fixtureConn{t: t, release: release})
+}
+
+type errConn struct{}
+
+func (errConn) MonCommand([]byte) ([]byte, string, error) {
+	return nil, "", errors.New("no monitors")
+}
+
+func TestAdminError(t *testing.T) {
+	ca := NewFromConn(errConn{})
+	_, err := ca.OSDMap()
+	assert.Error(t, err)
+	_, err = ca.MonMap()
+	assert.Error(t, err)
+	_, err = ca.PGMap()
+	assert.Error(t, err)
+	_, err = ca.FSMap()
+	assert.Error(t, err)
+	_, err = ca.Status()
+	assert.Error(t, err)
+	_, err = ca.Health()
+	assert.Error(t, err)
+	_, err = ca.HealthDetail()
+	assert.Error(t, err)
+	assert.Error(t, ca.Mute("OSD_DOWN", nil))
+	assert.Error(t, ca.Unmute("OSD_DOWN"))
+}
+
+func TestAllFixtures(t *testing.T) {
+	for _, release := range fixtureReleases {
+		t.Run(release, func(t *testing.T) {
+			ca := fixtureAdmin(t, release)
+			om, err := ca.OSDMap()
+			require.NoError(t, err)
+			assert.NotZero(t, om.Epoch)
+			assert.NotEmpty(t, om.FSID)
+			assert.False(t, om.Created.IsZero())
+			assert.NotEmpty(t, om.Pools)
+			assert.NotEmpty(t, om.OSDs)
+
+			mm, err := ca.MonMap()
+			require.NoError(t, err)
+			assert.Equal(t, om.FSID, mm.FSID)
+			assert.NotEmpty(t, mm.Mons)
+			assert.Equal(t, release, mm.MinMonReleaseName)
+
+			pm, err := ca.PGMap()
+			require.NoError(t, err)
+			assert.NotEmpty(t, pm.PGs)
+
+			_, err = ca.FSMap()
+			require.NoError(t, err)
+
+			st, err := ca.Status()
+			require.NoError(t, err)
+			assert.Equal(t, om.FSID, st.FSID)
+			assert.Equal(t, om.Epoch, st.OSDMap.Epoch)
+			assert.Len(t, st.QuorumNames, len(mm.Quorum))
+
+			h, err := ca.HealthDetail()
+			require.NoError(t, err)
+			assert.Equal(t, st.Health.Status, h.Status)
+			assert.Len(t, h.Checks, len(st.Health.Checks))
+		})
+	}
+}
+
+func TestLive(t *testing.T) {
+	tsuite.Run(t, new(ClusterAdminSuite))
+}
+
+// ClusterAdminSuite is a suite of tests for the cluster admin package that
+// requires a running cluster.
+type ClusterAdminSuite struct {
+	tsuite.Suite
+
+	vconn *admintest.Connector
+}
+
+func (suite *ClusterAdminSuite) SetupSuite() {
+	suite.vconn = admintest.NewConnector()
+}
+
+func (suite *ClusterAdminSuite) TestMaps() {
+	t := suite.T()
+	ca := NewFromConn(suite.vconn.Get(t))
+
+	om, err := ca.OSDMap()
+	require.NoError(t, err)
+	assert.NotZero(t, om.Epoch)
+	if assert.NotNil(t, om.OSD(0)) {
+		assert.True(t, om.OSD(0).Up)
+		assert.True(t, om.OSD(0).In)
+	}
+
+	mm, err := ca.MonMap()
+	require.NoError(t, err)
+	assert.Equal(t, om.FSID, mm.FSID)
+	assert.NotEmpty(t, mm.Mons)
+	assert.NotEmpty(t, mm.Quorum)
+
+	pm, err := ca.PGMap()
+	require.NoError(t, err)
+	total := 0
+	for _, n := range pm.StateCounts() {
+		total += n
+	}
+	assert.Equal(t, len(pm.PGs), total)
+
+	_, err = ca.FSMap()
+	require.NoError(t, err)
+
+	st, err := ca.Status()
+	require.NoError(t, err)
+	assert.Equal(t, om.FSID, st.FSID)
+	assert.NotEmpty(t, st.Health.Status)
+	assert.Equal(t, 1, st.OSDMap.NumUpOSDs)
+	assert.Equal(t, len(pm.PGs), st.PGMap.NumPGs)
+}
+
+func (suite *ClusterAdminSuite) TestMute() {
+	t := suite.T()
+	ca := NewFromConn(suite.vconn.Get(t))
+	code := "GO_CEPH_TEST"
+
+	err := ca.Mute(code, &MuteOptions{TTL: time.Hour, Sticky: true})
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, ca.Unmute(code)) }()
+
+	h, err := ca.Health()
+	require.NoError(t, err)
+	idx := slices.IndexFunc(h.Mutes, func(m HealthMute) bool {
+		return m.Code == code
+	})
+	if assert.NotEqual(t, -1, idx) {
+		assert.True(t, h.Mutes[idx].Sticky)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), h.Mutes[idx].TTL.Time, 5*time.Minute)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build ceph_preview
+
+/*
+Package cluster from common/admin contains typed models of the maps and the
+status of a Ceph cluster, together with functions fetching them from the
+monitors.
+
+The models cover the commonly used parts of the JSON output of the "osd
+dump", "mon dump", "pg dump", "fs dump" and "status" commands. Differences
+between Ceph releases in the layout of these outputs are handled when
+decoding, so the same Go types are returned for all supported releases.
+*/
+package cluster
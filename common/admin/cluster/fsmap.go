@@ -0,0 +1,76 @@
+//go:build ceph_preview
+
+package cluster
+
+// FSMap describes the file systems and MDS daemons of a cluster.
+type FSMap struct {
+	Epoch        uint32 `json:"epoch"`
+	DefaultFSCID int64  `json:"default_fscid"`
+	// Standbys are the MDS daemons not assigned to a file system.
+	Standbys    []MDS        `json:"standbys"`
+	Filesystems []Filesystem `json:"filesystems"`
+}
+
+// Filesystem describes a file system of the FS map.
+type Filesystem struct {
+	ID     int64  `json:"id"`
+	MDSMap MDSMap `json:"mdsmap"`
+}
+
+// MDSMap describes the state of a file system and its MDS daemons.
+type MDSMap struct {
+	Epoch        uint32  `json:"epoch"`
+	Name         string  `json:"fs_name"`
+	Enabled      bool    `json:"enabled"`
+	Created      Time    `json:"created"`
+	Modified     Time    `json:"modified"`
+	MaxMDS       int     `json:"max_mds"`
+	In           []int   `json:"in"`
+	Failed       []int   `json:"failed"`
+	Damaged      []int   `json:"damaged"`
+	Stopped      []int   `json:"stopped"`
+	DataPools    []int64 `json:"data_pools"`
+	MetadataPool int64   `json:"metadata_pool"`
+	// Up maps ranks, in the form "mds_<rank>", to the GIDs of the daemons
+	// holding them.
+	Up map[string]int64 `json:"up"`
+	// Info maps GIDs, in the form "gid_<gid>", to the daemons of the file
+	// system.
+	Info map[string]MDS `json:"info"`
+}
+
+// MDS describes an MDS daemon.
+type MDS struct {
+	GID  int64  `json:"gid"`
+	Name string `json:"name"`
+	Rank int    `json:"rank"`
+	// State is the state of the daemon, for example "up:active" or
+	// "up:standby".
+	State       string `json:"state"`
+	Incarnation int    `json:"incarnation"`
+	Addr        string `json:"addr"`
+	JoinFSCID   int64  `json:"join_fscid"`
+}
+
+// Filesystem returns the file system with the given name or nil.
+func (m *FSMap) Filesystem(name string) *Filesystem {
+	for i := range m.Filesystems {
+		if m.Filesystems[i].MDSMap.Name == name {
+			return &m.Filesystems[i]
+		}
+	}
+	return nil
+}
+
+// FSMap returns the current FS map.
+//
+// Similar To:
+//
+//	ceph fs dump
+func (a *Admin) FSMap() (*FSMap, error) {
+	m := &FSMap{}
+	if err := a.monCommand(map[string]any{"prefix": "fs dump"}, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,142 @@
+//go:build ceph_preview
+
+package cluster
+
+import (
+	"fmt"
+	"time"
+)
+
+// HealthStatus is the overall health of a cluster or the severity of a
+// health check.
+type HealthStatus string
+
+const (
+	// HealthOK indicates a healthy cluster.
+	HealthOK = HealthStatus("HEALTH_OK")
+	// HealthWarn indicates a problem that does not affect availability.
+	HealthWarn = HealthStatus("HEALTH_WARN")
+	// HealthErr indicates a serious problem.
+	HealthErr = HealthStatus("HEALTH_ERR")
+)
+
+// Health is the health of a cluster.
+type Health struct {
+	Status HealthStatus `json:"status"`
+	// Checks maps the codes of failed health checks, for example
+	// "OSD_DOWN", to their state.
+	Checks map[string]HealthCheck `json:"checks"`
+	// Mutes are the muted health checks.
+	Mutes []HealthMute `json:"mutes"`
+}
+
+// HealthCheck is the state of a failed health check.
+type HealthCheck struct {
+	Severity HealthStatus  `json:"severity"`
+	Summary  HealthSummary `json:"summary"`
+	// Detail contains detailed messages. It is only set by HealthDetail.
+	Detail []HealthDetail `json:"detail"`
+	Muted  bool           `json:"muted"`
+}
+
+// HealthSummary summarizes a failed health check.
+type HealthSummary struct {
+	Message string `json:"message"`
+	// Count is the number of affected items, for example the number of
+	// down OSDs.
+	Count int `json:"count"`
+}
+
+// HealthDetail is a detailed message of a failed health check.
+type HealthDetail struct {
+	Message string `json:"message"`
+}
+
+// HealthMute describes a muted health check.
+type HealthMute struct {
+	Code string `json:"code"`
+	// Sticky mutes stay in place when the health check clears.
+	Sticky  bool   `json:"sticky"`
+	Summary string `json:"summary"`
+	Count   int    `json:"count"`
+	// TTL is the time the mute expires at. It is zero for mutes without
+	// time limit.
+	TTL Time `json:"ttl"`
+}
+
+// Health returns the health of the cluster.
+//
+// Similar To:
+//
+//	ceph health
+func (a *Admin) Health() (*Health, error) {
+	h := &Health{}
+	if err := a.monCommand(map[string]any{"prefix": "health"}, h); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// HealthDetail returns the health of the cluster including the detailed
+// messages of the failed health checks.
+//
+// Similar To:
+//
+//	ceph health detail
+func (a *Admin) HealthDetail() (*Health, error) {
+	h := &Health{}
+	err := a.monCommand(map[string]any{
+		"prefix": "health",
+		"detail": "detail",
+	}, h)
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// MuteOptions configures a mute of a health check.
+type MuteOptions struct {
+	// TTL limits the time the health check is muted. It is rounded to
+	// seconds. The health check is muted until unmuted if TTL is zero.
+	TTL time.Duration
+	// Sticky keeps the mute in place when the health check clears.
+	// Otherwise the mute is removed once the health check clears.
+	Sticky bool
+}
+
+// Mute mutes the health check with the given code, for example "OSD_DOWN".
+// A muted health check does not affect the overall health status. A mute
+// that is not sticky is removed when the number of affected items, as
+// reported by HealthSummary.Count, increases. The options may be nil.
+//
+// Similar To:
+//
+//	ceph health mute <code> [<ttl>] [--sticky]
+func (a *Admin) Mute(code string, o *MuteOptions) error {
+	cmd := map[string]any{
+		"prefix": "health mute",
+		"code":   code,
+	}
+	if o != nil {
+		if o.TTL > 0 {
+			cmd["ttl"] = fmt.Sprintf("%ds", int64(o.TTL.Round(time.Second)/time.Second))
+		}
+		if o.Sticky {
+			cmd["sticky"] = true
+		}
+	}
+	return a.monCommandNoReply(cmd)
+}
+
+// Unmute removes the mute of the health check with the given code.
+//
+// Similar To:
+//
+//	ceph health unmute <code>
+func (a *Admin) Unmute(code string) error {
+	return a.monCommandNoReply(map[string]any{
+		"prefix": "health unmute",
+		"code":   code,
+	})
+}
@@ -0,0 +1,268 @@
+//go:build ceph_preview
+
+package cluster
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ceph/go-ceph/rados"
+)
+
+func TestHealth(t *testing.T) {
+	h, err := fixtureAdmin(t, "squid").Health()
+	require.NoError(t, err)
+	assert.Equal(t, HealthWarn, h.Status)
+	assert.Len(t, h.Checks, 2)
+	assert.Empty(t, h.Checks["POOL_NO_REDUNDANCY"].Detail)
+	require.Len(t, h.Mutes, 1)
+	assert.Equal(t, "OSDMAP_FLAGS", h.Mutes[0].Code)
+	assert.True(t, h.Mutes[0].Sticky)
+	assert.Equal(t, 2, h.Mutes[0].Count)
+	assert.Equal(t,
+		time.Date(2024, 9, 28, 8, 0, 0, 0, time.UTC), h.Mutes[0].TTL.UTC())
+
+	h, err = fixtureAdmin(t, "squid").HealthDetail()
+	require.NoError(t, err)
+	c := h.Checks["POOL_NO_REDUNDANCY"]
+	assert.Equal(t, HealthWarn, c.Severity)
+	assert.Equal(t, HealthSummary{
+		Message: "3 pool(s) have no replicas configured",
+		Count:   3,
+	}, c.Summary)
+	require.Len(t, c.Detail, 3)
+	assert.Equal(t, "pool '.mgr' has no replicas configured", c.Detail[0].Message)
+	assert.True(t, h.Checks["OSDMAP_FLAGS"].Muted)
+
+	// nautilus does not support mutes
+	h, err = fixtureAdmin(t, "nautilus").HealthDetail()
+	require.NoError(t, err)
+	assert.Empty(t, h.Mutes)
+	assert.Equal(t, "osd.2 (root=default,host=node3) is down",
+		h.Checks["OSD_DOWN"].Detail[0].Message)
+
+	h, err = fixtureAdmin(t, "quincy").Health()
+	require.NoError(t, err)
+	assert.Equal(t, HealthOK, h.Status)
+	assert.Empty(t, h.Checks)
+}
+
+// cmdConn records the commands it receives and replies with the given
+// results.
+type cmdConn struct {
+	mutex   sync.Mutex
+	cmds    []map[string]any
+	replies []string
+	err     error
+}
+
+func (c *cmdConn) MonCommand(buf []byte) ([]byte, string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var cmd map[string]any
+	if err := json.Unmarshal(buf, &cmd); err != nil {
+		return nil, "", err
+	}
+	c.cmds = append(c.cmds, cmd)
+	if c.err != nil {
+		return nil, "", c.err
+	}
+	if len(c.replies) == 0 {
+		return nil, "", nil
+	}
+	r := c.replies[0]
+	if len(c.replies) > 1 {
+		c.replies = c.replies[1:]
+	}
+	return []byte(r), "", nil
+}
+
+func TestMute(t *testing.T) {
+	conn := &cmdConn{}
+	ca := NewFromConn(conn)
+	require.NoError(t, ca.Mute("OSD_DOWN", nil))
+	require.NoError(t, ca.Mute("OSD_DOWN", &MuteOptions{
+		TTL:    90 * time.Minute,
+		Sticky: true,
+	}))
+	require.NoError(t, ca.Unmute("OSD_DOWN"))
+	assert.Equal(t, []map[string]any{
+		{"prefix": "health mute", "code": "OSD_DOWN"},
+		{"prefix": "health mute", "code": "OSD_DOWN", "ttl": "5400s", "sticky": true},
+		{"prefix": "health unmute", "code": "OSD_DOWN"},
+	}, conn.cmds)
+
+	conn.replies = []string{"unexpected"}
+	assert.Error(t, ca.Unmute("OSD_DOWN"))
+}
+
+func TestDiffHealth(t *testing.T) {
+	down := HealthCheck{
+		Severity: HealthWarn,
+		Summary:  HealthSummary{Message: "1 osds down", Count: 1},
+	}
+	full := HealthCheck{
+		Severity: HealthErr,
+		Summary:  HealthSummary{Message: "1 full osd(s)", Count: 1},
+	}
+	h1 := &Health{
+		Status: HealthWarn,
+		Checks: map[string]HealthCheck{"OSD_DOWN": down},
+	}
+	assert.Equal(t, []HealthEvent{
+		{Type: HealthStatusChanged, Status: HealthWarn},
+		{Type: HealthCheckRaised, Code: "OSD_DOWN", Check: down, Status: HealthWarn},
+	}, DiffHealth(nil, h1))
+	assert.Empty(t, DiffHealth(h1, h1))
+
+	down2 := down
+	down2.Summary = HealthSummary{Message: "2 osds down", Count: 2}
+	h2 := &Health{
+		Status: HealthErr,
+		Checks: map[string]HealthCheck{"OSD_DOWN": down2, "OSD_FULL": full},
+	}
+	assert.Equal(t, []HealthEvent{
+		{Type: HealthStatusChanged, Status: HealthErr},
+		{Type: HealthCheckUpdated, Code: "OSD_DOWN", Check: down2, Status: HealthErr},
+		{Type: HealthCheckRaised, Code: "OSD_FULL", Check: full, Status: HealthErr},
+	}, DiffHealth(h1, h2))
+
+	down3 := down2
+	down3.Muted = true
+	h3 := &Health{
+		Status: HealthErr,
+		Checks: map[string]HealthCheck{"OSD_DOWN": down3, "OSD_FULL": full},
+	}
+	assert.Equal(t, []HealthEvent{
+		{Type: HealthCheckUpdated, Code: "OSD_DOWN", Check: down3, Status: HealthErr},
+	}, DiffHealth(h2, h3))
+
+	h4 := &Health{Status: HealthOK}
+	assert.Equal(t, []HealthEvent{
+		{Type: HealthStatusChanged, Status: HealthOK},
+		{Type: HealthCheckCleared, Code: "OSD_DOWN", Check: down3, Status: HealthOK},
+		{Type: HealthCheckCleared, Code: "OSD_FULL", Check: full, Status: HealthOK},
+	}, DiffHealth(h3, h4))
+
+	assert.Equal(t, "cleared", HealthCheckCleared.String())
+	assert.Equal(t, "unknown", HealthEventType(-1).String())
+}
+
+func receiveEvent(t *testing.T, w *HealthWatcher) HealthEvent {
+	t.Helper()
+	select {
+	case ev := <-w.Events():
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for health event")
+	}
+	return HealthEvent{}
+}
+
+func TestWatchHealth(t *testing.T) {
+	conn := &cmdConn{replies: []string{
+		`{"status":"HEALTH_OK","checks":{}}`,
+		`{"status":"HEALTH_WARN","checks":{"OSD_DOWN":{"severity":"HEALTH_WARN","summary":{"message":"1 osds down","count":1}}}}`,
+		`{"status":"HEALTH_OK","checks":{}}`,
+	}}
+	// the interval is long enough that only Refresh triggers polls
+	w := NewFromConn(conn).WatchHealth(time.Hour)
+	defer w.Stop()
+
+	w.Refresh()
+	ev := receiveEvent(t, w)
+	assert.Equal(t, HealthStatusChanged, ev.Type)
+	assert.Equal(t, HealthWarn, ev.Status)
+	ev = receiveEvent(t, w)
+	assert.Equal(t, HealthCheckRaised, ev.Type)
+	assert.Equal(t, "OSD_DOWN", ev.Code)
+	assert.Equal(t, "1 osds down", ev.Check.Summary.Message)
+
+	w.Refresh()
+	ev = receiveEvent(t, w)
+	assert.Equal(t, HealthStatusChanged, ev.Type)
+	ev = receiveEvent(t, w)
+	assert.Equal(t, HealthCheckCleared, ev.Type)
+	assert.Equal(t, "OSD_DOWN", ev.Code)
+
+	conn.mutex.Lock()
+	assert.Equal(t, "detail", conn.cmds[0]["detail"])
+	conn.err = errors.New("no monitors")
+	conn.mutex.Unlock()
+	w.Refresh()
+	select {
+	case err := <-w.Errors():
+		assert.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for error")
+	}
+
+	w.Stop()
+	_, ok := <-w.Events()
+	assert.False(t, ok)
+	_, ok = <-w.Errors()
+	assert.False(t, ok)
+}
+
+func TestWatchHealthLog(t *testing.T) {
+	conn := &cmdConn{replies: []string{
+		`{"status":"HEALTH_OK","checks":{}}`,
+		`{"status":"HEALTH_WARN","checks":{"OSD_DOWN":{"severity":"HEALTH_WARN","summary":{"message":"1 osds down","count":1}}}}`,
+		`{"status":"HEALTH_OK","checks":{}}`,
+	}}
+	entries := make(chan rados.LogEntry)
+	w := NewFromConn(conn).WatchHealthLog(time.Hour, entries)
+	defer w.Stop()
+
+	// neither entry is about the health, so they must not trigger a poll
+	entries <- rados.LogEntry{
+		Channel: rados.LogChannelAudit,
+		Message: "Health check failed: from the audit log",
+	}
+	entries <- rados.LogEntry{
+		Channel: rados.LogChannelCluster,
+		Message: "osd.0 marked itself down",
+	}
+	entries <- rados.LogEntry{
+		Channel: rados.LogChannelCluster,
+		Message: "Health check failed: 1 osds down (OSD_DOWN)",
+	}
+	ev := receiveEvent(t, w)
+	assert.Equal(t, HealthStatusChanged, ev.Type)
+	ev = receiveEvent(t, w)
+	assert.Equal(t, HealthCheckRaised, ev.Type)
+	assert.Equal(t, "OSD_DOWN", ev.Code)
+	conn.mutex.Lock()
+	assert.Len(t, conn.cmds, 2)
+	conn.mutex.Unlock()
+
+	// the watcher keeps working once the log is closed
+	close(entries)
+	w.Refresh()
+	ev = receiveEvent(t, w)
+	assert.Equal(t, HealthStatusChanged, ev.Type)
+	ev = receiveEvent(t, w)
+	assert.Equal(t, HealthCheckCleared, ev.Type)
+}
+
+func TestIsHealthLogEntry(t *testing.T) {
+	for _, msg := range []string{
+		"Health check failed: 1 osds down (OSD_DOWN)",
+		"Health check cleared: OSD_DOWN (was: 1 osds down)",
+		"Health check update: 2 osds down (OSD_DOWN)",
+		"overall HEALTH_WARN 1 osds down",
+		"Cluster is now healthy",
+	} {
+		assert.True(t, isHealthLogEntry(rados.LogEntry{Message: msg}), msg)
+	}
+	assert.False(t, isHealthLogEntry(rados.LogEntry{
+		Channel: rados.LogChannelCluster,
+		Message: "pgmap v12: 33 pgs: 33 active+clean",
+	}))
+}
@@ -0,0 +1,274 @@
+//go:build ceph_preview
+
+package cluster
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ceph/go-ceph/rados"
+)
+
+// HealthEventType is the kind of change of the cluster health reported by a
+// HealthEvent.
+type HealthEventType int
+
+const (
+	// HealthStatusChanged is reported when the overall health status
+	// changed.
+	HealthStatusChanged HealthEventType = iota
+	// HealthCheckRaised is reported when a health check failed.
+	HealthCheckRaised
+	// HealthCheckUpdated is reported when the severity, summary, detail or
+	// mute of a failed health check changed.
+	HealthCheckUpdated
+	// HealthCheckCleared is reported when a failed health check cleared.
+	HealthCheckCleared
+)
+
+// String returns the name of the event type.
+func (t HealthEventType) String() string {
+	switch t {
+	case HealthStatusChanged:
+		return "status-changed"
+	case HealthCheckRaised:
+		return "raised"
+	case HealthCheckUpdated:
+		return "updated"
+	case HealthCheckCleared:
+		return "cleared"
+	}
+	return "unknown"
+}
+
+// HealthEvent describes a change of the cluster health.
+type HealthEvent struct {
+	Type HealthEventType
+	// Code is the code of the health check. It is empty for
+	// HealthStatusChanged events.
+	Code string
+	// Check is the new state of the health check, or the last state for
+	// HealthCheckCleared events.
+	Check HealthCheck
+	// Status is the overall health status after the change.
+	Status HealthStatus
+}
+
+// DiffHealth returns the events leading from the health prev to the health
+// cur. The events of the health checks are ordered by code. A nil prev is
+// treated as a healthy cluster without failed health checks.
+func DiffHealth(prev, cur *Health) []HealthEvent {
+	if prev == nil {
+		prev = &Health{Status: HealthOK}
+	}
+	var events []HealthEvent
+	if prev.Status != cur.Status {
+		events = append(events, HealthEvent{
+			Type:   HealthStatusChanged,
+			Status: cur.Status,
+		})
+	}
+	codes := make([]string, 0, len(prev.Checks)+len(cur.Checks))
+	for code := range prev.Checks {
+		codes = append(codes, code)
+	}
+	for code := range cur.Checks {
+		if _, ok := prev.Checks[code]; !ok {
+			codes = append(codes, code)
+		}
+	}
+	slices.Sort(codes)
+	for _, code := range codes {
+		p, inPrev := prev.Checks[code]
+		c, inCur := cur.Checks[code]
+		ev := HealthEvent{Code: code, Check: c, Status: cur.Status}
+		switch {
+		case !inPrev:
+			ev.Type = HealthCheckRaised
+		case !inCur:
+			ev.Type = HealthCheckCleared
+			ev.Check = p
+		case !p.equal(&c):
+			ev.Type = HealthCheckUpdated
+		default:
+			continue
+		}
+		events = append(events, ev)
+	}
+	return events
+}
+
+func (hc *HealthCheck) equal(o *HealthCheck) bool {
+	return hc.Severity == o.Severity &&
+		hc.Summary == o.Summary &&
+		hc.Muted == o.Muted &&
+		slices.Equal(hc.Detail, o.Detail)
+}
+
+const defaultHealthInterval = 30 * time.Second
+
+// HealthWatcher polls the health of a cluster and reports changes as
+// HealthEvents.
+type HealthWatcher struct {
+	admin      *Admin
+	interval   time.Duration
+	logEntries <-chan rados.LogEntry
+	events     chan HealthEvent
+	errors     chan error
+	refresh    chan struct{}
+	done       chan struct{}
+	stopOnce   sync.Once
+	wg         sync.WaitGroup
+}
+
+// WatchHealth starts a HealthWatcher polling the health of the cluster,
+// including details, every interval. The first poll reports all failed
+// health checks as raised. If interval is not positive the health is polled
+// every 30 seconds. A HealthWatcher must be stopped with the Stop method.
+//
+// The Events and Errors channels must be drained. Call Refresh to poll
+// immediately. To poll as soon as the cluster logs a health change use
+// WatchHealthLog instead.
+func (a *Admin) WatchHealth(interval time.Duration) *HealthWatcher {
+	return a.WatchHealthLog(interval, nil)
+}
+
+// WatchHealthLog starts a HealthWatcher like WatchHealth that additionally
+// polls the health for each entry received on logEntries that reports a
+// change of the cluster health, such as a failed or cleared health check.
+// logEntries is typically the Entries channel of a rados.MonitorLog
+// subscribed to rados.LogChannelCluster at rados.LogLevelInfo or below.
+// The watcher keeps polling every interval, also after logEntries is
+// closed. The MonitorLog is not closed by the watcher.
+func (a *Admin) WatchHealthLog(
+	interval time.Duration, logEntries <-chan rados.LogEntry) *HealthWatcher {
+
+	if interval <= 0 {
+		interval = defaultHealthInterval
+	}
+	w := &HealthWatcher{
+		admin:      a,
+		interval:   interval,
+		logEntries: logEntries,
+		events:     make(chan HealthEvent),
+		errors:     make(chan error),
+		refresh:    make(chan struct{}, 1),
+		done:       make(chan struct{}),
+	}
+	w.wg.Add(1)
+	go w.run()
+	return w
+}
+
+// isHealthLogEntry returns true if the cluster log entry e reports a change
+// of the cluster health. The monitors log these as, for example,
+// "Health check failed: 1 osds down (OSD_DOWN)", "Health check cleared:
+// OSD_DOWN (was: 1 osds down)" or "Cluster is now healthy".
+func isHealthLogEntry(e rados.LogEntry) bool {
+	if e.Channel != "" && e.Channel != rados.LogChannelCluster {
+		return false
+	}
+	return strings.HasPrefix(e.Message, "Health check ") ||
+		strings.HasPrefix(e.Message, "Health detail: ") ||
+		strings.HasPrefix(e.Message, "overall HEALTH_") ||
+		strings.HasPrefix(e.Message, "Cluster is now healthy")
+}
+
+// Events returns the channel the changes of the cluster health are
+// delivered on. It is closed when the watcher is stopped.
+func (w *HealthWatcher) Events() <-chan HealthEvent {
+	return w.events
+}
+
+// Errors returns the channel errors of failed polls are delivered on. It is
+// closed when the watcher is stopped.
+func (w *HealthWatcher) Errors() <-chan error {
+	return w.errors
+}
+
+// Refresh makes the watcher poll the health without waiting for the
+// interval to pass.
+func (w *HealthWatcher) Refresh() {
+	select {
+	case w.refresh <- struct{}{}:
+	default:
+	}
+}
+
+// Stop stops the watcher and closes its channels.
+func (w *HealthWatcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.done)
+	})
+	w.wg.Wait()
+}
+
+func (w *HealthWatcher) run() {
+	defer w.wg.Done()
+	defer close(w.errors)
+	defer close(w.events)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	var prev *Health
+	for {
+		cur, err := w.admin.HealthDetail()
+		if err != nil {
+			if !w.sendError(err) {
+				return
+			}
+		} else {
+			for _, ev := range DiffHealth(prev, cur) {
+				if !w.sendEvent(ev) {
+					return
+				}
+			}
+			prev = cur
+		}
+		if !w.wait(ticker.C) {
+			return
+		}
+	}
+}
+
+// wait blocks until the health needs to be polled again. It returns false
+// if the watcher was stopped.
+func (w *HealthWatcher) wait(ticks <-chan time.Time) bool {
+	for {
+		select {
+		case <-ticks:
+			return true
+		case <-w.refresh:
+			return true
+		case e, ok := <-w.logEntries:
+			if !ok {
+				// a nil channel never becomes ready
+				w.logEntries = nil
+			} else if isHealthLogEntry(e) {
+				return true
+			}
+		case <-w.done:
+			return false
+		}
+	}
+}
+
+func (w *HealthWatcher) sendEvent(ev HealthEvent) bool {
+	select {
+	case w.events <- ev:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+func (w *HealthWatcher) sendError(err error) bool {
+	select {
+	case w.errors <- err:
+		return true
+	case <-w.done:
+		return false
+	}
+}
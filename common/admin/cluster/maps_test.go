@@ -0,0 +1,135 @@
+//go:build ceph_preview
+
+package cluster
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMonMap(t *testing.T) {
+	m, err := fixtureAdmin(t, "nautilus").MonMap()
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, m.Epoch)
+	assert.Equal(t, 14, m.MinMonRelease)
+	require.Len(t, m.Mons, 3)
+	assert.Equal(t, "b", m.Mons[1].Name)
+	assert.Equal(t, []Addr{
+		{Type: "v2", Addr: "10.0.0.2:3300"},
+		{Type: "v1", Addr: "10.0.0.2:6789"},
+	}, m.Mons[1].PublicAddrs.Addrs)
+	assert.True(t, m.InQuorum(1))
+	assert.False(t, m.InQuorum(2))
+
+	m, err = fixtureAdmin(t, "squid").MonMap()
+	require.NoError(t, err)
+	assert.Equal(t, 19, m.MinMonRelease)
+	assert.False(t, m.Created.IsZero())
+}
+
+func TestPGMap(t *testing.T) {
+	// nautilus replies with a bare list
+	m, err := fixtureAdmin(t, "nautilus").PGMap()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{
+		"active+clean":               2,
+		"active+undersized+degraded": 1,
+	}, m.StateCounts())
+	assert.True(t, m.PGs[1].HasState("degraded"))
+	assert.False(t, m.PGs[1].HasState("clean"))
+
+	m, err = fixtureAdmin(t, "quincy").PGMap()
+	require.NoError(t, err)
+	require.Len(t, m.PGs, 4)
+	pg := m.PGs[3]
+	assert.Equal(t, "3.2", pg.PGID)
+	assert.Equal(t, []int{1, 0}, pg.Up)
+	assert.Equal(t, []int{0, 1}, pg.Acting)
+	assert.Equal(t, 1, pg.UpPrimary)
+	assert.Equal(t, 0, pg.ActingPrimary)
+	assert.True(t, pg.HasState("backfilling"))
+
+	err = json.Unmarshal([]byte(`"bad"`), m)
+	assert.Error(t, err)
+}
+
+func TestFSMap(t *testing.T) {
+	m, err := fixtureAdmin(t, "squid").FSMap()
+	require.NoError(t, err)
+	assert.EqualValues(t, 9, m.Epoch)
+	require.Len(t, m.Standbys, 1)
+	assert.Equal(t, "up:standby", m.Standbys[0].State)
+	assert.Equal(t, -1, m.Standbys[0].Rank)
+
+	fs := m.Filesystem("fs")
+	require.NotNil(t, fs)
+	assert.EqualValues(t, 1, fs.ID)
+	assert.True(t, fs.MDSMap.Enabled)
+	assert.Equal(t, []int64{3}, fs.MDSMap.DataPools)
+	assert.EqualValues(t, 2, fs.MDSMap.MetadataPool)
+	assert.EqualValues(t, 24405, fs.MDSMap.Up["mds_0"])
+	mds := fs.MDSMap.Info["gid_24405"]
+	assert.Equal(t, "fs.a", mds.Name)
+	assert.Equal(t, "up:active", mds.State)
+	assert.Nil(t, m.Filesystem("missing"))
+
+	m, err = fixtureAdmin(t, "nautilus").FSMap()
+	require.NoError(t, err)
+	assert.Equal(t, "cephfs", m.Filesystems[0].MDSMap.Name)
+	assert.False(t, m.Filesystems[0].MDSMap.Created.IsZero())
+
+	m, err = fixtureAdmin(t, "quincy").FSMap()
+	require.NoError(t, err)
+	assert.EqualValues(t, -1, m.DefaultFSCID)
+	assert.Empty(t, m.Filesystems)
+}
+
+func TestStatus(t *testing.T) {
+	s, err := fixtureAdmin(t, "nautilus").Status()
+	require.NoError(t, err)
+	assert.Equal(t, HealthWarn, s.Health.Status)
+	assert.Contains(t, s.Health.Checks, "OSD_DOWN")
+	assert.Equal(t, "1 osds down", s.Health.Checks["OSD_DOWN"].Summary.Message)
+	// nautilus nests the OSD map summary
+	assert.EqualValues(t, 42, s.OSDMap.Epoch)
+	assert.Equal(t, 3, s.OSDMap.NumOSDs)
+	assert.Equal(t, 2, s.OSDMap.NumUpOSDs)
+	assert.Equal(t, 96, s.PGMap.NumPGs)
+	assert.Equal(t, 1, s.PGMap.StateCounts()["active+undersized+degraded"])
+
+	s, err = fixtureAdmin(t, "quincy").Status()
+	require.NoError(t, err)
+	assert.Equal(t, HealthOK, s.Health.Status)
+	assert.Empty(t, s.Health.Checks)
+	assert.Equal(t, 1, s.OSDMap.NumRemappedPGs)
+	assert.Equal(t, 1, s.MonMap.NumMons)
+	assert.EqualValues(t, 214748364800, s.PGMap.BytesTotal)
+
+	s, err = fixtureAdmin(t, "squid").Status()
+	require.NoError(t, err)
+	c := s.Health.Checks["OSDMAP_FLAGS"]
+	assert.Equal(t, HealthWarn, c.Severity)
+	assert.True(t, c.Muted)
+	assert.Equal(t, 2, c.Summary.Count)
+	assert.False(t, s.Health.Checks["POOL_NO_REDUNDANCY"].Muted)
+	assert.EqualValues(t, 9, s.FSMap.Epoch)
+}
+
+func TestTime(t *testing.T) {
+	var v struct {
+		T Time `json:"t"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(`{"t":"0.000000"}`), &v))
+	assert.True(t, v.T.IsZero())
+	require.NoError(t, json.Unmarshal([]byte(`{"t":"2024-09-26T14:02:09.732761Z"}`), &v))
+	assert.Equal(t, 2024, v.T.Year())
+	assert.Error(t, json.Unmarshal([]byte(`{"t":"yesterday"}`), &v))
+
+	var f struct {
+		F flag `json:"f"`
+	}
+	assert.Error(t, json.Unmarshal([]byte(`{"f":2}`), &f))
+}
@@ -0,0 +1,52 @@
+//go:build ceph_preview
+
+package cluster
+
+// MonMap describes the monitors of a cluster.
+type MonMap struct {
+	Epoch    uint32 `json:"epoch"`
+	FSID     string `json:"fsid"`
+	Created  Time   `json:"created"`
+	Modified Time   `json:"modified"`
+	// MinMonRelease is the number of the oldest release the monitors must
+	// run, MinMonReleaseName its name.
+	MinMonRelease     int    `json:"min_mon_release"`
+	MinMonReleaseName string `json:"min_mon_release_name"`
+	Mons              []Mon  `json:"mons"`
+	// Quorum are the ranks of the monitors in quorum.
+	Quorum []int `json:"quorum"`
+}
+
+// Mon describes a monitor of the monitor map.
+type Mon struct {
+	Rank        int     `json:"rank"`
+	Name        string  `json:"name"`
+	PublicAddrs AddrVec `json:"public_addrs"`
+	Addr        string  `json:"addr"`
+	PublicAddr  string  `json:"public_addr"`
+	Priority    int     `json:"priority"`
+	Weight      int     `json:"weight"`
+}
+
+// InQuorum returns true if the monitor with the given rank is in quorum.
+func (m *MonMap) InQuorum(rank int) bool {
+	for _, r := range m.Quorum {
+		if r == rank {
+			return true
+		}
+	}
+	return false
+}
+
+// MonMap returns the current monitor map.
+//
+// Similar To:
+//
+//	ceph mon dump
+func (a *Admin) MonMap() (*MonMap, error) {
+	m := &MonMap{}
+	if err := a.monCommand(map[string]any{"prefix": "mon dump"}, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,173 @@
+//go:build ceph_preview
+
+package cluster
+
+import (
+	"encoding/json"
+	"slices"
+)
+
+// PoolType is the type of data protection of a pool.
+type PoolType int
+
+const (
+	// PoolTypeReplicated pools store multiple full copies of each object.
+	PoolTypeReplicated = PoolType(1)
+	// PoolTypeErasure pools store objects erasure coded.
+	PoolTypeErasure = PoolType(3)
+)
+
+// String returns the name of the pool type.
+func (t PoolType) String() string {
+	switch t {
+	case PoolTypeReplicated:
+		return "replicated"
+	case PoolTypeErasure:
+		return "erasure"
+	}
+	return "unknown"
+}
+
+// OSDMap describes the OSDs and pools of a cluster.
+type OSDMap struct {
+	Epoch    uint32 `json:"epoch"`
+	FSID     string `json:"fsid"`
+	Created  Time   `json:"created"`
+	Modified Time   `json:"modified"`
+	// Flags are the cluster wide OSD flags, for example "noout".
+	Flags                  []string `json:"-"`
+	CrushVersion           uint32   `json:"crush_version"`
+	FullRatio              float64  `json:"full_ratio"`
+	BackfillFullRatio      float64  `json:"backfillfull_ratio"`
+	NearFullRatio          float64  `json:"nearfull_ratio"`
+	MaxOSD                 int      `json:"max_osd"`
+	RequireMinCompatClient string   `json:"require_min_compat_client"`
+	MinCompatClient        string   `json:"min_compat_client"`
+	RequireOSDRelease      string   `json:"require_osd_release"`
+	Pools                  []Pool   `json:"pools"`
+	OSDs                   []OSD    `json:"osds"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (m *OSDMap) UnmarshalJSON(b []byte) error {
+	type osdMap OSDMap
+	v := struct {
+		*osdMap
+		Flags    string   `json:"flags"`
+		FlagsSet []string `json:"flags_set"`
+	}{osdMap: (*osdMap)(m)}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	// flags_set is not available in older releases
+	m.Flags = v.FlagsSet
+	if m.Flags == nil {
+		m.Flags = splitList(v.Flags)
+	}
+	return nil
+}
+
+// Pool returns the pool with the given name or nil.
+func (m *OSDMap) Pool(name string) *Pool {
+	for i := range m.Pools {
+		if m.Pools[i].Name == name {
+			return &m.Pools[i]
+		}
+	}
+	return nil
+}
+
+// OSD returns the OSD with the given ID or nil.
+func (m *OSDMap) OSD(id int) *OSD {
+	for i := range m.OSDs {
+		if m.OSDs[i].ID == id {
+			return &m.OSDs[i]
+		}
+	}
+	return nil
+}
+
+// Pool describes a pool of the OSD map.
+type Pool struct {
+	ID                 int64    `json:"pool"`
+	Name               string   `json:"pool_name"`
+	Type               PoolType `json:"type"`
+	Size               int      `json:"size"`
+	MinSize            int      `json:"min_size"`
+	CrushRule          int      `json:"crush_rule"`
+	PgNum              uint32   `json:"pg_num"`
+	PgPlacementNum     uint32   `json:"pg_placement_num"`
+	ObjectHash         int      `json:"object_hash"`
+	ErasureCodeProfile string   `json:"erasure_code_profile"`
+	// Flags are the names of the flags set on the pool, for example
+	// "hashpspool".
+	Flags []string `json:"-"`
+	// Applications are the names of the applications enabled on the pool.
+	Applications []string `json:"-"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (p *Pool) UnmarshalJSON(b []byte) error {
+	type pool Pool
+	v := struct {
+		*pool
+		FlagsNames          string                     `json:"flags_names"`
+		ApplicationMetadata map[string]json.RawMessage `json:"application_metadata"`
+	}{pool: (*pool)(p)}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	p.Flags = splitList(v.FlagsNames)
+	p.Applications = nil
+	for app := range v.ApplicationMetadata {
+		p.Applications = append(p.Applications, app)
+	}
+	slices.Sort(p.Applications)
+	return nil
+}
+
+// OSD describes an OSD of the OSD map.
+type OSD struct {
+	ID              int     `json:"osd"`
+	UUID            string  `json:"uuid"`
+	Up              bool    `json:"-"`
+	In              bool    `json:"-"`
+	Weight          float64 `json:"weight"`
+	PrimaryAffinity float64 `json:"primary_affinity"`
+	UpFrom          uint32  `json:"up_from"`
+	UpThru          uint32  `json:"up_thru"`
+	DownAt          uint32  `json:"down_at"`
+	PublicAddr      string  `json:"public_addr"`
+	ClusterAddr     string  `json:"cluster_addr"`
+	// State lists the states of the OSD, for example "exists" and "up".
+	State []string `json:"state"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (o *OSD) UnmarshalJSON(b []byte) error {
+	type osd OSD
+	v := struct {
+		*osd
+		Up flag `json:"up"`
+		In flag `json:"in"`
+	}{osd: (*osd)(o)}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	o.Up = bool(v.Up)
+	o.In = bool(v.In)
+	return nil
+}
+
+// OSDMap returns the current OSD map.
+//
+// Similar To:
+//
+//	ceph osd dump
+func (a *Admin) OSDMap() (*OSDMap, error) {
+	m := &OSDMap{}
+	if err := a.monCommand(map[string]any{"prefix": "osd dump"}, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,85 @@
+//go:build ceph_preview
+
+package cluster
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOSDMapNautilus(t *testing.T) {
+	m, err := fixtureAdmin(t, "nautilus").OSDMap()
+	require.NoError(t, err)
+	assert.EqualValues(t, 42, m.Epoch)
+	assert.Equal(t,
+		time.Date(2019, 6, 4, 12, 0, 0, 123456000, time.UTC), m.Created.Time)
+	// older releases only provide the flags as a string
+	assert.Contains(t, m.Flags, "noout")
+	assert.Len(t, m.Flags, 5)
+	assert.Equal(t, "nautilus", m.RequireOSDRelease)
+	assert.Equal(t, 0.85, m.NearFullRatio)
+
+	p := m.Pool("ec")
+	require.NotNil(t, p)
+	assert.EqualValues(t, 2, p.ID)
+	assert.Equal(t, PoolTypeErasure, p.Type)
+	assert.Equal(t, "erasure", p.Type.String())
+	assert.Equal(t, "default", p.ErasureCodeProfile)
+	assert.Equal(t, []string{"hashpspool", "ec_overwrites"}, p.Flags)
+	assert.Equal(t, []string{"cephfs", "rgw"}, p.Applications)
+	assert.Nil(t, m.Pool("missing"))
+
+	o := m.OSD(2)
+	require.NotNil(t, o)
+	assert.False(t, o.Up)
+	assert.False(t, o.In)
+	assert.Zero(t, o.Weight)
+	assert.EqualValues(t, 41, o.DownAt)
+	assert.Equal(t, []string{"autoout", "exists"}, o.State)
+	assert.True(t, m.OSD(0).Up)
+	assert.Nil(t, m.OSD(7))
+}
+
+func TestOSDMapQuincy(t *testing.T) {
+	m, err := fixtureAdmin(t, "quincy").OSDMap()
+	require.NoError(t, err)
+	assert.EqualValues(t, 118, m.Epoch)
+	assert.Equal(t,
+		time.Date(2022, 5, 12, 17, 42, 1, 987654000, time.UTC),
+		m.Modified.UTC())
+	assert.Equal(t, []string{
+		"pglog_hardlimit", "purged_snapdirs", "recovery_deletes", "sortbitwise",
+	}, m.Flags)
+
+	p := m.Pool(".mgr")
+	require.NotNil(t, p)
+	assert.Equal(t, PoolTypeReplicated, p.Type)
+	assert.EqualValues(t, 1, p.PgNum)
+	assert.Equal(t, []string{"mgr"}, p.Applications)
+
+	o := m.OSD(1)
+	require.NotNil(t, o)
+	assert.True(t, o.Up)
+	assert.True(t, o.In)
+	assert.Equal(t, 0.5, o.Weight)
+	assert.Equal(t, 0.25, o.PrimaryAffinity)
+}
+
+func TestOSDMapSquid(t *testing.T) {
+	m, err := fixtureAdmin(t, "squid").OSDMap()
+	require.NoError(t, err)
+	assert.Contains(t, m.Flags, "noscrub")
+	assert.Contains(t, m.Flags, "nodeep-scrub")
+	assert.Len(t, m.Pools, 3)
+	assert.Equal(t, "squid", m.RequireOSDRelease)
+	assert.Equal(t, 1, m.MaxOSD)
+	assert.Equal(t, "172.16.0.5:6801/7001", m.OSD(0).PublicAddr)
+}
+
+func TestPoolTypeString(t *testing.T) {
+	assert.Equal(t, "replicated", PoolTypeReplicated.String())
+	assert.Equal(t, "unknown", PoolType(2).String())
+}
@@ -0,0 +1,80 @@
+//go:build ceph_preview
+
+package cluster
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// PGMap lists the placement groups of a cluster.
+type PGMap struct {
+	PGs []PG
+}
+
+// PG describes the state of a placement group.
+type PG struct {
+	PGID string `json:"pgid"`
+	// State is the state of the placement group, for example
+	// "active+clean".
+	State         string `json:"state"`
+	Up            []int  `json:"up"`
+	UpPrimary     int    `json:"up_primary"`
+	Acting        []int  `json:"acting"`
+	ActingPrimary int    `json:"acting_primary"`
+}
+
+// HasState returns true if the state of the placement group includes s, for
+// example "clean".
+func (pg *PG) HasState(s string) bool {
+	for _, v := range strings.Split(pg.State, "+") {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (m *PGMap) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	if len(b) > 0 && b[0] == '[' {
+		// older releases return a bare list
+		return json.Unmarshal(b, &m.PGs)
+	}
+	var v struct {
+		PGStats []PG `json:"pg_stats"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	m.PGs = v.PGStats
+	return nil
+}
+
+// StateCounts returns the number of placement groups in each state.
+func (m *PGMap) StateCounts() map[string]int {
+	counts := map[string]int{}
+	for _, pg := range m.PGs {
+		counts[pg.State]++
+	}
+	return counts
+}
+
+// PGMap returns the states of all placement groups.
+//
+// Similar To:
+//
+//	ceph pg dump pgs_brief
+func (a *Admin) PGMap() (*PGMap, error) {
+	m := &PGMap{}
+	err := a.monCommand(map[string]any{
+		"prefix":       "pg dump",
+		"dumpcontents": []string{"pgs_brief"},
+	}, m)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,85 @@
+//go:build ceph_preview
+
+package cluster
+
+import (
+	"encoding/json"
+)
+
+// OSDMapSummary summarizes the OSD map.
+type OSDMapSummary struct {
+	Epoch          uint32 `json:"epoch"`
+	NumOSDs        int    `json:"num_osds"`
+	NumUpOSDs      int    `json:"num_up_osds"`
+	NumInOSDs      int    `json:"num_in_osds"`
+	NumRemappedPGs int    `json:"num_remapped_pgs"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (s *OSDMapSummary) UnmarshalJSON(b []byte) error {
+	type summary OSDMapSummary
+	// releases before octopus nest the summary in another "osdmap" object
+	var nested struct {
+		OSDMap *summary `json:"osdmap"`
+	}
+	if err := json.Unmarshal(b, &nested); err == nil && nested.OSDMap != nil {
+		*s = OSDMapSummary(*nested.OSDMap)
+		return nil
+	}
+	return json.Unmarshal(b, (*summary)(s))
+}
+
+// PGMapSummary summarizes the PG map.
+type PGMapSummary struct {
+	PGsByState []struct {
+		StateName string `json:"state_name"`
+		Count     int    `json:"count"`
+	} `json:"pgs_by_state"`
+	NumPGs     int    `json:"num_pgs"`
+	NumPools   int    `json:"num_pools"`
+	NumObjects uint64 `json:"num_objects"`
+	DataBytes  uint64 `json:"data_bytes"`
+	BytesUsed  uint64 `json:"bytes_used"`
+	BytesAvail uint64 `json:"bytes_avail"`
+	BytesTotal uint64 `json:"bytes_total"`
+}
+
+// StateCounts returns the number of placement groups in each state.
+func (s *PGMapSummary) StateCounts() map[string]int {
+	counts := map[string]int{}
+	for _, st := range s.PGsByState {
+		counts[st.StateName] += st.Count
+	}
+	return counts
+}
+
+// Status is the status of a cluster.
+type Status struct {
+	FSID          string   `json:"fsid"`
+	Health        Health   `json:"health"`
+	ElectionEpoch uint32   `json:"election_epoch"`
+	Quorum        []int    `json:"quorum"`
+	QuorumNames   []string `json:"quorum_names"`
+	MonMap        struct {
+		Epoch   uint32 `json:"epoch"`
+		NumMons int    `json:"num_mons"`
+	} `json:"monmap"`
+	OSDMap OSDMapSummary `json:"osdmap"`
+	PGMap  PGMapSummary  `json:"pgmap"`
+	FSMap  struct {
+		Epoch uint32 `json:"epoch"`
+	} `json:"fsmap"`
+}
+
+// Status returns the status of the cluster.
+//
+// Similar To:
+//
+//	ceph status
+func (a *Admin) Status() (*Status, error) {
+	s := &Status{}
+	if err := a.monCommand(map[string]any{"prefix": "status"}, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
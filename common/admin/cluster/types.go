@@ -0,0 +1,81 @@
+//go:build ceph_preview
+
+package cluster
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// timeLayouts are the layouts of time stamps used by the different maps
+// and Ceph releases.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999-0700",
+	"2006-01-02 15:04:05.999999",
+}
+
+// Time is a time stamp of a cluster map. The zero value is used for time
+// stamps that are not set.
+type Time struct {
+	time.Time
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *Time) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	if s == "" || strings.HasPrefix(s, "0.000000") {
+		t.Time = time.Time{}
+		return nil
+	}
+	for _, layout := range timeLayouts {
+		if v, err := time.Parse(layout, s); err == nil {
+			t.Time = v
+			return nil
+		}
+	}
+	return fmt.Errorf("cluster: invalid time stamp %q", s)
+}
+
+// flag is a boolean encoded as 0 or 1, like the up and in states of OSDs.
+type flag bool
+
+func (f *flag) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	switch string(b) {
+	case "0", "false":
+		*f = false
+	case "1", "true":
+		*f = true
+	default:
+		return fmt.Errorf("cluster: invalid flag %s", b)
+	}
+	return nil
+}
+
+// splitList splits a comma separated list, as used for flags.
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+// AddrVec is the list of addresses of a daemon.
+type AddrVec struct {
+	Addrs []Addr `json:"addrvec"`
+}
+
+// Addr is an address of a daemon.
+type Addr struct {
+	// Type is "v1", "v2" or "any".
+	Type  string `json:"type"`
+	Addr  string `json:"addr"`
+	Nonce uint32 `json:"nonce"`
+}
@@ -0,0 +1,116 @@
+//go:build ceph_preview
+
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tsuite "github.com/stretchr/testify/suite"
+
+	"github.com/ceph/go-ceph/common/cephconf"
+	"github.com/ceph/go-ceph/internal/admintest"
+)
+
+func TestConfigAdmin(t *testing.T) {
+	tsuite.Run(t, new(ConfigAdminSuite))
+}
+
+// ConfigAdminSuite is a suite of tests for the config admin package.
+type ConfigAdminSuite struct {
+	tsuite.Suite
+
+	vconn *admintest.Connector
+}
+
+func (suite *ConfigAdminSuite) SetupSuite() {
+	suite.vconn = admintest.NewConnector()
+}
+
+func (suite *ConfigAdminSuite) TestSetGetRemove() {
+	t := suite.T()
+	ca := NewFromConn(suite.vconn.Get(t))
+	tgt := Target{Section: "client.go-ceph-config"}
+
+	err := ca.Set(tgt, "rados_osd_op_timeout", "17", &SetOptions{Validate: true})
+	require.NoError(t, err)
+	v, err := ca.Get(tgt.Section, "rados_osd_op_timeout")
+	assert.NoError(t, err)
+	assert.Equal(t, "17", v)
+
+	settings, err := ca.Dump()
+	assert.NoError(t, err)
+	found := false
+	for _, s := range settings {
+		if s.Target.Section == tgt.Section && s.Name == "rados_osd_op_timeout" {
+			found = true
+			assert.Equal(t, "17", s.Value)
+		}
+	}
+	assert.True(t, found)
+
+	err = ca.Set(tgt, "rados_osd_op_timeout", "five", &SetOptions{Validate: true})
+	assert.ErrorIs(t, err, ErrInvalidValue)
+
+	require.NoError(t, ca.Remove(tgt, "rados_osd_op_timeout"))
+	v, err = ca.Get(tgt.Section, "rados_osd_op_timeout")
+	assert.NoError(t, err)
+	assert.Equal(t, "0", v)
+}
+
+func (suite *ConfigAdminSuite) TestMasks() {
+	t := suite.T()
+	ca := NewFromConn(suite.vconn.Get(t))
+	tgt, err := ParseTarget("osd/host:go-ceph-nonexistent/class:ssd")
+	require.NoError(t, err)
+
+	require.NoError(t, ca.Set(tgt, "osd_max_backfills", "3", nil))
+	defer func() { assert.NoError(t, ca.Remove(tgt, "osd_max_backfills")) }()
+	settings, err := ca.Dump()
+	assert.NoError(t, err)
+	found := false
+	for _, s := range settings {
+		if s.Name == "osd_max_backfills" && s.Target.String() == tgt.String() {
+			found = true
+		}
+	}
+	assert.True(t, found)
+}
+
+func (suite *ConfigAdminSuite) TestHelp() {
+	t := suite.T()
+	ca := NewFromConn(suite.vconn.Get(t))
+	o, err := ca.Help("osd_max_backfills")
+	require.NoError(t, err)
+	assert.Equal(t, TypeUint, o.Type)
+	assert.NotEmpty(t, o.Default)
+	assert.True(t, o.HasService("osd"))
+
+	_, err = ca.Help("go_ceph_no_such_option")
+	assert.Error(t, err)
+}
+
+func (suite *ConfigAdminSuite) TestShow() {
+	t := suite.T()
+	ca := NewFromConn(suite.vconn.Get(t))
+	opts, err := ca.Show("osd.0")
+	require.NoError(t, err)
+	assert.NotEmpty(t, opts)
+}
+
+func (suite *ConfigAdminSuite) TestAssimilateConf() {
+	t := suite.T()
+	ca := NewFromConn(suite.vconn.Get(t))
+	conf, err := cephconf.Parse(strings.NewReader(
+		"[client.go-ceph-assimilate]\nrados osd op timeout = 23\n"))
+	require.NoError(t, err)
+	_, err = ca.AssimilateConf(conf)
+	require.NoError(t, err)
+	tgt := Target{Section: "client.go-ceph-assimilate"}
+	defer func() { assert.NoError(t, ca.Remove(tgt, "rados_osd_op_timeout")) }()
+	v, err := ca.Get(tgt.Section, "rados_osd_op_timeout")
+	assert.NoError(t, err)
+	assert.Equal(t, "23", v)
+}
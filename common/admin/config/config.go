@@ -0,0 +1,196 @@
+//go:build ceph_preview
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/ceph/go-ceph/common/cephconf"
+	"github.com/ceph/go-ceph/internal/commands"
+)
+
+// SetOptions are optional parameters of Set.
+type SetOptions struct {
+	// Force sets the option even if it is unknown to the monitors.
+	Force bool
+	// Validate fetches the metadata of the option and validates the value
+	// before setting it.
+	Validate bool
+}
+
+// Set sets the option name to value for the target.
+//
+// Similar To:
+//
+//	ceph config set <who> <name> <value> [--force]
+func (ca *Admin) Set(who Target, name, value string, o *SetOptions) error {
+	if err := who.Validate(); err != nil {
+		return err
+	}
+	if o == nil {
+		o = &SetOptions{}
+	}
+	if o.Validate {
+		opt, err := ca.Help(name)
+		if err != nil {
+			return err
+		}
+		if err := opt.Validate(value); err != nil {
+			return err
+		}
+	}
+	m := map[string]any{
+		"prefix": "config set",
+		"who":    who.String(),
+		"name":   name,
+		"value":  value,
+	}
+	if o.Force {
+		m["force"] = true
+	}
+	return commands.MarshalMonCommand(ca.conn, m).NoBody().End()
+}
+
+// Get returns the value of the option name stored in the configuration
+// database for the daemon or client who, for example "osd.3". If the option
+// is not set the default value is returned.
+//
+// Similar To:
+//
+//	ceph config get <who> <name>
+func (ca *Admin) Get(who, name string) (string, error) {
+	m := map[string]string{
+		"prefix": "config get",
+		"who":    who,
+		"key":    name,
+	}
+	res := commands.MarshalMonCommand(ca.conn, m)
+	if err := res.End(); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(res.Body()), "\n"), nil
+}
+
+// Remove removes the option name set for the target.
+//
+// Similar To:
+//
+//	ceph config rm <who> <name>
+func (ca *Admin) Remove(who Target, name string) error {
+	if err := who.Validate(); err != nil {
+		return err
+	}
+	m := map[string]string{
+		"prefix": "config rm",
+		"who":    who.String(),
+		"name":   name,
+	}
+	return commands.MarshalMonCommand(ca.conn, m).NoBody().End()
+}
+
+// Setting is an option set in the configuration database.
+type Setting struct {
+	Target             Target      `json:"-"`
+	Name               string      `json:"name"`
+	Value              string      `json:"value"`
+	Level              OptionLevel `json:"level"`
+	CanUpdateAtRuntime bool        `json:"can_update_at_runtime"`
+}
+
+// UnmarshalJSON decodes a setting, combining the section and the mask into
+// the target.
+func (s *Setting) UnmarshalJSON(b []byte) error {
+	type setting Setting
+	aux := struct {
+		*setting
+		Section string `json:"section"`
+		Mask    string `json:"mask"`
+	}{setting: (*setting)(s)}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	s.Target = Target{Section: aux.Section}
+	if aux.Mask != "" {
+		masks, err := parseMasks(aux.Mask)
+		if err != nil {
+			return err
+		}
+		s.Target.Masks = masks
+	}
+	return nil
+}
+
+// Dump returns all options set in the configuration database.
+//
+// Similar To:
+//
+//	ceph config dump
+func (ca *Admin) Dump() ([]Setting, error) {
+	m := map[string]string{
+		"prefix": "config dump",
+		"format": "json",
+	}
+	var settings []Setting
+	if err := commands.MarshalMonCommand(ca.conn, m).Unmarshal(&settings).End(); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// Override is a value of an option from a source that was overridden by a
+// source with higher priority.
+type Override struct {
+	Source string `json:"source"`
+	Value  string `json:"value"`
+}
+
+// RunningOption is an option as used by a running daemon.
+type RunningOption struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	// Source is where the value is from, for example "mon" or "file".
+	Source    string     `json:"source"`
+	Overrides []Override `json:"overrides"`
+	// Ignores is set to "mon" if the daemon ignores the value of the
+	// configuration database.
+	Ignores string `json:"ignores"`
+}
+
+// Show returns the options of the running daemon that differ from the
+// defaults, as reported by the daemon to the manager.
+//
+// Similar To:
+//
+//	ceph config show <daemon>
+func (ca *Admin) Show(daemon string) ([]RunningOption, error) {
+	m := map[string]string{
+		"prefix": "config show",
+		"who":    daemon,
+		"format": "json",
+	}
+	var opts []RunningOption
+	if err := commands.MarshalMgrCommand(ca.conn, m).Unmarshal(&opts).End(); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+// AssimilateConf stores the options of the configuration file in the
+// configuration database. The options that could not be stored, for
+// example because they are needed to connect to the cluster, are returned.
+//
+// Similar To:
+//
+//	ceph config assimilate-conf -i <conf>
+func (ca *Admin) AssimilateConf(conf *cephconf.Conf) (*cephconf.Conf, error) {
+	m := map[string]string{
+		"prefix": "config assimilate-conf",
+	}
+	res := commands.MarshalMonCommandWithBuffer(ca.conn, m, []byte(conf.String()))
+	if err := res.End(); err != nil {
+		return nil, err
+	}
+	return cephconf.Parse(bytes.NewReader(res.Body()))
+}
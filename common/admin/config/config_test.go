@@ -0,0 +1,170 @@
+//go:build ceph_preview
+
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ceph/go-ceph/common/cephconf"
+)
+
+// fakeConn records the commands it receives and replies with the given
+// bodies.
+type fakeConn struct {
+	cmds    []map[string]any
+	input   []byte
+	replies []string
+	err     error
+}
+
+func (f *fakeConn) reply(buf []byte) ([]byte, string, error) {
+	var cmd map[string]any
+	if err := json.Unmarshal(buf, &cmd); err != nil {
+		return nil, "", err
+	}
+	f.cmds = append(f.cmds, cmd)
+	if f.err != nil {
+		return nil, "", f.err
+	}
+	if len(f.replies) == 0 {
+		return nil, "", nil
+	}
+	r := f.replies[0]
+	f.replies = f.replies[1:]
+	return []byte(r), "", nil
+}
+
+func (f *fakeConn) MonCommand(buf []byte) ([]byte, string, error) {
+	return f.reply(buf)
+}
+
+func (f *fakeConn) MonCommandWithInputBuffer(buf, input []byte) ([]byte, string, error) {
+	f.input = input
+	return f.reply(buf)
+}
+
+func (f *fakeConn) MgrCommand(buf [][]byte) ([]byte, string, error) {
+	return f.reply(buf[0])
+}
+
+func TestSet(t *testing.T) {
+	conn := &fakeConn{}
+	ca := NewFromConn(conn)
+	tgt := Target{Section: "osd", Masks: []Mask{{Type: "host", Value: "foo"}}}
+	err := ca.Set(tgt, "osd_max_backfills", "2", nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"prefix": "config set",
+		"who":    "osd/host:foo",
+		"name":   "osd_max_backfills",
+		"value":  "2",
+	}, conn.cmds[0])
+
+	err = ca.Set(Global, "foo", "bar", &SetOptions{Force: true})
+	require.NoError(t, err)
+	assert.Equal(t, true, conn.cmds[1]["force"])
+
+	// invalid values are not sent
+	conn.replies = []string{osdMaxBackfillsHelp}
+	err = ca.Set(tgt, "osd_max_backfills", "0", &SetOptions{Validate: true})
+	assert.ErrorIs(t, err, ErrInvalidValue)
+	assert.Len(t, conn.cmds, 3)
+	assert.Equal(t, "config help", conn.cmds[2]["prefix"])
+
+	conn.replies = []string{osdMaxBackfillsHelp}
+	err = ca.Set(tgt, "osd_max_backfills", "3", &SetOptions{Validate: true})
+	assert.NoError(t, err)
+	assert.Len(t, conn.cmds, 5)
+
+	err = ca.Set(Target{}, "foo", "bar", nil)
+	assert.ErrorIs(t, err, ErrInvalidTarget)
+	assert.Len(t, conn.cmds, 5)
+}
+
+func TestGetRemove(t *testing.T) {
+	conn := &fakeConn{replies: []string{"2\n"}}
+	ca := NewFromConn(conn)
+	v, err := ca.Get("osd.3", "osd_max_backfills")
+	require.NoError(t, err)
+	assert.Equal(t, "2", v)
+	assert.Equal(t, map[string]any{
+		"prefix": "config get",
+		"who":    "osd.3",
+		"key":    "osd_max_backfills",
+	}, conn.cmds[0])
+
+	err = ca.Remove(Target{Section: "osd", Masks: []Mask{{MaskClass, "ssd"}}}, "osd_max_backfills")
+	require.NoError(t, err)
+	assert.Equal(t, "osd/class:ssd", conn.cmds[1]["who"])
+
+	conn.err = errors.New("fail")
+	_, err = ca.Get("osd.3", "osd_max_backfills")
+	assert.Error(t, err)
+}
+
+func TestDump(t *testing.T) {
+	conn := &fakeConn{replies: []string{`[
+  {"section":"global","name":"mon_allow_pool_delete","value":"true","level":"advanced","can_update_at_runtime":true,"mask":"","location_type":"","location_value":""},
+  {"section":"osd","name":"osd_max_backfills","value":"2","level":"advanced","can_update_at_runtime":true,"mask":"host:foo/class:ssd","location_type":"host","location_value":"foo"}
+]`}}
+	ca := NewFromConn(conn)
+	settings, err := ca.Dump()
+	require.NoError(t, err)
+	assert.Equal(t, []Setting{
+		{
+			Target:             Global,
+			Name:               "mon_allow_pool_delete",
+			Value:              "true",
+			Level:              LevelAdvanced,
+			CanUpdateAtRuntime: true,
+		},
+		{
+			Target: Target{Section: "osd", Masks: []Mask{
+				{Type: "host", Value: "foo"},
+				{Type: MaskClass, Value: "ssd"},
+			}},
+			Name:               "osd_max_backfills",
+			Value:              "2",
+			Level:              LevelAdvanced,
+			CanUpdateAtRuntime: true,
+		},
+	}, settings)
+}
+
+func TestShow(t *testing.T) {
+	conn := &fakeConn{replies: []string{`[
+  {"name":"osd_max_backfills","value":"2","source":"mon","overrides":[{"source":"default","value":"1"}]},
+  {"name":"log_file","value":"/var/log/ceph/ceph-osd.0.log","source":"file","overrides":[],"ignores":"mon"}
+]`}}
+	ca := NewFromConn(conn)
+	opts, err := ca.Show("osd.0")
+	require.NoError(t, err)
+	require.Len(t, opts, 2)
+	assert.Equal(t, "mon", opts[0].Source)
+	assert.Equal(t, []Override{{Source: "default", Value: "1"}}, opts[0].Overrides)
+	assert.Equal(t, "mon", opts[1].Ignores)
+	assert.Equal(t, "config show", conn.cmds[0]["prefix"])
+	assert.Equal(t, "osd.0", conn.cmds[0]["who"])
+}
+
+func TestAssimilateConf(t *testing.T) {
+	conn := &fakeConn{replies: []string{"[global]\n\tfsid = abc\n"}}
+	ca := NewFromConn(conn)
+	conf, err := cephconf.Parse(strings.NewReader(
+		"[global]\nfsid = abc\nosd pool default size = 2\n"))
+	require.NoError(t, err)
+	rest, err := ca.AssimilateConf(conf)
+	require.NoError(t, err)
+	assert.Equal(t, "[global]\n\tfsid = abc\n\tosd_pool_default_size = 2\n", string(conn.input))
+	v, ok := rest.Get("global", "fsid")
+	assert.True(t, ok)
+	assert.Equal(t, "abc", v)
+	_, ok = rest.Get("global", "osd pool default size")
+	assert.False(t, ok)
+}
@@ -0,0 +1,12 @@
+//go:build ceph_preview
+
+/*
+Package config from common/admin contains a set of APIs to manage the
+centralized configuration database of a Ceph cluster.
+
+Options are set for a Target, a configuration section optionally limited by
+masks on the CRUSH location or the device class of the daemons. The
+metadata of options, as returned by Help, can be used to validate values
+before they are sent to the cluster.
+*/
+package config
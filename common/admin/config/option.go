@@ -0,0 +1,328 @@
+//go:build ceph_preview
+
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ceph/go-ceph/internal/commands"
+)
+
+// OptionType is the type of the values of an option.
+type OptionType string
+
+const (
+	// TypeUint is an unsigned integer. SI suffixes like "K" are accepted.
+	TypeUint = OptionType("uint")
+	// TypeInt is a signed integer. SI suffixes like "K" are accepted.
+	TypeInt = OptionType("int")
+	// TypeStr is a string, optionally limited to a set of enum values.
+	TypeStr = OptionType("str")
+	// TypeFloat is a floating point number.
+	TypeFloat = OptionType("float")
+	// TypeBool is a boolean.
+	TypeBool = OptionType("bool")
+	// TypeAddr is a network address.
+	TypeAddr = OptionType("addr")
+	// TypeAddrVec is a list of network addresses.
+	TypeAddrVec = OptionType("addrvec")
+	// TypeUUID is a UUID.
+	TypeUUID = OptionType("uuid")
+	// TypeSize is a size in bytes. IEC suffixes like "Ki" or "M" are
+	// accepted.
+	TypeSize = OptionType("size")
+	// TypeSecs is a duration in seconds. Units like "5m" are accepted.
+	TypeSecs = OptionType("secs")
+	// TypeMillisecs is a duration in milliseconds.
+	TypeMillisecs = OptionType("millisecs")
+)
+
+// OptionLevel is the level of expertise an option is meant for.
+type OptionLevel string
+
+const (
+	// LevelBasic options are meant for all users.
+	LevelBasic = OptionLevel("basic")
+	// LevelAdvanced options are meant for experienced users.
+	LevelAdvanced = OptionLevel("advanced")
+	// LevelDev options are meant for developers.
+	LevelDev = OptionLevel("dev")
+)
+
+// ErrInvalidValue is returned if a value is not valid for an option.
+var ErrInvalidValue = errors.New("invalid option value")
+
+// Option is the metadata of a configuration option.
+type Option struct {
+	Name     string      `json:"name"`
+	Type     OptionType  `json:"type"`
+	Level    OptionLevel `json:"level"`
+	Desc     string      `json:"desc"`
+	LongDesc string      `json:"long_desc"`
+	// Default is the default value, DaemonDefault the default value for
+	// daemons if it differs from Default. Both are empty if the option has
+	// no default.
+	Default       string   `json:"default"`
+	DaemonDefault string   `json:"daemon_default"`
+	Tags          []string `json:"tags"`
+	Services      []string `json:"services"`
+	SeeAlso       []string `json:"see_also"`
+	EnumValues    []string `json:"enum_values"`
+	// Min and Max are the limits of numeric values. They are empty if the
+	// option is not limited.
+	Min                string   `json:"min"`
+	Max                string   `json:"max"`
+	CanUpdateAtRuntime bool     `json:"can_update_at_runtime"`
+	Flags              []string `json:"flags"`
+}
+
+// scalar is a JSON string, number or boolean decoded to a string.
+type scalar string
+
+func (s *scalar) UnmarshalJSON(b []byte) error {
+	var str string
+	if err := json.Unmarshal(b, &str); err == nil {
+		*s = scalar(str)
+		return nil
+	}
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	switch v.(type) {
+	case nil:
+		*s = ""
+	case float64, bool:
+		*s = scalar(string(b))
+	default:
+		return fmt.Errorf("unexpected value %s", b)
+	}
+	return nil
+}
+
+// UnmarshalJSON decodes the option metadata. Ceph encodes default values
+// and limits with the type of the option, they are decoded to strings.
+func (o *Option) UnmarshalJSON(b []byte) error {
+	type option Option
+	aux := struct {
+		*option
+		Default       scalar `json:"default"`
+		DaemonDefault scalar `json:"daemon_default"`
+		Min           scalar `json:"min"`
+		Max           scalar `json:"max"`
+	}{option: (*option)(o)}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	o.Default = string(aux.Default)
+	o.DaemonDefault = string(aux.DaemonDefault)
+	o.Min = string(aux.Min)
+	o.Max = string(aux.Max)
+	return nil
+}
+
+// HasService returns true if the option is used by the service, for example
+// "osd". Options without services are used by all services.
+func (o *Option) HasService(service string) bool {
+	return len(o.Services) == 0 || slices.Contains(o.Services, service)
+}
+
+// Validate returns an error wrapping ErrInvalidValue if the value can not be
+// parsed according to the type of the option, is not one of the enum values
+// of the option or is outside of its limits. Addresses are not validated.
+func (o *Option) Validate(value string) error {
+	invalid := func(msg string) error {
+		return fmt.Errorf("%w: %s: %q %s", ErrInvalidValue, o.Name, value, msg)
+	}
+	switch o.Type {
+	case TypeStr:
+		if len(o.EnumValues) > 0 && !slices.Contains(o.EnumValues, value) {
+			return invalid("is not one of " + strings.Join(o.EnumValues, ", "))
+		}
+		return nil
+	case TypeBool:
+		if _, err := parseBool(value); err != nil {
+			return invalid("is not a boolean")
+		}
+		return nil
+	case TypeUUID:
+		if !uuidRegexp.MatchString(value) {
+			return invalid("is not a UUID")
+		}
+		return nil
+	case TypeAddr, TypeAddrVec:
+		return nil
+	}
+	v, err := o.parseNumber(value)
+	if err != nil {
+		return invalid(fmt.Sprintf("is not a valid %s: %v", o.Type, err))
+	}
+	if v == nil {
+		// unknown option type
+		return nil
+	}
+	if o.Min != "" {
+		if min, err := o.parseNumber(o.Min); err == nil && min != nil && v.Cmp(min) < 0 {
+			return invalid("is less than " + o.Min)
+		}
+	}
+	if o.Max != "" {
+		if max, err := o.parseNumber(o.Max); err == nil && max != nil && v.Cmp(max) > 0 {
+			return invalid("is greater than " + o.Max)
+		}
+	}
+	return nil
+}
+
+var uuidRegexp = regexp.MustCompile(
+	`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// parseBool parses booleans like Ceph does: "true", "false" or an integer.
+func parseBool(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	i, err := strconv.ParseInt(s, 10, 64)
+	return i != 0, err
+}
+
+// parseNumber parses a value of a numeric option type. It returns nil
+// without an error for other types.
+func (o *Option) parseNumber(s string) (*big.Float, error) {
+	switch o.Type {
+	case TypeInt, TypeUint:
+		v, err := parseSuffixed(s, siSuffixes, false)
+		if err == nil && o.Type == TypeUint && v.Sign() < 0 {
+			err = errors.New("negative value")
+		}
+		return v, err
+	case TypeSize:
+		return parseSuffixed(s, iecSuffixes, true)
+	case TypeMillisecs:
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Float).SetInt64(i), nil
+	case TypeSecs:
+		return parseTimespan(s)
+	case TypeFloat:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, err
+		}
+		return big.NewFloat(f), nil
+	}
+	return nil, nil
+}
+
+var (
+	siSuffixes = map[string]int64{
+		"": 1, "K": 1e3, "M": 1e6, "G": 1e9, "T": 1e12, "P": 1e15, "E": 1e18,
+	}
+	iecSuffixes = map[string]int64{
+		"": 1, "K": 1 << 10, "M": 1 << 20, "G": 1 << 30, "T": 1 << 40,
+		"P": 1 << 50, "E": 1 << 60,
+	}
+)
+
+// parseSuffixed parses an integer with an optional unit suffix. For IEC
+// sizes "K", "Ki", "KB" and "KiB" are all accepted.
+func parseSuffixed(s string, suffixes map[string]int64, iec bool) (*big.Float, error) {
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '-' && r != '+'
+	})
+	num, suffix := s, ""
+	if i >= 0 {
+		num, suffix = s[:i], s[i:]
+	}
+	if iec {
+		suffix = strings.TrimSuffix(suffix, "B")
+		if len(suffix) > 1 {
+			suffix = strings.TrimSuffix(suffix, "i")
+		}
+	}
+	mult, ok := suffixes[suffix]
+	if !ok {
+		return nil, fmt.Errorf("unknown suffix %q", suffix)
+	}
+	n, ok := new(big.Int).SetString(num, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid number %q", num)
+	}
+	n.Mul(n, big.NewInt(mult))
+	return new(big.Float).SetInt(n), nil
+}
+
+// timespanUnits maps the units accepted by Ceph for durations to seconds.
+var timespanUnits = map[string]int64{
+	"s": 1, "sec": 1, "secs": 1, "second": 1, "seconds": 1,
+	"m": 60, "min": 60, "mins": 60, "minute": 60, "minutes": 60,
+	"h": 3600, "hr": 3600, "hrs": 3600, "hour": 3600, "hours": 3600,
+	"d": 86400, "day": 86400, "days": 86400,
+	"w": 604800, "wk": 604800, "wks": 604800, "week": 604800, "weeks": 604800,
+	"mo": 2592000, "month": 2592000, "months": 2592000,
+	"y": 31536000, "yr": 31536000, "yrs": 31536000, "year": 31536000,
+	"years": 31536000,
+}
+
+var timespanRegexp = regexp.MustCompile(`^\s*([0-9]+)\s*([a-z]*)\s*`)
+
+// parseTimespan parses a duration like "90", "1h 30m" or "2days" to seconds.
+func parseTimespan(s string) (*big.Float, error) {
+	if s == "" {
+		return nil, errors.New("empty duration")
+	}
+	var total int64
+	for rest := s; rest != ""; {
+		m := timespanRegexp.FindStringSubmatch(rest)
+		if m == nil {
+			return nil, fmt.Errorf("invalid duration %q", s)
+		}
+		n, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		mult := int64(1)
+		if m[2] != "" {
+			var ok bool
+			if mult, ok = timespanUnits[m[2]]; !ok {
+				return nil, fmt.Errorf("unknown unit %q", m[2])
+			}
+		} else if rest != s || len(m[0]) != len(rest) {
+			// only a plain number may omit the unit
+			return nil, fmt.Errorf("missing unit in %q", s)
+		}
+		total += n * mult
+		rest = rest[len(m[0]):]
+	}
+	return new(big.Float).SetInt64(total), nil
+}
+
+// Help returns the metadata of the option with the given name.
+//
+// Similar To:
+//
+//	ceph config help <name>
+func (ca *Admin) Help(name string) (*Option, error) {
+	m := map[string]string{
+		"prefix": "config help",
+		"key":    name,
+		"format": "json",
+	}
+	var o Option
+	if err := commands.MarshalMonCommand(ca.conn, m).Unmarshal(&o).End(); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
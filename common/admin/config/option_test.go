@@ -0,0 +1,120 @@
+//go:build ceph_preview
+
+package config
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const osdMaxBackfillsHelp = `{
+  "name": "osd_max_backfills",
+  "type": "uint",
+  "level": "advanced",
+  "desc": "Maximum number of concurrent local and remote backfills or recoveries per OSD",
+  "long_desc": "",
+  "default": 1,
+  "daemon_default": "",
+  "tags": [],
+  "services": ["osd"],
+  "see_also": [],
+  "min": 1,
+  "max": 100,
+  "can_update_at_runtime": true,
+  "flags": ["runtime"]
+}`
+
+func TestOptionUnmarshal(t *testing.T) {
+	var o Option
+	require.NoError(t, json.Unmarshal([]byte(osdMaxBackfillsHelp), &o))
+	assert.Equal(t, "osd_max_backfills", o.Name)
+	assert.Equal(t, TypeUint, o.Type)
+	assert.Equal(t, LevelAdvanced, o.Level)
+	assert.Equal(t, "1", o.Default)
+	assert.Equal(t, "", o.DaemonDefault)
+	assert.Equal(t, "1", o.Min)
+	assert.Equal(t, "100", o.Max)
+	assert.True(t, o.CanUpdateAtRuntime)
+	assert.Equal(t, []string{"runtime"}, o.Flags)
+	assert.True(t, o.HasService("osd"))
+	assert.False(t, o.HasService("mon"))
+
+	err := json.Unmarshal([]byte(`{"name":"x","default":{}}`), &o)
+	assert.Error(t, err)
+}
+
+func TestOptionValidate(t *testing.T) {
+	type check struct {
+		value string
+		valid bool
+	}
+	tcases := []struct {
+		opt    Option
+		checks []check
+	}{
+		{
+			Option{Type: TypeUint, Min: "1", Max: "100"},
+			[]check{{"1", true}, {"100", true}, {"0", false}, {"101", false},
+				{"-1", false}, {"abc", false}, {"", false}},
+		},
+		{
+			Option{Type: TypeInt},
+			[]check{{"-5", true}, {"2K", true}, {"2Ki", false}, {"1.5", false}},
+		},
+		{
+			Option{Type: TypeUint, Max: "18446744073709551615"},
+			[]check{{"18446744073709551615", true}, {"18446744073709551616", false}},
+		},
+		{
+			Option{Type: TypeSize, Max: "1G"},
+			[]check{{"4096", true}, {"4K", true}, {"4KiB", true}, {"1G", true},
+				{"1025M", false}, {"4X", false}},
+		},
+		{
+			Option{Type: TypeFloat, Min: "0", Max: "1"},
+			[]check{{"0.5", true}, {"1.5", false}, {"x", false}},
+		},
+		{
+			Option{Type: TypeBool},
+			[]check{{"true", true}, {"False", true}, {"0", true}, {"yes", false}},
+		},
+		{
+			Option{Type: TypeSecs, Max: "1d"},
+			[]check{{"90", true}, {"5m", true}, {"1h 30min", true},
+				{"2days", false}, {"5 parsecs", false}, {"5m 3", false}},
+		},
+		{
+			Option{Type: TypeMillisecs},
+			[]check{{"250", true}, {"1s", false}},
+		},
+		{
+			Option{Type: TypeStr, EnumValues: []string{"crc", "none"}},
+			[]check{{"crc", true}, {"md5", false}},
+		},
+		{
+			Option{Type: TypeStr},
+			[]check{{"anything", true}},
+		},
+		{
+			Option{Type: TypeUUID},
+			[]check{{"6d1e2e4c-8c3f-4b9a-9a43-3c1c4d2a1f10", true}, {"6d1e2e4c", false}},
+		},
+		{
+			Option{Type: TypeAddr},
+			[]check{{"v2:10.0.0.1:3300", true}},
+		},
+	}
+	for _, tc := range tcases {
+		for _, c := range tc.checks {
+			err := tc.opt.Validate(c.value)
+			if c.valid {
+				assert.NoError(t, err, "%s %q", tc.opt.Type, c.value)
+			} else {
+				assert.ErrorIs(t, err, ErrInvalidValue, "%s %q", tc.opt.Type, c.value)
+			}
+		}
+	}
+}
@@ -0,0 +1,115 @@
+//go:build ceph_preview
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// GlobalSection is the section of options applying to all daemons and
+// clients.
+const GlobalSection = "global"
+
+// MaskClass is the type of masks limiting options to daemons using a device
+// class. Masks of any other type limit options to daemons at a CRUSH
+// location, for example "host" or "rack".
+const MaskClass = "class"
+
+// ErrInvalidTarget is returned if a target can not be parsed or is not
+// valid.
+var ErrInvalidTarget = errors.New("invalid config target")
+
+// Mask limits an option to daemons at a CRUSH location or using a device
+// class.
+type Mask struct {
+	// Type is MaskClass or a CRUSH bucket type, for example "host".
+	Type  string
+	Value string
+}
+
+// String returns the mask in the form "<type>:<value>".
+func (m Mask) String() string {
+	return m.Type + ":" + m.Value
+}
+
+// Target is what an option is set for: a section, for example "global",
+// "osd" or "osd.3", and optional masks.
+type Target struct {
+	Section string
+	Masks   []Mask
+}
+
+// Global is the target of options applying to all daemons and clients.
+var Global = Target{Section: GlobalSection}
+
+// ParseTarget parses a target in the form used by Ceph, for example
+// "osd/host:foo/class:ssd".
+func ParseTarget(s string) (Target, error) {
+	section, rest, hasMasks := strings.Cut(s, "/")
+	t := Target{Section: section}
+	if hasMasks {
+		masks, err := parseMasks(rest)
+		if err != nil {
+			return Target{}, fmt.Errorf("%w: %s: %w", ErrInvalidTarget, s, err)
+		}
+		t.Masks = masks
+	}
+	if err := t.Validate(); err != nil {
+		return Target{}, err
+	}
+	return t, nil
+}
+
+// parseMasks parses masks separated by slashes.
+func parseMasks(s string) ([]Mask, error) {
+	var masks []Mask
+	for _, m := range strings.Split(s, "/") {
+		typ, value, ok := strings.Cut(m, ":")
+		if !ok {
+			return nil, fmt.Errorf("mask %q is not of the form <type>:<value>", m)
+		}
+		masks = append(masks, Mask{Type: typ, Value: value})
+	}
+	return masks, nil
+}
+
+// Validate returns an error if the target has no section, a mask with an
+// empty type or value, or more than one location or class mask.
+func (t Target) Validate() error {
+	if t.Section == "" || strings.ContainsAny(t.Section, "/:") {
+		return fmt.Errorf("%w: invalid section %q", ErrInvalidTarget, t.Section)
+	}
+	var location, class bool
+	for _, m := range t.Masks {
+		if m.Type == "" || m.Value == "" {
+			return fmt.Errorf("%w: invalid mask %q", ErrInvalidTarget, m)
+		}
+		seen := &location
+		if m.Type == MaskClass {
+			seen = &class
+		}
+		if *seen {
+			return fmt.Errorf("%w: more than one %s mask", ErrInvalidTarget, m.Type)
+		}
+		*seen = true
+	}
+	return nil
+}
+
+// String returns the target in the form used by Ceph. The location mask is
+// written before the class mask.
+func (t Target) String() string {
+	var b strings.Builder
+	b.WriteString(t.Section)
+	for _, class := range []bool{false, true} {
+		for _, m := range t.Masks {
+			if (m.Type == MaskClass) == class {
+				b.WriteString("/")
+				b.WriteString(m.String())
+			}
+		}
+	}
+	return b.String()
+}
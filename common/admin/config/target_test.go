@@ -0,0 +1,45 @@
+//go:build ceph_preview
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTarget(t *testing.T) {
+	tgt, err := ParseTarget("osd/class:ssd/host:foo")
+	require.NoError(t, err)
+	assert.Equal(t, Target{Section: "osd", Masks: []Mask{
+		{Type: MaskClass, Value: "ssd"},
+		{Type: "host", Value: "foo"},
+	}}, tgt)
+	// the location mask is written first
+	assert.Equal(t, "osd/host:foo/class:ssd", tgt.String())
+
+	tgt, err = ParseTarget("global")
+	require.NoError(t, err)
+	assert.Equal(t, Global, tgt)
+	assert.Equal(t, "global", tgt.String())
+
+	tgt, err = ParseTarget("client.rgw.foo/rack:r1")
+	require.NoError(t, err)
+	assert.Equal(t, "client.rgw.foo", tgt.Section)
+	assert.Equal(t, []Mask{{Type: "rack", Value: "r1"}}, tgt.Masks)
+
+	for _, s := range []string{
+		"",
+		"/host:foo",
+		"osd/",
+		"osd/host",
+		"osd/host:",
+		"osd/:foo",
+		"osd/host:a/rack:b",
+		"osd/class:ssd/class:hdd",
+	} {
+		_, err := ParseTarget(s)
+		assert.ErrorIs(t, err, ErrInvalidTarget, s)
+	}
+}
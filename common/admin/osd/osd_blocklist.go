@@ -7,6 +7,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ceph/go-ceph/internal/commands"
@@ -107,8 +109,9 @@ func (osda *Admin) OSDBlocklist() (*[]Blocklist, error) {
 	return parseBlocklist(res)
 }
 
-// AddressEntry contains the ip or network address string along with the
-// optional expire value in seconds.
+// AddressEntry contains the ip, client or network address string along with
+// the optional expire value in seconds. A client address identifies a single
+// client instance, for example "192.168.1.2:0/3052406834".
 type AddressEntry struct {
 	Addr   string
 	Expire float64
@@ -130,13 +133,36 @@ func isValidCIDR(addr string) bool {
 	return false
 }
 
+// isValidEntityAddr checks for the address of a client instance in the form
+// used by ceph, "[v1:|v2:|any:]<ip>:<port>/<nonce>".
+func isValidEntityAddr(addr string) bool {
+	for _, prefix := range []string{"v1:", "v2:", "any:"} {
+		if a, ok := strings.CutPrefix(addr, prefix); ok {
+			addr = a
+			break
+		}
+	}
+	hostPort, nonce, ok := strings.Cut(addr, "/")
+	if !ok {
+		return false
+	}
+	if _, err := strconv.ParseUint(nonce, 10, 32); err != nil {
+		return false
+	}
+	host, _, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return false
+	}
+	return isValidIP(host)
+}
+
 func blocklistOpCmd(addr string, op string) (map[string]any, error) {
 	m := map[string]any{"prefix": "osd blocklist",
 		"blocklistop": op,
 		"addr":        addr,
 	}
 
-	if !isValidIP(addr) {
+	if !isValidIP(addr) && !isValidEntityAddr(addr) {
 		if !isValidCIDR(addr) {
 			return nil, ErrInvalidArgument
 		}
@@ -156,12 +182,12 @@ func (f float) MarshalJSON() ([]byte, error) {
 	return []byte(fmt.Sprintf("%.1f", float64(f))), nil
 }
 
-// OSDBlocklistAdd adds an ip address or network address in CIDR format to the
-// blocklist.
+// OSDBlocklistAdd adds an ip address, client address or network address in
+// CIDR format to the blocklist.
 //
 // Similar To:
 //
-//	ceph osd blocklist [range] add <ip_addr|cidr_network> [expire]
+//	ceph osd blocklist [range] add <ip_addr|client_addr|cidr_network> [expire]
 func (osda *Admin) OSDBlocklistAdd(entry AddressEntry) error {
 	if entry.Addr == "" {
 		return ErrEmptyArgument
@@ -185,12 +211,12 @@ func (osda *Admin) OSDBlocklistAdd(entry AddressEntry) error {
 	return res.End()
 }
 
-// OSDBlocklistRemove removes an ip address or network address from the
-// blocklist.
+// OSDBlocklistRemove removes an ip address, client address or network address
+// from the blocklist.
 //
 // Similar To:
 //
-//	ceph osd blocklist [range] rm <ip_addr|cidr_network>
+//	ceph osd blocklist [range] rm <ip_addr|client_addr|cidr_network>
 func (osda *Admin) OSDBlocklistRemove(entry AddressEntry) error {
 	if entry.Addr == "" {
 		return ErrEmptyArgument
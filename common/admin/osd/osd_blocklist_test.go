@@ -120,3 +120,54 @@ func (suite *OSDAdminSuite) TestOSDBlocklist() {
 		assert.Equal(t, prev, len(*res))
 	})
 }
+
+func (suite *OSDAdminSuite) TestOSDBlocklistClientAddr() {
+	osda := NewFromConn(suite.vconn.Get(suite.T()))
+
+	res, err := osda.OSDBlocklist()
+	assert.NoError(suite.T(), err)
+	prev := len(*res)
+
+	err = osda.OSDBlocklistAdd(AddressEntry{
+		Addr:   "192.168.122.5:0/3052406834",
+		Expire: 60,
+	})
+	assert.NoError(suite.T(), err)
+
+	res, err = osda.OSDBlocklist()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), prev+1, len(*res))
+
+	err = osda.OSDBlocklistRemove(AddressEntry{
+		Addr: "192.168.122.5:0/3052406834",
+	})
+	assert.NoError(suite.T(), err)
+
+	res, err = osda.OSDBlocklist()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), prev, len(*res))
+}
+
+func TestIsValidEntityAddr(t *testing.T) {
+	valid := []string{
+		"192.168.1.2:0/3052406834",
+		"v1:192.168.1.2:6789/0",
+		"v2:[fe80::1]:3300/12",
+		"any:10.0.0.1:0/1",
+	}
+	for _, addr := range valid {
+		assert.True(t, isValidEntityAddr(addr), addr)
+	}
+	invalid := []string{
+		"192.168.1.2",
+		"192.168.1.0/24",
+		"fe80::/64",
+		"192.168.1.2:0",
+		"192.168.1.2:0/x",
+		"host:0/1",
+		"v3:192.168.1.2:0/1",
+	}
+	for _, addr := range invalid {
+		assert.False(t, isValidEntityAddr(addr), addr)
+	}
+}
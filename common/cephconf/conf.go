@@ -0,0 +1,365 @@
+//go:build ceph_preview
+
+package cephconf
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// GlobalSection is the name of the section applying to all daemons and
+// clients.
+const GlobalSection = "global"
+
+// includeDirective includes another configuration file. Paths are relative
+// to the directory of the including file.
+const includeDirective = "!include"
+
+// ErrIncludeCycle is returned by ParseFile if files include each other.
+var ErrIncludeCycle = errors.New("include cycle")
+
+// ParseError describes a syntax error in a configuration or keyring file.
+type ParseError struct {
+	File string
+	Line int
+	Msg  string
+}
+
+// Error implements the error interface.
+func (e *ParseError) Error() string {
+	if e.File != "" {
+		return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Msg)
+	}
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// Option is a configuration option.
+type Option struct {
+	Name  string
+	Value string
+}
+
+// Section is a section of a configuration file.
+type Section struct {
+	Name    string
+	Options []Option
+}
+
+// Get returns the value of the option name in the section.
+func (s *Section) Get(name string) (string, bool) {
+	name = NormalizeName(name)
+	for _, o := range s.Options {
+		if o.Name == name {
+			return o.Value, true
+		}
+	}
+	return "", false
+}
+
+// Set sets the option name in the section to value.
+func (s *Section) Set(name, value string) {
+	name = NormalizeName(name)
+	for i := range s.Options {
+		if s.Options[i].Name == name {
+			s.Options[i].Value = value
+			return
+		}
+	}
+	s.Options = append(s.Options, Option{Name: name, Value: value})
+}
+
+// Remove removes the option name from the section.
+func (s *Section) Remove(name string) {
+	name = NormalizeName(name)
+	for i := range s.Options {
+		if s.Options[i].Name == name {
+			s.Options = append(s.Options[:i], s.Options[i+1:]...)
+			return
+		}
+	}
+}
+
+// Conf is a parsed configuration file. The order of sections and options is
+// kept when writing it.
+type Conf struct {
+	Sections []*Section
+}
+
+// NormalizeName returns the canonical form of an option name like Ceph does.
+// Leading and trailing whitespace is removed, runs of whitespace are
+// replaced by a single underscore and dashes by underscores, so "mon host",
+// "mon-host" and "mon_host" are the same option. Underscores are kept as
+// they are, so "mon__host" is a different option.
+func NormalizeName(name string) string {
+	name = strings.Join(strings.Fields(name), "_")
+	return strings.ReplaceAll(name, "-", "_")
+}
+
+// Section returns the section with the given name or nil.
+func (c *Conf) Section(name string) *Section {
+	for _, s := range c.Sections {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// AddSection returns the section with the given name, adding an empty
+// section if it does not exist.
+func (c *Conf) AddSection(name string) *Section {
+	if s := c.Section(name); s != nil {
+		return s
+	}
+	s := &Section{Name: name}
+	c.Sections = append(c.Sections, s)
+	return s
+}
+
+// Get returns the unexpanded value of the option name in the given section.
+func (c *Conf) Get(section, name string) (string, bool) {
+	s := c.Section(section)
+	if s == nil {
+		return "", false
+	}
+	return s.Get(name)
+}
+
+// Set sets the option name in the given section, adding the section if it
+// does not exist.
+func (c *Conf) Set(section, name, value string) {
+	c.AddSection(section).Set(name, value)
+}
+
+// Parse parses a configuration file. Include directives are not supported,
+// use ParseFile for files including other files.
+func Parse(r io.Reader) (*Conf, error) {
+	c := &Conf{}
+	p := parser{conf: c}
+	if err := p.parse(r); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ParseFile parses the configuration file at path. Lines of the form
+// "!include <path>" include another configuration file at that place. The
+// path of an included file is relative to the directory of the including
+// file. Note that Ceph itself does not support include directives, so files
+// including other files must be rendered with WriteTo before they are used
+// by Ceph.
+func ParseFile(path string) (*Conf, error) {
+	c := &Conf{}
+	p := parser{conf: c}
+	if err := p.parseFile(path); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+type parser struct {
+	conf    *Conf
+	files   []string
+	section *Section
+}
+
+func (p *parser) parseFile(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	for _, f := range p.files {
+		if f == abs {
+			return fmt.Errorf("%w: %s", ErrIncludeCycle, path)
+		}
+	}
+	f, err := os.Open(abs)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	p.files = append(p.files, abs)
+	defer func() { p.files = p.files[:len(p.files)-1] }()
+	return p.parse(f)
+}
+
+func (p *parser) errorf(line int, format string, args ...any) error {
+	e := &ParseError{Line: line, Msg: fmt.Sprintf(format, args...)}
+	if len(p.files) > 0 {
+		e.File = p.files[len(p.files)-1]
+	}
+	return e
+}
+
+func (p *parser) parse(r io.Reader) error {
+	lines, err := readLines(r)
+	if err != nil {
+		return err
+	}
+	for _, l := range lines {
+		if err := p.parseLine(l.num, l.text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type line struct {
+	num  int
+	text string
+}
+
+// readLines returns the lines of r with continuation lines, ending with a
+// backslash, joined.
+func readLines(r io.Reader) ([]line, error) {
+	var lines []line
+	sc := bufio.NewScanner(r)
+	var cur strings.Builder
+	start, num := 0, 0
+	for sc.Scan() {
+		num++
+		text := strings.TrimRight(sc.Text(), "\r")
+		if cur.Len() == 0 {
+			start = num
+		}
+		if strings.HasSuffix(text, "\\") {
+			cur.WriteString(strings.TrimSuffix(text, "\\"))
+			continue
+		}
+		cur.WriteString(text)
+		lines = append(lines, line{num: start, text: cur.String()})
+		cur.Reset()
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if cur.Len() > 0 {
+		lines = append(lines, line{num: start, text: cur.String()})
+	}
+	return lines, nil
+}
+
+func (p *parser) parseLine(num int, text string) error {
+	text = strings.TrimSpace(text)
+	switch {
+	case text == "" || text[0] == '#' || text[0] == ';':
+		return nil
+	case text[0] == '[':
+		end := strings.IndexByte(text, ']')
+		if end < 0 {
+			return p.errorf(num, "unterminated section header")
+		}
+		if rest := strings.TrimSpace(text[end+1:]); rest != "" && !isComment(rest) {
+			return p.errorf(num, "unexpected %q after section header", rest)
+		}
+		name := strings.TrimSpace(text[1:end])
+		if name == "" {
+			return p.errorf(num, "empty section name")
+		}
+		p.section = p.conf.AddSection(name)
+		return nil
+	case strings.HasPrefix(text, includeDirective+" "):
+		if len(p.files) == 0 {
+			return p.errorf(num, "include not supported")
+		}
+		path := strings.TrimSpace(strings.TrimPrefix(text, includeDirective))
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(p.files[len(p.files)-1]), path)
+		}
+		section := p.section
+		if err := p.parseFile(path); err != nil {
+			return err
+		}
+		// the section of the including file continues
+		p.section = section
+		return nil
+	}
+	name, value, ok := strings.Cut(text, "=")
+	if !ok {
+		return p.errorf(num, "expected name = value")
+	}
+	name = NormalizeName(name)
+	if name == "" {
+		return p.errorf(num, "empty option name")
+	}
+	value, err := parseValue(strings.TrimSpace(value))
+	if err != nil {
+		return p.errorf(num, "%s: %v", name, err)
+	}
+	if p.section == nil {
+		return p.errorf(num, "option %s outside of a section", name)
+	}
+	p.section.Set(name, value)
+	return nil
+}
+
+func isComment(s string) bool {
+	return s[0] == '#' || s[0] == ';'
+}
+
+// parseValue returns the value of an option without comments and quotes.
+func parseValue(s string) (string, error) {
+	if s == "" || s[0] != '"' {
+		if i := strings.IndexAny(s, "#;"); i >= 0 {
+			s = s[:i]
+		}
+		return strings.TrimSpace(s), nil
+	}
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				b.WriteByte(s[i])
+			}
+		case '"':
+			if rest := strings.TrimSpace(s[i+1:]); rest != "" && !isComment(rest) {
+				return "", fmt.Errorf("unexpected %q after quoted value", rest)
+			}
+			return b.String(), nil
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", errors.New("unterminated quote")
+}
+
+// quoteValue quotes a value if it would not be parsed back unchanged.
+func quoteValue(s string) string {
+	if s != strings.TrimSpace(s) || strings.ContainsAny(s, "#;\"\\") ||
+		strings.HasSuffix(s, "\\") {
+		r := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+		return `"` + r.Replace(s) + `"`
+	}
+	return s
+}
+
+// WriteTo writes the configuration in the format of a configuration file.
+// It implements io.WriterTo.
+func (c *Conf) WriteTo(w io.Writer) (int64, error) {
+	var b bytes.Buffer
+	for i, s := range c.Sections {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		fmt.Fprintf(&b, "[%s]\n", s.Name)
+		for _, o := range s.Options {
+			fmt.Fprintf(&b, "\t%s = %s\n", o.Name, quoteValue(o.Value))
+		}
+	}
+	return b.WriteTo(w)
+}
+
+// String returns the configuration in the format of a configuration file.
+func (c *Conf) String() string {
+	var b strings.Builder
+	_, _ = c.WriteTo(&b)
+	return b.String()
+}
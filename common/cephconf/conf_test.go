@@ -0,0 +1,204 @@
+//go:build ceph_preview
+
+package cephconf
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleConf = `# sample configuration
+[global]
+	fsid = 6d1e2e4c-8c3f-4b9a-9a43-3c1c4d2a1f10
+	mon host = [v2:10.0.0.1:3300,v1:10.0.0.1:6789] ; the monitors
+	auth-cluster-required = cephx
+	log file = /var/log/ceph/$cluster-$name.log
+	admin_socket = $run_dir/$cluster-$name.asok
+	run dir = /run/ceph
+
+[osd]
+	osd data = /var/lib/ceph/osd/$cluster-$id
+	osd_journal_size = 1024
+[osd.3]
+	osd journal size = \
+		2048
+[client.admin]
+	keyring = "/etc/ceph/$cluster.client.admin.keyring"
+	motd = "hash # and \"quotes\""
+`
+
+func TestParse(t *testing.T) {
+	c, err := Parse(strings.NewReader(sampleConf))
+	require.NoError(t, err)
+	require.Len(t, c.Sections, 4)
+	assert.Equal(t, "global", c.Sections[0].Name)
+
+	v, ok := c.Get("global", "mon_host")
+	assert.True(t, ok)
+	assert.Equal(t, "[v2:10.0.0.1:3300,v1:10.0.0.1:6789]", v)
+	v, ok = c.Get("global", "auth cluster required")
+	assert.True(t, ok)
+	assert.Equal(t, "cephx", v)
+	v, _ = c.Get("osd.3", "osd-journal-size")
+	assert.Equal(t, "2048", v)
+	v, _ = c.Get("client.admin", "motd")
+	assert.Equal(t, `hash # and "quotes"`, v)
+	_, ok = c.Get("mon", "mon host")
+	assert.False(t, ok)
+	_, ok = c.Get("global", "missing")
+	assert.False(t, ok)
+}
+
+func TestNormalizeName(t *testing.T) {
+	assert.Equal(t, "mon_host", NormalizeName("mon host"))
+	assert.Equal(t, "mon_host", NormalizeName(" mon \t host "))
+	assert.Equal(t, "mon_host", NormalizeName("mon-host"))
+	assert.Equal(t, "mon__host", NormalizeName("mon--host"))
+	assert.Equal(t, "mon__host", NormalizeName("mon__host"))
+	assert.Equal(t, "mon___host", NormalizeName("mon _ host"))
+	assert.Equal(t, "_mon_host_", NormalizeName("-mon_host_"))
+	assert.Equal(t, "", NormalizeName("  "))
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, s := range []string{
+		"[global",
+		"[]",
+		"[global] junk",
+		"[global]\nno value",
+		"[global]\n = value",
+		"[global]\nkey = \"unterminated",
+		"[global]\nkey = \"quoted\" junk",
+		"key = outside",
+		"[global]\n!include other.conf",
+	} {
+		_, err := Parse(strings.NewReader(s))
+		var perr *ParseError
+		assert.ErrorAs(t, err, &perr, s)
+	}
+	_, err := Parse(strings.NewReader("[global]\n\nfoo"))
+	assert.EqualError(t, err, "line 3: expected name = value")
+}
+
+func TestLookup(t *testing.T) {
+	c, err := Parse(strings.NewReader(sampleConf))
+	require.NoError(t, err)
+
+	osd := Entity{Cluster: "prod", Name: "osd.3", Host: "node1"}
+	v, ok, err := c.Lookup(osd, "osd journal size")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "2048", v)
+	v, _, err = c.Lookup(osd, "osd_data")
+	assert.NoError(t, err)
+	assert.Equal(t, "/var/lib/ceph/osd/prod-3", v)
+	v, _, err = c.Lookup(osd, "log file")
+	assert.NoError(t, err)
+	assert.Equal(t, "/var/log/ceph/prod-osd.3.log", v)
+	// options are referenced as meta variables
+	v, _, err = c.Lookup(osd, "admin socket")
+	assert.NoError(t, err)
+	assert.Equal(t, "/run/ceph/prod-osd.3.asok", v)
+
+	admin := Entity{Name: "client.admin"}
+	v, _, err = c.Lookup(admin, "keyring")
+	assert.NoError(t, err)
+	assert.Equal(t, "/etc/ceph/ceph.client.admin.keyring", v)
+	_, ok, err = c.Lookup(admin, "osd data")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	c.Set("global", "a", "${b}x")
+	c.Set("global", "b", "$host-$a")
+	_, _, err = c.Lookup(osd, "a")
+	assert.ErrorIs(t, err, ErrExpansionLoop)
+	_, err = c.Options(osd)
+	assert.ErrorIs(t, err, ErrExpansionLoop)
+
+	c.Set("global", "b", "${host}.$unknown$")
+	v, _, err = c.Lookup(osd, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, "node1.$x", v)
+	c.Set("global", "b", "${host")
+	_, _, err = c.Lookup(osd, "a")
+	assert.Error(t, err)
+}
+
+func TestOptions(t *testing.T) {
+	c, err := Parse(strings.NewReader(sampleConf))
+	require.NoError(t, err)
+	opts, err := c.Options(Entity{Name: "osd.3", Host: "node1"})
+	require.NoError(t, err)
+	assert.Equal(t, "2048", opts["osd_journal_size"])
+	assert.Equal(t, "/var/lib/ceph/osd/ceph-3", opts["osd_data"])
+	assert.Equal(t, "cephx", opts["auth_cluster_required"])
+	assert.NotContains(t, opts, "keyring")
+	assert.Len(t, opts, 8)
+}
+
+func TestWriteTo(t *testing.T) {
+	c, err := Parse(strings.NewReader(sampleConf))
+	require.NoError(t, err)
+	c.Set("mon.a", "public addr", " 10.0.0.1 ")
+	c.Section("osd").Remove("osd journal size")
+	c.Section("osd").Remove("missing")
+
+	out := c.String()
+	assert.Contains(t, out, "[global]\n\tfsid = 6d1e2e4c-8c3f-4b9a-9a43-3c1c4d2a1f10\n")
+	assert.Contains(t, out, "\tmotd = \"hash # and \\\"quotes\\\"\"\n")
+	assert.Contains(t, out, "\n[mon.a]\n\tpublic_addr = \" 10.0.0.1 \"\n")
+	assert.NotContains(t, out, "osd_journal_size = 1024")
+
+	// writing and parsing again results in the same configuration
+	c2, err := Parse(strings.NewReader(out))
+	require.NoError(t, err)
+	assert.Equal(t, c, c2)
+}
+
+func TestParseFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		p := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0o600))
+		return p
+	}
+	main := write("ceph.conf", `[global]
+fsid = abc
+!include conf.d/mons.conf
+log to stderr = true
+[client]
+!include /nonexistent/../`+dir+`/conf.d/client.conf
+`)
+	write("conf.d/mons.conf", "mon host = 10.0.0.1\n[mon]\nmon allow pool delete = true\n")
+	write("conf.d/client.conf", "rbd cache = false\n")
+
+	c, err := ParseFile(main)
+	require.NoError(t, err)
+	v, _ := c.Get("global", "mon host")
+	assert.Equal(t, "10.0.0.1", v)
+	v, _ = c.Get("mon", "mon allow pool delete")
+	assert.Equal(t, "true", v)
+	// the section continues after the include
+	v, _ = c.Get("global", "log to stderr")
+	assert.Equal(t, "true", v)
+	v, _ = c.Get("client", "rbd cache")
+	assert.Equal(t, "false", v)
+
+	write("a.conf", "[global]\n!include b.conf\n")
+	write("b.conf", "!include a.conf\n")
+	_, err = ParseFile(filepath.Join(dir, "a.conf"))
+	assert.ErrorIs(t, err, ErrIncludeCycle)
+
+	bad := write("bad.conf", "[global]\nfoo\n")
+	_, err = ParseFile(bad)
+	assert.EqualError(t, err, bad+":2: expected name = value")
+
+	_, err = ParseFile(filepath.Join(dir, "missing.conf"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,14 @@
+//go:build ceph_preview
+
+/*
+Package cephconf parses and writes Ceph configuration files (ceph.conf) and
+keyring files without using the Ceph libraries.
+
+A configuration file consists of sections, for example "[global]" or
+"[client.admin]", containing options. Option names are normalized, so that
+"mon host", "mon-host" and "mon_host" refer to the same option. The value of
+an option for a daemon or client is resolved from its own section, the
+section of its type and the global section, in that order, with meta
+variables like $cluster and $id expanded.
+*/
+package cephconf
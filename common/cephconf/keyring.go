@@ -0,0 +1,89 @@
+//go:build ceph_preview
+
+package cephconf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// capsPrefix is the prefix of the normalized names of capabilities in a
+// keyring.
+const capsPrefix = "caps_"
+
+// KeyringEntry is the key and the capabilities of an entity in a keyring.
+type KeyringEntry struct {
+	// Name is the name of the entity, for example "client.admin".
+	Name string
+	Key  string
+	// Caps maps daemon types, for example "mon", to capability strings.
+	Caps map[string]string
+}
+
+// Keyring is a list of keyring entries.
+type Keyring []KeyringEntry
+
+// ParseKeyring parses a keyring file.
+func ParseKeyring(r io.Reader) (Keyring, error) {
+	conf, err := Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	k := make(Keyring, 0, len(conf.Sections))
+	for _, s := range conf.Sections {
+		e := KeyringEntry{Name: s.Name}
+		for _, o := range s.Options {
+			switch {
+			case o.Name == "key":
+				e.Key = o.Value
+			case strings.HasPrefix(o.Name, capsPrefix):
+				if e.Caps == nil {
+					e.Caps = map[string]string{}
+				}
+				e.Caps[strings.TrimPrefix(o.Name, capsPrefix)] = o.Value
+			}
+		}
+		k = append(k, e)
+	}
+	return k, nil
+}
+
+// Entry returns the entry of the entity with the given name or nil.
+func (k Keyring) Entry(name string) *KeyringEntry {
+	for i := range k {
+		if k[i].Name == name {
+			return &k[i]
+		}
+	}
+	return nil
+}
+
+// WriteTo writes the keyring in the format of a keyring file. It implements
+// io.WriterTo.
+func (k Keyring) WriteTo(w io.Writer) (int64, error) {
+	var b bytes.Buffer
+	for _, e := range k {
+		fmt.Fprintf(&b, "[%s]\n", e.Name)
+		fmt.Fprintf(&b, "\tkey = %s\n", e.Key)
+		daemons := make([]string, 0, len(e.Caps))
+		for daemon := range e.Caps {
+			daemons = append(daemons, daemon)
+		}
+		slices.Sort(daemons)
+		for _, daemon := range daemons {
+			r := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+			fmt.Fprintf(&b, "\tcaps %s = \"%s\"\n", daemon, r.Replace(e.Caps[daemon]))
+		}
+	}
+	return b.WriteTo(w)
+}
+
+// String returns the keyring in the format of a keyring file.
+func (k Keyring) String() string {
+	var b strings.Builder
+	_, _ = k.WriteTo(&b)
+	return b.String()
+}
@@ -0,0 +1,52 @@
+//go:build ceph_preview
+
+package cephconf
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleKeyring = `[client.admin]
+	key = AQBwNjNmAAAAABAAL0wH9d2AYpVLSdwqyaD6Ug==
+	caps mds = "allow *"
+	caps mgr = "allow *"
+	caps mon = "allow *"
+	caps osd = "allow *"
+[client.rbd]
+	key = AQCyNjNmAAAAABAA0mAs0fs7DoDLHLPbWmHkTQ==
+	caps mon = "profile rbd"
+	caps osd = "profile rbd pool=images, allow command \"osd blocklist\""
+`
+
+func TestParseKeyring(t *testing.T) {
+	k, err := ParseKeyring(strings.NewReader(sampleKeyring))
+	require.NoError(t, err)
+	require.Len(t, k, 2)
+	e := k.Entry("client.rbd")
+	require.NotNil(t, e)
+	assert.Equal(t, "AQCyNjNmAAAAABAA0mAs0fs7DoDLHLPbWmHkTQ==", e.Key)
+	assert.Equal(t, map[string]string{
+		"mon": "profile rbd",
+		"osd": `profile rbd pool=images, allow command "osd blocklist"`,
+	}, e.Caps)
+	assert.Len(t, k.Entry("client.admin").Caps, 4)
+	assert.Nil(t, k.Entry("client.missing"))
+
+	assert.Equal(t, sampleKeyring, k.String())
+
+	_, err = ParseKeyring(strings.NewReader("[client.admin\n"))
+	assert.Error(t, err)
+}
+
+func TestKeyringWriteTo(t *testing.T) {
+	k := Keyring{{Name: "client.foo", Key: "AQA="}}
+	var b strings.Builder
+	n, err := k.WriteTo(&b)
+	assert.NoError(t, err)
+	assert.EqualValues(t, b.Len(), n)
+	assert.Equal(t, "[client.foo]\n\tkey = AQA=\n", b.String())
+}
@@ -0,0 +1,194 @@
+//go:build ceph_preview
+
+package cephconf
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// DefaultCluster is the default name of a cluster.
+const DefaultCluster = "ceph"
+
+// maxExpansionDepth limits the nesting of option references.
+const maxExpansionDepth = 16
+
+// ErrExpansionLoop is returned if option values reference each other.
+var ErrExpansionLoop = errors.New("option references loop")
+
+// Entity identifies the daemon or client the options are resolved for.
+type Entity struct {
+	// Cluster is the name of the cluster, DefaultCluster if empty.
+	Cluster string
+	// Name is the name of the daemon or client, for example "client.admin"
+	// or "osd.3".
+	Name string
+	// Host is the short host name. The host name of the system is used if
+	// empty.
+	Host string
+}
+
+// Type returns the type of the entity, for example "client".
+func (e Entity) Type() string {
+	t, _, _ := strings.Cut(e.Name, ".")
+	return t
+}
+
+// ID returns the ID of the entity, for example "admin".
+func (e Entity) ID() string {
+	_, id, _ := strings.Cut(e.Name, ".")
+	return id
+}
+
+func (e Entity) cluster() string {
+	if e.Cluster == "" {
+		return DefaultCluster
+	}
+	return e.Cluster
+}
+
+func (e Entity) host() string {
+	if e.Host != "" {
+		return e.Host
+	}
+	h, _ := os.Hostname()
+	h, _, _ = strings.Cut(h, ".")
+	return h
+}
+
+// sections returns the names of the sections applying to the entity, the
+// most specific first.
+func (e Entity) sections() []string {
+	var s []string
+	if e.Name != "" {
+		s = append(s, e.Name)
+		if t := e.Type(); t != e.Name {
+			s = append(s, t)
+		}
+	}
+	return append(s, GlobalSection)
+}
+
+// Lookup returns the value of the option name for the entity with meta
+// variables expanded.
+//
+// Meta variables have the form $var or ${var}. The variables $cluster,
+// $type, $id, $name, $host and $pid are supported. Any other variable
+// refers to the value of the option of that name.
+func (c *Conf) Lookup(e Entity, name string) (string, bool, error) {
+	v, ok := c.lookupRaw(e, NormalizeName(name))
+	if !ok {
+		return "", false, nil
+	}
+	v, err := c.expand(e, v, 0)
+	if err != nil {
+		return "", false, fmt.Errorf("%s: %w", name, err)
+	}
+	return v, true, nil
+}
+
+func (c *Conf) lookupRaw(e Entity, name string) (string, bool) {
+	for _, section := range e.sections() {
+		if v, ok := c.Get(section, name); ok {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// Options returns all options applying to the entity, with meta variables
+// expanded. Options of more specific sections override options of the same
+// name in less specific sections.
+func (c *Conf) Options(e Entity) (map[string]string, error) {
+	opts := map[string]string{}
+	for _, section := range e.sections() {
+		s := c.Section(section)
+		if s == nil {
+			continue
+		}
+		for _, o := range s.Options {
+			if _, ok := opts[o.Name]; ok {
+				continue
+			}
+			v, err := c.expand(e, o.Value, 0)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", o.Name, err)
+			}
+			opts[o.Name] = v
+		}
+	}
+	return opts, nil
+}
+
+func (c *Conf) expand(e Entity, s string, depth int) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+	if depth > maxExpansionDepth {
+		return "", ErrExpansionLoop
+	}
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(s, '$')
+		if i < 0 {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+		b.WriteString(s[:i])
+		s = s[i+1:]
+		var name string
+		if strings.HasPrefix(s, "{") {
+			end := strings.IndexByte(s, '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated ${ in %q", s)
+			}
+			name, s = s[1:end], s[end+1:]
+		} else {
+			n := 0
+			for n < len(s) && isNameChar(s[n]) {
+				n++
+			}
+			name, s = s[:n], s[n:]
+		}
+		if name == "" {
+			b.WriteByte('$')
+			continue
+		}
+		v, err := c.variable(e, name, depth)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(v)
+	}
+}
+
+func isNameChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' ||
+		c >= '0' && c <= '9'
+}
+
+func (c *Conf) variable(e Entity, name string, depth int) (string, error) {
+	switch name {
+	case "cluster":
+		return e.cluster(), nil
+	case "type":
+		return e.Type(), nil
+	case "id":
+		return e.ID(), nil
+	case "name":
+		return e.Name, nil
+	case "host":
+		return e.host(), nil
+	case "pid":
+		return strconv.Itoa(os.Getpid()), nil
+	}
+	v, ok := c.lookupRaw(e, NormalizeName(name))
+	if !ok {
+		// like Ceph, unknown variables expand to nothing
+		return "", nil
+	}
+	return c.expand(e, v, depth+1)
+}
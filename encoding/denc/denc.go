@@ -0,0 +1,381 @@
+//go:build ceph_preview
+
+package denc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+var (
+	// ErrShortBuffer is returned when the data ends before a value has been
+	// fully decoded.
+	ErrShortBuffer = errors.New("denc: short buffer")
+	// ErrIncompatible is returned when a versioned struct requires a newer
+	// decoder than the one being used.
+	ErrIncompatible = errors.New("denc: incompatible struct version")
+)
+
+// Encoder appends Ceph encoded values to a byte slice.
+type Encoder struct {
+	buf    []byte
+	starts []int
+}
+
+// NewEncoder returns a new Encoder.
+func NewEncoder() *Encoder {
+	return &Encoder{}
+}
+
+// Bytes returns the encoded data.
+func (e *Encoder) Bytes() []byte {
+	return e.buf
+}
+
+// U8 encodes an unsigned 8 bit integer.
+func (e *Encoder) U8(v uint8) {
+	e.buf = append(e.buf, v)
+}
+
+// U16 encodes an unsigned 16 bit integer.
+func (e *Encoder) U16(v uint16) {
+	e.buf = binary.LittleEndian.AppendUint16(e.buf, v)
+}
+
+// U32 encodes an unsigned 32 bit integer.
+func (e *Encoder) U32(v uint32) {
+	e.buf = binary.LittleEndian.AppendUint32(e.buf, v)
+}
+
+// U64 encodes an unsigned 64 bit integer.
+func (e *Encoder) U64(v uint64) {
+	e.buf = binary.LittleEndian.AppendUint64(e.buf, v)
+}
+
+// I8 encodes a signed 8 bit integer.
+func (e *Encoder) I8(v int8) {
+	e.U8(uint8(v))
+}
+
+// I16 encodes a signed 16 bit integer.
+func (e *Encoder) I16(v int16) {
+	e.U16(uint16(v))
+}
+
+// I32 encodes a signed 32 bit integer.
+func (e *Encoder) I32(v int32) {
+	e.U32(uint32(v))
+}
+
+// I64 encodes a signed 64 bit integer.
+func (e *Encoder) I64(v int64) {
+	e.U64(uint64(v))
+}
+
+// F32 encodes a 32 bit floating point number.
+func (e *Encoder) F32(v float32) {
+	e.U32(math.Float32bits(v))
+}
+
+// F64 encodes a 64 bit floating point number.
+func (e *Encoder) F64(v float64) {
+	e.U64(math.Float64bits(v))
+}
+
+// Bool encodes a boolean as a single byte.
+func (e *Encoder) Bool(v bool) {
+	if v {
+		e.U8(1)
+	} else {
+		e.U8(0)
+	}
+}
+
+// String encodes a string with a 32 bit length prefix.
+func (e *Encoder) String(s string) {
+	e.U32(uint32(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// Blob encodes a byte slice (a bufferlist) with a 32 bit length prefix.
+func (e *Encoder) Blob(b []byte) {
+	e.U32(uint32(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+// Raw encodes a byte slice without a length prefix.
+func (e *Encoder) Raw(b []byte) {
+	e.buf = append(e.buf, b...)
+}
+
+// Strings encodes a list of strings with a 32 bit count prefix.
+func (e *Encoder) Strings(l []string) {
+	e.U32(uint32(len(l)))
+	for _, s := range l {
+		e.String(s)
+	}
+}
+
+// Time encodes a time as a utime_t, 32 bit seconds and nanoseconds since the
+// epoch. The zero time is encoded as zero.
+func (e *Encoder) Time(t time.Time) {
+	if t.IsZero() {
+		e.U32(0)
+		e.U32(0)
+		return
+	}
+	e.U32(uint32(t.Unix()))
+	e.U32(uint32(t.Nanosecond()))
+}
+
+// Duration encodes a duration as a utime_t.
+func (e *Encoder) Duration(d time.Duration) {
+	e.U32(uint32(d / time.Second))
+	e.U32(uint32(d % time.Second))
+}
+
+// Start begins a versioned struct, like ENCODE_START, with the given struct
+// version and the oldest version a decoder must understand. Every Start must
+// be matched by a call to Finish.
+func (e *Encoder) Start(version, compat uint8) {
+	e.U8(version)
+	e.U8(compat)
+	e.starts = append(e.starts, len(e.buf))
+	e.U32(0) // length placeholder
+}
+
+// Finish ends the versioned struct begun by the most recent Start, like
+// ENCODE_FINISH.
+func (e *Encoder) Finish() {
+	n := len(e.starts) - 1
+	start := e.starts[n]
+	e.starts = e.starts[:n]
+	binary.LittleEndian.PutUint32(e.buf[start:], uint32(len(e.buf)-start-4))
+}
+
+// Decoder decodes Ceph encoded values from a byte slice. Errors are sticky:
+// once decoding failed all further calls return zero values and Err returns
+// the first error.
+type Decoder struct {
+	buf  []byte
+	off  int
+	ends []int
+	err  error
+}
+
+// NewDecoder returns a new Decoder reading from b.
+func NewDecoder(b []byte) *Decoder {
+	return &Decoder{buf: b}
+}
+
+// Err returns the first error encountered while decoding.
+func (d *Decoder) Err() error {
+	return d.err
+}
+
+// Remaining returns the number of bytes not yet decoded.
+func (d *Decoder) Remaining() int {
+	return len(d.buf) - d.off
+}
+
+func (d *Decoder) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+// limit returns the offset decoding must not go beyond.
+func (d *Decoder) limit() int {
+	if n := len(d.ends); n > 0 {
+		return d.ends[n-1]
+	}
+	return len(d.buf)
+}
+
+func (d *Decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || d.off+n > d.limit() {
+		d.fail(ErrShortBuffer)
+		return nil
+	}
+	b := d.buf[d.off : d.off+n]
+	d.off += n
+	return b
+}
+
+// U8 decodes an unsigned 8 bit integer.
+func (d *Decoder) U8() uint8 {
+	b := d.next(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+// U16 decodes an unsigned 16 bit integer.
+func (d *Decoder) U16() uint16 {
+	b := d.next(2)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(b)
+}
+
+// U32 decodes an unsigned 32 bit integer.
+func (d *Decoder) U32() uint32 {
+	b := d.next(4)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+// U64 decodes an unsigned 64 bit integer.
+func (d *Decoder) U64() uint64 {
+	b := d.next(8)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(b)
+}
+
+// I8 decodes a signed 8 bit integer.
+func (d *Decoder) I8() int8 {
+	return int8(d.U8())
+}
+
+// I16 decodes a signed 16 bit integer.
+func (d *Decoder) I16() int16 {
+	return int16(d.U16())
+}
+
+// I32 decodes a signed 32 bit integer.
+func (d *Decoder) I32() int32 {
+	return int32(d.U32())
+}
+
+// I64 decodes a signed 64 bit integer.
+func (d *Decoder) I64() int64 {
+	return int64(d.U64())
+}
+
+// F32 decodes a 32 bit floating point number.
+func (d *Decoder) F32() float32 {
+	return math.Float32frombits(d.U32())
+}
+
+// F64 decodes a 64 bit floating point number.
+func (d *Decoder) F64() float64 {
+	return math.Float64frombits(d.U64())
+}
+
+// Bool decodes a boolean encoded as a single byte.
+func (d *Decoder) Bool() bool {
+	return d.U8() != 0
+}
+
+// String decodes a string with a 32 bit length prefix.
+func (d *Decoder) String() string {
+	return string(d.next(int(d.U32())))
+}
+
+// Blob decodes a byte slice with a 32 bit length prefix. The returned slice
+// is a copy.
+func (d *Decoder) Blob() []byte {
+	b := d.next(int(d.U32()))
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
+
+// Raw decodes n bytes without a length prefix. The returned slice is a copy.
+func (d *Decoder) Raw(n int) []byte {
+	b := d.next(n)
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
+
+// Count decodes a 32 bit element count of a container. Each element takes up
+// at least minSize bytes, which is used to reject counts that can not
+// possibly fit into the remaining data.
+func (d *Decoder) Count(minSize int) int {
+	n := int(d.U32())
+	if d.err != nil {
+		return 0
+	}
+	if minSize > 0 && n > (d.limit()-d.off)/minSize {
+		d.fail(ErrShortBuffer)
+		return 0
+	}
+	return n
+}
+
+// Strings decodes a list of strings with a 32 bit count prefix.
+func (d *Decoder) Strings() []string {
+	n := d.Count(4)
+	l := make([]string, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		l = append(l, d.String())
+	}
+	return l
+}
+
+// Time decodes a utime_t. A zero utime_t is decoded as the zero time.
+func (d *Decoder) Time() time.Time {
+	sec, nsec := d.U32(), d.U32()
+	if sec == 0 && nsec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(sec), int64(nsec))
+}
+
+// Duration decodes a utime_t used as a duration.
+func (d *Decoder) Duration() time.Duration {
+	sec, nsec := d.U32(), d.U32()
+	return time.Duration(sec)*time.Second + time.Duration(nsec)
+}
+
+// Start begins decoding a versioned struct, like DECODE_START. The version
+// is the newest struct version the caller understands. Start returns the
+// version of the encoded struct. Every Start must be matched by a call to
+// Finish.
+func (d *Decoder) Start(version uint8) uint8 {
+	v, compat := d.U8(), d.U8()
+	n := int(d.U32())
+	if d.err != nil {
+		return 0
+	}
+	if compat > version {
+		d.fail(fmt.Errorf("%w: struct v%d requires v%d, decoder supports v%d",
+			ErrIncompatible, v, compat, version))
+		return 0
+	}
+	if n > d.limit()-d.off {
+		d.fail(ErrShortBuffer)
+		return 0
+	}
+	d.ends = append(d.ends, d.off+n)
+	return v
+}
+
+// Finish ends decoding of the versioned struct begun by the most recent
+// Start, like DECODE_FINISH. Any data of the struct that has not been decoded,
+// such as fields added by newer versions, is skipped.
+func (d *Decoder) Finish() {
+	n := len(d.ends) - 1
+	if n < 0 {
+		return
+	}
+	if d.err == nil {
+		d.off = d.ends[n]
+	}
+	d.ends = d.ends[:n]
+}
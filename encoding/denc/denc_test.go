@@ -0,0 +1,198 @@
+//go:build ceph_preview
+
+package denc
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrimitives(t *testing.T) {
+	e := NewEncoder()
+	e.U8(1)
+	e.U16(2)
+	e.U32(3)
+	e.U64(4)
+	e.I32(-5)
+	e.I64(-6)
+	e.F64(1.5)
+	e.Bool(true)
+	e.String("seven")
+	e.Blob([]byte{8, 8})
+	e.Strings([]string{"a", "bc"})
+	ts := time.Unix(1700000000, 1234)
+	e.Time(ts)
+	e.Time(time.Time{})
+	e.Duration(90 * time.Second)
+
+	d := NewDecoder(e.Bytes())
+	assert.EqualValues(t, 1, d.U8())
+	assert.EqualValues(t, 2, d.U16())
+	assert.EqualValues(t, 3, d.U32())
+	assert.EqualValues(t, 4, d.U64())
+	assert.EqualValues(t, -5, d.I32())
+	assert.EqualValues(t, -6, d.I64())
+	assert.EqualValues(t, 1.5, d.F64())
+	assert.True(t, d.Bool())
+	assert.Equal(t, "seven", d.String())
+	assert.Equal(t, []byte{8, 8}, d.Blob())
+	assert.Equal(t, []string{"a", "bc"}, d.Strings())
+	assert.True(t, ts.Equal(d.Time()))
+	assert.True(t, d.Time().IsZero())
+	assert.Equal(t, 90*time.Second, d.Duration())
+	assert.NoError(t, d.Err())
+	assert.Equal(t, 0, d.Remaining())
+}
+
+func TestWireFormat(t *testing.T) {
+	e := NewEncoder()
+	e.Start(2, 1)
+	e.String("ab")
+	e.Finish()
+	assert.Equal(t,
+		[]byte{2, 1, 6, 0, 0, 0, 2, 0, 0, 0, 'a', 'b'},
+		e.Bytes())
+}
+
+func TestVersionedStruct(t *testing.T) {
+	// a newer encoder added a field the decoder does not know about
+	e := NewEncoder()
+	e.Start(3, 1)
+	e.U32(42)
+	e.Start(1, 1)
+	e.String("inner")
+	e.Finish()
+	e.String("new field")
+	e.Finish()
+	e.U8(7)
+
+	d := NewDecoder(e.Bytes())
+	v := d.Start(2)
+	assert.EqualValues(t, 3, v)
+	assert.EqualValues(t, 42, d.U32())
+	d.Start(1)
+	assert.Equal(t, "inner", d.String())
+	d.Finish()
+	d.Finish()
+	assert.EqualValues(t, 7, d.U8())
+	assert.NoError(t, d.Err())
+
+	t.Run("incompatible", func(t *testing.T) {
+		e := NewEncoder()
+		e.Start(3, 3)
+		e.Finish()
+		d := NewDecoder(e.Bytes())
+		d.Start(2)
+		assert.ErrorIs(t, d.Err(), ErrIncompatible)
+	})
+
+	t.Run("overrun", func(t *testing.T) {
+		e := NewEncoder()
+		e.Start(1, 1)
+		e.U8(1)
+		e.Finish()
+		e.U32(99)
+		d := NewDecoder(e.Bytes())
+		d.Start(1)
+		// reading past the end of the struct fails
+		d.U32()
+		assert.ErrorIs(t, d.Err(), ErrShortBuffer)
+	})
+}
+
+func TestShortBuffer(t *testing.T) {
+	d := NewDecoder([]byte{5, 0, 0, 0, 'a'})
+	assert.Equal(t, "", d.String())
+	assert.ErrorIs(t, d.Err(), ErrShortBuffer)
+	// errors are sticky
+	assert.EqualValues(t, 0, d.U8())
+
+	d = NewDecoder([]byte{0xff, 0xff, 0xff, 0x7f})
+	assert.Len(t, d.Strings(), 0)
+	assert.ErrorIs(t, d.Err(), ErrShortBuffer)
+}
+
+func TestEntityName(t *testing.T) {
+	n, err := ParseEntityName("client.4123")
+	require.NoError(t, err)
+	assert.Equal(t, EntityName{Type: EntityTypeClient, Num: 4123}, n)
+	assert.Equal(t, "client.4123", n.String())
+
+	e := NewEncoder()
+	e.EntityName(n)
+	assert.Equal(t, []byte{8, 0x1b, 0x10, 0, 0, 0, 0, 0, 0}, e.Bytes())
+	d := NewDecoder(e.Bytes())
+	assert.Equal(t, n, d.EntityName())
+
+	_, err = ParseEntityName("client")
+	assert.ErrorIs(t, err, ErrMalformed)
+	_, err = ParseEntityName("bogus.1")
+	assert.ErrorIs(t, err, ErrMalformed)
+	_, err = ParseEntityName("osd.x")
+	assert.ErrorIs(t, err, ErrMalformed)
+}
+
+func TestEntityAddr(t *testing.T) {
+	t.Run("ipv4", func(t *testing.T) {
+		a := EntityAddr{
+			Type:  AddrTypeMsgr2,
+			Nonce: 1234,
+			IP:    net.ParseIP("10.0.0.1").To4(),
+			Port:  6800,
+		}
+		assert.Equal(t, "v2:10.0.0.1:6800/1234", a.String())
+		e := NewEncoder()
+		e.EntityAddr(a)
+		d := NewDecoder(e.Bytes())
+		assert.Equal(t, a, d.EntityAddr())
+		assert.NoError(t, d.Err())
+	})
+
+	t.Run("ipv6", func(t *testing.T) {
+		a := EntityAddr{
+			Type:  AddrTypeAny,
+			Nonce: 1,
+			IP:    net.ParseIP("fe80::1"),
+			Port:  3300,
+		}
+		assert.Equal(t, "[fe80::1]:3300/1", a.String())
+		e := NewEncoder()
+		e.EntityAddr(a)
+		d := NewDecoder(e.Bytes())
+		assert.Equal(t, a, d.EntityAddr())
+		assert.NoError(t, d.Err())
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		a := EntityAddr{Type: AddrTypeLegacy, Nonce: 5}
+		assert.Equal(t, "v1:-/5", a.String())
+		e := NewEncoder()
+		e.EntityAddr(a)
+		d := NewDecoder(e.Bytes())
+		assert.Equal(t, a, d.EntityAddr())
+		assert.Equal(t, "-", EntityAddr{}.String())
+	})
+
+	t.Run("legacy", func(t *testing.T) {
+		b := []byte{0, 0, 0, 0, 7, 0, 0, 0}
+		ss := make([]byte, sockaddrSz)
+		ss[1] = afInet // network byte order
+		ss[2], ss[3] = 0x1a, 0x90
+		copy(ss[4:], []byte{192, 168, 1, 2})
+		b = append(b, ss...)
+		d := NewDecoder(b)
+		a := d.EntityAddr()
+		assert.NoError(t, d.Err())
+		assert.Equal(t, "v1:192.168.1.2:6800/7", a.String())
+	})
+
+	t.Run("badMarker", func(t *testing.T) {
+		d := NewDecoder([]byte{9})
+		d.EntityAddr()
+		assert.ErrorIs(t, d.Err(), ErrMalformed)
+	})
+}
@@ -0,0 +1,42 @@
+//go:build ceph_preview
+
+/*
+Package denc implements encoding and decoding of the little-endian binary
+format Ceph uses to serialize data structures, known as "denc" or bufferlist
+encoding.
+
+The Encoder and Decoder types encode and decode individual values and
+versioned structs in the same way the ENCODE_START/ENCODE_FINISH and
+DECODE_START/DECODE_FINISH macros of Ceph do. The Marshal and Unmarshal
+functions use reflection to encode and decode Go values:
+
+  - Integers of fixed size, floats and booleans are encoded as little-endian
+    values of the same size; booleans take up one byte. The int and uint types
+    are not supported as their size depends on the platform.
+  - Strings and byte slices are encoded with a 32 bit length prefix.
+  - Slices are encoded as a 32 bit element count followed by the elements,
+    like std::vector or std::list. Arrays are encoded as their elements
+    without a count.
+  - Maps are encoded as a 32 bit entry count followed by the keys and values,
+    like std::map. Entries are sorted by key.
+  - Pointers are encoded as optional values, like std::optional: a boolean
+    that is true if the pointer is not nil, followed by the value.
+  - time.Time and time.Duration values are encoded as utime_t.
+  - Structs are encoded as their exported fields in order. A struct that has
+    a field of type Versioned is encoded as a versioned struct.
+  - Types implementing Marshaler or Unmarshaler encode or decode themselves.
+
+The encoding of struct fields can be customized with the "denc" struct tag.
+A field tagged with "-" is skipped. The tag of a Versioned field sets the
+version and compat version of the struct, and the "since" option marks fields
+that were added in later versions of a struct. These fields are left as they
+are when decoding older versions:
+
+	type Header struct {
+		_       denc.Versioned `denc:"version=2,compat=1"`
+		Marker  string
+		Time    time.Time
+		Pending uint32 `denc:"since=2"`
+	}
+*/
+package denc
@@ -0,0 +1,263 @@
+//go:build ceph_preview
+
+package denc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ErrMalformed is returned when encoded data is not valid for the type being
+// decoded.
+var ErrMalformed = errors.New("denc: malformed input")
+
+// Ceph entity types.
+const (
+	EntityTypeMon    = uint8(0x01)
+	EntityTypeMDS    = uint8(0x02)
+	EntityTypeOSD    = uint8(0x04)
+	EntityTypeClient = uint8(0x08)
+	EntityTypeMgr    = uint8(0x10)
+	EntityTypeAuth   = uint8(0x20)
+)
+
+var entityTypeNames = map[uint8]string{
+	EntityTypeMon:    "mon",
+	EntityTypeMDS:    "mds",
+	EntityTypeOSD:    "osd",
+	EntityTypeClient: "client",
+	EntityTypeMgr:    "mgr",
+	EntityTypeAuth:   "auth",
+}
+
+// EntityName identifies a Ceph entity, such as "client.4123". It corresponds
+// to entity_name_t.
+type EntityName struct {
+	Type uint8
+	Num  int64
+}
+
+// String returns the entity name in the "<type>.<num>" form used by Ceph.
+func (n EntityName) String() string {
+	t, ok := entityTypeNames[n.Type]
+	if !ok {
+		t = "unknown"
+	}
+	return t + "." + strconv.FormatInt(n.Num, 10)
+}
+
+// ParseEntityName parses an entity name in the "<type>.<num>" form.
+func ParseEntityName(s string) (EntityName, error) {
+	t, num, ok := strings.Cut(s, ".")
+	if !ok {
+		return EntityName{}, fmt.Errorf("%w: entity name %q", ErrMalformed, s)
+	}
+	for k, v := range entityTypeNames {
+		if v != t {
+			continue
+		}
+		n, err := strconv.ParseInt(num, 10, 64)
+		if err != nil {
+			return EntityName{}, fmt.Errorf("%w: entity name %q", ErrMalformed, s)
+		}
+		return EntityName{Type: k, Num: n}, nil
+	}
+	return EntityName{}, fmt.Errorf("%w: entity type %q", ErrMalformed, t)
+}
+
+// EntityName encodes an entity_name_t.
+func (e *Encoder) EntityName(n EntityName) {
+	e.U8(n.Type)
+	e.I64(n.Num)
+}
+
+// EntityName decodes an entity_name_t.
+func (d *Decoder) EntityName() EntityName {
+	return EntityName{Type: d.U8(), Num: d.I64()}
+}
+
+// MarshalDenc implements the Marshaler interface.
+func (n EntityName) MarshalDenc(e *Encoder) error {
+	e.EntityName(n)
+	return nil
+}
+
+// UnmarshalDenc implements the Unmarshaler interface.
+func (n *EntityName) UnmarshalDenc(d *Decoder) error {
+	*n = d.EntityName()
+	return d.Err()
+}
+
+// Entity address types.
+const (
+	AddrTypeNone   = uint32(0)
+	AddrTypeLegacy = uint32(1)
+	AddrTypeMsgr2  = uint32(2)
+	AddrTypeAny    = uint32(3)
+)
+
+var addrTypeNames = map[uint32]string{
+	AddrTypeNone:   "none",
+	AddrTypeLegacy: "v1",
+	AddrTypeMsgr2:  "v2",
+	AddrTypeAny:    "any",
+}
+
+const (
+	afInet     = 2
+	afInet6    = 10
+	sockaddrSz = 128
+)
+
+// EntityAddr is the network address of a Ceph entity. It corresponds to
+// entity_addr_t.
+type EntityAddr struct {
+	Type  uint32
+	Nonce uint32
+	IP    net.IP
+	Port  uint16
+}
+
+// String returns the address in the form used by Ceph, for example
+// "v2:10.0.0.1:6800/1234".
+func (a EntityAddr) String() string {
+	if a.Type == AddrTypeNone {
+		return "-"
+	}
+	var sb strings.Builder
+	if a.Type != AddrTypeAny {
+		t, ok := addrTypeNames[a.Type]
+		if !ok {
+			t = "???"
+		}
+		sb.WriteString(t + ":")
+	}
+	if a.IP == nil {
+		sb.WriteString("-")
+	} else {
+		sb.WriteString(net.JoinHostPort(a.IP.String(), strconv.Itoa(int(a.Port))))
+	}
+	sb.WriteString("/" + strconv.FormatUint(uint64(a.Nonce), 10))
+	return sb.String()
+}
+
+// sockaddr returns the address as the family specific part of a sockaddr,
+// following the family.
+func (a EntityAddr) sockaddr() (uint16, []byte) {
+	if a.IP == nil {
+		return 0, nil
+	}
+	if ip4 := a.IP.To4(); ip4 != nil {
+		b := make([]byte, 14)
+		binary.BigEndian.PutUint16(b, a.Port)
+		copy(b[2:], ip4)
+		return afInet, b
+	}
+	b := make([]byte, 26)
+	binary.BigEndian.PutUint16(b, a.Port)
+	copy(b[6:], a.IP.To16())
+	return afInet6, b
+}
+
+func (a *EntityAddr) setSockaddr(family uint16, data []byte) error {
+	switch family {
+	case 0:
+		a.IP = nil
+		a.Port = 0
+	case afInet:
+		if len(data) < 6 {
+			return ErrMalformed
+		}
+		a.Port = binary.BigEndian.Uint16(data)
+		a.IP = net.IP(append([]byte{}, data[2:6]...))
+	case afInet6:
+		if len(data) < 22 {
+			return ErrMalformed
+		}
+		a.Port = binary.BigEndian.Uint16(data)
+		a.IP = net.IP(append([]byte{}, data[6:22]...))
+	default:
+		return fmt.Errorf("%w: address family %d", ErrMalformed, family)
+	}
+	return nil
+}
+
+// EntityAddr encodes an entity_addr_t in the format used by clients that
+// support msgr2 addresses.
+func (e *Encoder) EntityAddr(a EntityAddr) {
+	e.U8(1) // marker
+	e.Start(1, 1)
+	e.U32(a.Type)
+	e.U32(a.Nonce)
+	family, data := a.sockaddr()
+	if data == nil {
+		e.U32(0)
+	} else {
+		e.U32(uint32(2 + len(data)))
+		e.U16(family)
+		e.Raw(data)
+	}
+	e.Finish()
+}
+
+// EntityAddr decodes an entity_addr_t, in either the legacy or the msgr2
+// aware format.
+func (d *Decoder) EntityAddr() EntityAddr {
+	var a EntityAddr
+	marker := d.U8()
+	if d.err != nil {
+		return a
+	}
+	switch marker {
+	case 0:
+		// legacy: the rest of a 32 bit type, the nonce and a sockaddr_storage
+		// with the family in network byte order
+		d.U8()
+		d.U16()
+		a.Type = AddrTypeLegacy
+		a.Nonce = d.U32()
+		ss := d.next(sockaddrSz)
+		if ss == nil {
+			return a
+		}
+		if err := a.setSockaddr(binary.BigEndian.Uint16(ss), ss[2:]); err != nil {
+			d.fail(err)
+		}
+	case 1:
+		d.Start(1)
+		a.Type = d.U32()
+		a.Nonce = d.U32()
+		if elen := int(d.U32()); elen > 0 {
+			if elen < 2 || elen > sockaddrSz {
+				d.fail(ErrMalformed)
+			}
+			family := d.U16()
+			data := d.next(elen - 2)
+			if d.err == nil {
+				if err := a.setSockaddr(family, data); err != nil {
+					d.fail(err)
+				}
+			}
+		}
+		d.Finish()
+	default:
+		d.fail(fmt.Errorf("%w: entity_addr_t marker %d", ErrMalformed, marker))
+	}
+	return a
+}
+
+// MarshalDenc implements the Marshaler interface.
+func (a EntityAddr) MarshalDenc(e *Encoder) error {
+	e.EntityAddr(a)
+	return nil
+}
+
+// UnmarshalDenc implements the Unmarshaler interface.
+func (a *EntityAddr) UnmarshalDenc(d *Decoder) error {
+	*a = d.EntityAddr()
+	return d.Err()
+}
@@ -0,0 +1,66 @@
+//go:build ceph_preview
+
+package denc
+
+import (
+	"bytes"
+	"testing"
+)
+
+// FuzzUnmarshal checks that decoding arbitrary data does not panic and that
+// decoded values encode to stable data.
+func FuzzUnmarshal(f *testing.F) {
+	seed, err := Marshal(testAll{Strs: []string{"a"}, Map: map[string]uint64{"k": 1}})
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(seed)
+	f.Add([]byte{})
+	f.Add([]byte{3, 2, 0xff, 0xff, 0xff, 0xff})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var v testAll
+		if err := Unmarshal(data, &v); err != nil {
+			return
+		}
+		b, err := Marshal(v)
+		if err != nil {
+			t.Fatalf("failed to encode decoded value: %v", err)
+		}
+		var v2 testAll
+		if err := Unmarshal(b, &v2); err != nil {
+			t.Fatalf("failed to decode encoded value: %v", err)
+		}
+		b2, err := Marshal(v2)
+		if err != nil {
+			t.Fatalf("failed to encode decoded value: %v", err)
+		}
+		if !bytes.Equal(b, b2) {
+			t.Fatalf("encoding is not stable: %x != %x", b, b2)
+		}
+	})
+}
+
+// FuzzDecoder checks that the Decoder methods do not panic on arbitrary data.
+func FuzzDecoder(f *testing.F) {
+	e := NewEncoder()
+	e.EntityAddr(EntityAddr{Type: AddrTypeMsgr2, IP: []byte{1, 2, 3, 4}})
+	e.HObject(HObject{Name: "obj"})
+	e.Strings([]string{"a", "b"})
+	f.Add(e.Bytes())
+	f.Add([]byte{0})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		d := NewDecoder(data)
+		d.EntityAddr()
+		d.HObject()
+		d.Start(1)
+		d.Strings()
+		d.Blob()
+		d.Finish()
+		d.EntityName()
+		if d.Err() == nil && d.Remaining() < 0 {
+			t.Fatalf("negative remaining data")
+		}
+	})
+}
@@ -0,0 +1,75 @@
+//go:build ceph_preview
+
+package denc
+
+import (
+	"math"
+)
+
+const (
+	// SnapHead is the snapshot ID of the head, the current version, of an
+	// object (CEPH_NOSNAP).
+	SnapHead = uint64(math.MaxUint64 - 1)
+	// SnapDir is the snapshot ID of the snapdir of an object (CEPH_SNAPDIR).
+	SnapDir = uint64(math.MaxUint64)
+)
+
+// HObject identifies an object, or a snapshot of an object, within a pool.
+// It corresponds to hobject_t.
+type HObject struct {
+	Key       string
+	Name      string
+	Snap      uint64
+	Hash      uint32
+	Max       bool
+	Namespace string
+	Pool      int64
+}
+
+// HObject encodes an hobject_t.
+func (e *Encoder) HObject(o HObject) {
+	e.Start(4, 3)
+	e.String(o.Key)
+	e.String(o.Name)
+	e.U64(o.Snap)
+	e.U32(o.Hash)
+	e.Bool(o.Max)
+	e.String(o.Namespace)
+	e.I64(o.Pool)
+	e.Finish()
+}
+
+// HObject decodes an hobject_t.
+func (d *Decoder) HObject() HObject {
+	var o HObject
+	v := d.Start(4)
+	o.Key = d.String()
+	o.Name = d.String()
+	o.Snap = d.U64()
+	o.Hash = d.U32()
+	if v >= 2 {
+		o.Max = d.Bool()
+	}
+	if v >= 4 {
+		o.Namespace = d.String()
+		o.Pool = d.I64()
+		// older versions encoded the pool of the minimum object as INT64_MIN
+		if o.Pool == math.MinInt64 && !o.Max && o.Name == "" {
+			o.Pool = -1
+		}
+	}
+	d.Finish()
+	return o
+}
+
+// MarshalDenc implements the Marshaler interface.
+func (o HObject) MarshalDenc(e *Encoder) error {
+	e.HObject(o)
+	return nil
+}
+
+// UnmarshalDenc implements the Unmarshaler interface.
+func (o *HObject) UnmarshalDenc(d *Decoder) error {
+	*o = d.HObject()
+	return d.Err()
+}
@@ -0,0 +1,510 @@
+//go:build ceph_preview
+
+package denc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrUnsupportedType is returned when a value of a type can not be
+	// encoded or decoded.
+	ErrUnsupportedType = errors.New("denc: unsupported type")
+	// ErrInvalidTag is returned when the denc struct tag of a field is not
+	// valid.
+	ErrInvalidTag = errors.New("denc: invalid struct tag")
+)
+
+// Marshaler is implemented by types that encode themselves.
+type Marshaler interface {
+	MarshalDenc(e *Encoder) error
+}
+
+// Unmarshaler is implemented by types that decode themselves. Decoding errors
+// may be returned or left in the Decoder.
+type Unmarshaler interface {
+	UnmarshalDenc(d *Decoder) error
+}
+
+// Versioned marks a struct as a versioned struct when used as the type of a
+// (usually blank) field. The version and compat version are set with the
+// struct tag of the field, for example `denc:"version=2,compat=1"`.
+type Versioned struct{}
+
+var (
+	marshalerType   = reflect.TypeFor[Marshaler]()
+	unmarshalerType = reflect.TypeFor[Unmarshaler]()
+	versionedType   = reflect.TypeFor[Versioned]()
+	timeType        = reflect.TypeFor[time.Time]()
+	durationType    = reflect.TypeFor[time.Duration]()
+)
+
+// Marshal returns the encoding of v.
+func Marshal(v any) ([]byte, error) {
+	e := NewEncoder()
+	if err := e.Encode(v); err != nil {
+		return nil, err
+	}
+	return e.Bytes(), nil
+}
+
+// Unmarshal decodes data into the value pointed to by v. Data following the
+// encoded value is ignored.
+func Unmarshal(data []byte, v any) error {
+	return NewDecoder(data).Decode(v)
+}
+
+// Encode encodes v. If v is a pointer the value it points to is encoded.
+func (e *Encoder) Encode(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	return e.encodeValue(rv)
+}
+
+// Decode decodes the next value into the value pointed to by v.
+func (d *Decoder) Decode(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("%w: Decode requires a non-nil pointer, got %T",
+			ErrUnsupportedType, v)
+	}
+	if err := d.decodeValue(rv.Elem()); err != nil {
+		d.fail(err)
+	}
+	return d.err
+}
+
+type structField struct {
+	index int
+	since uint8
+}
+
+type structInfo struct {
+	versioned bool
+	version   uint8
+	compat    uint8
+	fields    []structField
+}
+
+var structInfoCache sync.Map
+
+func parseVersionTag(tag string, si *structInfo) error {
+	for _, opt := range strings.Split(tag, ",") {
+		k, v, _ := strings.Cut(opt, "=")
+		n, err := strconv.ParseUint(v, 10, 8)
+		if err != nil {
+			return fmt.Errorf("%w: %q", ErrInvalidTag, tag)
+		}
+		switch k {
+		case "version":
+			si.version = uint8(n)
+		case "compat":
+			si.compat = uint8(n)
+		default:
+			return fmt.Errorf("%w: %q", ErrInvalidTag, tag)
+		}
+	}
+	if si.version == 0 || si.compat == 0 || si.compat > si.version {
+		return fmt.Errorf("%w: %q", ErrInvalidTag, tag)
+	}
+	return nil
+}
+
+func getStructInfo(t reflect.Type) (*structInfo, error) {
+	if si, ok := structInfoCache.Load(t); ok {
+		return si.(*structInfo), nil
+	}
+	si := &structInfo{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("denc")
+		if f.Type == versionedType {
+			if si.versioned {
+				return nil, fmt.Errorf("%w: %s has multiple Versioned fields",
+					ErrInvalidTag, t)
+			}
+			si.versioned = true
+			if err := parseVersionTag(tag, si); err != nil {
+				return nil, fmt.Errorf("%s: %w", t, err)
+			}
+			continue
+		}
+		if !f.IsExported() || tag == "-" {
+			continue
+		}
+		sf := structField{index: i}
+		if tag != "" {
+			k, v, _ := strings.Cut(tag, "=")
+			n, err := strconv.ParseUint(v, 10, 8)
+			if k != "since" || err != nil {
+				return nil, fmt.Errorf("%w: %s.%s: %q", ErrInvalidTag, t, f.Name, tag)
+			}
+			sf.since = uint8(n)
+		}
+		si.fields = append(si.fields, sf)
+	}
+	for _, f := range si.fields {
+		if f.since > 0 && (!si.versioned || f.since > si.version) {
+			return nil, fmt.Errorf("%w: %s.%s: since=%d does not match struct version",
+				ErrInvalidTag, t, t.Field(f.index).Name, f.since)
+		}
+	}
+	v, _ := structInfoCache.LoadOrStore(t, si)
+	return v.(*structInfo), nil
+}
+
+func (e *Encoder) encodeValue(v reflect.Value) error {
+	if !v.IsValid() {
+		return fmt.Errorf("%w: nil", ErrUnsupportedType)
+	}
+	t := v.Type()
+	if t.Kind() != reflect.Pointer && t.Implements(marshalerType) {
+		return v.Interface().(Marshaler).MarshalDenc(e)
+	}
+	if t.Kind() != reflect.Pointer && reflect.PointerTo(t).Implements(marshalerType) {
+		p := reflect.New(t)
+		p.Elem().Set(v)
+		return p.Interface().(Marshaler).MarshalDenc(e)
+	}
+	switch t {
+	case timeType:
+		e.Time(v.Interface().(time.Time))
+		return nil
+	case durationType:
+		e.Duration(time.Duration(v.Int()))
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		e.Bool(v.Bool())
+	case reflect.Int8:
+		e.I8(int8(v.Int()))
+	case reflect.Int16:
+		e.I16(int16(v.Int()))
+	case reflect.Int32:
+		e.I32(int32(v.Int()))
+	case reflect.Int64:
+		e.I64(v.Int())
+	case reflect.Uint8:
+		e.U8(uint8(v.Uint()))
+	case reflect.Uint16:
+		e.U16(uint16(v.Uint()))
+	case reflect.Uint32:
+		e.U32(uint32(v.Uint()))
+	case reflect.Uint64:
+		e.U64(v.Uint())
+	case reflect.Float32:
+		e.F32(float32(v.Float()))
+	case reflect.Float64:
+		e.F64(v.Float())
+	case reflect.String:
+		e.String(v.String())
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			e.Blob(v.Bytes())
+			return nil
+		}
+		e.U32(uint32(v.Len()))
+		return e.encodeElems(v)
+	case reflect.Array:
+		return e.encodeElems(v)
+	case reflect.Map:
+		return e.encodeMap(v)
+	case reflect.Pointer:
+		e.Bool(!v.IsNil())
+		if v.IsNil() {
+			return nil
+		}
+		return e.encodeValue(v.Elem())
+	case reflect.Struct:
+		return e.encodeStruct(v)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedType, t)
+	}
+	return nil
+}
+
+func (e *Encoder) encodeElems(v reflect.Value) error {
+	for i := 0; i < v.Len(); i++ {
+		if err := e.encodeValue(v.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *Encoder) encodeMap(v reflect.Value) error {
+	type entry struct {
+		key, val []byte
+	}
+	entries := make([]entry, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		ke := NewEncoder()
+		if err := ke.encodeValue(iter.Key()); err != nil {
+			return err
+		}
+		ve := NewEncoder()
+		if err := ve.encodeValue(iter.Value()); err != nil {
+			return err
+		}
+		entries = append(entries, entry{ke.Bytes(), ve.Bytes()})
+	}
+	less := keyLess(v.Type().Key())
+	sort.Slice(entries, func(i, j int) bool {
+		return less(entries[i].key, entries[j].key)
+	})
+	e.U32(uint32(len(entries)))
+	for _, en := range entries {
+		e.Raw(en.key)
+		e.Raw(en.val)
+	}
+	return nil
+}
+
+// keyLess returns a function ordering encoded map keys of type t the way a
+// std::map orders them.
+func keyLess(t reflect.Type) func(a, b []byte) bool {
+	custom := t == timeType || t == durationType ||
+		t.Implements(marshalerType) || reflect.PointerTo(t).Implements(marshalerType)
+	if custom {
+		return func(a, b []byte) bool {
+			return bytes.Compare(a, b) < 0
+		}
+	}
+	switch t.Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(a, b []byte) bool {
+			return NewDecoder(a).signed(len(a)) < NewDecoder(b).signed(len(b))
+		}
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return func(a, b []byte) bool {
+			return NewDecoder(a).unsigned(len(a)) < NewDecoder(b).unsigned(len(b))
+		}
+	case reflect.String:
+		// skip the length prefix
+		return func(a, b []byte) bool {
+			return bytes.Compare(a[4:], b[4:]) < 0
+		}
+	}
+	return func(a, b []byte) bool {
+		return bytes.Compare(a, b) < 0
+	}
+}
+
+func (d *Decoder) unsigned(size int) uint64 {
+	switch size {
+	case 1:
+		return uint64(d.U8())
+	case 2:
+		return uint64(d.U16())
+	case 4:
+		return uint64(d.U32())
+	}
+	return d.U64()
+}
+
+func (d *Decoder) signed(size int) int64 {
+	switch size {
+	case 1:
+		return int64(d.I8())
+	case 2:
+		return int64(d.I16())
+	case 4:
+		return int64(d.I32())
+	}
+	return d.I64()
+}
+
+func (e *Encoder) encodeStruct(v reflect.Value) error {
+	si, err := getStructInfo(v.Type())
+	if err != nil {
+		return err
+	}
+	if si.versioned {
+		e.Start(si.version, si.compat)
+	}
+	for _, f := range si.fields {
+		if err := e.encodeValue(v.Field(f.index)); err != nil {
+			return err
+		}
+	}
+	if si.versioned {
+		e.Finish()
+	}
+	return nil
+}
+
+func (d *Decoder) decodeValue(v reflect.Value) error {
+	if d.err != nil {
+		return d.err
+	}
+	t := v.Type()
+	if t.Kind() != reflect.Pointer && reflect.PointerTo(t).Implements(unmarshalerType) {
+		return d.unmarshal(v.Addr().Interface().(Unmarshaler))
+	}
+	switch t {
+	case timeType:
+		v.Set(reflect.ValueOf(d.Time()))
+		return nil
+	case durationType:
+		v.SetInt(int64(d.Duration()))
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		v.SetBool(d.Bool())
+	case reflect.Int8:
+		v.SetInt(int64(d.I8()))
+	case reflect.Int16:
+		v.SetInt(int64(d.I16()))
+	case reflect.Int32:
+		v.SetInt(int64(d.I32()))
+	case reflect.Int64:
+		v.SetInt(d.I64())
+	case reflect.Uint8:
+		v.SetUint(uint64(d.U8()))
+	case reflect.Uint16:
+		v.SetUint(uint64(d.U16()))
+	case reflect.Uint32:
+		v.SetUint(uint64(d.U32()))
+	case reflect.Uint64:
+		v.SetUint(d.U64())
+	case reflect.Float32:
+		v.SetFloat(float64(d.F32()))
+	case reflect.Float64:
+		v.SetFloat(d.F64())
+	case reflect.String:
+		v.SetString(d.String())
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			v.SetBytes(d.Blob())
+			return d.err
+		}
+		n := d.Count(minSize(t.Elem()))
+		s := reflect.MakeSlice(t, n, n)
+		for i := 0; i < n; i++ {
+			if err := d.decodeValue(s.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := d.decodeValue(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		return d.decodeMap(v)
+	case reflect.Pointer:
+		if !d.Bool() {
+			v.SetZero()
+			return d.err
+		}
+		p := reflect.New(t.Elem())
+		if err := d.decodeValue(p.Elem()); err != nil {
+			return err
+		}
+		v.Set(p)
+	case reflect.Struct:
+		return d.decodeStruct(v)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedType, t)
+	}
+	return d.err
+}
+
+func (d *Decoder) unmarshal(u Unmarshaler) error {
+	if err := u.UnmarshalDenc(d); err != nil {
+		d.fail(err)
+	}
+	return d.err
+}
+
+func (d *Decoder) decodeMap(v reflect.Value) error {
+	t := v.Type()
+	n := d.Count(minSize(t.Key()) + minSize(t.Elem()))
+	m := reflect.MakeMapWithSize(t, n)
+	for i := 0; i < n; i++ {
+		k := reflect.New(t.Key()).Elem()
+		if err := d.decodeValue(k); err != nil {
+			return err
+		}
+		e := reflect.New(t.Elem()).Elem()
+		if err := d.decodeValue(e); err != nil {
+			return err
+		}
+		m.SetMapIndex(k, e)
+	}
+	v.Set(m)
+	return d.err
+}
+
+func (d *Decoder) decodeStruct(v reflect.Value) error {
+	si, err := getStructInfo(v.Type())
+	if err != nil {
+		return err
+	}
+	version := uint8(0)
+	if si.versioned {
+		version = d.Start(si.version)
+	}
+	for _, f := range si.fields {
+		if f.since > version {
+			continue
+		}
+		if err := d.decodeValue(v.Field(f.index)); err != nil {
+			return err
+		}
+	}
+	if si.versioned {
+		d.Finish()
+	}
+	return d.err
+}
+
+// minSize returns the minimum number of bytes a value of type t is encoded
+// in, but at least one. It is used to reject element counts that can not fit
+// into the remaining data.
+func minSize(t reflect.Type) int {
+	n := 0
+	switch t {
+	case timeType, durationType:
+		return 8
+	}
+	if !reflect.PointerTo(t).Implements(unmarshalerType) {
+		switch t.Kind() {
+		case reflect.Int8, reflect.Uint8, reflect.Bool, reflect.Pointer:
+			n = 1
+		case reflect.Int16, reflect.Uint16:
+			n = 2
+		case reflect.Int32, reflect.Uint32, reflect.Float32,
+			reflect.String, reflect.Slice, reflect.Map:
+			n = 4
+		case reflect.Int64, reflect.Uint64, reflect.Float64:
+			n = 8
+		case reflect.Array:
+			if t.Len() > 0 {
+				n = t.Len() * minSize(t.Elem())
+			}
+		case reflect.Struct:
+			if si, err := getStructInfo(t); err == nil && si.versioned {
+				n = 6
+			}
+		}
+	}
+	return max(n, 1)
+}
@@ -0,0 +1,227 @@
+//go:build ceph_preview
+
+package denc
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testHeaderV1 struct {
+	_      Versioned `denc:"version=1,compat=1"`
+	Marker string
+	Time   time.Time
+}
+
+type testHeaderV2 struct {
+	_       Versioned `denc:"version=2,compat=1"`
+	Marker  string
+	Time    time.Time
+	Pending uint32 `denc:"since=2"`
+}
+
+type testPlain struct {
+	A     uint16
+	B     int8
+	skip  int    //nolint:unused
+	C     string `denc:"-"`
+	Ratio float32
+}
+
+type testAll struct {
+	_        Versioned `denc:"version=3,compat=2"`
+	U8       uint8
+	U16      uint16
+	U32      uint32
+	U64      uint64
+	I8       int8
+	I16      int16
+	I32      int32
+	I64      int64
+	F32      float32
+	F64      float64
+	Bool     bool
+	Str      string
+	Blob     []byte
+	Strs     []string
+	Arr      [3]uint16
+	Map      map[string]uint64
+	IntMap   map[int32]string
+	Opt      *uint32
+	OptNil   *testPlain
+	Time     time.Time
+	Dur      time.Duration
+	Plain    testPlain
+	Nested   []testHeaderV2
+	Entity   EntityName
+	Addr     EntityAddr
+	Object   HObject
+	ObjPtr   *HObject
+	ObjSlice []HObject
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	opt := uint32(17)
+	in := testAll{
+		U8: 1, U16: 2, U32: 3, U64: 4,
+		I8: -1, I16: -2, I32: -3, I64: -4,
+		F32: 0.5, F64: 1.25,
+		Bool:   true,
+		Str:    "str",
+		Blob:   []byte{1, 2, 3},
+		Strs:   []string{"a", "b"},
+		Arr:    [3]uint16{7, 8, 9},
+		Map:    map[string]uint64{"z": 1, "a": 2},
+		IntMap: map[int32]string{-1: "neg", 5: "pos"},
+		Opt:    &opt,
+		Time:   time.Unix(1700000000, 500),
+		Dur:    1500 * time.Millisecond,
+		Plain:  testPlain{A: 1, B: 2, Ratio: 0.25},
+		Nested: []testHeaderV2{{Marker: "m", Pending: 3}},
+		Entity: EntityName{Type: EntityTypeOSD, Num: 3},
+		Addr: EntityAddr{
+			Type: AddrTypeMsgr2, Nonce: 9, IP: net.IPv4(1, 2, 3, 4).To4(), Port: 1,
+		},
+		Object:   HObject{Name: "obj", Snap: SnapHead, Hash: 0xabc, Pool: 2},
+		ObjPtr:   &HObject{Name: "ptr", Pool: 1},
+		ObjSlice: []HObject{{Name: "x"}, {Name: "y"}},
+	}
+	b, err := Marshal(&in)
+	require.NoError(t, err)
+	b2, err := Marshal(in)
+	require.NoError(t, err)
+	assert.Equal(t, b, b2)
+
+	var out testAll
+	require.NoError(t, Unmarshal(b, &out))
+	assert.True(t, in.Time.Equal(out.Time))
+	out.Time = in.Time
+	assert.Equal(t, in, out)
+}
+
+func TestMarshalFormat(t *testing.T) {
+	t.Run("plain", func(t *testing.T) {
+		b, err := Marshal(testPlain{A: 0x102, B: -1, C: "ignored", Ratio: 1})
+		require.NoError(t, err)
+		assert.Equal(t, []byte{2, 1, 0xff, 0, 0, 0x80, 0x3f}, b)
+	})
+
+	t.Run("versioned", func(t *testing.T) {
+		b, err := Marshal(testHeaderV1{Marker: "m"})
+		require.NoError(t, err)
+		assert.Equal(t, []byte{
+			1, 1, 13, 0, 0, 0,
+			1, 0, 0, 0, 'm',
+			0, 0, 0, 0, 0, 0, 0, 0,
+		}, b)
+	})
+
+	t.Run("mapOrder", func(t *testing.T) {
+		b, err := Marshal(map[int16]bool{300: true, -1: false, 2: true})
+		require.NoError(t, err)
+		assert.Equal(t, []byte{
+			3, 0, 0, 0,
+			0xff, 0xff, 0,
+			2, 0, 1,
+			0x2c, 1, 1,
+		}, b)
+	})
+
+	t.Run("optional", func(t *testing.T) {
+		v := uint8(5)
+		b, err := Marshal(struct{ A, B *uint8 }{A: &v})
+		require.NoError(t, err)
+		assert.Equal(t, []byte{1, 5, 0}, b)
+	})
+}
+
+func TestVersionCompat(t *testing.T) {
+	t.Run("newerEncoder", func(t *testing.T) {
+		b, err := Marshal(testHeaderV2{Marker: "m", Pending: 5})
+		require.NoError(t, err)
+		b = append(b, 0xaa)
+		d := NewDecoder(b)
+		var h testHeaderV1
+		require.NoError(t, d.Decode(&h))
+		assert.Equal(t, "m", h.Marker)
+		// the unknown field was skipped
+		assert.EqualValues(t, 0xaa, d.U8())
+	})
+
+	t.Run("olderEncoder", func(t *testing.T) {
+		b, err := Marshal(testHeaderV1{Marker: "m"})
+		require.NoError(t, err)
+		h := testHeaderV2{Pending: 7}
+		require.NoError(t, Unmarshal(b, &h))
+		assert.Equal(t, "m", h.Marker)
+		assert.EqualValues(t, 7, h.Pending)
+	})
+
+	t.Run("incompatible", func(t *testing.T) {
+		b, err := Marshal(testAll{})
+		require.NoError(t, err)
+		var h testHeaderV1
+		assert.ErrorIs(t, Unmarshal(b, &h), ErrIncompatible)
+	})
+}
+
+func TestMarshalErrors(t *testing.T) {
+	_, err := Marshal(struct{ A int }{})
+	assert.ErrorIs(t, err, ErrUnsupportedType)
+	_, err = Marshal(nil)
+	assert.ErrorIs(t, err, ErrUnsupportedType)
+
+	var s struct{ A int }
+	assert.ErrorIs(t, Unmarshal([]byte{1, 2, 3, 4, 5, 6, 7, 8}, &s), ErrUnsupportedType)
+	assert.ErrorIs(t, Unmarshal(nil, s), ErrUnsupportedType)
+
+	type badVersion struct {
+		_ Versioned `denc:"version=1,compat=2"`
+	}
+	_, err = Marshal(badVersion{})
+	assert.ErrorIs(t, err, ErrInvalidTag)
+
+	type badSince struct {
+		_ Versioned `denc:"version=1,compat=1"`
+		A uint8     `denc:"since=2"`
+	}
+	_, err = Marshal(badSince{})
+	assert.ErrorIs(t, err, ErrInvalidTag)
+
+	type badOption struct {
+		A uint8 `denc:"bogus"`
+	}
+	_, err = Marshal(badOption{})
+	assert.ErrorIs(t, err, ErrInvalidTag)
+
+	var l []string
+	assert.ErrorIs(t, Unmarshal([]byte{0xff, 0xff, 0xff, 0xff}, &l), ErrShortBuffer)
+}
+
+func TestHObject(t *testing.T) {
+	o := HObject{
+		Key:       "key",
+		Name:      "name",
+		Snap:      SnapDir,
+		Hash:      0x12345678,
+		Namespace: "ns",
+		Pool:      7,
+	}
+	e := NewEncoder()
+	e.HObject(o)
+	b := e.Bytes()
+	assert.Equal(t, []byte{4, 3}, b[:2])
+	d := NewDecoder(b)
+	assert.Equal(t, o, d.HObject())
+	assert.NoError(t, d.Err())
+
+	e = NewEncoder()
+	e.HObject(HObject{Pool: -1 << 63})
+	var minObj HObject
+	require.NoError(t, Unmarshal(e.Bytes(), &minObj))
+	assert.EqualValues(t, -1, minObj.Pool)
+}
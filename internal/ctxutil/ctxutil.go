@@ -0,0 +1,33 @@
+// Package ctxutil helps to make blocking calls into the Ceph C libraries
+// responsive to the cancellation and deadlines of a context.Context.
+package ctxutil
+
+import (
+	"context"
+)
+
+// Do calls fn in a new goroutine and waits for it to return or for ctx to be
+// done, whichever happens first. If fn returns first its error is returned.
+// Otherwise ctx.Err() is returned and fn is left running. Once the abandoned
+// fn returns, abandon, if not nil, is called with fn's error so that the
+// caller can undo or clean up the effects of the call.
+func Do(ctx context.Context, fn func() error, abandon func(error)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- fn()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+	}
+	if abandon != nil {
+		go func() {
+			abandon(<-done)
+		}()
+	}
+	return ctx.Err()
+}
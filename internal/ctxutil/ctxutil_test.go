@@ -0,0 +1,46 @@
+package ctxutil
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDo(t *testing.T) {
+	t.Run("returns", func(t *testing.T) {
+		errFoo := errors.New("foo")
+		err := Do(context.Background(), func() error { return errFoo }, nil)
+		assert.ErrorIs(t, err, errFoo)
+		err = Do(context.Background(), func() error { return nil }, nil)
+		assert.NoError(t, err)
+	})
+	t.Run("alreadyCanceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		called := false
+		err := Do(ctx, func() error { called = true; return nil }, nil)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.False(t, called)
+	})
+	t.Run("abandoned", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		release := make(chan struct{})
+		abandoned := make(chan error)
+		errFoo := errors.New("foo")
+		err := Do(ctx,
+			func() error {
+				<-release
+				return errFoo
+			},
+			func(err error) {
+				abandoned <- err
+			})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		close(release)
+		assert.ErrorIs(t, <-abandoned, errFoo)
+	})
+}
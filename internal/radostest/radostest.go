@@ -0,0 +1,79 @@
+/*
+Package radostest provides a pool that is shared by the tests of a package.
+*/
+package radostest
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ceph/go-ceph/internal/admintest"
+	"github.com/ceph/go-ceph/rados"
+)
+
+// Pool is a pool with a random name that is created when it is first used.
+// It is deleted by Run once all tests of the package have run, so a package
+// using a Pool must run its tests with Run from TestMain:
+//
+//	var testPool radostest.Pool
+//
+//	func TestMain(m *testing.M) {
+//		os.Exit(testPool.Run(m))
+//	}
+type Pool struct {
+	// NoNamespaces makes IOContext return IOContexts of the default
+	// namespace, for example for rbd tests, as rbd requires namespaces to
+	// be created before they can be used.
+	NoNamespaces bool
+
+	mutex sync.Mutex
+	conn  *rados.Conn
+	name  string
+}
+
+// Run runs the tests and deletes the pool afterwards. It returns the exit
+// code to pass to os.Exit.
+func (p *Pool) Run(m *testing.M) int {
+	code := m.Run()
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.conn == nil {
+		return code
+	}
+	if err := p.conn.DeletePool(p.name); err != nil && code == 0 {
+		code = 1
+	}
+	p.conn.Shutdown()
+	p.conn = nil
+	return code
+}
+
+// IOContext returns an IOContext of the pool, creating the pool if needed.
+// Unless NoNamespaces is set, the IOContext uses a namespace named after
+// the test, so that tests do not see each other's objects. The IOContext is
+// destroyed when the test finishes.
+func (p *Pool) IOContext(t *testing.T) *rados.IOContext {
+	t.Helper()
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.conn == nil {
+		conn := admintest.NewConn(t)
+		name := uuid.Must(uuid.NewV4()).String()
+		if err := conn.MakePool(name); err != nil {
+			conn.Shutdown()
+			require.NoError(t, err)
+		}
+		p.conn = conn
+		p.name = name
+	}
+	ioctx, err := p.conn.OpenIOContext(p.name)
+	require.NoError(t, err)
+	t.Cleanup(ioctx.Destroy)
+	if !p.NoNamespaces {
+		ioctx.SetNamespace(t.Name())
+	}
+	return ioctx
+}
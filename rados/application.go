@@ -0,0 +1,223 @@
+//go:build ceph_preview
+
+package rados
+
+// #cgo LDFLAGS: -lrados
+// #include <stdlib.h>
+// #include <rados/librados.h>
+import "C"
+
+import (
+	"unsafe"
+
+	"github.com/ceph/go-ceph/internal/cutil"
+	"github.com/ceph/go-ceph/internal/retry"
+)
+
+// Well known pool application names, for use with EnableApplication.
+const (
+	// ApplicationRBD is the application name used by RBD pools.
+	ApplicationRBD = "rbd"
+	// ApplicationCephFS is the application name used by CephFS pools.
+	ApplicationCephFS = "cephfs"
+	// ApplicationRGW is the application name used by RGW pools.
+	ApplicationRGW = "rgw"
+)
+
+// EnableApplication associates the named application with the pool of the
+// IOContext. Unless force is true, enabling an application on a pool that
+// already has a different application enabled fails.
+//
+// Implements:
+//
+//	int rados_application_enable(rados_ioctx_t io, const char *app_name,
+//	                             int force);
+func (ioctx *IOContext) EnableApplication(appName string, force bool) error {
+	if err := ioctx.validate(); err != nil {
+		return err
+	}
+
+	cAppName := C.CString(appName)
+	defer C.free(unsafe.Pointer(cAppName))
+
+	var cForce C.int
+	if force {
+		cForce = 1
+	}
+	ret := C.rados_application_enable(ioctx.ioctx, cAppName, cForce)
+	return getError(ret)
+}
+
+// ListApplications returns the names of the applications enabled on the pool
+// of the IOContext.
+//
+// Implements:
+//
+//	int rados_application_list(rados_ioctx_t io, char *values,
+//	                           size_t *values_len);
+func (ioctx *IOContext) ListApplications() ([]string, error) {
+	if err := ioctx.validate(); err != nil {
+		return nil, err
+	}
+
+	var (
+		err  error
+		buf  []byte
+		cLen C.size_t
+	)
+	retry.WithSizes(256, 1<<16, func(size int) retry.Hint {
+		buf = make([]byte, size)
+		cLen = C.size_t(size)
+		ret := C.rados_application_list(
+			ioctx.ioctx,
+			(*C.char)(unsafe.Pointer(&buf[0])),
+			&cLen)
+		err = getError(ret)
+		return retry.Size(int(cLen)).If(err == errRange)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cutil.SplitSparseBuffer(buf[:cLen]), nil
+}
+
+// GetApplicationMetadata returns the value of the metadata key of the named
+// application enabled on the pool of the IOContext.
+//
+// Implements:
+//
+//	int rados_application_metadata_get(rados_ioctx_t io,
+//	                                   const char *app_name,
+//	                                   const char *key, char *value,
+//	                                   size_t *value_len);
+func (ioctx *IOContext) GetApplicationMetadata(appName, key string) (string, error) {
+	if err := ioctx.validate(); err != nil {
+		return "", err
+	}
+
+	cAppName := C.CString(appName)
+	defer C.free(unsafe.Pointer(cAppName))
+	cKey := C.CString(key)
+	defer C.free(unsafe.Pointer(cKey))
+
+	var (
+		err  error
+		buf  []byte
+		cLen C.size_t
+	)
+	retry.WithSizes(256, 1<<16, func(size int) retry.Hint {
+		buf = make([]byte, size)
+		cLen = C.size_t(size)
+		ret := C.rados_application_metadata_get(
+			ioctx.ioctx,
+			cAppName,
+			cKey,
+			(*C.char)(unsafe.Pointer(&buf[0])),
+			&cLen)
+		err = getError(ret)
+		return retry.Size(int(cLen)).If(err == errRange)
+	})
+	if err != nil {
+		return "", err
+	}
+	return C.GoString((*C.char)(unsafe.Pointer(&buf[0]))), nil
+}
+
+// SetApplicationMetadata sets the metadata key of the named application
+// enabled on the pool of the IOContext to value.
+//
+// Implements:
+//
+//	int rados_application_metadata_set(rados_ioctx_t io,
+//	                                   const char *app_name,
+//	                                   const char *key, const char *value);
+func (ioctx *IOContext) SetApplicationMetadata(appName, key, value string) error {
+	if err := ioctx.validate(); err != nil {
+		return err
+	}
+
+	cAppName := C.CString(appName)
+	defer C.free(unsafe.Pointer(cAppName))
+	cKey := C.CString(key)
+	defer C.free(unsafe.Pointer(cKey))
+	cValue := C.CString(value)
+	defer C.free(unsafe.Pointer(cValue))
+
+	ret := C.rados_application_metadata_set(
+		ioctx.ioctx, cAppName, cKey, cValue)
+	return getError(ret)
+}
+
+// RemoveApplicationMetadata removes the metadata key of the named application
+// enabled on the pool of the IOContext.
+//
+// Implements:
+//
+//	int rados_application_metadata_remove(rados_ioctx_t io,
+//	                                      const char *app_name,
+//	                                      const char *key);
+func (ioctx *IOContext) RemoveApplicationMetadata(appName, key string) error {
+	if err := ioctx.validate(); err != nil {
+		return err
+	}
+
+	cAppName := C.CString(appName)
+	defer C.free(unsafe.Pointer(cAppName))
+	cKey := C.CString(key)
+	defer C.free(unsafe.Pointer(cKey))
+
+	ret := C.rados_application_metadata_remove(ioctx.ioctx, cAppName, cKey)
+	return getError(ret)
+}
+
+// ListApplicationMetadata returns all metadata keys and values of the named
+// application enabled on the pool of the IOContext.
+//
+// Implements:
+//
+//	int rados_application_metadata_list(rados_ioctx_t io,
+//	                                    const char *app_name,
+//	                                    char *keys, size_t *key_len,
+//	                                    char *values, size_t *vals_len);
+func (ioctx *IOContext) ListApplicationMetadata(appName string) (map[string]string, error) {
+	if err := ioctx.validate(); err != nil {
+		return nil, err
+	}
+
+	cAppName := C.CString(appName)
+	defer C.free(unsafe.Pointer(cAppName))
+
+	var (
+		err            error
+		keys, vals     []byte
+		cKeyLen, cVLen C.size_t
+	)
+	retry.WithSizes(256, 1<<20, func(size int) retry.Hint {
+		keys = make([]byte, size)
+		vals = make([]byte, size)
+		cKeyLen = C.size_t(size)
+		cVLen = C.size_t(size)
+		ret := C.rados_application_metadata_list(
+			ioctx.ioctx,
+			cAppName,
+			(*C.char)(unsafe.Pointer(&keys[0])),
+			&cKeyLen,
+			(*C.char)(unsafe.Pointer(&vals[0])),
+			&cVLen)
+		err = getError(ret)
+		return retry.Size(max(int(cKeyLen), int(cVLen))).If(err == errRange)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	keyList := cutil.SplitBuffer(keys[:cKeyLen])
+	valList := cutil.SplitBuffer(vals[:cVLen])
+	m := make(map[string]string, len(keyList))
+	for i := range keyList {
+		if i < len(valList) {
+			m[keyList[i]] = valList[i]
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,76 @@
+//go:build ceph_preview
+
+package rados
+
+import (
+	"testing"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (suite *RadosTestSuite) TestPoolApplication() {
+	suite.SetupConnection()
+
+	pool := uuid.Must(uuid.NewV4()).String()
+	require.NoError(suite.T(), suite.conn.MakePool(pool))
+	defer func() {
+		assert.NoError(suite.T(), suite.conn.DeletePool(pool))
+	}()
+	ioctx, err := suite.conn.OpenIOContext(pool)
+	require.NoError(suite.T(), err)
+	defer ioctx.Destroy()
+
+	suite.T().Run("invalidIOContext", func(t *testing.T) {
+		ioctx := &IOContext{}
+		err := ioctx.EnableApplication("app", false)
+		assert.Equal(t, ErrInvalidIOContext, err)
+		_, err = ioctx.ListApplications()
+		assert.Equal(t, ErrInvalidIOContext, err)
+		_, err = ioctx.ListApplicationMetadata("app")
+		assert.Equal(t, ErrInvalidIOContext, err)
+	})
+
+	suite.T().Run("enable", func(t *testing.T) {
+		apps, err := ioctx.ListApplications()
+		assert.NoError(t, err)
+		assert.Len(t, apps, 0)
+
+		require.NoError(t, ioctx.EnableApplication("gotest", false))
+		apps, err = ioctx.ListApplications()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"gotest"}, apps)
+
+		// a second application needs to be forced
+		err = ioctx.EnableApplication("gotest2", false)
+		assert.Error(t, err)
+		require.NoError(t, ioctx.EnableApplication("gotest2", true))
+		apps, err = ioctx.ListApplications()
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{"gotest", "gotest2"}, apps)
+	})
+
+	suite.T().Run("metadata", func(t *testing.T) {
+		md, err := ioctx.ListApplicationMetadata("gotest")
+		assert.NoError(t, err)
+		assert.Len(t, md, 0)
+
+		require.NoError(t, ioctx.SetApplicationMetadata("gotest", "k1", "v1"))
+		require.NoError(t, ioctx.SetApplicationMetadata("gotest", "k2", ""))
+		v, err := ioctx.GetApplicationMetadata("gotest", "k1")
+		assert.NoError(t, err)
+		assert.Equal(t, "v1", v)
+
+		md, err = ioctx.ListApplicationMetadata("gotest")
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{"k1": "v1", "k2": ""}, md)
+
+		require.NoError(t, ioctx.RemoveApplicationMetadata("gotest", "k1"))
+		_, err = ioctx.GetApplicationMetadata("gotest", "k1")
+		assert.ErrorIs(t, err, ErrNotFound)
+		md, err = ioctx.ListApplicationMetadata("gotest")
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{"k2": ""}, md)
+	})
+}
@@ -0,0 +1,60 @@
+//go:build ceph_preview
+
+/*
+Package cls contains helpers shared by the typed object class clients in the
+sub-packages of rados/cls.
+
+Object classes are methods that run on the OSDs and are invoked with the Exec
+methods of rados.ReadOp and rados.WriteOp. Their input and output use Ceph's
+binary encoding. The sub-packages, such as rados/cls/lock or
+rados/cls/version, take care of the encoding and add the method calls to
+existing operations, so that they can be combined with other steps of a read
+or write operation.
+*/
+package cls
+
+import (
+	"github.com/ceph/go-ceph/rados"
+)
+
+// Op is the type constraint of operations that object class methods can be
+// added to.
+type Op interface {
+	*rados.ReadOp | *rados.WriteOp
+}
+
+// Exec adds the call of an object class method to a read or write operation.
+// The output of the method, if any, is discarded.
+func Exec[T Op](op T, clsName, method string, in []byte) {
+	switch o := any(op).(type) {
+	case *rados.ReadOp:
+		o.Exec(clsName, method, in)
+	case *rados.WriteOp:
+		o.Exec(clsName, method, in)
+	}
+}
+
+// Step is the result of an object class method that was added to a read
+// operation. The result is available once the operation was performed.
+type Step[T any] struct {
+	es     *rados.ReadOpExecStep
+	decode func([]byte) (T, error)
+}
+
+// NewStep returns a Step that decodes the output of es with the decode
+// function.
+func NewStep[T any](es *rados.ReadOpExecStep, decode func([]byte) (T, error)) *Step[T] {
+	return &Step[T]{es: es, decode: decode}
+}
+
+// Result returns the decoded output of the object class method. It returns
+// rados.ErrOperationIncomplete if the operation has not been performed or the
+// method failed.
+func (s *Step[T]) Result() (T, error) {
+	b, err := s.es.Bytes()
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return s.decode(b)
+}
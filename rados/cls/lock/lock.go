@@ -0,0 +1,202 @@
+//go:build ceph_preview
+
+/*
+Package lock is a client of the "lock" object class (cls_lock), which
+implements advisory locks on RADOS objects.
+*/
+package lock
+
+import (
+	"time"
+
+	"github.com/ceph/go-ceph/encoding/denc"
+	"github.com/ceph/go-ceph/rados"
+	"github.com/ceph/go-ceph/rados/cls"
+)
+
+// ClassName is the name of the object class.
+const ClassName = "lock"
+
+// Type is the type of a lock.
+type Type uint8
+
+const (
+	// TypeNone is the type of an object that is not locked.
+	TypeNone = Type(0)
+	// TypeExclusive locks can be held by a single locker.
+	TypeExclusive = Type(1)
+	// TypeShared locks can be held by multiple lockers.
+	TypeShared = Type(2)
+	// TypeExclusiveEphemeral locks are exclusive locks whose object is
+	// removed when the lock is released.
+	TypeExclusiveEphemeral = Type(3)
+)
+
+// Flags modify how a lock is acquired.
+type Flags uint8
+
+const (
+	// FlagMayRenew allows renewing a lock that is already held by the locker.
+	FlagMayRenew = Flags(1)
+	// FlagMustRenew fails the request unless the lock is already held by the
+	// locker.
+	FlagMustRenew = Flags(2)
+)
+
+// Request describes a lock to acquire.
+type Request struct {
+	Name        string
+	Type        Type
+	Cookie      string
+	Tag         string
+	Description string
+	// Duration is the time after which the lock expires. A zero duration
+	// never expires.
+	Duration time.Duration
+	Flags    Flags
+}
+
+// Locker identifies a holder of a lock.
+type Locker struct {
+	// Entity is the name of the client holding the lock, such as
+	// "client.4123".
+	Entity string
+	Cookie string
+}
+
+// LockerInfo describes a holder of a lock.
+type LockerInfo struct {
+	Locker
+	// Expiration is the time the lock expires. It is the zero time for locks
+	// that do not expire.
+	Expiration  time.Time
+	Address     string
+	Description string
+}
+
+// Info describes a lock and its holders.
+type Info struct {
+	Type    Type
+	Tag     string
+	Lockers []LockerInfo
+}
+
+// Lock adds the acquisition of a lock to the write operation.
+func Lock(op *rados.WriteOp, r Request) {
+	e := denc.NewEncoder()
+	e.Start(1, 1)
+	e.String(r.Name)
+	e.U8(uint8(r.Type))
+	e.String(r.Cookie)
+	e.String(r.Tag)
+	e.String(r.Description)
+	e.Duration(r.Duration)
+	e.U8(uint8(r.Flags))
+	e.Finish()
+	op.Exec(ClassName, "lock", e.Bytes())
+}
+
+// Unlock adds the release of a lock held by the client to the write
+// operation.
+func Unlock(op *rados.WriteOp, name, cookie string) {
+	e := denc.NewEncoder()
+	e.Start(1, 1)
+	e.String(name)
+	e.String(cookie)
+	e.Finish()
+	op.Exec(ClassName, "unlock", e.Bytes())
+}
+
+// BreakLock adds the release of a lock held by another client to the write
+// operation. It returns an error if the entity name of the locker is not
+// valid.
+func BreakLock(op *rados.WriteOp, name string, locker Locker) error {
+	entity, err := denc.ParseEntityName(locker.Entity)
+	if err != nil {
+		return err
+	}
+	e := denc.NewEncoder()
+	e.Start(1, 1)
+	e.String(name)
+	e.EntityName(entity)
+	e.String(locker.Cookie)
+	e.Finish()
+	op.Exec(ClassName, "break_lock", e.Bytes())
+	return nil
+}
+
+// SetCookie adds changing the cookie of a held lock to the write operation.
+func SetCookie(op *rados.WriteOp, name string, t Type, cookie, tag, newCookie string) {
+	e := denc.NewEncoder()
+	e.Start(1, 1)
+	e.String(name)
+	e.U8(uint8(t))
+	e.String(cookie)
+	e.String(tag)
+	e.String(newCookie)
+	e.Finish()
+	op.Exec(ClassName, "set_cookie", e.Bytes())
+}
+
+// AssertLocked adds a check that the client holds the lock to the operation.
+// The operation fails with EBUSY if the lock is not held.
+func AssertLocked[T cls.Op](op T, name string, t Type, cookie, tag string) {
+	e := denc.NewEncoder()
+	e.Start(1, 1)
+	e.String(name)
+	e.U8(uint8(t))
+	e.String(cookie)
+	e.String(tag)
+	e.Finish()
+	cls.Exec(op, ClassName, "assert_locked", e.Bytes())
+}
+
+// GetInfo adds retrieving the state of a lock to the read operation.
+func GetInfo(op *rados.ReadOp, name string) *cls.Step[*Info] {
+	e := denc.NewEncoder()
+	e.Start(1, 1)
+	e.String(name)
+	e.Finish()
+	return cls.NewStep(op.Exec(ClassName, "get_info", e.Bytes()), decodeInfo)
+}
+
+func decodeInfo(b []byte) (*Info, error) {
+	d := denc.NewDecoder(b)
+	info := &Info{}
+	d.Start(1)
+	n := d.Count(1)
+	for i := 0; i < n && d.Err() == nil; i++ {
+		var li LockerInfo
+		d.Start(1)
+		li.Entity = d.EntityName().String()
+		li.Cookie = d.String()
+		d.Finish()
+		d.Start(1)
+		li.Expiration = d.Time()
+		li.Address = d.EntityAddr().String()
+		li.Description = d.String()
+		d.Finish()
+		info.Lockers = append(info.Lockers, li)
+	}
+	info.Type = Type(d.U8())
+	info.Tag = d.String()
+	d.Finish()
+	if err := d.Err(); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// ListLocks adds listing the names of the locks of the object to the read
+// operation.
+func ListLocks(op *rados.ReadOp) *cls.Step[[]string] {
+	return cls.NewStep(op.Exec(ClassName, "list_locks", nil), decodeList)
+}
+
+func decodeList(b []byte) ([]string, error) {
+	d := denc.NewDecoder(b)
+	d.Start(1)
+	names := d.Strings()
+	d.Finish()
+	return names, d.Err()
+}
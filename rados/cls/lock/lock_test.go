@@ -0,0 +1,131 @@
+//go:build ceph_preview
+
+package lock
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ceph/go-ceph/internal/radostest"
+	"github.com/ceph/go-ceph/rados"
+)
+
+var testPool radostest.Pool
+
+func TestMain(m *testing.M) {
+	os.Exit(testPool.Run(m))
+}
+
+func TestLock(t *testing.T) {
+	ioctx := testPool.IOContext(t)
+	oid := "locked"
+
+	wop := rados.CreateWriteOp()
+	defer wop.Release()
+	wop.Create(rados.CreateIdempotent)
+	Lock(wop, Request{
+		Name:        "lck",
+		Type:        TypeExclusive,
+		Cookie:      "cookie1",
+		Tag:         "tag",
+		Description: "test lock",
+		Duration:    time.Hour,
+	})
+	require.NoError(t, wop.Operate(ioctx, oid, rados.OperationNoFlag))
+
+	t.Run("getInfo", func(t *testing.T) {
+		rop := rados.CreateReadOp()
+		defer rop.Release()
+		names := ListLocks(rop)
+		info := GetInfo(rop, "lck")
+		_, err := info.Result()
+		assert.ErrorIs(t, err, rados.ErrOperationIncomplete)
+		require.NoError(t, rop.Operate(ioctx, oid, rados.OperationNoFlag))
+
+		l, err := names.Result()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"lck"}, l)
+
+		i, err := info.Result()
+		require.NoError(t, err)
+		assert.Equal(t, TypeExclusive, i.Type)
+		assert.Equal(t, "tag", i.Tag)
+		require.Len(t, i.Lockers, 1)
+		li := i.Lockers[0]
+		assert.Contains(t, li.Entity, "client.")
+		assert.Equal(t, "cookie1", li.Cookie)
+		assert.Equal(t, "test lock", li.Description)
+		assert.True(t, li.Expiration.After(time.Now()))
+		assert.NotEqual(t, "-", li.Address)
+	})
+
+	t.Run("assertLocked", func(t *testing.T) {
+		wop := rados.CreateWriteOp()
+		defer wop.Release()
+		AssertLocked(wop, "lck", TypeExclusive, "cookie1", "tag")
+		wop.WriteFull([]byte("guarded"))
+		assert.NoError(t, wop.Operate(ioctx, oid, rados.OperationNoFlag))
+
+		rop := rados.CreateReadOp()
+		defer rop.Release()
+		AssertLocked(rop, "lck", TypeExclusive, "wrong", "tag")
+		assert.Error(t, rop.Operate(ioctx, oid, rados.OperationNoFlag))
+	})
+
+	t.Run("setCookie", func(t *testing.T) {
+		wop := rados.CreateWriteOp()
+		defer wop.Release()
+		SetCookie(wop, "lck", TypeExclusive, "cookie1", "tag", "cookie2")
+		require.NoError(t, wop.Operate(ioctx, oid, rados.OperationNoFlag))
+
+		rop := rados.CreateReadOp()
+		defer rop.Release()
+		AssertLocked(rop, "lck", TypeExclusive, "cookie2", "tag")
+		assert.NoError(t, rop.Operate(ioctx, oid, rados.OperationNoFlag))
+	})
+
+	t.Run("breakLock", func(t *testing.T) {
+		rop := rados.CreateReadOp()
+		defer rop.Release()
+		info := GetInfo(rop, "lck")
+		require.NoError(t, rop.Operate(ioctx, oid, rados.OperationNoFlag))
+		i, err := info.Result()
+		require.NoError(t, err)
+		require.Len(t, i.Lockers, 1)
+
+		wop := rados.CreateWriteOp()
+		defer wop.Release()
+		assert.Error(t, BreakLock(wop, "lck", Locker{Entity: "nobody"}))
+		require.NoError(t, BreakLock(wop, "lck", i.Lockers[0].Locker))
+		require.NoError(t, wop.Operate(ioctx, oid, rados.OperationNoFlag))
+
+		rop2 := rados.CreateReadOp()
+		defer rop2.Release()
+		names := ListLocks(rop2)
+		require.NoError(t, rop2.Operate(ioctx, oid, rados.OperationNoFlag))
+		l, err := names.Result()
+		require.NoError(t, err)
+		assert.Len(t, l, 0)
+	})
+
+	t.Run("unlock", func(t *testing.T) {
+		wop := rados.CreateWriteOp()
+		defer wop.Release()
+		Lock(wop, Request{Name: "shared", Type: TypeShared, Cookie: "c"})
+		require.NoError(t, wop.Operate(ioctx, oid, rados.OperationNoFlag))
+
+		wop2 := rados.CreateWriteOp()
+		defer wop2.Release()
+		Unlock(wop2, "shared", "c")
+		require.NoError(t, wop2.Operate(ioctx, oid, rados.OperationNoFlag))
+
+		wop3 := rados.CreateWriteOp()
+		defer wop3.Release()
+		Unlock(wop3, "shared", "c")
+		assert.ErrorIs(t, wop3.Operate(ioctx, oid, rados.OperationNoFlag), rados.ErrNotFound)
+	})
+}
@@ -0,0 +1,155 @@
+//go:build ceph_preview
+
+/*
+Package log is a client of the "log" object class (cls_log), which stores
+time ordered log entries in the omap of a RADOS object.
+*/
+package log
+
+import (
+	"time"
+
+	"github.com/ceph/go-ceph/encoding/denc"
+	"github.com/ceph/go-ceph/rados"
+	"github.com/ceph/go-ceph/rados/cls"
+)
+
+// ClassName is the name of the object class.
+const ClassName = "log"
+
+// Entry is a log entry.
+type Entry struct {
+	// ID is assigned by the object class when the entry is added.
+	ID        string
+	Section   string
+	Name      string
+	Timestamp time.Time
+	Data      []byte
+}
+
+// ListResult is the result of listing log entries.
+type ListResult struct {
+	Entries []Entry
+	// Marker is the position to continue listing from.
+	Marker string
+	// Truncated is true if there are more entries to list.
+	Truncated bool
+}
+
+// TrimRange selects the log entries to remove. Entries are selected by time
+// unless one of the markers is set.
+type TrimRange struct {
+	From       time.Time
+	To         time.Time
+	FromMarker string
+	ToMarker   string
+}
+
+// Header describes the newest entry of the log.
+type Header struct {
+	MaxMarker string
+	MaxTime   time.Time
+}
+
+func encodeEntry(e *denc.Encoder, entry Entry) {
+	e.Start(2, 1)
+	e.String(entry.Section)
+	e.String(entry.Name)
+	e.Time(entry.Timestamp)
+	e.Blob(entry.Data)
+	e.String(entry.ID)
+	e.Finish()
+}
+
+func decodeEntry(d *denc.Decoder) Entry {
+	var entry Entry
+	v := d.Start(2)
+	entry.Section = d.String()
+	entry.Name = d.String()
+	entry.Timestamp = d.Time()
+	entry.Data = d.Blob()
+	if v >= 2 {
+		entry.ID = d.String()
+	}
+	d.Finish()
+	return entry
+}
+
+// Add adds appending entries to the log to the write operation. If
+// monotonicInc is true, entries with a timestamp older than the newest entry
+// of the log get the timestamp of the newest entry.
+func Add(op *rados.WriteOp, monotonicInc bool, entries ...Entry) {
+	e := denc.NewEncoder()
+	e.Start(2, 1)
+	e.U32(uint32(len(entries)))
+	for _, entry := range entries {
+		encodeEntry(e, entry)
+	}
+	e.Bool(monotonicInc)
+	e.Finish()
+	op.Exec(ClassName, "add", e.Bytes())
+}
+
+// List adds listing up to maxEntries log entries with timestamps from from
+// (inclusive) to to (exclusive) to the read operation. A zero to time lists
+// all entries newer than from. Listing starts after marker, if set.
+func List(op *rados.ReadOp, from, to time.Time, marker string, maxEntries int) *cls.Step[*ListResult] {
+	e := denc.NewEncoder()
+	e.Start(1, 1)
+	e.Time(from)
+	e.String(marker)
+	e.I32(int32(maxEntries))
+	e.Time(to)
+	e.Finish()
+	return cls.NewStep(op.Exec(ClassName, "list", e.Bytes()), decodeList)
+}
+
+func decodeList(b []byte) (*ListResult, error) {
+	d := denc.NewDecoder(b)
+	r := &ListResult{}
+	d.Start(1)
+	n := d.Count(6)
+	for i := 0; i < n && d.Err() == nil; i++ {
+		r.Entries = append(r.Entries, decodeEntry(d))
+	}
+	r.Marker = d.String()
+	r.Truncated = d.Bool()
+	d.Finish()
+	if err := d.Err(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Trim adds removing log entries to the write operation. Trimming a range
+// without entries fails with ENODATA.
+func Trim(op *rados.WriteOp, r TrimRange) {
+	e := denc.NewEncoder()
+	e.Start(2, 1)
+	e.Time(r.From)
+	e.Time(r.To)
+	e.String(r.FromMarker)
+	e.String(r.ToMarker)
+	e.Finish()
+	op.Exec(ClassName, "trim", e.Bytes())
+}
+
+// Info adds retrieving the log header to the read operation.
+func Info(op *rados.ReadOp) *cls.Step[Header] {
+	e := denc.NewEncoder()
+	e.Start(1, 1)
+	e.Finish()
+	return cls.NewStep(op.Exec(ClassName, "info", e.Bytes()), decodeInfo)
+}
+
+func decodeInfo(b []byte) (Header, error) {
+	var h Header
+	d := denc.NewDecoder(b)
+	d.Start(1)
+	d.Start(1)
+	h.MaxMarker = d.String()
+	h.MaxTime = d.Time()
+	d.Finish()
+	d.Finish()
+	return h, d.Err()
+}
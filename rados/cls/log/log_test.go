@@ -0,0 +1,93 @@
+//go:build ceph_preview
+
+package log
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ceph/go-ceph/internal/radostest"
+	"github.com/ceph/go-ceph/rados"
+)
+
+var testPool radostest.Pool
+
+func TestMain(m *testing.M) {
+	os.Exit(testPool.Run(m))
+}
+
+func TestLog(t *testing.T) {
+	ioctx := testPool.IOContext(t)
+	oid := "log"
+	base := time.Unix(1700000000, 0)
+
+	entries := make([]Entry, 5)
+	for i := range entries {
+		entries[i] = Entry{
+			Section:   "sec",
+			Name:      "entry",
+			Timestamp: base.Add(time.Duration(i) * time.Minute),
+			Data:      []byte{byte(i)},
+		}
+	}
+	wop := rados.CreateWriteOp()
+	defer wop.Release()
+	Add(wop, false, entries...)
+	require.NoError(t, wop.Operate(ioctx, oid, rados.OperationNoFlag))
+
+	t.Run("list", func(t *testing.T) {
+		rop := rados.CreateReadOp()
+		defer rop.Release()
+		step := List(rop, base, time.Time{}, "", 3)
+		require.NoError(t, rop.Operate(ioctx, oid, rados.OperationNoFlag))
+		r, err := step.Result()
+		require.NoError(t, err)
+		require.Len(t, r.Entries, 3)
+		assert.True(t, r.Truncated)
+		for i, e := range r.Entries {
+			assert.Equal(t, "sec", e.Section)
+			assert.True(t, entries[i].Timestamp.Equal(e.Timestamp))
+			assert.Equal(t, []byte{byte(i)}, e.Data)
+			assert.NotEmpty(t, e.ID)
+		}
+
+		rop2 := rados.CreateReadOp()
+		defer rop2.Release()
+		step2 := List(rop2, base, time.Time{}, r.Marker, 3)
+		require.NoError(t, rop2.Operate(ioctx, oid, rados.OperationNoFlag))
+		r2, err := step2.Result()
+		require.NoError(t, err)
+		assert.Len(t, r2.Entries, 2)
+		assert.False(t, r2.Truncated)
+	})
+
+	t.Run("info", func(t *testing.T) {
+		rop := rados.CreateReadOp()
+		defer rop.Release()
+		step := Info(rop)
+		require.NoError(t, rop.Operate(ioctx, oid, rados.OperationNoFlag))
+		h, err := step.Result()
+		require.NoError(t, err)
+		assert.True(t, entries[4].Timestamp.Equal(h.MaxTime))
+		assert.NotEmpty(t, h.MaxMarker)
+	})
+
+	t.Run("trim", func(t *testing.T) {
+		wop := rados.CreateWriteOp()
+		defer wop.Release()
+		Trim(wop, TrimRange{From: base, To: base.Add(2 * time.Minute)})
+		require.NoError(t, wop.Operate(ioctx, oid, rados.OperationNoFlag))
+
+		rop := rados.CreateReadOp()
+		defer rop.Release()
+		step := List(rop, base, time.Time{}, "", 10)
+		require.NoError(t, rop.Operate(ioctx, oid, rados.OperationNoFlag))
+		r, err := step.Result()
+		require.NoError(t, err)
+		assert.Len(t, r.Entries, 3)
+	})
+}
@@ -0,0 +1,58 @@
+//go:build ceph_preview
+
+/*
+Package numops is a client of the "numops" object class (cls_numops), which
+performs arithmetic on numbers stored as decimal strings in the omap of a
+RADOS object.
+*/
+package numops
+
+import (
+	"strconv"
+
+	"github.com/ceph/go-ceph/encoding/denc"
+	"github.com/ceph/go-ceph/rados"
+)
+
+// ClassName is the name of the object class.
+const ClassName = "numops"
+
+func encodeArgs(key string, v float64) []byte {
+	e := denc.NewEncoder()
+	e.String(key)
+	e.String(strconv.FormatFloat(v, 'g', -1, 64))
+	return e.Bytes()
+}
+
+// Add adds adding v to the value of the omap key to the write operation. A
+// missing key is treated as zero. The operation fails with EBADMSG if the
+// stored value is not a number.
+func Add(op *rados.WriteOp, key string, v float64) {
+	op.Exec(ClassName, "add", encodeArgs(key, v))
+}
+
+// Mul adds multiplying the value of the omap key by v to the write
+// operation. A missing key is treated as zero. The operation fails with
+// EBADMSG if the stored value is not a number.
+func Mul(op *rados.WriteOp, key string, v float64) {
+	op.Exec(ClassName, "mul", encodeArgs(key, v))
+}
+
+// Get returns the value of the omap key of an object. It returns
+// rados.ErrNotFound if the key does not exist.
+func Get(ioctx *rados.IOContext, oid, key string) (float64, error) {
+	op := rados.CreateReadOp()
+	defer op.Release()
+	step := op.GetOmapValuesByKeys([]string{key})
+	if err := op.Operate(ioctx, oid, rados.OperationNoFlag); err != nil {
+		return 0, err
+	}
+	kv, err := step.Next()
+	if err != nil {
+		return 0, err
+	}
+	if kv == nil {
+		return 0, rados.ErrNotFound
+	}
+	return strconv.ParseFloat(string(kv.Value), 64)
+}
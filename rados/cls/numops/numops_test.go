@@ -0,0 +1,50 @@
+//go:build ceph_preview
+
+package numops
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ceph/go-ceph/internal/radostest"
+	"github.com/ceph/go-ceph/rados"
+)
+
+var testPool radostest.Pool
+
+func TestMain(m *testing.M) {
+	os.Exit(testPool.Run(m))
+}
+
+func TestNumops(t *testing.T) {
+	ioctx := testPool.IOContext(t)
+	oid := "numbers"
+	require.NoError(t, ioctx.Create(oid, rados.CreateIdempotent))
+
+	_, err := Get(ioctx, oid, "n")
+	assert.ErrorIs(t, err, rados.ErrNotFound)
+
+	wop := rados.CreateWriteOp()
+	defer wop.Release()
+	Add(wop, "n", 2.5)
+	require.NoError(t, wop.Operate(ioctx, oid, rados.OperationNoFlag))
+
+	wop2 := rados.CreateWriteOp()
+	defer wop2.Release()
+	Mul(wop2, "n", 4)
+	Add(wop2, "n", -1)
+	require.NoError(t, wop2.Operate(ioctx, oid, rados.OperationNoFlag))
+
+	v, err := Get(ioctx, oid, "n")
+	require.NoError(t, err)
+	assert.Equal(t, 9.0, v)
+
+	require.NoError(t, ioctx.SetOmap(oid, map[string][]byte{"s": []byte("abc")}))
+	wop3 := rados.CreateWriteOp()
+	defer wop3.Release()
+	Add(wop3, "s", 1)
+	assert.Error(t, wop3.Operate(ioctx, oid, rados.OperationNoFlag))
+}
@@ -0,0 +1,69 @@
+//go:build ceph_preview
+
+/*
+Package refcount is a client of the "refcount" object class (cls_refcount),
+which maintains a set of references on a RADOS object and removes the object
+once the last reference is dropped.
+*/
+package refcount
+
+import (
+	"github.com/ceph/go-ceph/encoding/denc"
+	"github.com/ceph/go-ceph/rados"
+	"github.com/ceph/go-ceph/rados/cls"
+)
+
+// ClassName is the name of the object class.
+const ClassName = "refcount"
+
+// Get adds taking a reference with the given tag to the write operation. If
+// implicitRef is true, an object without references is treated as holding a
+// single implicit reference, which is kept.
+func Get(op *rados.WriteOp, tag string, implicitRef bool) {
+	op.Exec(ClassName, "get", encodeTag(tag, implicitRef))
+}
+
+// Put adds dropping the reference with the given tag to the write operation.
+// The object is removed when the last reference is dropped. If implicitRef
+// is true, an object without references is treated as holding a single
+// implicit reference.
+func Put(op *rados.WriteOp, tag string, implicitRef bool) {
+	op.Exec(ClassName, "put", encodeTag(tag, implicitRef))
+}
+
+func encodeTag(tag string, implicitRef bool) []byte {
+	e := denc.NewEncoder()
+	e.Start(1, 1)
+	e.String(tag)
+	e.Bool(implicitRef)
+	e.Finish()
+	return e.Bytes()
+}
+
+// Set adds replacing the references of the object to the write operation.
+func Set(op *rados.WriteOp, refs []string) {
+	e := denc.NewEncoder()
+	e.Start(1, 1)
+	e.Strings(refs)
+	e.Finish()
+	op.Exec(ClassName, "set", e.Bytes())
+}
+
+// Read adds listing the references of the object to the read operation. If
+// implicitRef is true, an object without references reports a single
+// implicit reference with an empty tag.
+func Read(op *rados.ReadOp, implicitRef bool) *cls.Step[[]string] {
+	e := denc.NewEncoder()
+	e.Start(1, 1)
+	e.Bool(implicitRef)
+	e.Finish()
+	return cls.NewStep(op.Exec(ClassName, "read", e.Bytes()), decodeRead)
+}
+
+func decodeRead(b []byte) ([]string, error) {
+	d := denc.NewDecoder(b)
+	d.Start(1)
+	refs := d.Strings()
+	d.Finish()
+	return refs, d.Err()
+}
@@ -0,0 +1,64 @@
+//go:build ceph_preview
+
+package refcount
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ceph/go-ceph/internal/radostest"
+	"github.com/ceph/go-ceph/rados"
+)
+
+var testPool radostest.Pool
+
+func TestMain(m *testing.M) {
+	os.Exit(testPool.Run(m))
+}
+
+func readRefs(t *testing.T, ioctx *rados.IOContext, oid string) []string {
+	rop := rados.CreateReadOp()
+	defer rop.Release()
+	step := Read(rop, false)
+	require.NoError(t, rop.Operate(ioctx, oid, rados.OperationNoFlag))
+	refs, err := step.Result()
+	require.NoError(t, err)
+	return refs
+}
+
+func TestRefcount(t *testing.T) {
+	ioctx := testPool.IOContext(t)
+	oid := "counted"
+	require.NoError(t, ioctx.WriteFull(oid, []byte("data")))
+
+	wop := rados.CreateWriteOp()
+	defer wop.Release()
+	Get(wop, "a", false)
+	Get(wop, "b", false)
+	require.NoError(t, wop.Operate(ioctx, oid, rados.OperationNoFlag))
+	assert.ElementsMatch(t, []string{"a", "b"}, readRefs(t, ioctx, oid))
+
+	wop2 := rados.CreateWriteOp()
+	defer wop2.Release()
+	Set(wop2, []string{"x", "y", "z"})
+	require.NoError(t, wop2.Operate(ioctx, oid, rados.OperationNoFlag))
+	assert.ElementsMatch(t, []string{"x", "y", "z"}, readRefs(t, ioctx, oid))
+
+	wop3 := rados.CreateWriteOp()
+	defer wop3.Release()
+	Put(wop3, "x", false)
+	Put(wop3, "y", false)
+	require.NoError(t, wop3.Operate(ioctx, oid, rados.OperationNoFlag))
+	assert.Equal(t, []string{"z"}, readRefs(t, ioctx, oid))
+
+	// dropping the last reference removes the object
+	wop4 := rados.CreateWriteOp()
+	defer wop4.Release()
+	Put(wop4, "z", false)
+	require.NoError(t, wop4.Operate(ioctx, oid, rados.OperationNoFlag))
+	_, err := ioctx.Stat(oid)
+	assert.ErrorIs(t, err, rados.ErrNotFound)
+}
@@ -0,0 +1,111 @@
+//go:build ceph_preview
+
+/*
+Package timeindex is a client of the "timeindex" object class
+(cls_timeindex), which stores entries keyed by time in the omap of a RADOS
+object.
+*/
+package timeindex
+
+import (
+	"time"
+
+	"github.com/ceph/go-ceph/encoding/denc"
+	"github.com/ceph/go-ceph/rados"
+	"github.com/ceph/go-ceph/rados/cls"
+)
+
+// ClassName is the name of the object class.
+const ClassName = "timeindex"
+
+// Entry is an entry of the index.
+type Entry struct {
+	KeyTime time.Time
+	// KeyExt distinguishes entries with the same KeyTime.
+	KeyExt string
+	Value  []byte
+}
+
+// ListResult is the result of listing index entries.
+type ListResult struct {
+	Entries []Entry
+	// Marker is the position to continue listing from.
+	Marker string
+	// Truncated is true if there are more entries to list.
+	Truncated bool
+}
+
+// TrimRange selects the index entries to remove. Entries are selected by
+// time unless one of the markers is set.
+type TrimRange struct {
+	From       time.Time
+	To         time.Time
+	FromMarker string
+	ToMarker   string
+}
+
+// Add adds inserting entries to the write operation.
+func Add(op *rados.WriteOp, entries ...Entry) {
+	e := denc.NewEncoder()
+	e.Start(1, 1)
+	e.U32(uint32(len(entries)))
+	for _, entry := range entries {
+		e.Start(1, 1)
+		e.Time(entry.KeyTime)
+		e.String(entry.KeyExt)
+		e.Blob(entry.Value)
+		e.Finish()
+	}
+	e.Finish()
+	op.Exec(ClassName, "add", e.Bytes())
+}
+
+// List adds listing up to maxEntries index entries with times from from
+// (inclusive) to to (exclusive) to the read operation. Listing starts after
+// marker, if set.
+func List(op *rados.ReadOp, from, to time.Time, marker string, maxEntries int) *cls.Step[*ListResult] {
+	e := denc.NewEncoder()
+	e.Start(1, 1)
+	e.Time(from)
+	e.String(marker)
+	e.I32(int32(maxEntries))
+	e.Time(to)
+	e.Finish()
+	return cls.NewStep(op.Exec(ClassName, "list", e.Bytes()), decodeList)
+}
+
+func decodeList(b []byte) (*ListResult, error) {
+	d := denc.NewDecoder(b)
+	r := &ListResult{}
+	d.Start(1)
+	n := d.Count(6)
+	for i := 0; i < n && d.Err() == nil; i++ {
+		var entry Entry
+		d.Start(1)
+		entry.KeyTime = d.Time()
+		entry.KeyExt = d.String()
+		entry.Value = d.Blob()
+		d.Finish()
+		r.Entries = append(r.Entries, entry)
+	}
+	r.Marker = d.String()
+	r.Truncated = d.Bool()
+	d.Finish()
+	if err := d.Err(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Trim adds removing index entries to the write operation. Trimming a range
+// without entries fails with ENODATA.
+func Trim(op *rados.WriteOp, r TrimRange) {
+	e := denc.NewEncoder()
+	e.Start(1, 1)
+	e.Time(r.From)
+	e.Time(r.To)
+	e.String(r.FromMarker)
+	e.String(r.ToMarker)
+	e.Finish()
+	op.Exec(ClassName, "trim", e.Bytes())
+}
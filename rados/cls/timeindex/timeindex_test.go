@@ -0,0 +1,62 @@
+//go:build ceph_preview
+
+package timeindex
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ceph/go-ceph/internal/radostest"
+	"github.com/ceph/go-ceph/rados"
+)
+
+var testPool radostest.Pool
+
+func TestMain(m *testing.M) {
+	os.Exit(testPool.Run(m))
+}
+
+func TestTimeindex(t *testing.T) {
+	ioctx := testPool.IOContext(t)
+	oid := "index"
+	base := time.Unix(1700000000, 0)
+
+	wop := rados.CreateWriteOp()
+	defer wop.Release()
+	Add(wop,
+		Entry{KeyTime: base, KeyExt: "a", Value: []byte("1")},
+		Entry{KeyTime: base, KeyExt: "b", Value: []byte("2")},
+		Entry{KeyTime: base.Add(time.Hour), KeyExt: "c", Value: []byte("3")},
+	)
+	require.NoError(t, wop.Operate(ioctx, oid, rados.OperationNoFlag))
+
+	rop := rados.CreateReadOp()
+	defer rop.Release()
+	step := List(rop, base, base.Add(time.Minute), "", 10)
+	require.NoError(t, rop.Operate(ioctx, oid, rados.OperationNoFlag))
+	r, err := step.Result()
+	require.NoError(t, err)
+	require.Len(t, r.Entries, 2)
+	assert.False(t, r.Truncated)
+	assert.Equal(t, "a", r.Entries[0].KeyExt)
+	assert.Equal(t, []byte("2"), r.Entries[1].Value)
+	assert.True(t, base.Equal(r.Entries[1].KeyTime))
+
+	wop2 := rados.CreateWriteOp()
+	defer wop2.Release()
+	Trim(wop2, TrimRange{From: base, To: base.Add(time.Minute)})
+	require.NoError(t, wop2.Operate(ioctx, oid, rados.OperationNoFlag))
+
+	rop2 := rados.CreateReadOp()
+	defer rop2.Release()
+	step2 := List(rop2, base, time.Time{}, "", 10)
+	require.NoError(t, rop2.Operate(ioctx, oid, rados.OperationNoFlag))
+	r, err = step2.Result()
+	require.NoError(t, err)
+	require.Len(t, r.Entries, 1)
+	assert.Equal(t, "c", r.Entries[0].KeyExt)
+}
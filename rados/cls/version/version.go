@@ -0,0 +1,137 @@
+//go:build ceph_preview
+
+/*
+Package version is a client of the "version" object class (cls_version),
+which maintains a version and tag on RADOS objects that can be used for
+optimistic concurrency control.
+*/
+package version
+
+import (
+	"github.com/ceph/go-ceph/encoding/denc"
+	"github.com/ceph/go-ceph/rados"
+	"github.com/ceph/go-ceph/rados/cls"
+)
+
+// ClassName is the name of the object class.
+const ClassName = "version"
+
+// Version is the version of an object. It corresponds to obj_version.
+type Version struct {
+	Ver uint64
+	Tag string
+}
+
+// Cond is the comparison of a Condition.
+type Cond uint32
+
+const (
+	// CondNone is always true.
+	CondNone = Cond(0)
+	// CondEq is true if the versions are equal.
+	CondEq = Cond(1)
+	// CondGt is true if the version of the object is greater than the
+	// version of the condition.
+	CondGt = Cond(2)
+	// CondGe is true if the version of the object is greater than or equal
+	// to the version of the condition.
+	CondGe = Cond(3)
+	// CondLt is true if the version of the object is less than the version
+	// of the condition.
+	CondLt = Cond(4)
+	// CondLe is true if the version of the object is less than or equal to
+	// the version of the condition.
+	CondLe = Cond(5)
+	// CondTagEq is true if the tags are equal.
+	CondTagEq = Cond(6)
+	// CondTagNe is true if the tags are not equal.
+	CondTagNe = Cond(7)
+)
+
+// Condition compares the version of an object with Version.
+type Condition struct {
+	Version Version
+	Cond    Cond
+}
+
+func encodeVersion(e *denc.Encoder, v Version) {
+	e.Start(1, 1)
+	e.U64(v.Ver)
+	e.String(v.Tag)
+	e.Finish()
+}
+
+func encodeConds(e *denc.Encoder, conds []Condition) {
+	e.U32(uint32(len(conds)))
+	for _, c := range conds {
+		e.Start(1, 1)
+		encodeVersion(e, c.Version)
+		e.U32(uint32(c.Cond))
+		e.Finish()
+	}
+}
+
+// Set adds setting the version of the object to the write operation.
+func Set(op *rados.WriteOp, v Version) {
+	e := denc.NewEncoder()
+	e.Start(1, 1)
+	encodeVersion(e, v)
+	e.Finish()
+	op.Exec(ClassName, "set", e.Bytes())
+}
+
+// Inc adds incrementing the version of the object to the write operation. If
+// conditions are given the operation fails with ECANCELED unless all of them
+// are true. An object without a version gets a new random tag.
+func Inc(op *rados.WriteOp, conds ...Condition) {
+	e := denc.NewEncoder()
+	e.Start(1, 1)
+	encodeVersion(e, Version{})
+	encodeConds(e, conds)
+	e.Finish()
+	method := "inc"
+	if len(conds) > 0 {
+		method = "inc_conds"
+	}
+	op.Exec(ClassName, method, e.Bytes())
+}
+
+// Check adds a check of the version of the object to the operation. The
+// operation fails with ECANCELED unless all conditions are true.
+func Check[T cls.Op](op T, conds ...Condition) {
+	e := denc.NewEncoder()
+	e.Start(1, 1)
+	encodeVersion(e, Version{})
+	encodeConds(e, conds)
+	e.Finish()
+	cls.Exec(op, ClassName, "check_conds", e.Bytes())
+}
+
+// Read adds reading the version of the object to the read operation. An
+// object without a version has a zero Version.
+func Read(op *rados.ReadOp) *cls.Step[Version] {
+	return cls.NewStep(op.Exec(ClassName, "read", nil), decodeRead)
+}
+
+func decodeRead(b []byte) (Version, error) {
+	var v Version
+	d := denc.NewDecoder(b)
+	d.Start(1)
+	d.Start(1)
+	v.Ver = d.U64()
+	v.Tag = d.String()
+	d.Finish()
+	d.Finish()
+	return v, d.Err()
+}
+
+// Get returns the version of an object.
+func Get(ioctx *rados.IOContext, oid string) (Version, error) {
+	op := rados.CreateReadOp()
+	defer op.Release()
+	step := Read(op)
+	if err := op.Operate(ioctx, oid, rados.OperationNoFlag); err != nil {
+		return Version{}, err
+	}
+	return step.Result()
+}
@@ -0,0 +1,83 @@
+//go:build ceph_preview
+
+package version
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ceph/go-ceph/internal/radostest"
+	"github.com/ceph/go-ceph/rados"
+)
+
+var testPool radostest.Pool
+
+func TestMain(m *testing.M) {
+	os.Exit(testPool.Run(m))
+}
+
+func TestVersion(t *testing.T) {
+	ioctx := testPool.IOContext(t)
+	oid := "versioned"
+	require.NoError(t, ioctx.Create(oid, rados.CreateIdempotent))
+
+	v, err := Get(ioctx, oid)
+	require.NoError(t, err)
+	assert.Equal(t, Version{}, v)
+
+	wop := rados.CreateWriteOp()
+	defer wop.Release()
+	Set(wop, Version{Ver: 5, Tag: "abc"})
+	require.NoError(t, wop.Operate(ioctx, oid, rados.OperationNoFlag))
+
+	v, err = Get(ioctx, oid)
+	require.NoError(t, err)
+	assert.Equal(t, Version{Ver: 5, Tag: "abc"}, v)
+
+	t.Run("inc", func(t *testing.T) {
+		wop := rados.CreateWriteOp()
+		defer wop.Release()
+		Inc(wop)
+		require.NoError(t, wop.Operate(ioctx, oid, rados.OperationNoFlag))
+		v, err := Get(ioctx, oid)
+		require.NoError(t, err)
+		assert.Equal(t, Version{Ver: 6, Tag: "abc"}, v)
+	})
+
+	t.Run("incConds", func(t *testing.T) {
+		stale := Condition{Version: Version{Ver: 5, Tag: "abc"}, Cond: CondEq}
+		wop := rados.CreateWriteOp()
+		defer wop.Release()
+		Inc(wop, stale)
+		assert.Error(t, wop.Operate(ioctx, oid, rados.OperationNoFlag))
+
+		current := Condition{Version: Version{Ver: 6, Tag: "abc"}, Cond: CondEq}
+		wop2 := rados.CreateWriteOp()
+		defer wop2.Release()
+		Inc(wop2, current)
+		require.NoError(t, wop2.Operate(ioctx, oid, rados.OperationNoFlag))
+		v, err := Get(ioctx, oid)
+		require.NoError(t, err)
+		assert.EqualValues(t, 7, v.Ver)
+	})
+
+	t.Run("check", func(t *testing.T) {
+		rop := rados.CreateReadOp()
+		defer rop.Release()
+		Check(rop, Condition{Version: Version{Ver: 3}, Cond: CondGt})
+		read := Read(rop)
+		require.NoError(t, rop.Operate(ioctx, oid, rados.OperationNoFlag))
+		v, err := read.Result()
+		require.NoError(t, err)
+		assert.EqualValues(t, 7, v.Ver)
+
+		wop := rados.CreateWriteOp()
+		defer wop.Release()
+		Check(wop, Condition{Version: Version{Tag: "abc"}, Cond: CondTagNe})
+		wop.WriteFull([]byte("data"))
+		assert.Error(t, wop.Operate(ioctx, oid, rados.OperationNoFlag))
+	})
+}
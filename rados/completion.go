@@ -0,0 +1,261 @@
+//go:build ceph_preview
+
+package rados
+
+/*
+#cgo LDFLAGS: -lrados
+#include <stdlib.h>
+#include <rados/librados.h>
+
+extern void completionCallback(rados_completion_t, uintptr_t);
+
+// inline wrapper to cast uintptr_t to void*
+static inline int wrap_rados_aio_create_completion2(uintptr_t arg,
+	rados_completion_t *pc) {
+		return rados_aio_create_completion2((void*)arg,
+			(rados_callback_t)completionCallback, pc);
+};
+*/
+import "C"
+
+import (
+	"sync"
+	"unsafe"
+
+	"github.com/ceph/go-ceph/internal/callbacks"
+	"github.com/ceph/go-ceph/internal/cutil"
+)
+
+// completions tracks the in-flight asynchronous operations.
+var completions = callbacks.New()
+
+// Completion represents an asynchronous operation that has been submitted to
+// librados. The result of the operation can be obtained by blocking on the
+// Wait method, by polling the IsComplete method or by receiving from the
+// channel returned by the Done method.
+//
+// Any Go buffers passed to the call that created the Completion are in use
+// by librados until the operation has completed and must not be modified (or
+// read, for read operations) before then.
+//
+// Once the result has been consumed the Release method must be called to free
+// the resources associated with the Completion.
+type Completion struct {
+	comp    C.rados_completion_t
+	ioctx   *IOContext
+	cbIndex uintptr
+	done    chan struct{}
+
+	// finish is called, from the librados callback thread, with the return
+	// value of the operation. It may replace the error set on the
+	// completion.
+	finish func(ret C.int) error
+
+	// guards pin the Go buffers handed to librados for the lifetime of the
+	// operation. slots track C memory, such as the locations the guards write
+	// to, that is freed when the completion is released.
+	guards []*cutil.PtrGuard
+	slots  []cutil.CPtr
+
+	mutex    sync.Mutex
+	ret      C.int
+	err      error
+	released bool
+}
+
+func newCompletion(ioctx *IOContext) (*Completion, error) {
+	if err := ioctx.validate(); err != nil {
+		return nil, err
+	}
+	c := &Completion{
+		ioctx: ioctx,
+		done:  make(chan struct{}),
+	}
+	c.cbIndex = completions.Add(c)
+	ret := C.wrap_rados_aio_create_completion2(
+		C.uintptr_t(c.cbIndex), &c.comp)
+	if ret != 0 {
+		completions.Remove(c.cbIndex)
+		return nil, getError(ret)
+	}
+	return c, nil
+}
+
+// pin guards the Go buffer b for as long as librados may access it and
+// returns a pointer to the buffer that can be handed to librados.
+func (c *Completion) pin(b []byte) *C.char {
+	if len(b) == 0 {
+		return nil
+	}
+	slot := cutil.Malloc(cutil.PtrSize)
+	c.slots = append(c.slots, slot)
+	c.guards = append(c.guards, cutil.NewPtrGuard(slot, unsafe.Pointer(&b[0])))
+	return *(**C.char)(unsafe.Pointer(slot))
+}
+
+// alloc returns size bytes of C memory that remain valid until the
+// completion is released.
+func (c *Completion) alloc(size int) *C.char {
+	if size == 0 {
+		return nil
+	}
+	buf := cutil.Malloc(cutil.SizeT(size))
+	c.slots = append(c.slots, buf)
+	return (*C.char)(buf)
+}
+
+// copyIn returns a copy of b in C memory owned by the completion.
+func (c *Completion) copyIn(b []byte) *C.char {
+	buf := c.alloc(len(b))
+	if buf != nil {
+		copy(unsafe.Slice((*byte)(unsafe.Pointer(buf)), len(b)), b)
+	}
+	return buf
+}
+
+// unpin releases the guards of the buffers that were handed to librados.
+func (c *Completion) unpin() {
+	for i := range c.guards {
+		c.guards[i].Release()
+	}
+	c.guards = nil
+}
+
+// submitted must be called with the return value of the librados call that
+// started the asynchronous operation. If the operation could not be started
+// the completion is released and an error is returned.
+func (c *Completion) submitted(ret C.int) error {
+	if ret == 0 {
+		return nil
+	}
+	// the callback will never fire, so finish up here
+	completions.Remove(c.cbIndex)
+	c.unpin()
+	close(c.done)
+	c.Release()
+	return getError(ret)
+}
+
+func (c *Completion) complete() {
+	ret := C.rados_aio_get_return_value(c.comp)
+	var err error
+	if ret < 0 {
+		err = getError(ret)
+	}
+	if c.finish != nil {
+		err = c.finish(ret)
+	}
+	c.unpin()
+
+	c.mutex.Lock()
+	c.ret = ret
+	c.err = err
+	c.mutex.Unlock()
+
+	completions.Remove(c.cbIndex)
+	close(c.done)
+}
+
+// Done returns a channel that is closed once the operation has completed.
+func (c *Completion) Done() <-chan struct{} {
+	return c.done
+}
+
+// Wait blocks until the operation has completed. It returns the non-negative
+// return value of the operation, for example the number of bytes read by a
+// read operation, or an error. Unlike the librados wait functions, Wait only
+// blocks the calling goroutine and not an OS thread.
+func (c *Completion) Wait() (int, error) {
+	<-c.done
+	return c.result()
+}
+
+func (c *Completion) result() (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return int(c.ret), nil
+}
+
+// WaitForSafe blocks until the operation is safe on disk. Since Ceph Pacific
+// librados no longer distinguishes between completed and safe operations and
+// WaitForSafe is equivalent to Wait.
+func (c *Completion) WaitForSafe() (int, error) {
+	return c.Wait()
+}
+
+// IsComplete returns true if the operation has completed. The result of a
+// completed operation can be obtained by calling Wait without blocking.
+func (c *Completion) IsComplete() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsSafe returns true if the operation is safe on disk. See WaitForSafe.
+func (c *Completion) IsSafe() bool {
+	return c.IsComplete()
+}
+
+// Version returns the version of the object read or written by the completed
+// operation.
+//
+// Implements:
+//
+//	uint64_t rados_aio_get_version(rados_completion_t c);
+func (c *Completion) Version() (uint64, error) {
+	if !c.IsComplete() {
+		return 0, ErrOperationIncomplete
+	}
+	return uint64(C.rados_aio_get_version(c.comp)), nil
+}
+
+// Cancel requests that librados cancel the operation. The Completion still
+// needs to be waited for, canceled operations complete with an error.
+//
+// Implements:
+//
+//	int rados_aio_cancel(rados_ioctx_t io, rados_completion_t completion);
+func (c *Completion) Cancel() error {
+	if c.IsComplete() {
+		return nil
+	}
+	if err := c.ioctx.validate(); err != nil {
+		return err
+	}
+	return getError(C.rados_aio_cancel(c.ioctx.ioctx, c.comp))
+}
+
+// Release frees the resources associated with the Completion. If the
+// operation is still in flight Release blocks until it has completed.
+//
+// Implements:
+//
+//	void rados_aio_release(rados_completion_t c);
+func (c *Completion) Release() {
+	<-c.done
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.released {
+		return
+	}
+	c.released = true
+	C.rados_aio_release(c.comp)
+	c.comp = nil
+	for i := range c.slots {
+		cutil.Free(c.slots[i])
+	}
+	c.slots = nil
+}
+
+//export completionCallback
+func completionCallback(_ C.rados_completion_t, index uintptr) {
+	v := completions.Lookup(index)
+	c := v.(*Completion)
+	c.complete()
+}
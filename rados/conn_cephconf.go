@@ -0,0 +1,45 @@
+//go:build ceph_preview
+
+package rados
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ceph/go-ceph/common/cephconf"
+)
+
+// ApplyConf sets the configuration options of the parsed configuration file
+// that apply to the connection. The options of the global section, the
+// section of the client type and the section of the client name are applied
+// with meta variables expanded, in the same way as ReadConfigFile would do.
+// Options unknown to the client are skipped.
+func (c *Conn) ApplyConf(conf *cephconf.Conf) error {
+	var (
+		e   cephconf.Entity
+		err error
+	)
+	if e.Name, err = c.GetConfigOption("name"); err != nil {
+		return err
+	}
+	if e.Cluster, err = c.GetConfigOption("cluster"); err != nil {
+		return err
+	}
+	if e.Host, err = c.GetConfigOption("host"); err != nil {
+		return err
+	}
+	opts, err := conf.Options(e)
+	if err != nil {
+		return err
+	}
+	for name, value := range opts {
+		err := c.SetConfigOption(name, value)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
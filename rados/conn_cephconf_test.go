@@ -0,0 +1,47 @@
+//go:build ceph_preview
+
+package rados
+
+import (
+	"strings"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ceph/go-ceph/common/cephconf"
+)
+
+func (suite *RadosTestSuite) TestApplyConf() {
+	conn, err := NewConnWithUser("admin")
+	require.NoError(suite.T(), err)
+	defer conn.Shutdown()
+
+	conf, err := cephconf.Parse(strings.NewReader(`
+[global]
+	log file = /tmp/$cluster-$name.log
+	___dne___ = ignored
+[client]
+	rados osd op timeout = 17
+[client.admin]
+	rados mon op timeout = 23
+[client.other]
+	rados mon op timeout = 99
+`))
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), conn.ApplyConf(conf))
+
+	for name, expected := range map[string]string{
+		"log_file":             "/tmp/ceph-client.admin.log",
+		"rados_osd_op_timeout": "17",
+		"rados_mon_op_timeout": "23",
+	} {
+		value, err := conn.GetConfigOption(name)
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), expected, value, name)
+	}
+
+	conf.Set("global", "a", "$b")
+	conf.Set("global", "b", "$a")
+	err = conn.ApplyConf(conf)
+	assert.ErrorIs(suite.T(), err, cephconf.ErrExpansionLoop)
+}
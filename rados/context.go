@@ -0,0 +1,202 @@
+//go:build ceph_preview
+
+package rados
+
+import "C"
+
+import (
+	"context"
+	"unsafe"
+
+	"github.com/ceph/go-ceph/internal/ctxutil"
+)
+
+// ConnectContext establishes a connection to a RADOS cluster like Connect,
+// but gives up waiting once ctx is done. In that case ctx.Err() is returned
+// and the connection attempt is left to finish in the background.
+//
+// The Conn must not be used after a cancelled ConnectContext, not even to
+// call Shutdown: it is shut down from a background goroutine once the
+// connection attempt finishes, which would race with any use by the caller.
+// Only if ctx was already done when ConnectContext was called, no attempt is
+// made and the Conn remains usable.
+func (c *Conn) ConnectContext(ctx context.Context) error {
+	return ctxutil.Do(ctx, c.Connect, func(err error) {
+		if err == nil {
+			c.Shutdown()
+		}
+	})
+}
+
+// MonCommandContext sends a command to one of the monitors like MonCommand,
+// but gives up waiting for the reply once ctx is done, returning ctx.Err().
+func (c *Conn) MonCommandContext(ctx context.Context, args []byte) ([]byte, string, error) {
+	return c.MonCommandWithInputBufferContext(ctx, args, nil)
+}
+
+// MonCommandWithInputBufferContext sends a command, with an input buffer, to
+// one of the monitors like MonCommandWithInputBuffer, but gives up waiting for
+// the reply once ctx is done, returning ctx.Err().
+func (c *Conn) MonCommandWithInputBufferContext(
+	ctx context.Context, args, inputBuffer []byte) ([]byte, string, error) {
+
+	var (
+		buf    []byte
+		status string
+	)
+	err := ctxutil.Do(ctx, func() error {
+		var err error
+		buf, status, err = c.MonCommandWithInputBuffer(args, inputBuffer)
+		return err
+	}, nil)
+	if err != nil && ctx.Err() == err {
+		return nil, "", err
+	}
+	return buf, status, err
+}
+
+// MgrCommandContext sends a command to a ceph-mgr like MgrCommand, but gives
+// up waiting for the reply once ctx is done, returning ctx.Err().
+func (c *Conn) MgrCommandContext(ctx context.Context, args [][]byte) ([]byte, string, error) {
+	return c.MgrCommandWithInputBufferContext(ctx, args, nil)
+}
+
+// MgrCommandWithInputBufferContext sends a command, with an input buffer, to a
+// ceph-mgr like MgrCommandWithInputBuffer, but gives up waiting for the reply
+// once ctx is done, returning ctx.Err().
+func (c *Conn) MgrCommandWithInputBufferContext(
+	ctx context.Context, args [][]byte, inputBuffer []byte) ([]byte, string, error) {
+
+	var (
+		buf    []byte
+		status string
+	)
+	err := ctxutil.Do(ctx, func() error {
+		var err error
+		buf, status, err = c.MgrCommandWithInputBuffer(args, inputBuffer)
+		return err
+	}, nil)
+	if err != nil && ctx.Err() == err {
+		return nil, "", err
+	}
+	return buf, status, err
+}
+
+// WaitContext blocks until the operation has completed or ctx is done. If ctx
+// is done first, the operation is canceled and ctx.Err() is returned. The
+// Completion must still be released, which blocks until librados has
+// finished with the operation.
+func (c *Completion) WaitContext(ctx context.Context) (int, error) {
+	select {
+	case <-c.done:
+		return c.result()
+	case <-ctx.Done():
+	}
+	// a cancellation error only means there was nothing left to cancel
+	_ = c.Cancel()
+	return 0, ctx.Err()
+}
+
+// waitContextAndRelease waits for the completion like WaitContext and makes
+// sure the completion gets released once librados is done with it.
+func waitContextAndRelease(ctx context.Context, c *Completion) (int, error) {
+	n, err := c.WaitContext(ctx)
+	releaseWhenDone(c)
+	return n, err
+}
+
+// releaseWhenDone releases the completion, in the background if librados is
+// still working on the operation.
+func releaseWhenDone(c *Completion) {
+	if c.IsComplete() {
+		c.Release()
+	} else {
+		go c.Release()
+	}
+}
+
+// ReadContext reads up to len(data) bytes from the object with key oid
+// starting at byte offset offset like Read. If ctx is done before the read
+// completes, the read is canceled and ctx.Err() is returned. The data is read
+// into a private buffer and only copied into data once the read succeeded, so
+// data is left untouched if an error is returned.
+func (ioctx *IOContext) ReadContext(ctx context.Context, oid string, data []byte, offset uint64) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	c, err := newCompletion(ioctx)
+	if err != nil {
+		return 0, err
+	}
+	buf := c.alloc(len(data))
+	if err := ioctx.aioRead(c, oid, buf, len(data), offset); err != nil {
+		return 0, err
+	}
+	defer releaseWhenDone(c)
+	n, err := c.WaitContext(ctx)
+	if err != nil || n == 0 {
+		return 0, err
+	}
+	return copy(data, unsafe.Slice((*byte)(unsafe.Pointer(buf)), n)), nil
+}
+
+// WriteContext writes len(data) bytes to the object with key oid starting at
+// byte offset offset like Write. If ctx is done before the write completes,
+// the write is canceled and ctx.Err() is returned. A canceled write may or
+// may not have been applied to the object. The data is copied into a private
+// buffer before the write is started, so data may be reused as soon as
+// WriteContext returns.
+func (ioctx *IOContext) WriteContext(ctx context.Context, oid string, data []byte, offset uint64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c, err := newCompletion(ioctx)
+	if err != nil {
+		return err
+	}
+	if err := ioctx.aioWrite(c, oid, c.copyIn(data), len(data), offset); err != nil {
+		return err
+	}
+	_, err = waitContextAndRelease(ctx, c)
+	return err
+}
+
+// WriteFullContext writes len(data) bytes to the object with key oid like
+// WriteFull. If ctx is done before the write completes, the write is canceled
+// and ctx.Err() is returned. A canceled write may or may not have been applied
+// to the object. Like with WriteContext, data may be reused as soon as
+// WriteFullContext returns.
+func (ioctx *IOContext) WriteFullContext(ctx context.Context, oid string, data []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c, err := newCompletion(ioctx)
+	if err != nil {
+		return err
+	}
+	if err := ioctx.aioWriteFull(c, oid, c.copyIn(data), len(data)); err != nil {
+		return err
+	}
+	_, err = waitContextAndRelease(ctx, c)
+	return err
+}
+
+// AppendContext appends len(data) bytes to the object with key oid like
+// Append. If ctx is done before the append completes, the append is canceled
+// and ctx.Err() is returned. A canceled append may or may not have been
+// applied to the object. Like with WriteContext, data may be reused as soon
+// as AppendContext returns.
+func (ioctx *IOContext) AppendContext(ctx context.Context, oid string, data []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c, err := newCompletion(ioctx)
+	if err != nil {
+		return err
+	}
+	if err := ioctx.aioAppend(c, oid, c.copyIn(data), len(data)); err != nil {
+		return err
+	}
+	_, err = waitContextAndRelease(ctx, c)
+	return err
+}
@@ -0,0 +1,108 @@
+//go:build ceph_preview
+
+package rados
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (suite *RadosTestSuite) TestConnectContext() {
+	ta := assert.New(suite.T())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := suite.conn.ConnectContext(ctx)
+	ta.ErrorIs(err, context.Canceled)
+
+	conn, err := NewConn()
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), conn.ReadDefaultConfigFile())
+	ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	err = conn.ConnectContext(ctx)
+	ta.NoError(err)
+	conn.Shutdown()
+}
+
+func (suite *RadosTestSuite) TestCommandContext() {
+	suite.SetupConnection()
+	ta := assert.New(suite.T())
+
+	command, err := json.Marshal(
+		map[string]string{"prefix": "df", "format": "json"})
+	require.NoError(suite.T(), err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	buf, info, err := suite.conn.MonCommandContext(ctx, command)
+	ta.NoError(err)
+	ta.Equal("", info)
+	var message map[string]interface{}
+	ta.NoError(json.Unmarshal(buf, &message))
+
+	command, err = json.Marshal(
+		map[string]string{"prefix": "balancer status", "format": "json"})
+	require.NoError(suite.T(), err)
+	buf, _, err = suite.conn.MgrCommandContext(ctx, [][]byte{command})
+	ta.NoError(err)
+	ta.NoError(json.Unmarshal(buf, &message))
+
+	canceled, cancel2 := context.WithCancel(context.Background())
+	cancel2()
+	buf, _, err = suite.conn.MonCommandContext(canceled, command)
+	ta.ErrorIs(err, context.Canceled)
+	ta.Nil(buf)
+	_, _, err = suite.conn.MgrCommandContext(canceled, [][]byte{command})
+	ta.ErrorIs(err, context.Canceled)
+}
+
+func (suite *RadosTestSuite) TestIOContextContext() {
+	suite.SetupConnection()
+	ta := assert.New(suite.T())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	oid := suite.GenObjectName()
+	ta.NoError(suite.ioctx.WriteFullContext(ctx, oid, []byte("hello")))
+	ta.NoError(suite.ioctx.AppendContext(ctx, oid, []byte(" world")))
+	ta.NoError(suite.ioctx.WriteContext(ctx, oid, []byte("H"), 0))
+
+	buf := make([]byte, 32)
+	n, err := suite.ioctx.ReadContext(ctx, oid, buf, 0)
+	ta.NoError(err)
+	ta.Equal("Hello world", string(buf[:n]))
+
+	copy(buf, "untouched")
+	_, err = suite.ioctx.ReadContext(ctx, oid+"-missing", buf, 0)
+	ta.ErrorIs(err, ErrNotFound)
+	ta.Equal("untouched", string(buf[:9]))
+
+	n, err = suite.ioctx.ReadContext(ctx, oid, buf[:5], 6)
+	ta.NoError(err)
+	ta.Equal("world", string(buf[:n]))
+
+	canceled, cancel2 := context.WithCancel(context.Background())
+	cancel2()
+	_, err = suite.ioctx.ReadContext(canceled, oid, buf, 0)
+	ta.ErrorIs(err, context.Canceled)
+}
+
+func (suite *RadosTestSuite) TestCompletionWaitContext() {
+	suite.SetupConnection()
+	ta := assert.New(suite.T())
+
+	oid := suite.GenObjectName()
+	c, err := suite.ioctx.AioWriteFull(oid, []byte("data"))
+	require.NoError(suite.T(), err)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_, err = c.WaitContext(ctx)
+	ta.NoError(err)
+	c.Release()
+}
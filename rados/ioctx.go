@@ -104,6 +104,10 @@ type IOContext struct {
 	// that Go's GC doesn't trigger the Conn's finalizer before this
 	// IOContext is destroyed.
 	conn *Conn
+
+	// locator is the key set by SetLocator, librados provides no way to
+	// query it.
+	locator string
 }
 
 // validate returns an error if the ioctx is not ready to be used
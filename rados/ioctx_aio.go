@@ -0,0 +1,271 @@
+//go:build ceph_preview
+
+package rados
+
+// #cgo LDFLAGS: -lrados
+// #include <stdlib.h>
+// #include <rados/librados.h>
+import "C"
+
+import (
+	"time"
+	"unsafe"
+
+	"github.com/ceph/go-ceph/internal/cutil"
+)
+
+// AioWrite asynchronously writes len(data) bytes to the object with key oid
+// starting at byte offset offset. The data buffer must not be modified until
+// the returned Completion is done.
+//
+// Implements:
+//
+//	int rados_aio_write(rados_ioctx_t io, const char *oid,
+//	                    rados_completion_t completion,
+//	                    const char *buf, size_t len, uint64_t off);
+func (ioctx *IOContext) AioWrite(oid string, data []byte, offset uint64) (*Completion, error) {
+	c, err := newCompletion(ioctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := ioctx.aioWrite(c, oid, c.pin(data), len(data), offset); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (ioctx *IOContext) aioWrite(
+	c *Completion, oid string, buf *C.char, size int, offset uint64) error {
+
+	cOid := C.CString(oid)
+	defer C.free(unsafe.Pointer(cOid))
+
+	ret := C.rados_aio_write(
+		ioctx.ioctx,
+		cOid,
+		c.comp,
+		buf,
+		C.size_t(size),
+		C.uint64_t(offset))
+	return c.submitted(ret)
+}
+
+// AioWriteFull asynchronously writes len(data) bytes to the object with key
+// oid, atomically replacing any existing object content. The data buffer must
+// not be modified until the returned Completion is done.
+//
+// Implements:
+//
+//	int rados_aio_write_full(rados_ioctx_t io, const char *oid,
+//	                         rados_completion_t completion,
+//	                         const char *buf, size_t len);
+func (ioctx *IOContext) AioWriteFull(oid string, data []byte) (*Completion, error) {
+	c, err := newCompletion(ioctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := ioctx.aioWriteFull(c, oid, c.pin(data), len(data)); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (ioctx *IOContext) aioWriteFull(
+	c *Completion, oid string, buf *C.char, size int) error {
+
+	cOid := C.CString(oid)
+	defer C.free(unsafe.Pointer(cOid))
+
+	ret := C.rados_aio_write_full(
+		ioctx.ioctx,
+		cOid,
+		c.comp,
+		buf,
+		C.size_t(size))
+	return c.submitted(ret)
+}
+
+// AioAppend asynchronously appends len(data) bytes to the object with key oid.
+// The data buffer must not be modified until the returned Completion is done.
+//
+// Implements:
+//
+//	int rados_aio_append(rados_ioctx_t io, const char *oid,
+//	                     rados_completion_t completion,
+//	                     const char *buf, size_t len);
+func (ioctx *IOContext) AioAppend(oid string, data []byte) (*Completion, error) {
+	c, err := newCompletion(ioctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := ioctx.aioAppend(c, oid, c.pin(data), len(data)); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (ioctx *IOContext) aioAppend(
+	c *Completion, oid string, buf *C.char, size int) error {
+
+	cOid := C.CString(oid)
+	defer C.free(unsafe.Pointer(cOid))
+
+	ret := C.rados_aio_append(
+		ioctx.ioctx,
+		cOid,
+		c.comp,
+		buf,
+		C.size_t(size))
+	return c.submitted(ret)
+}
+
+// AioRead asynchronously reads up to len(data) bytes from the object with key
+// oid starting at byte offset offset. The number of bytes read is returned by
+// the Wait method of the returned Completion. The contents of data are
+// undefined until the Completion is done.
+//
+// Implements:
+//
+//	int rados_aio_read(rados_ioctx_t io, const char *oid,
+//	                   rados_completion_t completion,
+//	                   char *buf, size_t len, uint64_t off);
+func (ioctx *IOContext) AioRead(oid string, data []byte, offset uint64) (*Completion, error) {
+	c, err := newCompletion(ioctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := ioctx.aioRead(c, oid, c.pin(data), len(data), offset); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (ioctx *IOContext) aioRead(
+	c *Completion, oid string, buf *C.char, size int, offset uint64) error {
+
+	cOid := C.CString(oid)
+	defer C.free(unsafe.Pointer(cOid))
+
+	ret := C.rados_aio_read(
+		ioctx.ioctx,
+		cOid,
+		c.comp,
+		buf,
+		C.size_t(size),
+		C.uint64_t(offset))
+	return c.submitted(ret)
+}
+
+// AioDelete asynchronously deletes the object with key oid.
+//
+// Implements:
+//
+//	int rados_aio_remove(rados_ioctx_t io, const char *oid,
+//	                     rados_completion_t completion);
+func (ioctx *IOContext) AioDelete(oid string) (*Completion, error) {
+	c, err := newCompletion(ioctx)
+	if err != nil {
+		return nil, err
+	}
+	cOid := C.CString(oid)
+	defer C.free(unsafe.Pointer(cOid))
+
+	ret := C.rados_aio_remove(ioctx.ioctx, cOid, c.comp)
+	if err := c.submitted(ret); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// StatCompletion is returned by AioStat. Once the operation is done the
+// Stat method returns the size and modification time of the object.
+type StatCompletion struct {
+	*Completion
+
+	stat ObjectStat
+	// C returned data:
+	cSize  *C.uint64_t
+	cMtime *C.time_t
+}
+
+// Stat waits for the operation to complete and returns the size of the
+// object and its last modification time.
+func (sc *StatCompletion) Stat() (ObjectStat, error) {
+	if _, err := sc.Wait(); err != nil {
+		return ObjectStat{}, err
+	}
+	return sc.stat, nil
+}
+
+// AioStat asynchronously retrieves the size of the object with key oid and
+// its last modification time.
+//
+// Implements:
+//
+//	int rados_aio_stat(rados_ioctx_t io, const char *o,
+//	                   rados_completion_t completion,
+//	                   uint64_t *psize, time_t *pmtime);
+func (ioctx *IOContext) AioStat(oid string) (*StatCompletion, error) {
+	c, err := newCompletion(ioctx)
+	if err != nil {
+		return nil, err
+	}
+	sc := &StatCompletion{
+		Completion: c,
+		cSize:      (*C.uint64_t)(C.malloc(C.sizeof_uint64_t)),
+		cMtime:     (*C.time_t)(C.malloc(C.sizeof_time_t)),
+	}
+	c.slots = append(c.slots,
+		cutil.CPtr(unsafe.Pointer(sc.cSize)),
+		cutil.CPtr(unsafe.Pointer(sc.cMtime)))
+	c.finish = func(ret C.int) error {
+		if ret < 0 {
+			return getError(ret)
+		}
+		sc.stat = ObjectStat{
+			Size:    uint64(*sc.cSize),
+			ModTime: time.Unix(int64(*sc.cMtime), 0),
+		}
+		return nil
+	}
+	cOid := C.CString(oid)
+	defer C.free(unsafe.Pointer(cOid))
+
+	ret := C.rados_aio_stat(ioctx.ioctx, cOid, c.comp, sc.cSize, sc.cMtime)
+	if err := c.submitted(ret); err != nil {
+		return nil, err
+	}
+	return sc, nil
+}
+
+// AioFlush blocks until all pending asynchronous writes in the IOContext are
+// safe.
+//
+// Implements:
+//
+//	int rados_aio_flush(rados_ioctx_t io);
+func (ioctx *IOContext) AioFlush() error {
+	if err := ioctx.validate(); err != nil {
+		return err
+	}
+	return getError(C.rados_aio_flush(ioctx.ioctx))
+}
+
+// AioFlushAsync returns a Completion that is done once all asynchronous
+// writes pending in the IOContext at the time of the call are safe.
+//
+// Implements:
+//
+//	int rados_aio_flush_async(rados_ioctx_t io,
+//	                          rados_completion_t completion);
+func (ioctx *IOContext) AioFlushAsync() (*Completion, error) {
+	c, err := newCompletion(ioctx)
+	if err != nil {
+		return nil, err
+	}
+	ret := C.rados_aio_flush_async(ioctx.ioctx, c.comp)
+	if err := c.submitted(ret); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
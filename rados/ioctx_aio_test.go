@@ -0,0 +1,120 @@
+//go:build ceph_preview
+
+package rados
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (suite *RadosTestSuite) TestAioWriteRead() {
+	suite.SetupConnection()
+	ta := assert.New(suite.T())
+
+	oid := suite.GenObjectName()
+	data := []byte("input data for the async write test")
+
+	c, err := suite.ioctx.AioWrite(oid, data, 0)
+	require.NoError(suite.T(), err)
+	n, err := c.Wait()
+	ta.NoError(err)
+	ta.Equal(0, n)
+	ta.True(c.IsComplete())
+	ta.True(c.IsSafe())
+	v, err := c.Version()
+	ta.NoError(err)
+	ta.NotZero(v)
+	c.Release()
+
+	buf := make([]byte, 64)
+	c, err = suite.ioctx.AioRead(oid, buf, 0)
+	require.NoError(suite.T(), err)
+	<-c.Done()
+	n, err = c.Wait()
+	ta.NoError(err)
+	ta.Equal(len(data), n)
+	ta.Equal(data, buf[:n])
+	c.Release()
+
+	// reading an object that does not exist fails
+	c, err = suite.ioctx.AioRead(oid+"-missing", buf, 0)
+	require.NoError(suite.T(), err)
+	_, err = c.Wait()
+	ta.ErrorIs(err, ErrNotFound)
+	c.Release()
+}
+
+func (suite *RadosTestSuite) TestAioWriteFullAppend() {
+	suite.SetupConnection()
+	ta := assert.New(suite.T())
+
+	oid := suite.GenObjectName()
+	c, err := suite.ioctx.AioWriteFull(oid, []byte("hello"))
+	require.NoError(suite.T(), err)
+	_, err = c.Wait()
+	ta.NoError(err)
+	c.Release()
+
+	c, err = suite.ioctx.AioAppend(oid, []byte(" world"))
+	require.NoError(suite.T(), err)
+	_, err = c.WaitForSafe()
+	ta.NoError(err)
+	c.Release()
+
+	ta.NoError(suite.ioctx.AioFlush())
+
+	sc, err := suite.ioctx.AioStat(oid)
+	require.NoError(suite.T(), err)
+	st, err := sc.Stat()
+	ta.NoError(err)
+	ta.EqualValues(11, st.Size)
+	sc.Release()
+
+	buf := make([]byte, 32)
+	n, err := suite.ioctx.Read(oid, buf, 0)
+	ta.NoError(err)
+	ta.Equal("hello world", string(buf[:n]))
+
+	c, err = suite.ioctx.AioDelete(oid)
+	require.NoError(suite.T(), err)
+	_, err = c.Wait()
+	ta.NoError(err)
+	c.Release()
+
+	sc, err = suite.ioctx.AioStat(oid)
+	require.NoError(suite.T(), err)
+	_, err = sc.Stat()
+	ta.ErrorIs(err, ErrNotFound)
+	sc.Release()
+}
+
+func (suite *RadosTestSuite) TestAioMany() {
+	suite.SetupConnection()
+	ta := assert.New(suite.T())
+
+	comps := make([]*Completion, 32)
+	oids := make([]string, len(comps))
+	for i := range comps {
+		oids[i] = suite.GenObjectName()
+		c, err := suite.ioctx.AioWrite(oids[i], suite.RandomBytes(4096), 0)
+		require.NoError(suite.T(), err)
+		comps[i] = c
+	}
+	c, err := suite.ioctx.AioFlushAsync()
+	require.NoError(suite.T(), err)
+	_, err = c.Wait()
+	ta.NoError(err)
+	c.Release()
+	for i := range comps {
+		ta.True(comps[i].IsComplete())
+		_, err := comps[i].Wait()
+		ta.NoError(err)
+		comps[i].Release()
+	}
+
+	for i := range oids {
+		st, err := suite.ioctx.Stat(oids[i])
+		ta.NoError(err)
+		ta.EqualValues(4096, st.Size)
+	}
+}
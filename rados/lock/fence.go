@@ -0,0 +1,52 @@
+//go:build !octopus && ceph_preview
+
+package lock
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+
+	"github.com/ceph/go-ceph/common/admin/osd"
+	"github.com/ceph/go-ceph/rados"
+)
+
+// Fence breaks the lock name of the object oid held by clients that are
+// considered dead. Each holder is added to the OSD blocklist before its lock
+// is broken, so that it can not modify data protected by the lock anymore if
+// it turns out to be alive. Blocklist entries expire after expire, zero uses
+// the cluster default. Fence returns the number of holders that were fenced.
+//
+// Fence must only be called for holders that are known to be dead, for
+// example because their lease expired without being renewed.
+func Fence(ioctx *rados.IOContext, oid, name string, osda *osd.Admin, expire time.Duration) (int, error) {
+	info, err := ioctx.ListLockers(oid, name)
+	if err != nil {
+		return 0, err
+	}
+	n := min(info.NumLockers, len(info.Clients), len(info.Cookies), len(info.Addrs))
+	fenced := 0
+	for i := 0; i < n; i++ {
+		err := osda.OSDBlocklistAdd(osd.AddressEntry{
+			Addr:   info.Addrs[i],
+			Expire: expire.Seconds(),
+		})
+		if err != nil {
+			return fenced, fmt.Errorf("blocklisting %s: %w", info.Addrs[i], err)
+		}
+		ret, err := ioctx.BreakLock(oid, name, info.Clients[i], info.Cookies[i])
+		if err != nil {
+			return fenced, err
+		}
+		switch ret {
+		case -int(syscall.ENOENT):
+			// the holder released the lock meanwhile
+			continue
+		case -int(syscall.EINVAL):
+			return fenced, fmt.Errorf("breaking lock of %s: invalid client",
+				info.Clients[i])
+		}
+		fenced++
+	}
+	return fenced, nil
+}
@@ -0,0 +1,73 @@
+//go:build !octopus && ceph_preview
+
+package lock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ceph/go-ceph/common/admin/osd"
+	"github.com/ceph/go-ceph/internal/admintest"
+	"github.com/ceph/go-ceph/rados"
+)
+
+func testConn(t *testing.T) *rados.Conn {
+	conn := admintest.NewConn(t)
+	t.Cleanup(conn.Shutdown)
+	return conn
+}
+
+func TestFence(t *testing.T) {
+	ioctx := testPool.IOContext(t)
+	conn := testConn(t)
+	osda := osd.NewFromConn(conn)
+
+	// the holder uses its own connection so that fencing it does not
+	// blocklist the connection of the test
+	holderConn := testConn(t)
+	pool, err := ioctx.GetPoolName()
+	require.NoError(t, err)
+	holderIOCtx, err := holderConn.OpenIOContext(pool)
+	require.NoError(t, err)
+	t.Cleanup(holderIOCtx.Destroy)
+	holderIOCtx.SetNamespace(t.Name())
+	holder := NewMutex(holderIOCtx, "obj", nil)
+	require.NoError(t, holder.Lock(context.Background()))
+	// stop the renewal of the fenced holder, which fails to unlock
+	t.Cleanup(func() { _ = holder.Unlock() })
+
+	info, err := ioctx.ListLockers("obj", defaultName)
+	require.NoError(t, err)
+	require.Equal(t, 1, info.NumLockers)
+	t.Cleanup(func() {
+		assert.NoError(t, osda.OSDBlocklistRemove(osd.AddressEntry{Addr: info.Addrs[0]}))
+	})
+
+	n, err := Fence(ioctx, "obj", defaultName, osda, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	bl, err := osda.OSDBlocklist()
+	require.NoError(t, err)
+	found := false
+	for _, b := range *bl {
+		if b.Addr == info.Addrs[0] {
+			found = true
+		}
+	}
+	assert.True(t, found)
+
+	m := NewMutex(ioctx, "obj", nil)
+	ok, err := m.TryLock()
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.NoError(t, m.Unlock())
+
+	n, err = Fence(ioctx, "obj", defaultName, osda, time.Minute)
+	assert.NoError(t, err)
+	assert.Zero(t, n)
+}
@@ -0,0 +1,480 @@
+//go:build ceph_preview
+
+/*
+Package lock implements mutual exclusion between clients with the advisory
+locks of RADOS objects.
+
+A lock is held for a limited time, its lease duration, and is renewed in the
+background while it is held. If the lock can not be renewed in time, for
+example because the client lost its connection to the cluster and another
+client broke the lock, it is lost. The Done and Err methods report the loss
+of a lock, so that work protected by it can be stopped. A lost lock must
+still be unlocked, which releases whatever is left of it on the OSD.
+*/
+package lock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	mrand "math/rand/v2"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ceph/go-ceph/internal/log"
+	"github.com/ceph/go-ceph/rados"
+)
+
+const (
+	defaultName          = "lock"
+	defaultDuration      = 30 * time.Second
+	defaultRetryDelay    = 100 * time.Millisecond
+	defaultMaxRetryDelay = 5 * time.Second
+
+	// flagMayRenew is LOCK_FLAG_MAY_RENEW
+	flagMayRenew = byte(1)
+)
+
+var (
+	// ErrLocked is returned when trying to acquire a lock that the Mutex or
+	// RWMutex already holds.
+	ErrLocked = errors.New("lock is already held")
+	// ErrNotLocked is returned when releasing a lock that is not held.
+	ErrNotLocked = errors.New("lock is not held")
+	// ErrLockLost is returned when the lease of a lock could not be renewed.
+	ErrLockLost = errors.New("lock lost")
+
+	errBusy = errors.New("lock is busy")
+)
+
+// Options control a Mutex or RWMutex. Zero values select defaults.
+type Options struct {
+	// Name is the name of the lock. An object can have multiple locks with
+	// different names. The default is "lock".
+	Name string
+	// Cookie identifies the holder of the lock together with the client
+	// instance. The default is a random value.
+	Cookie string
+	// Tag is the tag of shared locks. All holders of a shared lock must use
+	// the same tag.
+	Tag string
+	// Description is shown when listing the holders of a lock.
+	Description string
+	// Duration is the lease duration after which the lock expires unless it
+	// is renewed. The default is 30 seconds.
+	Duration time.Duration
+	// RenewInterval is the time between renewals of the lease. The default is
+	// a third of Duration.
+	RenewInterval time.Duration
+	// ExpiryMargin is how long before the lease expires the lock is
+	// considered lost if it could not be renewed, to allow for the latency
+	// of the lock operations and for clock drift. The default is a tenth of
+	// Duration.
+	ExpiryMargin time.Duration
+	// RetryDelay is the initial delay between attempts to acquire a busy
+	// lock. The delay doubles after each attempt up to MaxRetryDelay.
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+}
+
+func (o *Options) setDefaults() {
+	if o.Name == "" {
+		o.Name = defaultName
+	}
+	if o.Cookie == "" {
+		b := make([]byte, 16)
+		_, _ = rand.Read(b)
+		o.Cookie = hex.EncodeToString(b)
+	}
+	if o.Duration <= 0 {
+		o.Duration = defaultDuration
+	}
+	if o.RenewInterval <= 0 || o.RenewInterval >= o.Duration {
+		o.RenewInterval = o.Duration / 3
+	}
+	if o.ExpiryMargin <= 0 || o.ExpiryMargin >= o.Duration {
+		o.ExpiryMargin = o.Duration / 10
+	}
+	if o.RetryDelay <= 0 {
+		o.RetryDelay = defaultRetryDelay
+	}
+	if o.MaxRetryDelay < o.RetryDelay {
+		o.MaxRetryDelay = max(defaultMaxRetryDelay, o.RetryDelay)
+	}
+}
+
+// lease is a held lock that is kept alive by renewing it.
+type lease struct {
+	shared bool
+	stop   chan struct{}
+	// done is closed when the lease is stopped or lost, settled once no
+	// renewal is in flight anymore
+	done    chan struct{}
+	settled chan struct{}
+
+	mutex sync.Mutex
+	err   error
+}
+
+func (ls *lease) setErr(err error) {
+	ls.mutex.Lock()
+	defer ls.mutex.Unlock()
+	ls.err = err
+}
+
+func (ls *lease) getErr() error {
+	ls.mutex.Lock()
+	defer ls.mutex.Unlock()
+	return ls.err
+}
+
+// locker implements acquiring, renewing and releasing a lock. It holds at
+// most one lease at a time.
+type locker struct {
+	ioctx *rados.IOContext
+	oid   string
+	opts  Options
+
+	// acquire acquires or renews the lock and release releases it, they
+	// are take and give unless replaced by tests
+	acquire func(shared bool) error
+	release func() (int, error)
+
+	mutex sync.Mutex
+	held  *lease
+	// last is the most recent lease, which may no longer be held
+	last *lease
+}
+
+func newLocker(ioctx *rados.IOContext, oid string, opts *Options) *locker {
+	l := &locker{ioctx: ioctx, oid: oid}
+	if opts != nil {
+		l.opts = *opts
+	}
+	l.opts.setDefaults()
+	l.acquire = l.take
+	l.release = l.give
+	return l
+}
+
+// take acquires or renews the lock.
+func (l *locker) take(shared bool) error {
+	var (
+		ret   int
+		err   error
+		flags = flagMayRenew
+	)
+	if shared {
+		ret, err = l.ioctx.LockShared(l.oid, l.opts.Name, l.opts.Cookie,
+			l.opts.Tag, l.opts.Description, l.opts.Duration, &flags)
+	} else {
+		ret, err = l.ioctx.LockExclusive(l.oid, l.opts.Name, l.opts.Cookie,
+			l.opts.Description, l.opts.Duration, &flags)
+	}
+	switch {
+	case err != nil:
+		return err
+	case ret == -int(syscall.EBUSY):
+		return errBusy
+	}
+	// EEXIST, the lock is already held by us, is not expected with the
+	// renew flag but means the lock is held as well.
+	return nil
+}
+
+// give releases the lock.
+func (l *locker) give() (int, error) {
+	return l.ioctx.Unlock(l.oid, l.opts.Name, l.opts.Cookie)
+}
+
+func (l *locker) tryLock(shared bool) (bool, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.held != nil {
+		return false, ErrLocked
+	}
+	// the lease starts when the lock operation is sent, not when it returns
+	start := time.Now()
+	err := l.acquire(shared)
+	if errors.Is(err, errBusy) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	ls := &lease{
+		shared:  shared,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+		settled: make(chan struct{}),
+	}
+	l.held = ls
+	l.last = ls
+	go l.renew(ls, start)
+	return true, nil
+}
+
+func (l *locker) lock(ctx context.Context, shared bool) error {
+	delay := l.opts.RetryDelay
+	for {
+		ok, err := l.tryLock(shared)
+		if ok || err != nil {
+			return err
+		}
+		// add up to 50% of jitter so that waiting clients spread out
+		t := time.NewTimer(delay + mrand.N(delay/2+1))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+		delay = min(2*delay, l.opts.MaxRetryDelay)
+	}
+}
+
+// renew renews the lease until it is stopped or lost. The lease is lost if
+// another client holds the lock or the lease, which started when the lock
+// operation that last succeeded was sent, is about to expire. A renewal that
+// hangs does not delay the loss.
+func (l *locker) renew(ls *lease, renewed time.Time) {
+	defer close(ls.done)
+	t := time.NewTicker(l.opts.RenewInterval)
+	defer t.Stop()
+	expiry := time.NewTimer(time.Until(l.expiry(renewed)))
+	defer expiry.Stop()
+
+	var (
+		// pending receives the result of the renewal in flight, if any
+		pending <-chan error
+		start   time.Time
+		lastErr error
+	)
+	for {
+		select {
+		case <-ls.stop:
+			// a renewal completing after the unlock would take the lock
+			// again
+			if pending != nil {
+				<-pending
+			}
+			close(ls.settled)
+			return
+		case <-expiry.C:
+			if lastErr == nil {
+				lastErr = errors.New("lease expired")
+			}
+			ls.setErr(fmt.Errorf("%w: %v", ErrLockLost, lastErr))
+			go l.settle(ls, pending)
+			return
+		case <-t.C:
+			if pending == nil {
+				start = time.Now()
+				ch := make(chan error, 1)
+				go func() {
+					ch <- l.acquire(ls.shared)
+				}()
+				pending = ch
+			}
+		case err := <-pending:
+			pending = nil
+			if err == nil {
+				renewed = start
+				expiry.Reset(time.Until(l.expiry(renewed)))
+				continue
+			}
+			if errors.Is(err, errBusy) {
+				ls.setErr(fmt.Errorf("%w: %v", ErrLockLost, err))
+				close(ls.settled)
+				return
+			}
+			lastErr = err
+			log.Debugf("renewing lock %q of %q: %v", l.opts.Name, l.oid, err)
+		}
+	}
+}
+
+// settle waits for the renewal in flight when the lease was lost, if any. If
+// the renewal succeeded nonetheless the lock is held again, so it is released
+// to match the loss.
+func (l *locker) settle(ls *lease, pending <-chan error) {
+	defer close(ls.settled)
+	if pending == nil || <-pending != nil {
+		return
+	}
+	if _, err := l.release(); err != nil {
+		log.Debugf("releasing lost lock %q of %q: %v", l.opts.Name, l.oid, err)
+	}
+}
+
+// expiry returns the time at which a lease that started at start is
+// considered lost.
+func (l *locker) expiry(start time.Time) time.Time {
+	return start.Add(l.opts.Duration - l.opts.ExpiryMargin)
+}
+
+func (l *locker) unlock(shared bool) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	ls := l.held
+	if ls == nil || ls.shared != shared {
+		return ErrNotLocked
+	}
+	l.held = nil
+	close(ls.stop)
+	<-ls.done
+	<-ls.settled
+	// a lost lease may still be held on the OSD, for up to ExpiryMargin, so
+	// the lock is released in any case
+	lostErr := ls.getErr()
+	ret, err := l.release()
+	if lostErr != nil {
+		return lostErr
+	}
+	if err != nil {
+		return err
+	}
+	if ret == -int(syscall.ENOENT) {
+		// the lease expired and the lock was broken or taken over
+		ls.setErr(ErrLockLost)
+		return ErrLockLost
+	}
+	return nil
+}
+
+var closedChan = func() chan struct{} {
+	c := make(chan struct{})
+	close(c)
+	return c
+}()
+
+func (l *locker) done() <-chan struct{} {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.held == nil {
+		return closedChan
+	}
+	return l.held.done
+}
+
+func (l *locker) err() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.last == nil {
+		return ErrNotLocked
+	}
+	if err := l.last.getErr(); err != nil {
+		return err
+	}
+	if l.held == nil {
+		return ErrNotLocked
+	}
+	return nil
+}
+
+// Mutex is an exclusive lock on a RADOS object.
+type Mutex struct {
+	l *locker
+}
+
+// NewMutex returns a new Mutex for the object oid. If opts is nil defaults
+// are used. The IOContext must not be destroyed while the lock is held.
+func NewMutex(ioctx *rados.IOContext, oid string, opts *Options) *Mutex {
+	return &Mutex{l: newLocker(ioctx, oid, opts)}
+}
+
+// Lock acquires the lock, waiting until it becomes available or ctx is done.
+// It returns ErrLocked if the Mutex already holds the lock. A lost lock
+// counts as held until Unlock is called.
+func (m *Mutex) Lock(ctx context.Context) error {
+	return m.l.lock(ctx, false)
+}
+
+// TryLock acquires the lock if it is available and reports whether it did.
+func (m *Mutex) TryLock() (bool, error) {
+	return m.l.tryLock(false)
+}
+
+// Unlock releases the lock. It returns ErrLockLost if the lock was lost
+// while it was held. Unlock must also be called after the lock was lost,
+// before the lock can be acquired again.
+func (m *Mutex) Unlock() error {
+	return m.l.unlock(false)
+}
+
+// Done returns a channel that is closed when the lock is lost. The channel
+// is only valid while the lock is held, if the lock is not held a closed
+// channel is returned.
+func (m *Mutex) Done() <-chan struct{} {
+	return m.l.done()
+}
+
+// Err returns nil while the lock is held, ErrLockLost if the most recently
+// held lock was lost and ErrNotLocked otherwise.
+func (m *Mutex) Err() error {
+	return m.l.err()
+}
+
+// RWMutex is a lock on a RADOS object that can be held exclusively by a
+// single writer or shared by multiple readers. An RWMutex holds either the
+// exclusive or the shared lock at a time. Like with Mutex, a lost lock must
+// be released with Unlock or RUnlock before a lock can be acquired again.
+type RWMutex struct {
+	l *locker
+}
+
+// NewRWMutex returns a new RWMutex for the object oid. If opts is nil
+// defaults are used. The IOContext must not be destroyed while the lock is
+// held.
+func NewRWMutex(ioctx *rados.IOContext, oid string, opts *Options) *RWMutex {
+	return &RWMutex{l: newLocker(ioctx, oid, opts)}
+}
+
+// Lock acquires the exclusive lock, waiting until it becomes available or
+// ctx is done.
+func (rw *RWMutex) Lock(ctx context.Context) error {
+	return rw.l.lock(ctx, false)
+}
+
+// TryLock acquires the exclusive lock if it is available and reports
+// whether it did.
+func (rw *RWMutex) TryLock() (bool, error) {
+	return rw.l.tryLock(false)
+}
+
+// Unlock releases the exclusive lock.
+func (rw *RWMutex) Unlock() error {
+	return rw.l.unlock(false)
+}
+
+// RLock acquires the shared lock, waiting until it becomes available or ctx
+// is done.
+func (rw *RWMutex) RLock(ctx context.Context) error {
+	return rw.l.lock(ctx, true)
+}
+
+// TryRLock acquires the shared lock if it is available and reports whether
+// it did.
+func (rw *RWMutex) TryRLock() (bool, error) {
+	return rw.l.tryLock(true)
+}
+
+// RUnlock releases the shared lock.
+func (rw *RWMutex) RUnlock() error {
+	return rw.l.unlock(true)
+}
+
+// Done returns a channel that is closed when the held lock is lost. If no
+// lock is held a closed channel is returned.
+func (rw *RWMutex) Done() <-chan struct{} {
+	return rw.l.done()
+}
+
+// Err returns nil while a lock is held, ErrLockLost if the most recently
+// held lock was lost and ErrNotLocked otherwise.
+func (rw *RWMutex) Err() error {
+	return rw.l.err()
+}
@@ -0,0 +1,253 @@
+//go:build ceph_preview
+
+package lock
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ceph/go-ceph/internal/radostest"
+)
+
+var testPool radostest.Pool
+
+func TestMain(m *testing.M) {
+	os.Exit(testPool.Run(m))
+}
+
+func TestOptionsDefaults(t *testing.T) {
+	var o Options
+	o.setDefaults()
+	assert.Equal(t, defaultName, o.Name)
+	assert.Len(t, o.Cookie, 32)
+	assert.Equal(t, defaultDuration, o.Duration)
+	assert.Equal(t, defaultDuration/3, o.RenewInterval)
+	assert.Equal(t, defaultDuration/10, o.ExpiryMargin)
+	assert.Equal(t, defaultRetryDelay, o.RetryDelay)
+	assert.Equal(t, defaultMaxRetryDelay, o.MaxRetryDelay)
+
+	var o2 Options
+	o2.setDefaults()
+	assert.NotEqual(t, o.Cookie, o2.Cookie)
+
+	o = Options{Duration: time.Second, RenewInterval: 2 * time.Second, RetryDelay: time.Minute}
+	o.setDefaults()
+	assert.Equal(t, time.Second/3, o.RenewInterval)
+	assert.Equal(t, time.Second/10, o.ExpiryMargin)
+	assert.Equal(t, time.Minute, o.MaxRetryDelay)
+}
+
+func TestLeaseExpiry(t *testing.T) {
+	opts := &Options{
+		Duration:      time.Second,
+		RenewInterval: 100 * time.Millisecond,
+		ExpiryMargin:  100 * time.Millisecond,
+	}
+
+	t.Run("renewed", func(t *testing.T) {
+		l := newLocker(nil, "obj", opts)
+		var calls atomic.Int32
+		l.acquire = func(bool) error {
+			calls.Add(1)
+			return nil
+		}
+		ok, err := l.tryLock(false)
+		require.NoError(t, err)
+		require.True(t, ok)
+		time.Sleep(1500 * time.Millisecond)
+		assert.NoError(t, l.err())
+		assert.Greater(t, calls.Load(), int32(5))
+		ls := l.held
+		close(ls.stop)
+		<-ls.done
+	})
+
+	t.Run("hanging", func(t *testing.T) {
+		l := newLocker(nil, "obj", opts)
+		hang := make(chan struct{})
+		var calls, releases atomic.Int32
+		l.acquire = func(bool) error {
+			if calls.Add(1) == 1 {
+				// the lock operation is slow, renewals hang
+				time.Sleep(200 * time.Millisecond)
+				return nil
+			}
+			<-hang
+			return nil
+		}
+		l.release = func() (int, error) {
+			releases.Add(1)
+			return 0, nil
+		}
+		start := time.Now()
+		ok, err := l.tryLock(false)
+		require.NoError(t, err)
+		require.True(t, ok)
+		select {
+		case <-l.done():
+		case <-time.After(2 * time.Second):
+			t.Fatal("lock loss not detected")
+		}
+		// the loss is reported ExpiryMargin before the lease, which started
+		// when the lock operation was sent, expires on the OSD
+		elapsed := time.Since(start)
+		assert.GreaterOrEqual(t, elapsed, 900*time.Millisecond)
+		assert.Less(t, elapsed, time.Second)
+		assert.ErrorIs(t, l.err(), ErrLockLost)
+		assert.EqualValues(t, 2, calls.Load())
+
+		// the hanging renewal succeeds late and is released again
+		close(hang)
+		ls := l.held
+		<-ls.settled
+		assert.EqualValues(t, 1, releases.Load())
+
+		_, err = l.tryLock(false)
+		assert.ErrorIs(t, err, ErrLocked)
+		assert.ErrorIs(t, l.unlock(false), ErrLockLost)
+		assert.EqualValues(t, 2, releases.Load())
+		ok, err = l.tryLock(false)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.NoError(t, l.unlock(false))
+	})
+
+	t.Run("failing", func(t *testing.T) {
+		l := newLocker(nil, "obj", opts)
+		var calls, releases atomic.Int32
+		l.acquire = func(bool) error {
+			if calls.Add(1) == 1 {
+				return nil
+			}
+			return errors.New("transient")
+		}
+		l.release = func() (int, error) {
+			releases.Add(1)
+			return 0, nil
+		}
+		ok, err := l.tryLock(false)
+		require.NoError(t, err)
+		require.True(t, ok)
+		select {
+		case <-l.done():
+		case <-time.After(2 * time.Second):
+			t.Fatal("lock loss not detected")
+		}
+		assert.ErrorContains(t, l.err(), "transient")
+		// the lock is still released on the OSD
+		assert.ErrorIs(t, l.unlock(false), ErrLockLost)
+		assert.EqualValues(t, 1, releases.Load())
+	})
+}
+
+func TestMutex(t *testing.T) {
+	ioctx := testPool.IOContext(t)
+	opts := &Options{Duration: 3 * time.Second, RetryDelay: 10 * time.Millisecond}
+	m1 := NewMutex(ioctx, "obj", opts)
+	m2 := NewMutex(ioctx, "obj", opts)
+
+	assert.ErrorIs(t, m1.Err(), ErrNotLocked)
+	assert.ErrorIs(t, m1.Unlock(), ErrNotLocked)
+
+	require.NoError(t, m1.Lock(context.Background()))
+	assert.NoError(t, m1.Err())
+	assert.ErrorIs(t, m1.Lock(context.Background()), ErrLocked)
+
+	ok, err := m2.TryLock()
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, m2.Lock(ctx), context.DeadlineExceeded)
+
+	// the lease is renewed beyond its duration
+	time.Sleep(4 * time.Second)
+	assert.NoError(t, m1.Err())
+	select {
+	case <-m1.Done():
+		t.Fatal("lock lost")
+	default:
+	}
+	ok, err = m2.TryLock()
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	locked := make(chan error)
+	go func() {
+		locked <- m2.Lock(context.Background())
+	}()
+	require.NoError(t, m1.Unlock())
+	require.NoError(t, <-locked)
+	assert.ErrorIs(t, m1.Err(), ErrNotLocked)
+	assert.NoError(t, m2.Unlock())
+}
+
+func TestMutexLost(t *testing.T) {
+	ioctx := testPool.IOContext(t)
+	m := NewMutex(ioctx, "obj", &Options{Duration: 3 * time.Second})
+	require.NoError(t, m.Lock(context.Background()))
+
+	info, err := ioctx.ListLockers("obj", defaultName)
+	require.NoError(t, err)
+	require.Equal(t, 1, info.NumLockers)
+	ret, err := ioctx.BreakLock("obj", defaultName, info.Clients[0], info.Cookies[0])
+	require.NoError(t, err)
+	require.Zero(t, ret)
+
+	// another client takes over the lock
+	other := NewMutex(ioctx, "obj", nil)
+	require.NoError(t, other.Lock(context.Background()))
+	defer func() { assert.NoError(t, other.Unlock()) }()
+
+	select {
+	case <-m.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("lock loss not detected")
+	}
+	assert.ErrorIs(t, m.Err(), ErrLockLost)
+	assert.ErrorIs(t, m.Unlock(), ErrLockLost)
+	assert.ErrorIs(t, m.Err(), ErrLockLost)
+}
+
+func TestRWMutex(t *testing.T) {
+	ioctx := testPool.IOContext(t)
+	opts := func() *Options {
+		return &Options{Tag: "tag", RetryDelay: 10 * time.Millisecond}
+	}
+	r1 := NewRWMutex(ioctx, "obj", opts())
+	r2 := NewRWMutex(ioctx, "obj", opts())
+	w := NewRWMutex(ioctx, "obj", opts())
+
+	require.NoError(t, r1.RLock(context.Background()))
+	ok, err := r2.TryRLock()
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.ErrorIs(t, r2.Unlock(), ErrNotLocked)
+
+	ok, err = w.TryLock()
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	info, err := ioctx.ListLockers("obj", defaultName)
+	require.NoError(t, err)
+	assert.Equal(t, 2, info.NumLockers)
+	assert.False(t, info.Exclusive)
+	assert.Equal(t, "tag", info.Tag)
+
+	assert.NoError(t, r1.RUnlock())
+	assert.NoError(t, r2.RUnlock())
+
+	require.NoError(t, w.Lock(context.Background()))
+	ok, err = r1.TryRLock()
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.NoError(t, w.Unlock())
+}
@@ -0,0 +1,386 @@
+//go:build ceph_preview
+
+package rados
+
+/*
+#cgo LDFLAGS: -lrados
+#include <stdlib.h>
+#include <rados/librados.h>
+
+extern void monitorLogCallback(uintptr_t, char*, char*, char*, char*,
+	uint64_t, uint64_t, uint64_t, char*, char*);
+
+// inline wrapper to cast uintptr_t to void*
+static inline int wrap_rados_monitor_log2(rados_t cluster, const char *level,
+	uintptr_t arg) {
+		return rados_monitor_log2(cluster, level,
+			(rados_log_callback2_t)monitorLogCallback, (void*)arg);
+};
+
+static inline int wrap_rados_monitor_log2_stop(rados_t cluster) {
+	return rados_monitor_log2(cluster, "", NULL, NULL);
+};
+*/
+import "C"
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"github.com/ceph/go-ceph/internal/callbacks"
+)
+
+// LogLevel is the minimum severity of the cluster log entries received by a
+// MonitorLog.
+type LogLevel string
+
+const (
+	// LogLevelDebug receives all entries.
+	LogLevelDebug = LogLevel("debug")
+	// LogLevelInfo receives informational entries and above.
+	LogLevelInfo = LogLevel("info")
+	// LogLevelSec receives security related entries and above.
+	LogLevelSec = LogLevel("sec")
+	// LogLevelWarn receives warnings and errors.
+	LogLevelWarn = LogLevel("warn")
+	// LogLevelError receives errors only.
+	LogLevelError = LogLevel("error")
+)
+
+const (
+	// LogChannelCluster is the channel of the cluster log.
+	LogChannelCluster = "cluster"
+	// LogChannelAudit is the channel of the audit log.
+	LogChannelAudit = "audit"
+)
+
+var (
+	// ErrInvalidLogLevel is returned by MonitorLog and SetLevel for unknown
+	// log levels.
+	ErrInvalidLogLevel = errors.New("invalid log level")
+	// ErrMonitorLogClosed is returned by SetLevel if the MonitorLog is
+	// closed.
+	ErrMonitorLogClosed = errors.New("monitor log closed")
+)
+
+// logLevels are ordered by severity, like clog_type.
+var logLevels = []LogLevel{
+	LogLevelDebug,
+	LogLevelInfo,
+	LogLevelSec,
+	LogLevelWarn,
+	LogLevelError,
+}
+
+// logEntryLevels maps the levels as reported in log entries to LogLevels.
+var logEntryLevels = map[string]LogLevel{
+	"[DBG]": LogLevelDebug,
+	"[INF]": LogLevelInfo,
+	"[SEC]": LogLevelSec,
+	"[WRN]": LogLevelWarn,
+	"[ERR]": LogLevelError,
+}
+
+// monitorLogBuffer is the number of entries buffered for each MonitorLog.
+const monitorLogBuffer = 128
+
+// LogEntry is an entry of the cluster log.
+type LogEntry struct {
+	// Line is the entry formatted as in the log file.
+	Line string
+	// Channel is the log channel, for example "cluster" or "audit".
+	Channel string
+	// Who is the instance that logged the entry, Name its entity name, for
+	// example "mon.a".
+	Who   string
+	Name  string
+	Stamp time.Time
+	Seq   uint64
+	Level LogLevel
+	// Message is the logged message.
+	Message string
+}
+
+// MonitorLog receives entries of the cluster log.
+//
+// librados only supports a single subscription per connection, so all
+// MonitorLogs of a Conn share one subscription at the lowest level any of
+// them requested. The subscription is renewed when the level changes and
+// dropped when the last MonitorLog is closed or the Conn is shut down.
+type MonitorLog struct {
+	hub     *monitorLogHub
+	channel string
+	level   int
+	entries chan LogEntry
+	dropped atomic.Uint64
+	closed  bool
+}
+
+// monitorLogHub multiplexes the single subscription of a connection.
+type monitorLogHub struct {
+	cluster C.rados_t
+	cbIndex uintptr
+
+	// subMutex serializes changes of the subscription. librados holds its
+	// client lock while calling the callback, so it must not be held by the
+	// callback.
+	subMutex sync.Mutex
+	level    LogLevel
+	released bool
+
+	mutex sync.RWMutex
+	logs  map[*MonitorLog]struct{}
+}
+
+var (
+	monitorLogCallbacks = callbacks.New()
+	monitorLogHubs      = map[C.rados_t]*monitorLogHub{}
+	monitorLogHubsMtx   sync.Mutex
+)
+
+func init() {
+	shutdownHooks = append(shutdownHooks, closeMonitorLogs)
+}
+
+// MonitorLog subscribes to the cluster log. Entries of level and above are
+// delivered on the channel returned by the Entries method. If channel is
+// not empty, only entries of that log channel, for example
+// LogChannelCluster, are delivered.
+//
+// Entries are delivered from a librados thread that must not block. If the
+// Entries channel is full, entries are dropped and counted, see Dropped.
+// The MonitorLog must be closed with the Close method.
+//
+// Implements:
+//
+//	int rados_monitor_log2(rados_t cluster, const char *level,
+//	                       rados_log_callback2_t cb, void *arg);
+func (c *Conn) MonitorLog(level LogLevel, channel string) (*MonitorLog, error) {
+	if err := c.ensureConnected(); err != nil {
+		return nil, err
+	}
+	prio := slices.Index(logLevels, level)
+	if prio < 0 {
+		return nil, ErrInvalidLogLevel
+	}
+
+	hub := lockMonitorLogHub(c.cluster)
+	defer hub.subMutex.Unlock()
+	m := &MonitorLog{
+		hub:     hub,
+		channel: channel,
+		level:   prio,
+		entries: make(chan LogEntry, monitorLogBuffer),
+	}
+	hub.mutex.Lock()
+	hub.logs[m] = struct{}{}
+	hub.mutex.Unlock()
+	if err := hub.resubscribe(); err != nil {
+		hub.mutex.Lock()
+		delete(hub.logs, m)
+		hub.mutex.Unlock()
+		return nil, err
+	}
+	return m, nil
+}
+
+// Entries returns the channel the log entries are delivered on. It is
+// closed when the MonitorLog is closed or the Conn is shut down.
+func (m *MonitorLog) Entries() <-chan LogEntry {
+	return m.entries
+}
+
+// Dropped returns the number of entries dropped because the Entries channel
+// was full.
+func (m *MonitorLog) Dropped() uint64 {
+	return m.dropped.Load()
+}
+
+// SetLevel changes the minimum level of the entries delivered. The
+// subscription of the connection is renewed if required.
+func (m *MonitorLog) SetLevel(level LogLevel) error {
+	prio := slices.Index(logLevels, level)
+	if prio < 0 {
+		return ErrInvalidLogLevel
+	}
+	hub := m.hub
+	hub.subMutex.Lock()
+	defer hub.subMutex.Unlock()
+	hub.mutex.Lock()
+	if m.closed {
+		hub.mutex.Unlock()
+		return ErrMonitorLogClosed
+	}
+	old := m.level
+	m.level = prio
+	hub.mutex.Unlock()
+	if err := hub.resubscribe(); err != nil {
+		hub.mutex.Lock()
+		m.level = old
+		hub.mutex.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Close ends the subscription and closes the Entries channel.
+func (m *MonitorLog) Close() error {
+	hub := m.hub
+	hub.subMutex.Lock()
+	defer hub.subMutex.Unlock()
+	hub.mutex.Lock()
+	if m.closed {
+		hub.mutex.Unlock()
+		return nil
+	}
+	m.close()
+	hub.mutex.Unlock()
+	return hub.resubscribe()
+}
+
+// close must be called with the mutex of the hub locked.
+func (m *MonitorLog) close() {
+	m.closed = true
+	delete(m.hub.logs, m)
+	close(m.entries)
+}
+
+// resubscribe updates the subscription to the lowest level requested. It
+// must be called with subMutex locked.
+func (hub *monitorLogHub) resubscribe() error {
+	hub.mutex.RLock()
+	var level LogLevel
+	if len(hub.logs) > 0 {
+		prio := len(logLevels) - 1
+		for m := range hub.logs {
+			prio = min(prio, m.level)
+		}
+		level = logLevels[prio]
+	}
+	hub.mutex.RUnlock()
+
+	if level == hub.level {
+		return nil
+	}
+	if level == "" {
+		hub.level = ""
+		ret := C.wrap_rados_monitor_log2_stop(hub.cluster)
+		hub.release()
+		return getError(ret)
+	}
+	clevel := C.CString(string(level))
+	defer C.free(unsafe.Pointer(clevel))
+	ret := C.wrap_rados_monitor_log2(hub.cluster, clevel, C.uintptr_t(hub.cbIndex))
+	if err := getError(ret); err != nil {
+		return err
+	}
+	hub.level = level
+	return nil
+}
+
+// lockMonitorLogHub returns the hub of a connection, creating it if needed,
+// with its subMutex locked.
+func lockMonitorLogHub(cluster C.rados_t) *monitorLogHub {
+	for {
+		monitorLogHubsMtx.Lock()
+		hub := monitorLogHubs[cluster]
+		if hub == nil {
+			hub = &monitorLogHub{
+				cluster: cluster,
+				logs:    map[*MonitorLog]struct{}{},
+			}
+			hub.cbIndex = monitorLogCallbacks.Add(hub)
+			monitorLogHubs[cluster] = hub
+		}
+		monitorLogHubsMtx.Unlock()
+
+		hub.subMutex.Lock()
+		if !hub.released {
+			return hub
+		}
+		// the last subscription of the hub was closed meanwhile
+		hub.subMutex.Unlock()
+	}
+}
+
+// release forgets the hub once it has no subscription. It must be called
+// with subMutex locked.
+func (hub *monitorLogHub) release() {
+	hub.released = true
+	monitorLogHubsMtx.Lock()
+	defer monitorLogHubsMtx.Unlock()
+	if monitorLogHubs[hub.cluster] == hub {
+		delete(monitorLogHubs, hub.cluster)
+	}
+	monitorLogCallbacks.Remove(hub.cbIndex)
+}
+
+func (hub *monitorLogHub) deliver(e LogEntry) {
+	prio := slices.Index(logLevels, e.Level)
+	if prio < 0 {
+		// deliver entries of unknown levels to everyone
+		prio = len(logLevels)
+	}
+	hub.mutex.RLock()
+	defer hub.mutex.RUnlock()
+	for m := range hub.logs {
+		if prio < m.level || (m.channel != "" && m.channel != e.Channel) {
+			continue
+		}
+		select {
+		case m.entries <- e:
+		default:
+			m.dropped.Add(1)
+		}
+	}
+}
+
+// closeMonitorLogs closes all MonitorLogs of a connection that is shut
+// down.
+func closeMonitorLogs(c *Conn) {
+	monitorLogHubsMtx.Lock()
+	hub := monitorLogHubs[c.cluster]
+	monitorLogHubsMtx.Unlock()
+	if hub == nil {
+		return
+	}
+	hub.subMutex.Lock()
+	defer hub.subMutex.Unlock()
+	hub.mutex.Lock()
+	for m := range hub.logs {
+		m.close()
+	}
+	hub.mutex.Unlock()
+	_ = hub.resubscribe()
+}
+
+//export monitorLogCallback
+func monitorLogCallback(
+	index uintptr, line, channel, who, name *C.char,
+	sec, nsec, seq C.uint64_t, level, msg *C.char) {
+
+	v := monitorLogCallbacks.Lookup(index)
+	hub, ok := v.(*monitorLogHub)
+	if !ok {
+		return
+	}
+	lvl := C.GoString(level)
+	e := LogEntry{
+		Line:    C.GoString(line),
+		Channel: C.GoString(channel),
+		Who:     C.GoString(who),
+		Name:    C.GoString(name),
+		Stamp:   time.Unix(int64(sec), int64(nsec)),
+		Seq:     uint64(seq),
+		Level:   LogLevel(lvl),
+		Message: C.GoString(msg),
+	}
+	if l, ok := logEntryLevels[lvl]; ok {
+		e.Level = l
+	}
+	hub.deliver(e)
+}
@@ -0,0 +1,110 @@
+//go:build ceph_preview
+
+package rados
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (suite *RadosTestSuite) clusterLog(text string) {
+	cmd, err := json.Marshal(map[string]any{
+		"prefix":  "log",
+		"logtext": []string{text},
+	})
+	require.NoError(suite.T(), err)
+	_, _, err = suite.conn.MonCommand(cmd)
+	require.NoError(suite.T(), err)
+}
+
+// waitLogEntry waits for an entry containing text.
+func waitLogEntry(m *MonitorLog, text string) (LogEntry, bool) {
+	timeout := time.After(30 * time.Second)
+	for {
+		select {
+		case e, ok := <-m.Entries():
+			if !ok {
+				return LogEntry{}, false
+			}
+			if strings.Contains(e.Message, text) {
+				return e, true
+			}
+		case <-timeout:
+			return LogEntry{}, false
+		}
+	}
+}
+
+func (suite *RadosTestSuite) TestMonitorLog() {
+	suite.SetupConnection()
+	ta := assert.New(suite.T())
+
+	m, err := suite.conn.MonitorLog(LogLevelInfo, LogChannelCluster)
+	require.NoError(suite.T(), err)
+	defer func() { ta.NoError(m.Close()) }()
+	audit, err := suite.conn.MonitorLog(LogLevelDebug, LogChannelAudit)
+	require.NoError(suite.T(), err)
+
+	text := fmt.Sprintf("go-ceph monitor log test %d", time.Now().UnixNano())
+	suite.clusterLog(text)
+	e, ok := waitLogEntry(m, text)
+	if ta.True(ok) {
+		ta.Equal(LogChannelCluster, e.Channel)
+		ta.Equal(LogLevelInfo, e.Level)
+		ta.True(strings.HasPrefix(e.Name, "mon."), e.Name)
+		ta.WithinDuration(time.Now(), e.Stamp, time.Minute)
+		ta.NotZero(e.Seq)
+		ta.Contains(e.Line, text)
+	}
+	// the log command itself is audited
+	_, ok = waitLogEntry(audit, text)
+	ta.True(ok)
+	ta.NoError(audit.Close())
+	ta.NoError(audit.Close())
+	_, ok = <-audit.Entries()
+	ta.False(ok)
+	ta.ErrorIs(audit.SetLevel(LogLevelInfo), ErrMonitorLogClosed)
+
+	// informational entries are not delivered at the warning level
+	require.NoError(suite.T(), m.SetLevel(LogLevelWarn))
+	suite.clusterLog(text + " warn")
+	select {
+	case e := <-m.Entries():
+		ta.NotEqual(LogLevelInfo, e.Level, e.Message)
+	case <-time.After(2 * time.Second):
+	}
+	require.NoError(suite.T(), m.SetLevel(LogLevelInfo))
+	suite.clusterLog(text + " info")
+	_, ok = waitLogEntry(m, text+" info")
+	ta.True(ok)
+	ta.Zero(m.Dropped())
+
+	ta.ErrorIs(m.SetLevel("verbose"), ErrInvalidLogLevel)
+	_, err = suite.conn.MonitorLog("verbose", "")
+	ta.ErrorIs(err, ErrInvalidLogLevel)
+}
+
+func (suite *RadosTestSuite) TestMonitorLogShutdown() {
+	conn, err := NewConn()
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), conn.ReadDefaultConfigFile())
+
+	_, err = conn.MonitorLog(LogLevelInfo, "")
+	assert.ErrorIs(suite.T(), err, ErrNotConnected)
+
+	require.NoError(suite.T(), conn.Connect())
+	m, err := conn.MonitorLog(LogLevelInfo, "")
+	require.NoError(suite.T(), err)
+	conn.Shutdown()
+
+	// draining the entries ends as the channel is closed
+	for ok := true; ok; {
+		_, ok = <-m.Entries()
+	}
+	assert.NoError(suite.T(), m.Close())
+}
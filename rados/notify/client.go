@@ -0,0 +1,180 @@
+//go:build ceph_preview
+
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+	"time"
+
+	"github.com/ceph/go-ceph/encoding/denc"
+	"github.com/ceph/go-ceph/internal/ctxutil"
+	"github.com/ceph/go-ceph/internal/errutil"
+	"github.com/ceph/go-ceph/rados"
+)
+
+// ErrTimeout is returned by Results.Err if some watchers did not respond in
+// time.
+var ErrTimeout = errors.New("watchers did not respond in time")
+
+var errTimedOut = errutil.GetError("rados", -int(syscall.ETIMEDOUT))
+
+// ClientOptions control a Client. Zero values select defaults.
+type ClientOptions struct {
+	// Codec encodes payloads. The default is JSONCodec.
+	Codec Codec
+	// Timeout is the time to wait for responses. Zero uses the librados
+	// default. A shorter deadline of the context passed to Notify takes
+	// precedence.
+	Timeout time.Duration
+}
+
+// Client sends requests to the Servers watching an object.
+type Client struct {
+	ioctx *rados.IOContext
+	oid   string
+	opts  ClientOptions
+}
+
+// NewClient returns a new Client for the object oid. If opts is nil defaults
+// are used.
+func NewClient(ioctx *rados.IOContext, oid string, opts *ClientOptions) *Client {
+	c := &Client{ioctx: ioctx, oid: oid}
+	if opts != nil {
+		c.opts = *opts
+	}
+	if c.opts.Codec == nil {
+		c.opts.Codec = JSONCodec
+	}
+	return c
+}
+
+// Response is the response of a single watcher.
+type Response struct {
+	WatcherID  rados.WatcherID
+	NotifierID rados.NotifierID
+	// Data is the encoded response payload.
+	Data []byte
+	// Err is the error returned by the handler or an error describing why
+	// the response could not be used.
+	Err error
+
+	codec Codec
+}
+
+// Decode decodes the response payload into v. It returns Err if it is set.
+func (r *Response) Decode(v any) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	return r.codec.Unmarshal(r.Data, v)
+}
+
+// Results are the responses of the watchers of an object to a request.
+type Results struct {
+	Responses []Response
+	// Timeouts are the watchers that did not respond in time.
+	Timeouts []rados.NotifyTimeout
+}
+
+// Err returns the errors of all responses and ErrTimeout if some watchers
+// did not respond, joined into a single error. It returns nil if all
+// watchers responded successfully.
+func (rs *Results) Err() error {
+	var errs []error
+	for _, r := range rs.Responses {
+		if r.Err != nil {
+			errs = append(errs, fmt.Errorf("watcher %d: %w", r.WatcherID, r.Err))
+		}
+	}
+	if len(rs.Timeouts) > 0 {
+		errs = append(errs, fmt.Errorf("%w: %d watchers", ErrTimeout, len(rs.Timeouts)))
+	}
+	return errors.Join(errs...)
+}
+
+// Notify sends a request to all watchers of the object and returns their
+// responses. Watchers that do not respond in time are listed in the
+// Timeouts of the Results rather than reported as an error. If ctx is done
+// before the responses arrive, Notify returns ctx.Err().
+func (c *Client) Notify(ctx context.Context, method string, req any) (*Results, error) {
+	payload, err := c.opts.Codec.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	data, err := denc.Marshal(&requestEnvelope{Method: method, Payload: payload})
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := c.opts.Timeout
+	if deadline, ok := ctx.Deadline(); ok {
+		if d := time.Until(deadline); timeout == 0 || d < timeout {
+			// librados works with whole seconds
+			timeout = max(d.Truncate(time.Second), time.Second)
+		}
+	}
+
+	var (
+		acks     []rados.NotifyAck
+		timeouts []rados.NotifyTimeout
+	)
+	err = ctxutil.Do(ctx, func() error {
+		var err error
+		acks, timeouts, err = c.ioctx.NotifyWithTimeout(c.oid, data, timeout)
+		return err
+	}, nil)
+	if err != nil && !(errors.Is(err, errTimedOut) && ctx.Err() == nil) {
+		return nil, err
+	}
+
+	rs := &Results{
+		Responses: make([]Response, 0, len(acks)),
+		Timeouts:  timeouts,
+	}
+	for _, ack := range acks {
+		rs.Responses = append(rs.Responses, c.response(ack))
+	}
+	return rs, nil
+}
+
+func (c *Client) response(ack rados.NotifyAck) Response {
+	r := Response{
+		WatcherID:  ack.WatcherID,
+		NotifierID: ack.NotifierID,
+		codec:      c.opts.Codec,
+	}
+	var env responseEnvelope
+	if err := denc.Unmarshal(ack.Response, &env); err != nil {
+		r.Err = fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+		return r
+	}
+	r.Data = env.Payload
+	r.Err = env.err()
+	return r
+}
+
+// Call sends a request to all watchers of the object and decodes the
+// successful responses into values of type Resp. If some watchers failed or
+// did not respond, the responses of the other watchers are returned together
+// with the error of Results.Err.
+func Call[Resp any](ctx context.Context, c *Client, method string, req any) ([]Resp, error) {
+	rs, err := c.Notify(ctx, method, req)
+	if err != nil {
+		return nil, err
+	}
+	resps := make([]Resp, 0, len(rs.Responses))
+	for i := range rs.Responses {
+		var v Resp
+		if err := rs.Responses[i].Decode(&v); err != nil {
+			if rs.Responses[i].Err == nil {
+				rs.Responses[i].Err = fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+			}
+			continue
+		}
+		resps = append(resps, v)
+	}
+	return resps, rs.Err()
+}
@@ -0,0 +1,43 @@
+//go:build ceph_preview
+
+package notify
+
+import (
+	"encoding/json"
+
+	"github.com/ceph/go-ceph/encoding/denc"
+)
+
+// Codec encodes and decodes request and response payloads.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+type dencCodec struct{}
+
+func (dencCodec) Marshal(v any) ([]byte, error) {
+	return denc.Marshal(v)
+}
+
+func (dencCodec) Unmarshal(data []byte, v any) error {
+	return denc.Unmarshal(data, v)
+}
+
+var (
+	// JSONCodec encodes payloads as JSON.
+	JSONCodec Codec = jsonCodec{}
+	// DencCodec encodes payloads in Ceph's binary encoding, see the
+	// encoding/denc package.
+	DencCodec Codec = dencCodec{}
+)
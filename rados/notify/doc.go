@@ -0,0 +1,19 @@
+//go:build ceph_preview
+
+/*
+Package notify implements a request/response protocol on top of the
+watch/notify mechanism of RADOS objects.
+
+A Server watches an object and dispatches notifications sent to it to
+handlers registered by method name. The responses of the handlers are sent
+back as notification acks. The Server re-establishes its watch if it is
+lost, for example after a network disconnect.
+
+A Client sends requests to all Servers watching an object and collects their
+responses, and the watchers that did not respond in time, into a Results
+value.
+
+Request and response payloads are encoded with a Codec, JSON by default. Both
+sides of a connection must use the same Codec.
+*/
+package notify
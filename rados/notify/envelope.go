@@ -0,0 +1,67 @@
+//go:build ceph_preview
+
+package notify
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ceph/go-ceph/encoding/denc"
+)
+
+var (
+	// ErrUnknownMethod is returned for requests of methods that the Server
+	// has no handler for.
+	ErrUnknownMethod = errors.New("unknown method")
+	// ErrBadRequest is returned for requests that the Server could not
+	// decode.
+	ErrBadRequest = errors.New("bad request")
+	// ErrInvalidResponse is returned for acks that are not responses of a
+	// Server, for example acks of watchers not using this package.
+	ErrInvalidResponse = errors.New("invalid response")
+)
+
+// RemoteError is the error returned by the handler of a request.
+type RemoteError struct {
+	Message string
+}
+
+// Error returns the error message.
+func (e *RemoteError) Error() string {
+	return "remote error: " + e.Message
+}
+
+// response status codes
+const (
+	statusOK = uint8(iota)
+	statusError
+	statusUnknownMethod
+	statusBadRequest
+)
+
+type requestEnvelope struct {
+	_       denc.Versioned `denc:"version=1,compat=1"`
+	Method  string
+	Payload []byte
+}
+
+type responseEnvelope struct {
+	_       denc.Versioned `denc:"version=1,compat=1"`
+	Status  uint8
+	Error   string
+	Payload []byte
+}
+
+func (r *responseEnvelope) err() error {
+	switch r.Status {
+	case statusOK:
+		return nil
+	case statusError:
+		return &RemoteError{Message: r.Error}
+	case statusUnknownMethod:
+		return fmt.Errorf("%w: %s", ErrUnknownMethod, r.Error)
+	case statusBadRequest:
+		return fmt.Errorf("%w: %s", ErrBadRequest, r.Error)
+	}
+	return fmt.Errorf("%w: status %d", ErrInvalidResponse, r.Status)
+}
@@ -0,0 +1,165 @@
+//go:build ceph_preview
+
+package notify
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ceph/go-ceph/encoding/denc"
+	"github.com/ceph/go-ceph/internal/radostest"
+	"github.com/ceph/go-ceph/rados"
+)
+
+var testPool radostest.Pool
+
+func TestMain(m *testing.M) {
+	os.Exit(testPool.Run(m))
+}
+
+type echoRequest struct {
+	Text string
+}
+
+type echoResponse struct {
+	Text string
+	From string
+}
+
+func startServer(t *testing.T, ioctx *rados.IOContext, oid, name string, opts *ServerOptions) *Server {
+	s := NewServer(ioctx, oid, opts)
+	Handle(s, "echo", func(_ context.Context, req echoRequest) (echoResponse, error) {
+		return echoResponse{Text: req.Text, From: name}, nil
+	})
+	Handle(s, "upper", func(_ context.Context, req echoRequest) (string, error) {
+		if req.Text == "" {
+			return "", errors.New("empty text")
+		}
+		return strings.ToUpper(req.Text), nil
+	})
+	require.NoError(t, s.Start())
+	t.Cleanup(func() { assert.NoError(t, s.Close()) })
+	return s
+}
+
+func TestNotify(t *testing.T) {
+	ioctx := testPool.IOContext(t)
+	oid := "rpc"
+	require.NoError(t, ioctx.Create(oid, rados.CreateIdempotent))
+	startServer(t, ioctx, oid, "a", nil)
+	startServer(t, ioctx, oid, "b", nil)
+	c := NewClient(ioctx, oid, &ClientOptions{Timeout: 5 * time.Second})
+	ctx := context.Background()
+
+	t.Run("call", func(t *testing.T) {
+		resps, err := Call[echoResponse](ctx, c, "echo", echoRequest{Text: "hi"})
+		require.NoError(t, err)
+		require.Len(t, resps, 2)
+		from := []string{resps[0].From, resps[1].From}
+		assert.ElementsMatch(t, []string{"a", "b"}, from)
+		assert.Equal(t, "hi", resps[0].Text)
+	})
+
+	t.Run("handlerError", func(t *testing.T) {
+		rs, err := c.Notify(ctx, "upper", echoRequest{})
+		require.NoError(t, err)
+		require.Len(t, rs.Responses, 2)
+		var re *RemoteError
+		assert.ErrorAs(t, rs.Responses[0].Err, &re)
+		assert.Equal(t, "empty text", re.Message)
+		assert.Error(t, rs.Err())
+
+		upper, err := Call[string](ctx, c, "upper", echoRequest{Text: "abc"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"ABC", "ABC"}, upper)
+	})
+
+	t.Run("unknownMethod", func(t *testing.T) {
+		_, err := Call[string](ctx, c, "bogus", nil)
+		assert.ErrorIs(t, err, ErrUnknownMethod)
+	})
+
+	t.Run("badRequest", func(t *testing.T) {
+		_, err := Call[string](ctx, c, "upper", 5)
+		assert.ErrorIs(t, err, ErrBadRequest)
+	})
+}
+
+func TestNotifyTimeout(t *testing.T) {
+	ioctx := testPool.IOContext(t)
+	oid := "rpc-timeout"
+	require.NoError(t, ioctx.Create(oid, rados.CreateIdempotent))
+	startServer(t, ioctx, oid, "a", nil)
+
+	// a raw watcher that never acks
+	w, err := ioctx.Watch(oid)
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, w.Delete()) }()
+	go func() {
+		for range w.Events() {
+		}
+	}()
+
+	c := NewClient(ioctx, oid, &ClientOptions{Timeout: 2 * time.Second})
+	rs, err := c.Notify(context.Background(), "echo", echoRequest{Text: "x"})
+	require.NoError(t, err)
+	assert.Len(t, rs.Responses, 1)
+	assert.Len(t, rs.Timeouts, 1)
+	assert.ErrorIs(t, rs.Err(), ErrTimeout)
+
+	resps, err := Call[echoResponse](context.Background(), c, "echo", echoRequest{Text: "y"})
+	assert.ErrorIs(t, err, ErrTimeout)
+	assert.Len(t, resps, 1)
+}
+
+func TestServerClose(t *testing.T) {
+	ioctx := testPool.IOContext(t)
+	oid := "rpc-close"
+	require.NoError(t, ioctx.Create(oid, rados.CreateIdempotent))
+	s := NewServer(ioctx, oid, nil)
+	require.NoError(t, s.Start())
+	require.NoError(t, s.Close())
+	assert.ErrorIs(t, s.Start(), ErrServerClosed)
+
+	c := NewClient(ioctx, oid, nil)
+	rs, err := c.Notify(context.Background(), "echo", nil)
+	require.NoError(t, err)
+	assert.Len(t, rs.Responses, 0)
+	assert.Len(t, rs.Timeouts, 0)
+}
+
+func TestDispatch(t *testing.T) {
+	s := NewServer(nil, "", &ServerOptions{Codec: DencCodec})
+	Handle(s, "add", func(_ context.Context, req [2]int32) (int32, error) {
+		return req[0] + req[1], nil
+	})
+	c := NewClient(nil, "", &ClientOptions{Codec: DencCodec})
+
+	payload, err := DencCodec.Marshal([2]int32{2, 3})
+	require.NoError(t, err)
+	ev := rados.NotifyEvent{WatcherID: 1}
+	ev.Data, err = denc.Marshal(&requestEnvelope{Method: "add", Payload: payload})
+	require.NoError(t, err)
+	ack, err := denc.Marshal(s.dispatch(ev))
+	require.NoError(t, err)
+	r := c.response(rados.NotifyAck{WatcherID: 1, Response: ack})
+	var sum int32
+	require.NoError(t, r.Decode(&sum))
+	assert.EqualValues(t, 5, sum)
+
+	ev.Data = []byte("garbage")
+	ack, err = denc.Marshal(s.dispatch(ev))
+	require.NoError(t, err)
+	r = c.response(rados.NotifyAck{Response: ack})
+	assert.ErrorIs(t, r.Err, ErrBadRequest)
+
+	r = c.response(rados.NotifyAck{Response: []byte("raw ack")})
+	assert.ErrorIs(t, r.Err, ErrInvalidResponse)
+}
@@ -0,0 +1,269 @@
+//go:build ceph_preview
+
+package notify
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ceph/go-ceph/encoding/denc"
+	"github.com/ceph/go-ceph/internal/log"
+	"github.com/ceph/go-ceph/rados"
+)
+
+const (
+	defaultRetryDelay    = time.Second
+	defaultMaxRetryDelay = 30 * time.Second
+)
+
+var (
+	// ErrServerClosed is returned by Start if the Server has been closed.
+	ErrServerClosed = errors.New("notify server closed")
+
+	errWatchClosed = errors.New("watch closed")
+)
+
+// Request is a request received by a Server.
+type Request struct {
+	Method     string
+	NotifierID rados.NotifierID
+	Data       []byte
+
+	codec Codec
+}
+
+// Decode decodes the request payload into v.
+func (r *Request) Decode(v any) error {
+	return r.codec.Unmarshal(r.Data, v)
+}
+
+// HandlerFunc handles requests of a method. The returned value is encoded
+// and sent as the response. If an error is returned the client receives a
+// RemoteError with the error message instead. The context is canceled when
+// the Server is closed.
+type HandlerFunc func(ctx context.Context, req *Request) (any, error)
+
+// Handle registers a typed handler for a method. The request payload is
+// decoded into a value of type Req before fn is called.
+func Handle[Req, Resp any](s *Server, method string, fn func(context.Context, Req) (Resp, error)) {
+	s.HandleFunc(method, func(ctx context.Context, r *Request) (any, error) {
+		var req Req
+		if err := r.Decode(&req); err != nil {
+			return nil, badRequestError{err}
+		}
+		return fn(ctx, req)
+	})
+}
+
+type badRequestError struct {
+	error
+}
+
+// ServerOptions control a Server. Zero values select defaults.
+type ServerOptions struct {
+	// Codec encodes payloads. The default is JSONCodec.
+	Codec Codec
+	// WatchTimeout is the time after which the OSDs consider the watch lost
+	// if they do not hear from the client. Zero uses the librados default.
+	WatchTimeout time.Duration
+	// RetryDelay is the initial delay between attempts to re-establish a
+	// lost watch. The delay doubles after each failed attempt up to
+	// MaxRetryDelay.
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+	// ErrorHandler, if set, is called with errors of the watch and of failed
+	// attempts to re-establish it.
+	ErrorHandler func(error)
+}
+
+// Server dispatches requests sent to an object to registered handlers.
+type Server struct {
+	ioctx *rados.IOContext
+	oid   string
+	opts  ServerOptions
+
+	mutex    sync.RWMutex
+	handlers map[string]HandlerFunc
+	started  bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewServer returns a new Server for the object oid. If opts is nil defaults
+// are used. The IOContext must not be destroyed before the Server is closed.
+func NewServer(ioctx *rados.IOContext, oid string, opts *ServerOptions) *Server {
+	s := &Server{
+		ioctx:    ioctx,
+		oid:      oid,
+		handlers: map[string]HandlerFunc{},
+	}
+	if opts != nil {
+		s.opts = *opts
+	}
+	if s.opts.Codec == nil {
+		s.opts.Codec = JSONCodec
+	}
+	if s.opts.RetryDelay <= 0 {
+		s.opts.RetryDelay = defaultRetryDelay
+	}
+	if s.opts.MaxRetryDelay < s.opts.RetryDelay {
+		s.opts.MaxRetryDelay = max(defaultMaxRetryDelay, s.opts.RetryDelay)
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	return s
+}
+
+// HandleFunc registers the handler for a method, replacing any previously
+// registered handler. Handlers may be registered while the Server is
+// running.
+func (s *Server) HandleFunc(method string, h HandlerFunc) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.handlers[method] = h
+}
+
+// Start watches the object and starts serving requests in the background.
+func (s *Server) Start() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.ctx.Err() != nil {
+		return ErrServerClosed
+	}
+	if s.started {
+		return nil
+	}
+	w, err := s.watch()
+	if err != nil {
+		return err
+	}
+	s.started = true
+	s.wg.Add(1)
+	go s.run(w)
+	return nil
+}
+
+// Close stops serving requests, removes the watch and waits for running
+// handlers to return.
+func (s *Server) Close() error {
+	s.cancel()
+	s.wg.Wait()
+	return nil
+}
+
+func (s *Server) watch() (*rados.Watcher, error) {
+	return s.ioctx.WatchWithTimeout(s.oid, s.opts.WatchTimeout)
+}
+
+func (s *Server) reportError(err error) {
+	if s.opts.ErrorHandler != nil {
+		s.opts.ErrorHandler(err)
+		return
+	}
+	log.Warnf("notify server for %q: %v", s.oid, err)
+}
+
+func (s *Server) run(w *rados.Watcher) {
+	defer s.wg.Done()
+	for w != nil {
+		err := s.serve(w)
+		if derr := w.Delete(); derr != nil {
+			log.Debugf("notify server for %q: deleting watch: %v", s.oid, derr)
+		}
+		if err == nil {
+			return
+		}
+		s.reportError(err)
+		w = s.rewatch()
+	}
+}
+
+// serve dispatches the events of the watcher until the watch fails, which is
+// returned, or the Server is closed.
+func (s *Server) serve(w *rados.Watcher) error {
+	for {
+		select {
+		case <-s.ctx.Done():
+			return nil
+		case ev, ok := <-w.Events():
+			if !ok {
+				return errWatchClosed
+			}
+			s.wg.Add(1)
+			go s.handle(ev)
+		case err, ok := <-w.Errors():
+			if !ok {
+				return errWatchClosed
+			}
+			return err
+		}
+	}
+}
+
+// rewatch re-establishes the watch, retrying with increasing delays. It
+// returns nil if the Server was closed.
+func (s *Server) rewatch() *rados.Watcher {
+	delay := s.opts.RetryDelay
+	for {
+		t := time.NewTimer(delay)
+		select {
+		case <-s.ctx.Done():
+			t.Stop()
+			return nil
+		case <-t.C:
+		}
+		w, err := s.watch()
+		if err == nil {
+			return w
+		}
+		s.reportError(err)
+		delay = min(2*delay, s.opts.MaxRetryDelay)
+	}
+}
+
+func (s *Server) handle(ev rados.NotifyEvent) {
+	defer s.wg.Done()
+	resp := s.dispatch(ev)
+	b, err := denc.Marshal(resp)
+	if err == nil {
+		err = ev.Ack(b)
+	}
+	if err != nil {
+		s.reportError(err)
+	}
+}
+
+func (s *Server) dispatch(ev rados.NotifyEvent) *responseEnvelope {
+	var env requestEnvelope
+	if err := denc.Unmarshal(ev.Data, &env); err != nil {
+		return &responseEnvelope{Status: statusBadRequest, Error: err.Error()}
+	}
+	s.mutex.RLock()
+	h, ok := s.handlers[env.Method]
+	s.mutex.RUnlock()
+	if !ok {
+		return &responseEnvelope{Status: statusUnknownMethod, Error: env.Method}
+	}
+	req := &Request{
+		Method:     env.Method,
+		NotifierID: ev.NotifierID,
+		Data:       env.Payload,
+		codec:      s.opts.Codec,
+	}
+	v, err := h(s.ctx, req)
+	if err != nil {
+		status := statusError
+		if errors.As(err, &badRequestError{}) {
+			status = statusBadRequest
+		}
+		return &responseEnvelope{Status: status, Error: err.Error()}
+	}
+	b, err := s.opts.Codec.Marshal(v)
+	if err != nil {
+		return &responseEnvelope{Status: statusError, Error: err.Error()}
+	}
+	return &responseEnvelope{Status: statusOK, Payload: b}
+}
@@ -0,0 +1,409 @@
+//go:build ceph_preview
+
+package rados
+
+import (
+	"errors"
+	"io"
+)
+
+const (
+	defaultObjectChunkSize = 1 << 20
+	defaultObjectReadahead = 4
+)
+
+// ErrInvalidOffset is returned when seeking to, reading at or writing at a
+// negative offset of an Object.
+var ErrInvalidOffset = errors.New("invalid object offset")
+
+// ObjectOptions control an Object. Zero values select defaults.
+type ObjectOptions struct {
+	// ChunkSize is the size of the individual reads and writes an Object
+	// issues. The default is 1 MiB.
+	ChunkSize int
+	// Readahead is the maximum number of chunks read or written in
+	// parallel. Sequential reads prefetch up to Readahead chunks, starting
+	// with a single chunk and doubling the number while the object is read
+	// sequentially. The default is 4.
+	Readahead int
+}
+
+// Object provides access to the data of a RADOS object through the
+// interfaces of the io package, so that objects can be used with io.Copy,
+// http.ServeContent and similar functions.
+//
+// Object implements io.ReadSeeker, io.Writer, io.ReaderAt, io.WriterAt,
+// io.WriterTo, io.ReaderFrom and io.Closer. ReadAt and WriteAt may be called
+// concurrently, all other methods must not be called concurrently. Close must
+// be called to release the resources of prefetched reads.
+type Object struct {
+	ioctx *IOContext
+	oid   string
+	opts  ObjectOptions
+
+	pos int64
+	// cur is the unread part of the most recently read chunk, starting at
+	// pos, and curBuf the buffer of that chunk
+	cur    []byte
+	curBuf []byte
+	eof    bool
+	// ahead are the prefetched chunks, starting at pos + len(cur), and next
+	// is the offset of the chunk following them
+	ahead  []pendingChunk
+	next   int64
+	window int
+	free   [][]byte
+}
+
+type pendingChunk struct {
+	c   *Completion
+	buf []byte
+}
+
+// chunkOp is an asynchronous operation on a chunk of a buffer.
+type chunkOp struct {
+	c    *Completion
+	size int
+	n    int
+	err  error
+}
+
+// Object returns an Object for the object oid using default options.
+func (ioctx *IOContext) Object(oid string) *Object {
+	return ioctx.ObjectWithOptions(oid, nil)
+}
+
+// ObjectWithOptions returns an Object for the object oid. If opts is nil
+// defaults are used.
+func (ioctx *IOContext) ObjectWithOptions(oid string, opts *ObjectOptions) *Object {
+	o := &Object{ioctx: ioctx, oid: oid}
+	if opts != nil {
+		o.opts = *opts
+	}
+	if o.opts.ChunkSize <= 0 {
+		o.opts.ChunkSize = defaultObjectChunkSize
+	}
+	if o.opts.Readahead <= 0 {
+		o.opts.Readahead = defaultObjectReadahead
+	}
+	return o
+}
+
+// Oid returns the name of the object.
+func (o *Object) Oid() string {
+	return o.oid
+}
+
+// Stat returns the size and modification time of the object.
+func (o *Object) Stat() (ObjectStat, error) {
+	return o.ioctx.Stat(o.oid)
+}
+
+// Close releases the prefetched reads of the Object. The Object may be used
+// again after Close.
+func (o *Object) Close() error {
+	o.resetPrefetch()
+	o.free = nil
+	return nil
+}
+
+// runChunks splits p into chunks and starts op for each of them, keeping at
+// most Readahead operations in flight. No further chunks are started after
+// an operation failed. The completed operations are returned in order.
+func (o *Object) runChunks(p []byte, off int64,
+	op func(b []byte, off uint64) (*Completion, error)) []chunkOp {
+
+	var (
+		done  []chunkOp
+		queue []chunkOp
+	)
+	failed := false
+	wait := func() {
+		cop := queue[0]
+		queue = queue[1:]
+		cop.n, cop.err = cop.c.Wait()
+		cop.c.Release()
+		failed = failed || cop.err != nil
+		done = append(done, cop)
+	}
+	for i := 0; i < len(p) && !failed; i += o.opts.ChunkSize {
+		if len(queue) == o.opts.Readahead {
+			wait()
+			if failed {
+				break
+			}
+		}
+		end := min(i+o.opts.ChunkSize, len(p))
+		c, err := op(p[i:end], uint64(off)+uint64(i))
+		if err != nil {
+			done = append(done, chunkOp{size: end - i, err: err})
+			failed = true
+			break
+		}
+		queue = append(queue, chunkOp{c: c, size: end - i})
+	}
+	for len(queue) > 0 {
+		wait()
+	}
+	return done
+}
+
+// ReadAt reads len(p) bytes of the object starting at offset off. Large
+// reads are split into chunks that are read in parallel. If the object ends
+// before len(p) bytes were read, io.EOF is returned.
+func (o *Object) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, ErrInvalidOffset
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	n := 0
+	for _, cop := range o.runChunks(p, off, func(b []byte, off uint64) (*Completion, error) {
+		return o.ioctx.AioRead(o.oid, b, off)
+	}) {
+		if cop.err != nil {
+			return n, cop.err
+		}
+		n += cop.n
+		if cop.n < cop.size {
+			return n, io.EOF
+		}
+	}
+	return n, nil
+}
+
+// WriteAt writes len(p) bytes to the object starting at offset off. Large
+// writes are split into chunks that are written in parallel. If a chunk
+// fails, the returned count only includes the chunks preceding it, later
+// chunks may have been written nonetheless.
+func (o *Object) WriteAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, ErrInvalidOffset
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	n := 0
+	for _, cop := range o.runChunks(p, off, func(b []byte, off uint64) (*Completion, error) {
+		return o.ioctx.AioWrite(o.oid, b, off)
+	}) {
+		if cop.err != nil {
+			return n, cop.err
+		}
+		n += cop.size
+	}
+	return n, nil
+}
+
+// Seek sets the offset for the next Read or Write. Seeking relative to the
+// end of the object stats the object.
+func (o *Object) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = o.pos + offset
+	case io.SeekEnd:
+		st, err := o.Stat()
+		if err != nil {
+			return o.pos, err
+		}
+		pos = int64(st.Size) + offset
+	default:
+		return o.pos, ErrInvalidOffset
+	}
+	if pos < 0 {
+		return o.pos, ErrInvalidOffset
+	}
+	if d := pos - o.pos; d >= 0 && d <= int64(len(o.cur)) {
+		// keep the prefetched data when skipping forward within the chunk
+		o.cur = o.cur[d:]
+	} else {
+		o.resetPrefetch()
+	}
+	o.pos = pos
+	return pos, nil
+}
+
+// Read reads up to len(p) bytes from the current offset. While the object is
+// read sequentially, the following chunks are prefetched in the background.
+func (o *Object) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	for len(o.cur) == 0 {
+		if o.eof {
+			return 0, io.EOF
+		}
+		if err := o.fill(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, o.cur)
+	o.cur = o.cur[n:]
+	o.pos += int64(n)
+	return n, nil
+}
+
+// WriteTo writes the object from the current offset to its end to w.
+func (o *Object) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+	for {
+		for len(o.cur) == 0 {
+			if o.eof {
+				return total, nil
+			}
+			if err := o.fill(); err != nil {
+				return total, err
+			}
+		}
+		n, err := w.Write(o.cur)
+		o.cur = o.cur[n:]
+		o.pos += int64(n)
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+}
+
+// Write writes len(p) bytes at the current offset.
+func (o *Object) Write(p []byte) (int, error) {
+	o.resetPrefetch()
+	n, err := o.WriteAt(p, o.pos)
+	o.pos += int64(n)
+	return n, err
+}
+
+// ReadFrom writes the data read from r until io.EOF at the current offset.
+// Up to Readahead chunks are written in parallel while r is read.
+func (o *Object) ReadFrom(r io.Reader) (int64, error) {
+	o.resetPrefetch()
+	var (
+		total int64
+		queue []pendingChunk
+		err   error
+	)
+	off := o.pos
+	wait := func() {
+		pc := queue[0]
+		queue = queue[1:]
+		if _, werr := pc.c.Wait(); werr != nil && err == nil {
+			err = werr
+		}
+		pc.c.Release()
+		if err == nil {
+			total += int64(len(pc.buf))
+		}
+		o.free = append(o.free, pc.buf[:cap(pc.buf)])
+	}
+	for err == nil {
+		buf := o.getBuf()
+		n, rerr := io.ReadFull(r, buf)
+		if n > 0 {
+			if len(queue) == o.opts.Readahead {
+				wait()
+				if err != nil {
+					break
+				}
+			}
+			c, aerr := o.ioctx.AioWrite(o.oid, buf[:n], uint64(off))
+			if aerr != nil {
+				err = aerr
+				break
+			}
+			queue = append(queue, pendingChunk{c: c, buf: buf[:n]})
+			off += int64(n)
+		}
+		if rerr == io.EOF || rerr == io.ErrUnexpectedEOF {
+			break
+		}
+		err = rerr
+	}
+	for len(queue) > 0 {
+		wait()
+	}
+	o.pos += total
+	return total, err
+}
+
+func (o *Object) getBuf() []byte {
+	if n := len(o.free); n > 0 {
+		b := o.free[n-1]
+		o.free = o.free[:n-1]
+		return b
+	}
+	return make([]byte, o.opts.ChunkSize)
+}
+
+// prefetch starts reads of the chunks following the prefetched ones until
+// window reads are in flight.
+func (o *Object) prefetch() error {
+	if len(o.ahead) == 0 {
+		o.next = o.pos + int64(len(o.cur))
+	}
+	for len(o.ahead) < max(o.window, 1) {
+		buf := o.getBuf()
+		c, err := o.ioctx.AioRead(o.oid, buf, uint64(o.next))
+		if err != nil {
+			o.free = append(o.free, buf)
+			if len(o.ahead) == 0 {
+				return err
+			}
+			break
+		}
+		o.ahead = append(o.ahead, pendingChunk{c: c, buf: buf})
+		o.next += int64(len(buf))
+	}
+	return nil
+}
+
+// fill makes the next prefetched chunk the current one.
+func (o *Object) fill() error {
+	if err := o.prefetch(); err != nil {
+		return err
+	}
+	pc := o.ahead[0]
+	o.ahead = o.ahead[1:]
+	n, err := pc.c.Wait()
+	pc.c.Release()
+	if o.curBuf != nil {
+		o.free = append(o.free, o.curBuf)
+	}
+	o.curBuf = pc.buf
+	if err != nil {
+		o.resetPrefetch()
+		return err
+	}
+	o.cur = pc.buf[:n]
+	if n < len(pc.buf) {
+		// the object ends within this chunk, the reads after it are void
+		o.eof = true
+		o.releaseAhead()
+		return nil
+	}
+	o.window = min(max(2*o.window, 2), o.opts.Readahead)
+	// a failure to start the next reads is reported by the next fill
+	_ = o.prefetch()
+	return nil
+}
+
+func (o *Object) releaseAhead() {
+	for _, pc := range o.ahead {
+		_, _ = pc.c.Wait()
+		pc.c.Release()
+		o.free = append(o.free, pc.buf)
+	}
+	o.ahead = nil
+}
+
+// resetPrefetch discards the prefetched data, for example because the
+// offset changed or the object is written.
+func (o *Object) resetPrefetch() {
+	o.releaseAhead()
+	o.cur = nil
+	o.eof = false
+	o.window = 0
+}
@@ -0,0 +1,497 @@
+//go:build ceph_preview
+
+package rados
+
+// #cgo LDFLAGS: -lrados
+// #include <stdlib.h>
+// #include <rados/librados.h>
+import "C"
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+const (
+	defaultScanWorkers   = 8
+	defaultScanSlices    = 256
+	defaultScanBatchSize = 1000
+
+	scanTokenVersion = 2
+)
+
+// ErrInvalidScanToken is returned when an ObjectScanToken can not be decoded
+// or does not fit the scan it is used with.
+var ErrInvalidScanToken = errors.New("invalid object scan token")
+
+// ObjectListItem describes an object found by an ObjectScan.
+type ObjectListItem struct {
+	Name      string
+	Namespace string
+	Locator   string
+}
+
+// XattrFilter selects objects with an xattr of the given name and value. The
+// filter is evaluated by the OSDs.
+type XattrFilter struct {
+	Name  string
+	Value []byte
+}
+
+// ObjectScanOptions control an ObjectScan. Zero values select defaults.
+type ObjectScanOptions struct {
+	// Workers is the number of slices that are listed concurrently.
+	Workers int
+	// Slices is the number of disjoint ranges the pool is divided into.
+	// Slices that were completed are skipped when resuming a scan, so more
+	// slices mean less listing is repeated. If the scan is resumed from a
+	// token, Slices must be zero or match the token.
+	Slices int
+	// BatchSize is the maximum number of objects fetched per request.
+	BatchSize int
+	// Prefix, if set, skips objects whose names do not start with it.
+	Prefix string
+	// Xattr, if set, only returns objects matching the filter.
+	Xattr *XattrFilter
+	// Resume, if set, continues the scan the token was taken from. The
+	// token must have been taken from a scan of the same pool and namespace
+	// with the same Prefix and Xattr.
+	Resume *ObjectScanToken
+}
+
+// ObjectScanToken records the progress of an ObjectScan: which slices have
+// been completed and, for the slices in progress, the last object that was
+// passed to the callback. It also records the pool, namespace, slice count
+// and filters of the scan so it is only used to resume the same scan. It can
+// be serialized with MarshalBinary and restored with UnmarshalBinary to resume
+// an interrupted scan, possibly in a different process.
+//
+// librados has no C API to serialize a listing cursor, so a slice in progress
+// is resumed by listing it again from its start, only passing the objects
+// after the recorded one to the callback.
+type ObjectScanToken struct {
+	pool      int64
+	namespace string
+	prefix    string
+	filter    []byte
+	slices    uint32
+	done      []byte
+	last      map[uint32]ObjectListItem
+}
+
+func newObjectScanToken(slices uint32) *ObjectScanToken {
+	return &ObjectScanToken{
+		slices: slices,
+		done:   make([]byte, (slices+7)/8),
+		last:   map[uint32]ObjectListItem{},
+	}
+}
+
+func (t *ObjectScanToken) clone() *ObjectScanToken {
+	c := *t
+	c.filter = append([]byte(nil), t.filter...)
+	c.done = append([]byte(nil), t.done...)
+	c.last = make(map[uint32]ObjectListItem, len(t.last))
+	for i, item := range t.last {
+		c.last[i] = item
+	}
+	return &c
+}
+
+func (t *ObjectScanToken) isDone(i uint32) bool {
+	return t.done[i/8]&(1<<(i%8)) != 0
+}
+
+func (t *ObjectScanToken) setDone(i uint32) {
+	t.done[i/8] |= 1 << (i % 8)
+	delete(t.last, i)
+}
+
+// Slices returns the number of slices of the scan.
+func (t *ObjectScanToken) Slices() int {
+	return int(t.slices)
+}
+
+// Completed returns the number of slices that have been completed.
+func (t *ObjectScanToken) Completed() int {
+	n := 0
+	for i := uint32(0); i < t.slices; i++ {
+		if t.isDone(i) {
+			n++
+		}
+	}
+	return n
+}
+
+// MarshalBinary encodes the token.
+func (t *ObjectScanToken) MarshalBinary() ([]byte, error) {
+	b := []byte{scanTokenVersion}
+	b = binary.BigEndian.AppendUint64(b, uint64(t.pool))
+	b = appendTokenBytes(b, []byte(t.namespace))
+	b = appendTokenBytes(b, []byte(t.prefix))
+	b = appendTokenBytes(b, t.filter)
+	b = binary.BigEndian.AppendUint32(b, t.slices)
+	b = append(b, t.done...)
+
+	// sort the slices in progress for a stable encoding
+	inProgress := make([]uint32, 0, len(t.last))
+	for i := range t.last {
+		inProgress = append(inProgress, i)
+	}
+	slices.Sort(inProgress)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(inProgress)))
+	for _, i := range inProgress {
+		item := t.last[i]
+		b = binary.BigEndian.AppendUint32(b, i)
+		b = appendTokenBytes(b, []byte(item.Name))
+		b = appendTokenBytes(b, []byte(item.Namespace))
+		b = appendTokenBytes(b, []byte(item.Locator))
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes a token produced by MarshalBinary.
+func (t *ObjectScanToken) UnmarshalBinary(b []byte) error {
+	d := tokenDecoder{b: b}
+	if v := d.bytes(1); v == nil || v[0] != scanTokenVersion {
+		return ErrInvalidScanToken
+	}
+	nt := ObjectScanToken{
+		pool:      int64(d.uint64()),
+		namespace: d.string(),
+		prefix:    d.string(),
+		filter:    d.lenBytes(),
+		slices:    d.uint32(),
+		last:      map[uint32]ObjectListItem{},
+	}
+	if nt.slices == 0 || (uint64(nt.slices)+7)/8 > uint64(len(d.b)) {
+		return ErrInvalidScanToken
+	}
+	nt.done = append([]byte(nil), d.bytes(int((nt.slices+7)/8))...)
+	for n := d.uint32(); n > 0 && !d.bad; n-- {
+		i := d.uint32()
+		item := ObjectListItem{
+			Name:      d.string(),
+			Namespace: d.string(),
+			Locator:   d.string(),
+		}
+		if i >= nt.slices || nt.isDone(i) {
+			return ErrInvalidScanToken
+		}
+		nt.last[i] = item
+	}
+	if d.bad || len(d.b) != 0 {
+		return ErrInvalidScanToken
+	}
+	*t = nt
+	return nil
+}
+
+func appendTokenBytes(b, v []byte) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(v)))
+	return append(b, v...)
+}
+
+// tokenDecoder reads the fields of an encoded ObjectScanToken. Once the
+// input is exhausted bad is set and nil or zero values are returned.
+type tokenDecoder struct {
+	b   []byte
+	bad bool
+}
+
+func (d *tokenDecoder) bytes(n int) []byte {
+	if d.bad || len(d.b) < n {
+		d.bad = true
+		return nil
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *tokenDecoder) uint32() uint32 {
+	if b := d.bytes(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (d *tokenDecoder) uint64() uint64 {
+	if b := d.bytes(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (d *tokenDecoder) lenBytes() []byte {
+	n := d.uint32()
+	if uint64(n) > uint64(len(d.b)) {
+		d.bad = true
+		return nil
+	}
+	return append([]byte(nil), d.bytes(int(n))...)
+}
+
+func (d *tokenDecoder) string() string {
+	return string(d.lenBytes())
+}
+
+// ObjectScan lists the objects of a pool by dividing it into disjoint slices
+// that are listed concurrently. The objects are listed in the namespace of
+// the IOContext, set the namespace to AllNamespaces to list all objects of
+// the pool.
+type ObjectScan struct {
+	ioctx *IOContext
+	opts  ObjectScanOptions
+
+	mutex sync.Mutex
+	token *ObjectScanToken
+}
+
+// NewObjectScan returns a new ObjectScan of the pool of the IOContext. If
+// opts is nil defaults are used. ErrInvalidScanToken is returned if
+// opts.Resume was taken from a different scan.
+func (ioctx *IOContext) NewObjectScan(opts *ObjectScanOptions) (*ObjectScan, error) {
+	if err := ioctx.validate(); err != nil {
+		return nil, err
+	}
+	s := &ObjectScan{ioctx: ioctx}
+	if opts != nil {
+		s.opts = *opts
+	}
+	if s.opts.Workers <= 0 {
+		s.opts.Workers = defaultScanWorkers
+	}
+	if s.opts.BatchSize <= 0 {
+		s.opts.BatchSize = defaultScanBatchSize
+	}
+	namespace, err := ioctx.GetNamespace()
+	if err != nil {
+		return nil, err
+	}
+	var filter []byte
+	if s.opts.Xattr != nil {
+		filter = encodeXattrFilter(s.opts.Xattr)
+	}
+
+	if r := s.opts.Resume; r != nil {
+		if r.slices == 0 ||
+			(s.opts.Slices > 0 && uint32(s.opts.Slices) != r.slices) ||
+			r.pool != ioctx.GetPoolID() ||
+			r.namespace != namespace ||
+			r.prefix != s.opts.Prefix ||
+			!bytes.Equal(r.filter, filter) {
+			return nil, ErrInvalidScanToken
+		}
+		s.token = r.clone()
+		return s, nil
+	}
+	if s.opts.Slices <= 0 {
+		s.opts.Slices = defaultScanSlices
+	}
+	s.token = newObjectScanToken(uint32(s.opts.Slices))
+	s.token.pool = ioctx.GetPoolID()
+	s.token.namespace = namespace
+	s.token.prefix = s.opts.Prefix
+	s.token.filter = filter
+	return s, nil
+}
+
+// encodeXattrFilter encodes the "plain" object listing filter understood by
+// the OSDs. User xattrs are stored with an underscore prefix.
+func encodeXattrFilter(f *XattrFilter) []byte {
+	var b []byte
+	for _, s := range [][]byte{[]byte("plain"), []byte("_" + f.Name), f.Value} {
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(s)))
+		b = append(b, s...)
+	}
+	return b
+}
+
+// Token returns a snapshot of the progress of the scan. It may be called
+// while the scan is running.
+func (s *ObjectScan) Token() *ObjectScanToken {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.token.clone()
+}
+
+// Run lists the objects of all slices that have not yet been completed,
+// calling fn for each object. The fn function is called concurrently from
+// multiple goroutines. A slice is only marked as completed once fn has
+// returned for all of its objects. When a scan is resumed, the objects of a
+// slice that was in progress are passed to fn starting after the last object
+// fn returned nil for. If that object no longer exists the whole slice is
+// passed to fn again.
+//
+// Run stops early, returning the error, if fn returns an error, listing fails
+// or ctx is done.
+func (s *ObjectScan) Run(ctx context.Context, fn func(ObjectListItem) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	slices := make(chan uint32)
+	errs := make(chan error, s.opts.Workers)
+	wg := sync.WaitGroup{}
+	for w := 0; w < s.opts.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range slices {
+				if err := s.listSlice(ctx, i, fn); err != nil {
+					errs <- err
+					cancel()
+					return
+				}
+				s.mutex.Lock()
+				s.token.setDone(i)
+				s.mutex.Unlock()
+			}
+		}()
+	}
+
+	token := s.Token()
+feed:
+	for i := uint32(0); i < token.slices; i++ {
+		if token.isDone(i) {
+			continue
+		}
+		select {
+		case slices <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(slices)
+	wg.Wait()
+
+	select {
+	case err := <-errs:
+		return err
+	default:
+	}
+	return ctx.Err()
+}
+
+// listSlice lists the objects in slice i of the pool. If the slice was in
+// progress, fn is only called for the objects after the last one recorded.
+func (s *ObjectScan) listSlice(ctx context.Context, i uint32, fn func(ObjectListItem) error) error {
+	s.mutex.Lock()
+	last, inProgress := s.token.last[i]
+	s.mutex.Unlock()
+	if inProgress {
+		found, err := s.listRange(ctx, i, &last, fn)
+		if err != nil || found {
+			return err
+		}
+		// the object is gone, so there is no telling where to continue
+	}
+	_, err := s.listRange(ctx, i, nil, fn)
+	return err
+}
+
+// listRange lists the objects in slice i of the pool, calling fn for the
+// objects after the object after, or for all objects if after is nil. It
+// returns false if after was not found.
+//
+// Implements:
+//
+//	void rados_object_list_slice(rados_ioctx_t io,
+//	                             const rados_object_list_cursor start,
+//	                             const rados_object_list_cursor finish,
+//	                             const size_t n,
+//	                             const size_t m,
+//	                             rados_object_list_cursor *split_start,
+//	                             rados_object_list_cursor *split_finish);
+//	int rados_object_list(rados_ioctx_t io,
+//	                      const rados_object_list_cursor start,
+//	                      const rados_object_list_cursor finish,
+//	                      const size_t result_size,
+//	                      const char *filter_buf,
+//	                      const size_t filter_buf_len,
+//	                      rados_object_list_item *results,
+//	                      rados_object_list_cursor *next);
+func (s *ObjectScan) listRange(
+	ctx context.Context, i uint32, after *ObjectListItem,
+	fn func(ObjectListItem) error) (bool, error) {
+
+	io := s.ioctx.ioctx
+	begin := C.rados_object_list_begin(io)
+	defer C.rados_object_list_cursor_free(io, begin)
+	end := C.rados_object_list_end(io)
+	defer C.rados_object_list_cursor_free(io, end)
+
+	var start, finish C.rados_object_list_cursor
+	C.rados_object_list_slice(
+		io, begin, end, C.size_t(i), C.size_t(s.token.slices), &start, &finish)
+	defer C.rados_object_list_cursor_free(io, finish)
+
+	filter := s.token.filter
+	var (
+		cFilter    *C.char
+		cFilterLen = C.size_t(len(filter))
+	)
+	if len(filter) > 0 {
+		cFilter = (*C.char)(C.CBytes(filter))
+		defer C.free(unsafe.Pointer(cFilter))
+	}
+
+	items := make([]C.rados_object_list_item, s.opts.BatchSize)
+	cur := start
+	defer func() { C.rados_object_list_cursor_free(io, cur) }()
+	for C.rados_object_list_cursor_cmp(io, cur, finish) < 0 {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		var next C.rados_object_list_cursor
+		ret := C.rados_object_list(
+			io,
+			cur,
+			finish,
+			C.size_t(len(items)),
+			cFilter,
+			cFilterLen,
+			&items[0],
+			&next)
+		if ret < 0 {
+			return false, getError(ret)
+		}
+		batch := make([]ObjectListItem, 0, int(ret))
+		for _, item := range items[:ret] {
+			name := C.GoStringN(item.oid, C.int(item.oid_length))
+			if !strings.HasPrefix(name, s.opts.Prefix) {
+				continue
+			}
+			batch = append(batch, ObjectListItem{
+				Name:      name,
+				Namespace: C.GoStringN(item.nspace, C.int(item.nspace_length)),
+				Locator:   C.GoStringN(item.locator, C.int(item.locator_length)),
+			})
+		}
+		C.rados_object_list_free(C.size_t(ret), &items[0])
+		C.rados_object_list_cursor_free(io, cur)
+		cur = next
+
+		for _, item := range batch {
+			if after != nil {
+				if item == *after {
+					after = nil
+				}
+				continue
+			}
+			if err := fn(item); err != nil {
+				return false, err
+			}
+			s.mutex.Lock()
+			s.token.last[i] = item
+			s.mutex.Unlock()
+		}
+	}
+	return after == nil, nil
+}
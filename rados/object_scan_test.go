@@ -0,0 +1,204 @@
+//go:build ceph_preview
+
+package rados
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (suite *RadosTestSuite) TestObjectScan() {
+	suite.SetupConnection()
+
+	suite.ioctx.SetNamespace(suite.T().Name())
+	const count = 100
+	expected := map[string]bool{}
+	for i := 0; i < count; i++ {
+		oid := fmt.Sprintf("scan-%03d", i)
+		require.NoError(suite.T(), suite.ioctx.Create(oid, CreateIdempotent))
+		if i%10 == 0 {
+			require.NoError(suite.T(), suite.ioctx.SetXattr(oid, "tag", []byte("ten")))
+		}
+		expected[oid] = true
+	}
+	require.NoError(suite.T(), suite.ioctx.Create("other", CreateIdempotent))
+
+	scan := func(t *testing.T, opts *ObjectScanOptions) map[string]bool {
+		s, err := suite.ioctx.NewObjectScan(opts)
+		require.NoError(t, err)
+		found := map[string]bool{}
+		mutex := sync.Mutex{}
+		err = s.Run(context.Background(), func(item ObjectListItem) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+			found[item.Name] = true
+			return nil
+		})
+		require.NoError(t, err)
+		tok := s.Token()
+		assert.Equal(t, tok.Slices(), tok.Completed())
+		return found
+	}
+
+	suite.T().Run("all", func(t *testing.T) {
+		found := scan(t, &ObjectScanOptions{Workers: 4, Slices: 16, BatchSize: 7})
+		assert.Len(t, found, count+1)
+	})
+
+	suite.T().Run("prefix", func(t *testing.T) {
+		found := scan(t, &ObjectScanOptions{Prefix: "scan-"})
+		assert.Equal(t, expected, found)
+	})
+
+	suite.T().Run("xattr", func(t *testing.T) {
+		found := scan(t, &ObjectScanOptions{
+			Xattr: &XattrFilter{Name: "tag", Value: []byte("ten")},
+		})
+		assert.Len(t, found, count/10)
+		for oid := range found {
+			assert.Contains(t, expected, oid)
+		}
+	})
+
+	suite.T().Run("resume", func(t *testing.T) {
+		s, err := suite.ioctx.NewObjectScan(&ObjectScanOptions{
+			Workers: 1,
+			Slices:  32,
+			Prefix:  "scan-",
+		})
+		require.NoError(t, err)
+		errStop := errors.New("stop")
+		found := map[string]bool{}
+		n := 0
+		err = s.Run(context.Background(), func(item ObjectListItem) error {
+			if n == count/2 {
+				return errStop
+			}
+			n++
+			found[item.Name] = true
+			return nil
+		})
+		assert.ErrorIs(t, err, errStop)
+
+		b, err := s.Token().MarshalBinary()
+		require.NoError(t, err)
+		tok := &ObjectScanToken{}
+		require.NoError(t, tok.UnmarshalBinary(b))
+		assert.Equal(t, 32, tok.Slices())
+		assert.Less(t, tok.Completed(), 32)
+
+		s, err = suite.ioctx.NewObjectScan(&ObjectScanOptions{
+			Prefix: "scan-",
+			Resume: tok,
+		})
+		require.NoError(t, err)
+		err = s.Run(context.Background(), func(item ObjectListItem) error {
+			// the slice in progress continues after the last object
+			assert.False(t, found[item.Name], item.Name)
+			n++
+			found[item.Name] = true
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, expected, found)
+		assert.Equal(t, count, n)
+	})
+
+	suite.T().Run("resumeMismatch", func(t *testing.T) {
+		s, err := suite.ioctx.NewObjectScan(&ObjectScanOptions{
+			Slices: 8,
+			Prefix: "scan-",
+			Xattr:  &XattrFilter{Name: "tag", Value: []byte("ten")},
+		})
+		require.NoError(t, err)
+		tok := s.Token()
+
+		_, err = suite.ioctx.NewObjectScan(&ObjectScanOptions{
+			Slices: 8,
+			Prefix: "scan-",
+			Xattr:  &XattrFilter{Name: "tag", Value: []byte("ten")},
+			Resume: tok,
+		})
+		assert.NoError(t, err)
+
+		for name, opts := range map[string]ObjectScanOptions{
+			"slices": {Slices: 16, Prefix: "scan-",
+				Xattr: &XattrFilter{Name: "tag", Value: []byte("ten")}},
+			"prefix": {Prefix: "other-",
+				Xattr: &XattrFilter{Name: "tag", Value: []byte("ten")}},
+			"xattr": {Prefix: "scan-",
+				Xattr: &XattrFilter{Name: "tag", Value: []byte("one")}},
+			"noXattr": {Prefix: "scan-"},
+		} {
+			opts.Resume = tok
+			_, err = suite.ioctx.NewObjectScan(&opts)
+			assert.ErrorIs(t, err, ErrInvalidScanToken, name)
+		}
+
+		suite.ioctx.SetNamespace("other")
+		defer suite.ioctx.SetNamespace(suite.T().Name())
+		_, err = suite.ioctx.NewObjectScan(&ObjectScanOptions{
+			Prefix: "scan-",
+			Xattr:  &XattrFilter{Name: "tag", Value: []byte("ten")},
+			Resume: tok,
+		})
+		assert.ErrorIs(t, err, ErrInvalidScanToken)
+	})
+
+	suite.T().Run("canceled", func(t *testing.T) {
+		s, err := suite.ioctx.NewObjectScan(nil)
+		require.NoError(t, err)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err = s.Run(ctx, func(ObjectListItem) error { return nil })
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	suite.T().Run("invalidToken", func(t *testing.T) {
+		tok := &ObjectScanToken{}
+		assert.ErrorIs(t, tok.UnmarshalBinary([]byte{1, 0, 0}), ErrInvalidScanToken)
+		assert.ErrorIs(t, tok.UnmarshalBinary([]byte{1, 0, 0, 0, 9, 0}), ErrInvalidScanToken)
+		b, err := newObjectScanToken(9).MarshalBinary()
+		require.NoError(t, err)
+		assert.ErrorIs(t, tok.UnmarshalBinary(b[:len(b)-1]), ErrInvalidScanToken)
+		assert.ErrorIs(t, tok.UnmarshalBinary(append(b, 0)), ErrInvalidScanToken)
+		_, err = suite.ioctx.NewObjectScan(&ObjectScanOptions{Resume: tok})
+		assert.ErrorIs(t, err, ErrInvalidScanToken)
+	})
+}
+
+func TestObjectScanTokenEncoding(t *testing.T) {
+	tok := newObjectScanToken(20)
+	tok.pool = 7
+	tok.namespace = "ns"
+	tok.prefix = "scan-"
+	tok.filter = encodeXattrFilter(&XattrFilter{Name: "tag", Value: []byte("v")})
+	tok.setDone(3)
+	tok.setDone(19)
+	tok.last[5] = ObjectListItem{Name: "scan-1", Namespace: "ns"}
+	tok.last[11] = ObjectListItem{Name: "scan-2", Namespace: "ns", Locator: "loc"}
+
+	b, err := tok.MarshalBinary()
+	require.NoError(t, err)
+	b2, err := tok.clone().MarshalBinary()
+	require.NoError(t, err)
+	assert.Equal(t, b, b2)
+
+	var decoded ObjectScanToken
+	require.NoError(t, decoded.UnmarshalBinary(b))
+	assert.Equal(t, tok, &decoded)
+	assert.Equal(t, 20, decoded.Slices())
+	assert.Equal(t, 2, decoded.Completed())
+
+	// a slice can not be both done and in progress
+	tok.done[0] |= 1 << 5
+	b, err = tok.MarshalBinary()
+	require.NoError(t, err)
+	assert.ErrorIs(t, decoded.UnmarshalBinary(b), ErrInvalidScanToken)
+}
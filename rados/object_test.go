@@ -0,0 +1,152 @@
+//go:build ceph_preview
+
+package rados
+
+import (
+	"bytes"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ interface {
+	io.ReadSeekCloser
+	io.Writer
+	io.ReaderAt
+	io.WriterAt
+	io.WriterTo
+	io.ReaderFrom
+} = (*Object)(nil)
+
+func testObjectData(size int) []byte {
+	data := make([]byte, size)
+	r := rand.NewChaCha8([32]byte{})
+	_, _ = r.Read(data)
+	return data
+}
+
+func (suite *RadosTestSuite) TestObjectReadAtWriteAt() {
+	suite.SetupConnection()
+	ta := assert.New(suite.T())
+
+	oid := suite.GenObjectName()
+	o := suite.ioctx.ObjectWithOptions(oid, &ObjectOptions{ChunkSize: 1000, Readahead: 3})
+	defer o.Close()
+	data := testObjectData(10500)
+
+	n, err := o.WriteAt(data, 0)
+	require.NoError(suite.T(), err)
+	ta.Equal(len(data), n)
+
+	buf := make([]byte, 4500)
+	n, err = o.ReadAt(buf, 1234)
+	ta.NoError(err)
+	ta.Equal(len(buf), n)
+	ta.Equal(data[1234:1234+len(buf)], buf)
+
+	// reading past the end returns io.EOF
+	n, err = o.ReadAt(buf, 8000)
+	ta.ErrorIs(err, io.EOF)
+	ta.Equal(2500, n)
+	ta.Equal(data[8000:], buf[:n])
+
+	_, err = o.ReadAt(buf, -1)
+	ta.ErrorIs(err, ErrInvalidOffset)
+
+	_, err = suite.ioctx.Object(oid+"-missing").ReadAt(buf, 0)
+	ta.ErrorIs(err, ErrNotFound)
+}
+
+func (suite *RadosTestSuite) TestObjectReadSeek() {
+	suite.SetupConnection()
+	ta := assert.New(suite.T())
+
+	oid := suite.GenObjectName()
+	data := testObjectData(20000)
+	require.NoError(suite.T(), suite.ioctx.WriteFull(oid, data))
+
+	o := suite.ioctx.ObjectWithOptions(oid, &ObjectOptions{ChunkSize: 1024, Readahead: 4})
+	defer o.Close()
+	out, err := io.ReadAll(o)
+	ta.NoError(err)
+	ta.Equal(data, out)
+
+	pos, err := o.Seek(-100, io.SeekEnd)
+	ta.NoError(err)
+	ta.EqualValues(19900, pos)
+	out, err = io.ReadAll(o)
+	ta.NoError(err)
+	ta.Equal(data[19900:], out)
+
+	_, err = o.Seek(500, io.SeekStart)
+	ta.NoError(err)
+	buf := make([]byte, 10)
+	_, err = io.ReadFull(o, buf)
+	ta.NoError(err)
+	ta.Equal(data[500:510], buf)
+	// skip forward within the current chunk
+	pos, err = o.Seek(90, io.SeekCurrent)
+	ta.NoError(err)
+	ta.EqualValues(600, pos)
+	_, err = io.ReadFull(o, buf)
+	ta.NoError(err)
+	ta.Equal(data[600:610], buf)
+
+	_, err = o.Seek(-1000, io.SeekCurrent)
+	ta.ErrorIs(err, ErrInvalidOffset)
+}
+
+func (suite *RadosTestSuite) TestObjectCopy() {
+	suite.SetupConnection()
+	ta := assert.New(suite.T())
+
+	oid := suite.GenObjectName()
+	data := testObjectData(50000)
+	o := suite.ioctx.ObjectWithOptions(oid, &ObjectOptions{ChunkSize: 4096})
+	defer o.Close()
+
+	// ReadFrom
+	n, err := o.ReadFrom(bytes.NewReader(data))
+	ta.NoError(err)
+	ta.EqualValues(len(data), n)
+	st, err := o.Stat()
+	ta.NoError(err)
+	ta.EqualValues(len(data), st.Size)
+
+	// Write appends at the current offset
+	_, err = o.Write([]byte("tail"))
+	ta.NoError(err)
+
+	// WriteTo
+	_, err = o.Seek(0, io.SeekStart)
+	ta.NoError(err)
+	var out bytes.Buffer
+	n, err = io.Copy(&out, o)
+	ta.NoError(err)
+	ta.EqualValues(len(data)+4, n)
+	ta.Equal(append(data, "tail"...), out.Bytes())
+}
+
+func (suite *RadosTestSuite) TestObjectServeContent() {
+	suite.SetupConnection()
+	ta := assert.New(suite.T())
+
+	oid := suite.GenObjectName()
+	data := []byte(strings.Repeat("0123456789", 100))
+	require.NoError(suite.T(), suite.ioctx.WriteFull(oid, data))
+
+	o := suite.ioctx.Object(oid)
+	defer o.Close()
+	req := httptest.NewRequest(http.MethodGet, "/obj", nil)
+	req.Header.Set("Range", "bytes=10-19")
+	rec := httptest.NewRecorder()
+	http.ServeContent(rec, req, oid, time.Time{}, o)
+	ta.Equal(http.StatusPartialContent, rec.Code)
+	ta.Equal("0123456789", rec.Body.String())
+}
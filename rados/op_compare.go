@@ -0,0 +1,180 @@
+//go:build ceph_preview
+
+package rados
+
+// #cgo LDFLAGS: -lrados
+// #include <rados/librados.h>
+// #include <stdlib.h>
+//
+import "C"
+
+import (
+	"unsafe"
+)
+
+// CmpXattrOp is the comparison applied by the CmpXattr and OmapCmp steps of
+// read and write operations. The stored value is the left-hand side of the
+// comparison.
+type CmpXattrOp uint8
+
+const (
+	// CmpXattrOpEq tests that the stored value is equal to the given value.
+	CmpXattrOpEq = CmpXattrOp(C.LIBRADOS_CMPXATTR_OP_EQ)
+	// CmpXattrOpNe tests that the stored value is not equal to the given
+	// value.
+	CmpXattrOpNe = CmpXattrOp(C.LIBRADOS_CMPXATTR_OP_NE)
+	// CmpXattrOpGt tests that the stored value is greater than the given
+	// value.
+	CmpXattrOpGt = CmpXattrOp(C.LIBRADOS_CMPXATTR_OP_GT)
+	// CmpXattrOpGte tests that the stored value is greater than or equal to
+	// the given value.
+	CmpXattrOpGte = CmpXattrOp(C.LIBRADOS_CMPXATTR_OP_GTE)
+	// CmpXattrOpLt tests that the stored value is less than the given value.
+	CmpXattrOpLt = CmpXattrOp(C.LIBRADOS_CMPXATTR_OP_LT)
+	// CmpXattrOpLte tests that the stored value is less than or equal to the
+	// given value.
+	CmpXattrOpLte = CmpXattrOp(C.LIBRADOS_CMPXATTR_OP_LTE)
+)
+
+func bytesPtr(b []byte) *C.char {
+	if len(b) == 0 {
+		return nil
+	}
+	return (*C.char)(unsafe.Pointer(&b[0]))
+}
+
+// CmpXattr ensures that the named xattr of the object compares to value as
+// given by op. If the comparison fails the whole operation fails with
+// ECANCELED.
+//
+// Implements:
+//
+//	void rados_read_op_cmpxattr(rados_read_op_t read_op,
+//	                            const char *name,
+//	                            uint8_t comparison_operator,
+//	                            const char *value,
+//	                            size_t value_len);
+func (r *ReadOp) CmpXattr(name string, op CmpXattrOp, value []byte) {
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+
+	C.rados_read_op_cmpxattr(
+		r.op,
+		cName,
+		C.uint8_t(op),
+		bytesPtr(value),
+		C.size_t(len(value)))
+}
+
+// CmpXattr ensures that the named xattr of the object compares to value as
+// given by op. If the comparison fails the whole operation fails with
+// ECANCELED and no changes are made to the object.
+//
+// Implements:
+//
+//	void rados_write_op_cmpxattr(rados_write_op_t write_op,
+//	                             const char *name,
+//	                             uint8_t comparison_operator,
+//	                             const char *value,
+//	                             size_t value_len);
+func (w *WriteOp) CmpXattr(name string, op CmpXattrOp, value []byte) {
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+
+	C.rados_write_op_cmpxattr(
+		w.op,
+		cName,
+		C.uint8_t(op),
+		bytesPtr(value),
+		C.size_t(len(value)))
+}
+
+// OmapCmpStep holds the result of an OmapCmp step of a read or write
+// operation. Result is valid only after Operate() was called.
+type OmapCmpStep struct {
+	// C returned data:
+	prval *C.int
+
+	// Result of the comparison, zero if it succeeded.
+	Result int
+}
+
+func newOmapCmpStep() *OmapCmpStep {
+	s := &OmapCmpStep{
+		prval: (*C.int)(C.malloc(C.sizeof_int)),
+	}
+	*s.prval = 0
+	return s
+}
+
+func (s *OmapCmpStep) update() error {
+	s.Result = int(*s.prval)
+	return getError(*s.prval)
+}
+
+func (s *OmapCmpStep) free() {
+	C.free(unsafe.Pointer(s.prval))
+	s.prval = nil
+}
+
+// OmapCmp ensures that the value of the omap key of the object compares to
+// value as given by op. If the comparison fails the whole operation fails
+// with ECANCELED.
+//
+// Implements:
+//
+//	void rados_read_op_omap_cmp2(rados_read_op_t read_op,
+//	                             const char *key,
+//	                             uint8_t comparison_operator,
+//	                             const char *val,
+//	                             size_t key_len,
+//	                             size_t val_len,
+//	                             int *prval);
+func (r *ReadOp) OmapCmp(key string, op CmpXattrOp, value []byte) *OmapCmpStep {
+	s := newOmapCmpStep()
+	r.steps = append(r.steps, s)
+
+	cKey := C.CString(key)
+	defer C.free(unsafe.Pointer(cKey))
+
+	C.rados_read_op_omap_cmp2(
+		r.op,
+		cKey,
+		C.uint8_t(op),
+		bytesPtr(value),
+		C.size_t(len(key)),
+		C.size_t(len(value)),
+		s.prval)
+	return s
+}
+
+// OmapCmp ensures that the value of the omap key of the object compares to
+// value as given by op. If the comparison fails the whole operation fails
+// with ECANCELED and no changes are made to the object.
+//
+// Implements:
+//
+//	void rados_write_op_omap_cmp2(rados_write_op_t write_op,
+//	                              const char *key,
+//	                              uint8_t comparison_operator,
+//	                              const char *val,
+//	                              size_t key_len,
+//	                              size_t val_len,
+//	                              int *prval);
+func (w *WriteOp) OmapCmp(key string, op CmpXattrOp, value []byte) *OmapCmpStep {
+	s := newOmapCmpStep()
+	w.steps = append(w.steps, s)
+
+	cKey := C.CString(key)
+	defer C.free(unsafe.Pointer(cKey))
+
+	C.rados_write_op_omap_cmp2(
+		w.op,
+		cKey,
+		C.uint8_t(op),
+		bytesPtr(value),
+		C.size_t(len(key)),
+		C.size_t(len(value)),
+		s.prval)
+	return s
+}
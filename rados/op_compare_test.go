@@ -0,0 +1,81 @@
+//go:build ceph_preview
+
+package rados
+
+import (
+	"errors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (suite *RadosTestSuite) TestOpCmpXattr() {
+	suite.SetupConnection()
+	ta := assert.New(suite.T())
+
+	oid := suite.GenObjectName()
+	op1 := CreateWriteOp()
+	defer op1.Release()
+	op1.Create(CreateIdempotent)
+	op1.SetXattr("version", []byte("2"))
+	require.NoError(suite.T(), op1.Operate(suite.ioctx, oid, OperationNoFlag))
+
+	// matching comparison, the write is applied
+	op2 := CreateWriteOp()
+	defer op2.Release()
+	op2.CmpXattr("version", CmpXattrOpEq, []byte("2"))
+	op2.SetXattr("version", []byte("3"))
+	ta.NoError(op2.Operate(suite.ioctx, oid, OperationNoFlag))
+
+	// failing comparison, the write is not applied
+	op3 := CreateWriteOp()
+	defer op3.Release()
+	op3.CmpXattr("version", CmpXattrOpEq, []byte("2"))
+	op3.SetXattr("version", []byte("4"))
+	err := op3.Operate(suite.ioctx, oid, OperationNoFlag)
+	ta.Error(err)
+
+	v := make([]byte, 8)
+	n, err := suite.ioctx.GetXattr(oid, "version", v)
+	ta.NoError(err)
+	ta.Equal([]byte("3"), v[:n])
+
+	op4 := CreateReadOp()
+	defer op4.Release()
+	op4.CmpXattr("version", CmpXattrOpGt, []byte("2"))
+	ta.NoError(op4.Operate(suite.ioctx, oid, OperationNoFlag))
+
+	op5 := CreateReadOp()
+	defer op5.Release()
+	op5.CmpXattr("version", CmpXattrOpLt, []byte("2"))
+	ta.Error(op5.Operate(suite.ioctx, oid, OperationNoFlag))
+}
+
+func (suite *RadosTestSuite) TestOpOmapCmp() {
+	suite.SetupConnection()
+	ta := assert.New(suite.T())
+
+	oid := suite.GenObjectName()
+	op1 := CreateWriteOp()
+	defer op1.Release()
+	op1.SetOmap(map[string][]byte{"owner": []byte("alice")})
+	require.NoError(suite.T(), op1.Operate(suite.ioctx, oid, OperationNoFlag))
+
+	op2 := CreateWriteOp()
+	defer op2.Release()
+	s2 := op2.OmapCmp("owner", CmpXattrOpEq, []byte("alice"))
+	op2.SetOmap(map[string][]byte{"owner": []byte("bob")})
+	ta.NoError(op2.Operate(suite.ioctx, oid, OperationNoFlag))
+	ta.Equal(0, s2.Result)
+
+	op3 := CreateReadOp()
+	defer op3.Release()
+	s3 := op3.OmapCmp("owner", CmpXattrOpEq, []byte("alice"))
+	err := op3.Operate(suite.ioctx, oid, OperationNoFlag)
+	ta.Error(err)
+	var opErr OperationError
+	if ta.True(errors.As(err, &opErr)) {
+		ta.Contains(opErr.StepErrors, 0)
+	}
+	ta.NotEqual(0, s3.Result)
+}
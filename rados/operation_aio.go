@@ -0,0 +1,102 @@
+//go:build ceph_preview
+
+package rados
+
+// #cgo LDFLAGS: -lrados
+// #include <stdlib.h>
+// #include <rados/librados.h>
+import "C"
+
+import (
+	"unsafe"
+
+	ts "github.com/ceph/go-ceph/internal/timespec"
+)
+
+// OperateAsync will asynchronously perform the operation(s). The steps of the
+// write op are updated once the returned Completion is done. Wait on the
+// Completion returns an OperationError in the same cases Operate would. The
+// WriteOp must not be released before the Completion is done.
+//
+// Implements:
+//
+//	int rados_aio_write_op_operate2(rados_write_op_t write_op,
+//	                                rados_ioctx_t io,
+//	                                rados_completion_t completion,
+//	                                const char *oid,
+//	                                struct timespec *mtime,
+//	                                int flags);
+func (w *WriteOp) OperateAsync(ioctx *IOContext, oid string, flags OperationFlags) (*Completion, error) {
+	return w.operateAsync2(ioctx, oid, nil, flags)
+}
+
+// OperateWithMtimeAsync will asynchronously perform the operation while
+// setting the modification time stamp to the supplied value. See
+// OperateAsync.
+func (w *WriteOp) OperateWithMtimeAsync(
+	ioctx *IOContext, oid string, mtime Timespec, flags OperationFlags) (*Completion, error) {
+
+	return w.operateAsync2(ioctx, oid, &mtime, flags)
+}
+
+func (w *WriteOp) operateAsync2(
+	ioctx *IOContext, oid string, mtime *Timespec, flags OperationFlags) (*Completion, error) {
+
+	c, err := newCompletion(ioctx)
+	if err != nil {
+		return nil, err
+	}
+	c.finish = func(ret C.int) error {
+		return w.update(writeOp, ret)
+	}
+
+	cOid := C.CString(oid)
+	defer C.free(unsafe.Pointer(cOid))
+	var cMtime *C.struct_timespec
+	if mtime != nil {
+		cMtime = &C.struct_timespec{}
+		ts.CopyToCStruct(
+			ts.Timespec(*mtime),
+			ts.CTimespecPtr(cMtime))
+	}
+
+	ret := C.rados_aio_write_op_operate2(
+		w.op, ioctx.ioctx, c.comp, cOid, cMtime, C.int(flags))
+	if err := c.submitted(ret); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// OperateAsync will asynchronously perform the operation(s). The steps of the
+// read op are updated once the returned Completion is done and must not be
+// accessed before then. Wait on the Completion returns an OperationError in
+// the same cases Operate would. The ReadOp must not be released before the
+// Completion is done.
+//
+// Implements:
+//
+//	int rados_aio_read_op_operate(rados_read_op_t read_op,
+//	                              rados_ioctx_t io,
+//	                              rados_completion_t completion,
+//	                              const char *oid,
+//	                              int flags);
+func (r *ReadOp) OperateAsync(ioctx *IOContext, oid string, flags OperationFlags) (*Completion, error) {
+	c, err := newCompletion(ioctx)
+	if err != nil {
+		return nil, err
+	}
+	c.finish = func(ret C.int) error {
+		return r.update(readOp, ret)
+	}
+
+	cOid := C.CString(oid)
+	defer C.free(unsafe.Pointer(cOid))
+
+	ret := C.rados_aio_read_op_operate(
+		r.op, ioctx.ioctx, c.comp, cOid, C.int(flags))
+	if err := c.submitted(ret); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
@@ -0,0 +1,81 @@
+//go:build ceph_preview
+
+package rados
+
+import (
+	"errors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (suite *RadosTestSuite) TestWriteOpOperateAsync() {
+	suite.SetupConnection()
+	ta := assert.New(suite.T())
+	oid := suite.GenObjectName()
+
+	op := CreateWriteOp()
+	defer op.Release()
+	op.Create(CreateExclusive)
+	op.SetOmap(map[string][]byte{"foo": []byte("bar")})
+	op.WriteFull([]byte("async op data"))
+	c, err := op.OperateAsync(suite.ioctx, oid, OperationNoFlag)
+	require.NoError(suite.T(), err)
+	_, err = c.Wait()
+	ta.NoError(err)
+	c.Release()
+
+	// exclusive create fails the 2nd time
+	op2 := CreateWriteOp()
+	defer op2.Release()
+	op2.Create(CreateExclusive)
+	c, err = op2.OperateWithMtimeAsync(suite.ioctx, oid, timeStamp(), OperationNoFlag)
+	require.NoError(suite.T(), err)
+	_, err = c.Wait()
+	ta.ErrorIs(err, ErrObjectExists)
+	var opErr OperationError
+	ta.True(errors.As(err, &opErr))
+	c.Release()
+}
+
+func (suite *RadosTestSuite) TestReadOpOperateAsync() {
+	suite.SetupConnection()
+	ta := assert.New(suite.T())
+	oid := suite.GenObjectName()
+
+	data := []byte("some data to be read back")
+	err := suite.ioctx.WriteFull(oid, data)
+	require.NoError(suite.T(), err)
+	err = suite.ioctx.SetOmap(oid, map[string][]byte{
+		"alpha": []byte("one"),
+		"beta":  []byte("two"),
+	})
+	require.NoError(suite.T(), err)
+
+	op := CreateReadOp()
+	defer op.Release()
+	buf := make([]byte, 64)
+	rs := op.Read(0, buf)
+	gos := op.GetOmapValues("", "", 10)
+	c, err := op.OperateAsync(suite.ioctx, oid, OperationNoFlag)
+	require.NoError(suite.T(), err)
+	_, err = c.Wait()
+	ta.NoError(err)
+	c.Release()
+
+	ta.EqualValues(len(data), rs.BytesRead)
+	ta.Equal(data, buf[:rs.BytesRead])
+	ta.Equal(map[string][]byte{
+		"alpha": []byte("one"),
+		"beta":  []byte("two"),
+	}, getAllMap(gos))
+
+	op2 := CreateReadOp()
+	defer op2.Release()
+	op2.AssertExists()
+	c, err = op2.OperateAsync(suite.ioctx, oid+"-missing", OperationNoFlag)
+	require.NoError(suite.T(), err)
+	_, err = c.Wait()
+	ta.ErrorIs(err, ErrNotFound)
+	c.Release()
+}
@@ -0,0 +1,381 @@
+//go:build ceph_preview
+
+package rados
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// object hash types of a pool, CEPH_STR_HASH_*
+const (
+	hashTypeLinux    = 1
+	hashTypeRjenkins = 2
+)
+
+// ErrUnsupportedHash is returned by NewPlacementCache if the pool uses an
+// object hash function that is not implemented.
+var ErrUnsupportedHash = errors.New("unsupported object hash")
+
+// PGID identifies a placement group of a pool.
+type PGID struct {
+	Pool int64
+	Seed uint32
+}
+
+// String returns the placement group ID in the form used by Ceph, for
+// example "1.2f".
+func (p PGID) String() string {
+	return fmt.Sprintf("%d.%x", p.Pool, p.Seed)
+}
+
+// ParsePGID parses a placement group ID of the form "<pool>.<hex seed>".
+func ParsePGID(s string) (PGID, error) {
+	pool, seed, ok := strings.Cut(s, ".")
+	if !ok {
+		return PGID{}, fmt.Errorf("invalid pg id %q", s)
+	}
+	p, err := strconv.ParseInt(pool, 10, 64)
+	if err != nil {
+		return PGID{}, fmt.Errorf("invalid pg id %q: %w", s, err)
+	}
+	n, err := strconv.ParseUint(seed, 16, 32)
+	if err != nil {
+		return PGID{}, fmt.Errorf("invalid pg id %q: %w", s, err)
+	}
+	return PGID{Pool: p, Seed: uint32(n)}, nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (p *PGID) UnmarshalText(b []byte) error {
+	v, err := ParsePGID(string(b))
+	if err != nil {
+		return err
+	}
+	*p = v
+	return nil
+}
+
+// ObjectPlacement describes where an object is stored.
+type ObjectPlacement struct {
+	// Epoch is the OSD map epoch the placement was computed with.
+	Epoch     uint32 `json:"epoch"`
+	Pool      string `json:"pool"`
+	PoolID    int64  `json:"pool_id"`
+	Object    string `json:"-"`
+	Namespace string `json:"-"`
+	// RawPGID is the full hash of the object, PGID the placement group the
+	// object is mapped to.
+	RawPGID PGID `json:"raw_pgid"`
+	PGID    PGID `json:"pgid"`
+	// Up are the OSDs the placement group maps to, Acting the OSDs
+	// currently serving it. They differ while the placement group is
+	// remapped.
+	Up            []int `json:"up"`
+	UpPrimary     int   `json:"up_primary"`
+	Acting        []int `json:"acting"`
+	ActingPrimary int   `json:"acting_primary"`
+}
+
+// ObjectPlacement returns the placement group and OSDs of the object oid in
+// the given pool and namespace.
+//
+// Similar To:
+//
+//	ceph osd map <pool> <object> [<nspace>]
+func (c *Conn) ObjectPlacement(pool, namespace, oid string) (*ObjectPlacement, error) {
+	if err := c.ensureConnected(); err != nil {
+		return nil, err
+	}
+	cmd := map[string]interface{}{
+		"prefix": "osd map",
+		"pool":   pool,
+		"object": oid,
+		"format": "json",
+	}
+	if namespace != "" {
+		cmd["nspace"] = namespace
+	}
+	p := &ObjectPlacement{}
+	if err := c.monCommand(cmd, p); err != nil {
+		return nil, err
+	}
+	p.Object = oid
+	p.Namespace = namespace
+	return p, nil
+}
+
+// ObjectPlacement returns the placement group and OSDs of the object oid,
+// taking the namespace and the locator key set on the IOContext into
+// account.
+//
+// Similar To:
+//
+//	ceph osd map <pool> <object> [<nspace>]
+func (ioctx *IOContext) ObjectPlacement(oid string) (*ObjectPlacement, error) {
+	pool, namespace, err := ioctx.placementPool()
+	if err != nil {
+		return nil, err
+	}
+	// The placement only depends on the locator key if one is set, so the
+	// placement of the key is the placement of the object.
+	p, err := ioctx.conn.ObjectPlacement(pool, namespace, ioctx.placementKey(oid))
+	if err != nil {
+		return nil, err
+	}
+	p.Object = oid
+	return p, nil
+}
+
+func (ioctx *IOContext) placementPool() (string, string, error) {
+	if err := ioctx.validate(); err != nil {
+		return "", "", err
+	}
+	pool, err := ioctx.GetPoolName()
+	if err != nil {
+		return "", "", err
+	}
+	namespace, err := ioctx.GetNamespace()
+	if err != nil {
+		return "", "", err
+	}
+	return pool, namespace, nil
+}
+
+func (ioctx *IOContext) placementKey(oid string) string {
+	if ioctx.locator != "" {
+		return ioctx.locator
+	}
+	return oid
+}
+
+// PlacementCache looks up the placement of objects of a pool with few
+// requests to the monitors. The placement group of an object is computed
+// locally from the object hash, and the OSDs of each placement group are
+// only requested once.
+//
+// The cache does not notice changes of the OSD map. Call Refresh to drop
+// the cached placements, for example after OSDs changed their state.
+type PlacementCache struct {
+	ioctx *IOContext
+
+	mutex     sync.Mutex
+	poolID    int64
+	pgNum     uint32
+	hash      int
+	placement map[uint32]*ObjectPlacement
+}
+
+// NewPlacementCache returns a PlacementCache for the pool of the IOContext.
+// The namespace and locator key set on the IOContext at the time of each
+// lookup are used.
+func (ioctx *IOContext) NewPlacementCache() (*PlacementCache, error) {
+	pc := &PlacementCache{ioctx: ioctx}
+	if err := pc.Refresh(); err != nil {
+		return nil, err
+	}
+	return pc, nil
+}
+
+type osdDumpPools struct {
+	Pools []struct {
+		Pool       int64  `json:"pool"`
+		PgNum      uint32 `json:"pg_num"`
+		ObjectHash int    `json:"object_hash"`
+	} `json:"pools"`
+}
+
+// Refresh drops the cached placements and reloads the number of placement
+// groups of the pool.
+//
+// Similar To:
+//
+//	ceph osd dump
+func (pc *PlacementCache) Refresh() error {
+	if err := pc.ioctx.validate(); err != nil {
+		return err
+	}
+	var dump osdDumpPools
+	err := pc.ioctx.conn.monCommand(map[string]interface{}{
+		"prefix": "osd dump",
+		"format": "json",
+	}, &dump)
+	if err != nil {
+		return err
+	}
+	id := pc.ioctx.GetPoolID()
+	for _, p := range dump.Pools {
+		if p.Pool != id {
+			continue
+		}
+		if p.ObjectHash != hashTypeLinux && p.ObjectHash != hashTypeRjenkins {
+			return fmt.Errorf("%w: %d", ErrUnsupportedHash, p.ObjectHash)
+		}
+		pc.mutex.Lock()
+		defer pc.mutex.Unlock()
+		pc.poolID = id
+		pc.pgNum = p.PgNum
+		pc.hash = p.ObjectHash
+		pc.placement = map[uint32]*ObjectPlacement{}
+		return nil
+	}
+	return ErrNotFound
+}
+
+// PGID returns the placement group of the object oid without contacting the
+// cluster.
+func (pc *PlacementCache) PGID(oid string) PGID {
+	namespace, _ := pc.ioctx.GetNamespace()
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+	_, pg := pc.pgid(namespace, pc.ioctx.placementKey(oid))
+	return pg
+}
+
+func (pc *PlacementCache) pgid(namespace, key string) (raw, pg PGID) {
+	h := objectHash(pc.hash, namespace, key)
+	raw = PGID{Pool: pc.poolID, Seed: h}
+	pg = PGID{Pool: pc.poolID, Seed: stableMod(h, pc.pgNum, pgNumMask(pc.pgNum))}
+	return raw, pg
+}
+
+// ObjectPlacement returns the placement group and OSDs of the object oid.
+// Only the first lookup of an object of each placement group contacts the
+// monitors.
+func (pc *PlacementCache) ObjectPlacement(oid string) (*ObjectPlacement, error) {
+	pool, namespace, err := pc.ioctx.placementPool()
+	if err != nil {
+		return nil, err
+	}
+	key := pc.ioctx.placementKey(oid)
+	pc.mutex.Lock()
+	raw, pg := pc.pgid(namespace, key)
+	cached := pc.placement[pg.Seed]
+	pc.mutex.Unlock()
+
+	if cached == nil {
+		p, err := pc.ioctx.conn.ObjectPlacement(pool, namespace, key)
+		if err != nil {
+			return nil, err
+		}
+		if p.PGID != pg {
+			// the number of placement groups changed
+			if err := pc.Refresh(); err != nil {
+				return nil, err
+			}
+			pg = p.PGID
+			raw = p.RawPGID
+		}
+		pc.mutex.Lock()
+		pc.placement[pg.Seed] = p
+		pc.mutex.Unlock()
+		cached = p
+	}
+	p := *cached
+	p.Object = oid
+	p.Namespace = namespace
+	p.RawPGID = raw
+	p.Up = append([]int(nil), cached.Up...)
+	p.Acting = append([]int(nil), cached.Acting...)
+	return &p, nil
+}
+
+// objectHash returns the hash of an object key in a namespace like
+// pg_pool_t::hash_key.
+func objectHash(hash int, namespace, key string) uint32 {
+	if namespace != "" {
+		key = namespace + "\037" + key
+	}
+	if hash == hashTypeLinux {
+		return strHashLinux([]byte(key))
+	}
+	return strHashRjenkins([]byte(key))
+}
+
+// pgNumMask returns the smallest mask of the form 2^n-1 covering pgNum-1.
+func pgNumMask(pgNum uint32) uint32 {
+	if pgNum == 0 {
+		return 0
+	}
+	return 1<<bits.Len32(pgNum-1) - 1
+}
+
+// stableMod maps x to [0, b) such that increasing b moves few values, like
+// ceph_stable_mod.
+func stableMod(x, b, bmask uint32) uint32 {
+	if x&bmask < b {
+		return x & bmask
+	}
+	return x & (bmask >> 1)
+}
+
+// strHashLinux implements ceph_str_hash_linux.
+func strHashLinux(k []byte) uint32 {
+	var h uint32
+	for _, c := range k {
+		h = (h + uint32(c)<<4 + uint32(c)>>4) * 11
+	}
+	return h
+}
+
+func rjenkinsMix(a, b, c uint32) (uint32, uint32, uint32) {
+	a -= b
+	a -= c
+	a ^= c >> 13
+	b -= c
+	b -= a
+	b ^= a << 8
+	c -= a
+	c -= b
+	c ^= b >> 13
+	a -= b
+	a -= c
+	a ^= c >> 12
+	b -= c
+	b -= a
+	b ^= a << 16
+	c -= a
+	c -= b
+	c ^= b >> 5
+	a -= b
+	a -= c
+	a ^= c >> 3
+	b -= c
+	b -= a
+	b ^= a << 10
+	c -= a
+	c -= b
+	c ^= b >> 15
+	return a, b, c
+}
+
+func le32(k []byte) uint32 {
+	return uint32(k[0]) | uint32(k[1])<<8 | uint32(k[2])<<16 | uint32(k[3])<<24
+}
+
+// strHashRjenkins implements ceph_str_hash_rjenkins, Robert Jenkins' hash
+// function.
+func strHashRjenkins(k []byte) uint32 {
+	a, b, c := uint32(0x9e3779b9), uint32(0x9e3779b9), uint32(0)
+	length := uint32(len(k))
+	for len(k) >= 12 {
+		a += le32(k[0:])
+		b += le32(k[4:])
+		c += le32(k[8:])
+		a, b, c = rjenkinsMix(a, b, c)
+		k = k[12:]
+	}
+	// the remaining bytes are added as if zero padded, the lowest byte of c
+	// is reserved for the length
+	var t [12]byte
+	copy(t[:], k)
+	a += le32(t[0:])
+	b += le32(t[4:])
+	c += length + le32(t[8:])<<8
+	_, _, c = rjenkinsMix(a, b, c)
+	return c
+}
@@ -0,0 +1,99 @@
+//go:build ceph_preview
+
+package rados
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObjectHash(t *testing.T) {
+	// from the documentation of "ceph osd map"
+	assert.EqualValues(t, 0x7fc1f406, objectHash(hashTypeRjenkins, "", "foo"))
+	assert.Equal(t, strHashRjenkins([]byte("ns\037foo")),
+		objectHash(hashTypeRjenkins, "ns", "foo"))
+	assert.Equal(t, strHashLinux([]byte("foo")), objectHash(hashTypeLinux, "", "foo"))
+	assert.EqualValues(t, 0, strHashLinux(nil))
+	assert.EqualValues(t, (6<<4+6)*11, strHashLinux([]byte{0x66}))
+}
+
+func TestStableMod(t *testing.T) {
+	assert.EqualValues(t, 7, pgNumMask(8))
+	assert.EqualValues(t, 15, pgNumMask(12))
+	assert.EqualValues(t, 0, pgNumMask(1))
+	assert.EqualValues(t, 6, stableMod(0x7fc1f406, 8, pgNumMask(8)))
+	// seeds beyond pg_num fall back to the lower half of the mask
+	assert.EqualValues(t, 5, stableMod(13, 12, 15))
+	assert.EqualValues(t, 11, stableMod(11, 12, 15))
+	for x := range uint32(1000) {
+		assert.Less(t, stableMod(x, 12, 15), uint32(12))
+	}
+}
+
+func TestParsePGID(t *testing.T) {
+	pg, err := ParsePGID("3.2f")
+	require.NoError(t, err)
+	assert.Equal(t, PGID{Pool: 3, Seed: 0x2f}, pg)
+	assert.Equal(t, "3.2f", pg.String())
+
+	for _, s := range []string{"", "3", "x.1", "3.zz", "3.100000000"} {
+		_, err := ParsePGID(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func (suite *RadosTestSuite) TestObjectPlacement() {
+	suite.SetupConnection()
+	ta := assert.New(suite.T())
+
+	p, err := suite.ioctx.ObjectPlacement("foo")
+	require.NoError(suite.T(), err)
+	ta.Equal(suite.pool, p.Pool)
+	ta.Equal(suite.ioctx.GetPoolID(), p.PoolID)
+	ta.Equal("foo", p.Object)
+	ta.Equal(PGID{Pool: p.PoolID, Seed: 0x7fc1f406}, p.RawPGID)
+	ta.NotEmpty(p.Up)
+	ta.NotEmpty(p.Acting)
+	ta.Contains(p.Acting, p.ActingPrimary)
+	ta.NotZero(p.Epoch)
+
+	// a locator key places objects like the key
+	suite.ioctx.SetLocator("foo")
+	defer suite.ioctx.SetLocator("")
+	p2, err := suite.ioctx.ObjectPlacement("bar")
+	require.NoError(suite.T(), err)
+	ta.Equal("bar", p2.Object)
+	ta.Equal(p.PGID, p2.PGID)
+	suite.ioctx.SetLocator("")
+
+	suite.ioctx.SetNamespace("ns")
+	defer suite.ioctx.SetNamespace("")
+	p3, err := suite.ioctx.ObjectPlacement("foo")
+	require.NoError(suite.T(), err)
+	ta.Equal("ns", p3.Namespace)
+	ta.EqualValues(objectHash(hashTypeRjenkins, "ns", "foo"), p3.RawPGID.Seed)
+}
+
+func (suite *RadosTestSuite) TestPlacementCache() {
+	suite.SetupConnection()
+	ta := assert.New(suite.T())
+
+	pc, err := suite.ioctx.NewPlacementCache()
+	require.NoError(suite.T(), err)
+	for i := range 20 {
+		oid := fmt.Sprintf("obj%d", i)
+		want, err := suite.ioctx.ObjectPlacement(oid)
+		require.NoError(suite.T(), err)
+		ta.Equal(want.PGID, pc.PGID(oid))
+		got, err := pc.ObjectPlacement(oid)
+		require.NoError(suite.T(), err)
+		ta.Equal(want.RawPGID, got.RawPGID)
+		ta.Equal(want.PGID, got.PGID)
+		ta.Equal(want.Acting, got.Acting)
+		ta.Equal(oid, got.Object)
+	}
+	ta.NoError(pc.Refresh())
+}
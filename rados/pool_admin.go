@@ -0,0 +1,237 @@
+//go:build ceph_preview
+
+package rados
+
+// #cgo LDFLAGS: -lrados
+// #include <stdlib.h>
+// #include <rados/librados.h>
+import "C"
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"unsafe"
+)
+
+// PoolType is the type of data protection used by a pool.
+type PoolType string
+
+const (
+	// PoolTypeReplicated pools store multiple full copies of each object.
+	PoolTypeReplicated = PoolType("replicated")
+	// PoolTypeErasure pools store objects erasure coded, as defined by an
+	// erasure code profile.
+	PoolTypeErasure = PoolType("erasure")
+)
+
+// PoolOptions are the optional settings of a pool created by
+// MakePoolWithOptions. Zero values select the cluster defaults.
+type PoolOptions struct {
+	// Type of the pool, defaults to a replicated pool.
+	Type PoolType
+	// PgNum is the number of placement groups of the pool.
+	PgNum uint32
+	// PgpNum is the number of placement groups used for placement. It
+	// defaults to PgNum.
+	PgpNum uint32
+	// CrushRule is the name of the CRUSH rule used by the pool.
+	CrushRule string
+	// ErasureCodeProfile is the name of the erasure code profile of an
+	// erasure coded pool.
+	ErasureCodeProfile string
+	// Size is the number of replicas of a replicated pool.
+	Size uint32
+	// Application, if set, is enabled on the pool once it has been created.
+	Application string
+}
+
+// monCommand marshals cmd to JSON and sends it to the monitors. If out is not
+// nil the JSON reply is unmarshaled into it.
+func (c *Conn) monCommand(cmd map[string]interface{}, out interface{}) error {
+	args, err := json.Marshal(cmd)
+	if err != nil {
+		return err
+	}
+	buf, status, err := c.MonCommand(args)
+	if err != nil {
+		if status != "" {
+			return fmt.Errorf("%w: %s", err, status)
+		}
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(buf, out)
+}
+
+// MakePoolWithCrushRule creates a new pool using the CRUSH rule with the given
+// ID.
+//
+// Implements:
+//
+//	int rados_pool_create_with_crush_rule(rados_t cluster,
+//	                                      const char *pool_name,
+//	                                      uint8_t crush_rule_num);
+func (c *Conn) MakePoolWithCrushRule(name string, ruleID uint8) error {
+	if err := c.ensureConnected(); err != nil {
+		return err
+	}
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+	ret := C.rados_pool_create_with_crush_rule(
+		c.cluster, cName, C.uint8_t(ruleID))
+	return getError(ret)
+}
+
+// MakePoolWithOptions creates a new pool with the given options. If opts is
+// nil the pool is created with default settings.
+//
+// Similar To:
+//
+//	ceph osd pool create <name> [<pg_num> [<pgp_num>]] [replicated|erasure]
+//	  [<erasure_code_profile>] [<rule>] [--size <size>]
+func (c *Conn) MakePoolWithOptions(name string, opts *PoolOptions) error {
+	if err := c.ensureConnected(); err != nil {
+		return err
+	}
+	if opts == nil {
+		opts = &PoolOptions{}
+	}
+	cmd := map[string]interface{}{
+		"prefix": "osd pool create",
+		"pool":   name,
+		"format": "json",
+	}
+	if opts.Type != "" {
+		cmd["pool_type"] = string(opts.Type)
+	}
+	if opts.PgNum != 0 {
+		cmd["pg_num"] = opts.PgNum
+	}
+	if opts.PgpNum != 0 {
+		cmd["pgp_num"] = opts.PgpNum
+	}
+	if opts.CrushRule != "" {
+		cmd["rule"] = opts.CrushRule
+	}
+	if opts.ErasureCodeProfile != "" {
+		cmd["erasure_code_profile"] = opts.ErasureCodeProfile
+	}
+	if opts.Size != 0 {
+		cmd["size"] = opts.Size
+	}
+	if err := c.monCommand(cmd, nil); err != nil {
+		return err
+	}
+	if opts.Application == "" {
+		return nil
+	}
+
+	ioctx, err := c.OpenIOContext(name)
+	if err != nil {
+		return err
+	}
+	defer ioctx.Destroy()
+	return ioctx.EnableApplication(opts.Application, false)
+}
+
+// SetPoolSize sets the number of replicas of a replicated pool.
+//
+// Similar To:
+//
+//	ceph osd pool set <pool> size <size>
+func (c *Conn) SetPoolSize(pool string, size uint32) error {
+	return c.setPoolVar(pool, "size", strconv.FormatUint(uint64(size), 10))
+}
+
+// SetPoolCrushRule sets the CRUSH rule used by a pool.
+//
+// Similar To:
+//
+//	ceph osd pool set <pool> crush_rule <rule>
+func (c *Conn) SetPoolCrushRule(pool, rule string) error {
+	return c.setPoolVar(pool, "crush_rule", rule)
+}
+
+func (c *Conn) setPoolVar(pool, name, value string) error {
+	if err := c.ensureConnected(); err != nil {
+		return err
+	}
+	return c.monCommand(map[string]interface{}{
+		"prefix": "osd pool set",
+		"pool":   pool,
+		"var":    name,
+		"val":    value,
+		"format": "json",
+	}, nil)
+}
+
+// PoolQuota contains the quotas of a pool. A zero value means the quota is
+// not set.
+type PoolQuota struct {
+	MaxObjects uint64 `json:"quota_max_objects"`
+	MaxBytes   uint64 `json:"quota_max_bytes"`
+}
+
+// GetPoolQuota returns the quotas of a pool.
+//
+// Similar To:
+//
+//	ceph osd pool get-quota <pool>
+func (c *Conn) GetPoolQuota(pool string) (PoolQuota, error) {
+	var q PoolQuota
+	if err := c.ensureConnected(); err != nil {
+		return q, err
+	}
+	err := c.monCommand(map[string]interface{}{
+		"prefix": "osd pool get-quota",
+		"pool":   pool,
+		"format": "json",
+	}, &q)
+	return q, err
+}
+
+// PoolQuotaUpdate selects the quotas of a pool changed by SetPoolQuota. A
+// nil field leaves the quota unchanged, a zero value removes the quota.
+type PoolQuotaUpdate struct {
+	MaxObjects *uint64
+	MaxBytes   *uint64
+}
+
+// SetPoolQuota changes the quotas of a pool. Only the quotas set in the
+// update are changed, like with the ceph command.
+//
+// Similar To:
+//
+//	ceph osd pool set-quota <pool> max_objects <max_objects>
+//	ceph osd pool set-quota <pool> max_bytes <max_bytes>
+func (c *Conn) SetPoolQuota(pool string, update PoolQuotaUpdate) error {
+	if err := c.ensureConnected(); err != nil {
+		return err
+	}
+	fields := []struct {
+		name  string
+		value *uint64
+	}{
+		{"max_objects", update.MaxObjects},
+		{"max_bytes", update.MaxBytes},
+	}
+	for _, f := range fields {
+		if f.value == nil {
+			continue
+		}
+		err := c.monCommand(map[string]interface{}{
+			"prefix": "osd pool set-quota",
+			"pool":   pool,
+			"field":  f.name,
+			"val":    strconv.FormatUint(*f.value, 10),
+			"format": "json",
+		}, nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+//go:build ceph_preview
+
+package rados
+
+import (
+	"github.com/gofrs/uuid/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (suite *RadosTestSuite) TestMakePoolWithOptions() {
+	suite.SetupConnection()
+	ta := assert.New(suite.T())
+
+	pool := uuid.Must(uuid.NewV4()).String()
+	err := suite.conn.MakePoolWithOptions(pool, &PoolOptions{
+		PgNum:       8,
+		CrushRule:   "replicated_rule",
+		Application: ApplicationRBD,
+	})
+	require.NoError(suite.T(), err)
+	defer func() {
+		ta.NoError(suite.conn.DeletePool(pool))
+	}()
+
+	ioctx, err := suite.conn.OpenIOContext(pool)
+	require.NoError(suite.T(), err)
+	defer ioctx.Destroy()
+	apps, err := ioctx.ListApplications()
+	ta.NoError(err)
+	ta.Equal([]string{ApplicationRBD}, apps)
+
+	ta.NoError(suite.conn.SetPoolCrushRule(pool, "replicated_rule"))
+
+	// creating an existing pool is not an error for ceph
+	ta.NoError(suite.conn.MakePoolWithOptions(pool, nil))
+	// an unknown rule is
+	err = suite.conn.MakePoolWithOptions(
+		uuid.Must(uuid.NewV4()).String(), &PoolOptions{CrushRule: "nope"})
+	ta.Error(err)
+}
+
+func (suite *RadosTestSuite) TestPoolQuota() {
+	suite.SetupConnection()
+	ta := assert.New(suite.T())
+
+	pool := uuid.Must(uuid.NewV4()).String()
+	require.NoError(suite.T(), suite.conn.MakePool(pool))
+	defer func() {
+		ta.NoError(suite.conn.DeletePool(pool))
+	}()
+
+	q, err := suite.conn.GetPoolQuota(pool)
+	ta.NoError(err)
+	ta.Equal(PoolQuota{}, q)
+
+	maxObjects, maxBytes, zero := uint64(1000), uint64(1<<30), uint64(0)
+	ta.NoError(suite.conn.SetPoolQuota(pool, PoolQuotaUpdate{
+		MaxObjects: &maxObjects,
+		MaxBytes:   &maxBytes,
+	}))
+	q, err = suite.conn.GetPoolQuota(pool)
+	ta.NoError(err)
+	ta.Equal(PoolQuota{MaxObjects: 1000, MaxBytes: 1 << 30}, q)
+
+	// quotas that are not part of the update are left alone
+	ta.NoError(suite.conn.SetPoolQuota(pool, PoolQuotaUpdate{MaxObjects: &zero}))
+	q, err = suite.conn.GetPoolQuota(pool)
+	ta.NoError(err)
+	ta.Equal(PoolQuota{MaxBytes: 1 << 30}, q)
+
+	ta.NoError(suite.conn.SetPoolQuota(pool, PoolQuotaUpdate{}))
+	q, err = suite.conn.GetPoolQuota(pool)
+	ta.NoError(err)
+	ta.Equal(PoolQuota{MaxBytes: 1 << 30}, q)
+
+	ta.NoError(suite.conn.SetPoolQuota(pool, PoolQuotaUpdate{MaxBytes: &zero}))
+	q, err = suite.conn.GetPoolQuota(pool)
+	ta.NoError(err)
+	ta.Equal(PoolQuota{}, q)
+
+	_, err = suite.conn.GetPoolQuota("no-such-pool")
+	ta.Error(err)
+}
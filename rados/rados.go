@@ -62,6 +62,9 @@ func Version() (int, int, int) {
 	return int(cMajor), int(cMinor), int(cPatch)
 }
 
+// shutdownHooks are called with a connection before it is shut down.
+var shutdownHooks []func(*Conn)
+
 func makeConn() *Conn {
 	return &Conn{connected: false}
 }
@@ -125,6 +128,9 @@ func NewConnWithClusterAndUser(clusterName string, userName string) (*Conn, erro
 func freeConn(conn *Conn) {
 	if conn.cluster != nil {
 		log.Warnf("unreachable Conn object has not been shut down. Cleaning up.")
+		for _, hook := range shutdownHooks {
+			hook(conn)
+		}
 		C.rados_shutdown(conn.cluster)
 		// prevent calling rados_shutdown() more than once
 		conn.cluster = nil
@@ -18,6 +18,7 @@ import (
 //
 //	void rados_ioctx_locator_set_key(rados_ioctx_t io, const char *key);
 func (ioctx *IOContext) SetLocator(locator string) {
+	ioctx.locator = locator
 	if locator == "" {
 		C.rados_ioctx_locator_set_key(ioctx.ioctx, nil)
 	} else {
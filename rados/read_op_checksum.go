@@ -0,0 +1,95 @@
+//go:build ceph_preview
+
+package rados
+
+// #cgo LDFLAGS: -lrados
+// #include <rados/librados.h>
+// #include <stdlib.h>
+//
+import "C"
+
+import (
+	"unsafe"
+)
+
+// ReadOpChecksumStep holds the result of the Checksum read operation.
+// Result is valid only after Operate() was called.
+type ReadOpChecksumStep struct {
+	// C returned data:
+	cChecksum *C.char
+	prval     *C.int
+
+	dst []byte
+}
+
+func newReadOpChecksumStep(dst []byte) *ReadOpChecksumStep {
+	s := &ReadOpChecksumStep{
+		cChecksum: (*C.char)(C.malloc(C.size_t(len(dst)))),
+		prval:     (*C.int)(C.malloc(C.sizeof_int)),
+		dst:       dst,
+	}
+	*s.prval = 0
+	return s
+}
+
+func (s *ReadOpChecksumStep) free() {
+	C.free(unsafe.Pointer(s.cChecksum))
+	s.cChecksum = nil
+	C.free(unsafe.Pointer(s.prval))
+	s.prval = nil
+}
+
+func (s *ReadOpChecksumStep) update() error {
+	if err := getError(*s.prval); err != nil {
+		return err
+	}
+	copy(s.dst, unsafe.Slice((*byte)(unsafe.Pointer(s.cChecksum)), len(s.dst)))
+	return nil
+}
+
+// Checksum calculates the checksum of the object data like
+// IOContext.Checksum. The checksum is written into dst once the operation has
+// been performed successfully.
+//
+// Implements:
+//
+//	void rados_read_op_checksum(rados_read_op_t read_op,
+//	                            rados_checksum_type_t type,
+//	                            const char *init_value,
+//	                            size_t init_value_len,
+//	                            uint64_t offset,
+//	                            size_t len,
+//	                            size_t chunk_size,
+//	                            char *pchecksum,
+//	                            size_t checksum_len,
+//	                            int *prval);
+func (r *ReadOp) Checksum(checksumType ChecksumType, dst []byte, opts *ChecksumOptions) *ReadOpChecksumStep {
+	if opts == nil {
+		opts = &ChecksumOptions{}
+	}
+	initValue := opts.InitValue
+	if initValue == nil {
+		initLen := 4
+		if checksumType == ChecksumTypeXXHash64 {
+			initLen = 8
+		}
+		initValue = make([]byte, initLen)
+	}
+
+	s := newReadOpChecksumStep(dst)
+	r.steps = append(r.steps, s)
+
+	// the initial value is copied by librados
+	C.rados_read_op_checksum(
+		r.op,
+		C.rados_checksum_type_t(checksumType),
+		bytesPtr(initValue),
+		C.size_t(len(initValue)),
+		C.uint64_t(opts.Off),
+		C.size_t(opts.Len),
+		C.size_t(opts.ChunkSize),
+		s.cChecksum,
+		C.size_t(len(dst)),
+		s.prval)
+	return s
+}
@@ -0,0 +1,82 @@
+//go:build ceph_preview
+
+package rados
+
+// #cgo LDFLAGS: -lrados
+// #include <rados/librados.h>
+// #include <stdlib.h>
+//
+import "C"
+
+import (
+	"unsafe"
+)
+
+// ReadOpGetXattrsStep holds the result of the GetXattrs read operation.
+// Result is valid only after Operate() was called.
+type ReadOpGetXattrsStep struct {
+	// C returned data:
+	iter  C.rados_xattrs_iter_t
+	prval *C.int
+
+	// Xattrs maps the names of the xattrs of the object to their values.
+	Xattrs map[string][]byte
+}
+
+func newReadOpGetXattrsStep() *ReadOpGetXattrsStep {
+	s := &ReadOpGetXattrsStep{
+		prval: (*C.int)(C.malloc(C.sizeof_int)),
+	}
+	*s.prval = 0
+	return s
+}
+
+func (s *ReadOpGetXattrsStep) update() error {
+	if err := getError(*s.prval); err != nil {
+		return err
+	}
+	if s.iter == nil {
+		// the operation failed before reaching this step
+		return nil
+	}
+	xattrs := make(map[string][]byte)
+	for {
+		var (
+			cName, cVal *C.char
+			cLen        C.size_t
+		)
+		ret := C.rados_getxattrs_next(s.iter, &cName, &cVal, &cLen)
+		if ret < 0 {
+			return getError(ret)
+		}
+		if cName == nil {
+			break
+		}
+		xattrs[C.GoString(cName)] = C.GoBytes(unsafe.Pointer(cVal), C.int(cLen))
+	}
+	s.Xattrs = xattrs
+	return nil
+}
+
+func (s *ReadOpGetXattrsStep) free() {
+	if s.iter != nil {
+		C.rados_getxattrs_end(s.iter)
+	}
+	s.iter = nil
+	C.free(unsafe.Pointer(s.prval))
+	s.prval = nil
+}
+
+// GetXattrs retrieves all xattrs of the object.
+//
+// Implements:
+//
+//	void rados_read_op_getxattrs(rados_read_op_t read_op,
+//	                             rados_xattrs_iter_t *iter,
+//	                             int *prval);
+func (r *ReadOp) GetXattrs() *ReadOpGetXattrsStep {
+	s := newReadOpGetXattrsStep()
+	r.steps = append(r.steps, s)
+	C.rados_read_op_getxattrs(r.op, &s.iter, s.prval)
+	return s
+}
@@ -0,0 +1,104 @@
+//go:build ceph_preview
+
+package rados
+
+// #cgo LDFLAGS: -lrados
+// #include <rados/librados.h>
+// #include <stdlib.h>
+//
+import "C"
+
+import (
+	"unsafe"
+)
+
+// ReadOpOmapGetKeysStep holds the result of the GetOmapKeys read operation.
+// Result is valid only after Operate() was called.
+type ReadOpOmapGetKeysStep struct {
+	// C returned data:
+	iter  C.rados_omap_iter_t
+	more  *C.uchar
+	prval *C.int
+
+	// Keys are the omap keys retrieved from the object.
+	Keys []string
+	// More is true if there are more keys after the last retrieved key.
+	More bool
+}
+
+func newReadOpOmapGetKeysStep() *ReadOpOmapGetKeysStep {
+	s := &ReadOpOmapGetKeysStep{
+		more:  (*C.uchar)(C.malloc(C.sizeof_uchar)),
+		prval: (*C.int)(C.malloc(C.sizeof_int)),
+	}
+	*s.more = 0
+	*s.prval = 0
+	return s
+}
+
+func (s *ReadOpOmapGetKeysStep) update() error {
+	if err := getError(*s.prval); err != nil {
+		return err
+	}
+	if s.iter == nil {
+		// the operation failed before reaching this step
+		return nil
+	}
+	keys := []string{}
+	for {
+		var (
+			cKey, cVal       *C.char
+			cKeyLen, cValLen C.size_t
+		)
+		ret := C.rados_omap_get_next2(s.iter, &cKey, &cVal, &cKeyLen, &cValLen)
+		if ret != 0 {
+			return getError(ret)
+		}
+		if cKey == nil {
+			break
+		}
+		keys = append(keys, string(C.GoBytes(unsafe.Pointer(cKey), C.int(cKeyLen))))
+	}
+	s.Keys = keys
+	s.More = *s.more != 0
+	return nil
+}
+
+func (s *ReadOpOmapGetKeysStep) free() {
+	if s.iter != nil {
+		C.rados_omap_get_end(s.iter)
+	}
+	s.iter = nil
+	C.free(unsafe.Pointer(s.more))
+	s.more = nil
+	C.free(unsafe.Pointer(s.prval))
+	s.prval = nil
+}
+
+// GetOmapKeys retrieves up to maxReturn omap keys of the object that sort
+// after startAfter.
+//
+// Implements:
+//
+//	void rados_read_op_omap_get_keys2(rados_read_op_t read_op,
+//	                                  const char *start_after,
+//	                                  uint64_t max_return,
+//	                                  rados_omap_iter_t *iter,
+//	                                  unsigned char *pmore,
+//	                                  int *prval);
+func (r *ReadOp) GetOmapKeys(startAfter string, maxReturn uint64) *ReadOpOmapGetKeysStep {
+	s := newReadOpOmapGetKeysStep()
+	r.steps = append(r.steps, s)
+
+	cStartAfter := C.CString(startAfter)
+	defer C.free(unsafe.Pointer(cStartAfter))
+
+	C.rados_read_op_omap_get_keys2(
+		r.op,
+		cStartAfter,
+		C.uint64_t(maxReturn),
+		&s.iter,
+		s.more,
+		s.prval)
+	return s
+}
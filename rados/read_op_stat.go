@@ -0,0 +1,75 @@
+//go:build ceph_preview
+
+package rados
+
+// #cgo LDFLAGS: -lrados
+// #include <rados/librados.h>
+// #include <stdlib.h>
+//
+import "C"
+
+import (
+	"time"
+	"unsafe"
+)
+
+// ReadOpStatStep holds the result of the Stat read operation.
+// Result is valid only after Operate() was called.
+type ReadOpStatStep struct {
+	// C returned data:
+	size  *C.uint64_t
+	mtime *C.time_t
+	prval *C.int
+
+	// Size of the object in bytes.
+	Size uint64
+	// ModTime is the last modification time of the object.
+	ModTime time.Time
+}
+
+func newReadOpStatStep() *ReadOpStatStep {
+	s := &ReadOpStatStep{
+		size:  (*C.uint64_t)(C.malloc(C.sizeof_uint64_t)),
+		mtime: (*C.time_t)(C.malloc(C.sizeof_time_t)),
+		prval: (*C.int)(C.malloc(C.sizeof_int)),
+	}
+	*s.prval = 0
+	return s
+}
+
+func (s *ReadOpStatStep) update() error {
+	if err := getError(*s.prval); err != nil {
+		return err
+	}
+	s.Size = uint64(*s.size)
+	s.ModTime = time.Unix(int64(*s.mtime), 0)
+	return nil
+}
+
+func (s *ReadOpStatStep) free() {
+	C.free(unsafe.Pointer(s.size))
+	C.free(unsafe.Pointer(s.mtime))
+	C.free(unsafe.Pointer(s.prval))
+	s.size = nil
+	s.mtime = nil
+	s.prval = nil
+}
+
+// Stat retrieves the size of the object and its last modification time.
+//
+// Implements:
+//
+//	void rados_read_op_stat(rados_read_op_t read_op,
+//	                        uint64_t *psize,
+//	                        time_t *pmtime,
+//	                        int *prval);
+func (r *ReadOp) Stat() *ReadOpStatStep {
+	s := newReadOpStatStep()
+	r.steps = append(r.steps, s)
+	C.rados_read_op_stat(
+		r.op,
+		s.size,
+		s.mtime,
+		s.prval)
+	return s
+}
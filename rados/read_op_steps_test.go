@@ -0,0 +1,99 @@
+//go:build ceph_preview
+
+package rados
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (suite *RadosTestSuite) TestReadOpStat() {
+	suite.SetupConnection()
+	ta := assert.New(suite.T())
+
+	oid := suite.GenObjectName()
+	before := time.Now().Add(-time.Minute)
+	require.NoError(suite.T(), suite.ioctx.WriteFull(oid, []byte("0123456789")))
+
+	op := CreateReadOp()
+	defer op.Release()
+	s := op.Stat()
+	ta.NoError(op.Operate(suite.ioctx, oid, OperationNoFlag))
+	ta.EqualValues(10, s.Size)
+	ta.True(s.ModTime.After(before))
+
+	op2 := CreateReadOp()
+	defer op2.Release()
+	op2.Stat()
+	err := op2.Operate(suite.ioctx, oid+"-missing", OperationNoFlag)
+	ta.ErrorIs(err, ErrNotFound)
+}
+
+func (suite *RadosTestSuite) TestReadOpChecksum() {
+	suite.SetupConnection()
+	ta := assert.New(suite.T())
+
+	oid := suite.GenObjectName()
+	require.NoError(suite.T(), suite.ioctx.WriteFull(oid, []byte("checksum me")))
+
+	expected := make([]byte, 8)
+	require.NoError(suite.T(),
+		suite.ioctx.Checksum(oid, ChecksumTypeCRC32C, expected, nil))
+
+	op := CreateReadOp()
+	defer op.Release()
+	dst := make([]byte, 8)
+	op.Checksum(ChecksumTypeCRC32C, dst, nil)
+	ta.NoError(op.Operate(suite.ioctx, oid, OperationNoFlag))
+	ta.Equal(expected, dst)
+}
+
+func (suite *RadosTestSuite) TestReadOpGetXattrs() {
+	suite.SetupConnection()
+	ta := assert.New(suite.T())
+
+	oid := suite.GenObjectName()
+	wop := CreateWriteOp()
+	defer wop.Release()
+	wop.Create(CreateIdempotent)
+	wop.SetXattr("a", []byte("1"))
+	wop.SetXattr("b", []byte("2"))
+	require.NoError(suite.T(), wop.Operate(suite.ioctx, oid, OperationNoFlag))
+
+	op := CreateReadOp()
+	defer op.Release()
+	s := op.GetXattrs()
+	ta.NoError(op.Operate(suite.ioctx, oid, OperationNoFlag))
+	ta.Equal(map[string][]byte{"a": []byte("1"), "b": []byte("2")}, s.Xattrs)
+}
+
+func (suite *RadosTestSuite) TestReadOpGetOmapKeys() {
+	suite.SetupConnection()
+	ta := assert.New(suite.T())
+
+	oid := suite.GenObjectName()
+	wop := CreateWriteOp()
+	defer wop.Release()
+	wop.SetOmap(map[string][]byte{
+		"a": []byte("1"),
+		"b": []byte("2"),
+		"c": []byte("3"),
+	})
+	require.NoError(suite.T(), wop.Operate(suite.ioctx, oid, OperationNoFlag))
+
+	op := CreateReadOp()
+	defer op.Release()
+	s := op.GetOmapKeys("", 2)
+	ta.NoError(op.Operate(suite.ioctx, oid, OperationNoFlag))
+	ta.Equal([]string{"a", "b"}, s.Keys)
+	ta.True(s.More)
+
+	op2 := CreateReadOp()
+	defer op2.Release()
+	s2 := op2.GetOmapKeys("b", 10)
+	ta.NoError(op2.Operate(suite.ioctx, oid, OperationNoFlag))
+	ta.Equal([]string{"c"}, s2.Keys)
+	ta.False(s2.More)
+}
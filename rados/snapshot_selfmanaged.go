@@ -0,0 +1,100 @@
+//go:build ceph_preview
+
+package rados
+
+// #cgo LDFLAGS: -lrados
+// #include <stdlib.h>
+// #include <rados/librados.h>
+import "C"
+
+import (
+	"unsafe"
+)
+
+// SelfManagedSnapCreate allocates a new self-managed snapshot ID in the pool.
+// Self-managed snapshots are tracked by the application, which is expected to
+// pass the snapshot IDs to SetWriteSnapContext, rather than by the pool. A pool
+// can use either pool snapshots or self-managed snapshots but not both.
+//
+// Implements:
+//
+//	int rados_ioctx_selfmanaged_snap_create(rados_ioctx_t io,
+//	                                        rados_snap_t *snapid);
+func (ioctx *IOContext) SelfManagedSnapCreate() (SnapID, error) {
+	if err := ioctx.validate(); err != nil {
+		return 0, err
+	}
+
+	var snapID C.rados_snap_t
+	ret := C.rados_ioctx_selfmanaged_snap_create(ioctx.ioctx, &snapID)
+	return SnapID(snapID), getError(ret)
+}
+
+// SelfManagedSnapRemove releases a self-managed snapshot ID. Clones that only
+// belong to the snapshot are removed by the OSDs in the background.
+//
+// Implements:
+//
+//	int rados_ioctx_selfmanaged_snap_remove(rados_ioctx_t io,
+//	                                        rados_snap_t snapid);
+func (ioctx *IOContext) SelfManagedSnapRemove(snapID SnapID) error {
+	if err := ioctx.validate(); err != nil {
+		return err
+	}
+
+	ret := C.rados_ioctx_selfmanaged_snap_remove(
+		ioctx.ioctx, C.rados_snap_t(snapID))
+	return getError(ret)
+}
+
+// SelfManagedSnapRollback rolls back the object with key oid to the
+// self-managed snapshot. The contents of the object will be the same as when
+// the snapshot was taken.
+//
+// Implements:
+//
+//	int rados_ioctx_selfmanaged_snap_rollback(rados_ioctx_t io,
+//	                                          const char *oid,
+//	                                          rados_snap_t snapid);
+func (ioctx *IOContext) SelfManagedSnapRollback(oid string, snapID SnapID) error {
+	if err := ioctx.validate(); err != nil {
+		return err
+	}
+
+	cOid := C.CString(oid)
+	defer C.free(unsafe.Pointer(cOid))
+
+	ret := C.rados_ioctx_selfmanaged_snap_rollback(
+		ioctx.ioctx, cOid, C.rados_snap_t(snapID))
+	return getError(ret)
+}
+
+// SetWriteSnapContext sets the snapshot context used for writes through the
+// IOContext. The seq is the newest snapshot ID known to the application and
+// snaps are all of the existing self-managed snapshot IDs, sorted in
+// descending order. Writes to an object that has not been cloned since the
+// newest snapshot in snaps first preserve the current object contents in a
+// clone. Passing a seq of zero and no snaps resets the write snapshot context.
+//
+// Implements:
+//
+//	int rados_ioctx_selfmanaged_snap_set_write_ctx(rados_ioctx_t io,
+//	                                               rados_snap_t seq,
+//	                                               rados_snap_t *snaps,
+//	                                               int num_snaps);
+func (ioctx *IOContext) SetWriteSnapContext(seq SnapID, snaps []SnapID) error {
+	if err := ioctx.validate(); err != nil {
+		return err
+	}
+
+	var cSnaps *C.rados_snap_t
+	if len(snaps) > 0 {
+		cSnaps = (*C.rados_snap_t)(unsafe.Pointer(&snaps[0]))
+	}
+	ret := C.rados_ioctx_selfmanaged_snap_set_write_ctx(
+		ioctx.ioctx,
+		C.rados_snap_t(seq),
+		cSnaps,
+		C.int(len(snaps)))
+	return getError(ret)
+}
@@ -0,0 +1,75 @@
+//go:build ceph_preview
+
+package rados
+
+import (
+	"testing"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (suite *RadosTestSuite) TestSelfManagedSnapshots() {
+	suite.SetupConnection()
+
+	// self-managed snapshots can not be used in a pool that has pool
+	// snapshots, so use a dedicated pool
+	pool := uuid.Must(uuid.NewV4()).String()
+	require.NoError(suite.T(), suite.conn.MakePool(pool))
+	defer func() {
+		assert.NoError(suite.T(), suite.conn.DeletePool(pool))
+	}()
+	ioctx, err := suite.conn.OpenIOContext(pool)
+	require.NoError(suite.T(), err)
+	defer ioctx.Destroy()
+
+	suite.T().Run("invalidIOContext", func(t *testing.T) {
+		ioctx := &IOContext{}
+		_, err := ioctx.SelfManagedSnapCreate()
+		assert.Equal(t, ErrInvalidIOContext, err)
+		err = ioctx.SelfManagedSnapRemove(1)
+		assert.Equal(t, ErrInvalidIOContext, err)
+		err = ioctx.SelfManagedSnapRollback("obj", 1)
+		assert.Equal(t, ErrInvalidIOContext, err)
+		err = ioctx.SetWriteSnapContext(0, nil)
+		assert.Equal(t, ErrInvalidIOContext, err)
+	})
+
+	suite.T().Run("snapshotAndRollback", func(t *testing.T) {
+		oid := "obj"
+		v1 := []byte("The Philosopher's Stone")
+		v2 := []byte("The Chamber of Secrets")
+		require.NoError(t, ioctx.WriteFull(oid, v1))
+
+		snap1, err := ioctx.SelfManagedSnapCreate()
+		require.NoError(t, err)
+		defer func() {
+			assert.NoError(t, ioctx.SelfManagedSnapRemove(snap1))
+		}()
+		require.NoError(t, ioctx.SetWriteSnapContext(snap1, []SnapID{snap1}))
+		defer func() {
+			assert.NoError(t, ioctx.SetWriteSnapContext(0, nil))
+		}()
+
+		// the write clones the object before modifying it
+		require.NoError(t, ioctx.WriteFull(oid, v2))
+
+		require.NoError(t, ioctx.SetReadSnap(snap1))
+		buf := make([]byte, 64)
+		n, err := ioctx.Read(oid, buf, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, v1, buf[:n])
+		require.NoError(t, ioctx.SetReadSnap(SnapHead))
+
+		n, err = ioctx.Read(oid, buf, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, v2, buf[:n])
+
+		require.NoError(t, ioctx.SelfManagedSnapRollback(oid, snap1))
+		n, err = ioctx.Read(oid, buf, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, v1, buf[:n])
+	})
+
+}
@@ -0,0 +1,192 @@
+//go:build ceph_preview
+
+package striper
+
+// #cgo LDFLAGS: -lrados -lradosstriper
+// #include <stdlib.h>
+// #include <radosstriper/libradosstriper.h>
+import "C"
+
+import (
+	"unsafe"
+)
+
+// AioWrite asynchronously writes len(data) bytes to the striped object
+// starting at byte offset offset. The data buffer must not be modified until
+// the returned Completion is done.
+//
+// Implements:
+//
+//	int rados_striper_aio_write(rados_striper_t striper,
+//	                            const char *soid,
+//	                            rados_completion_t completion,
+//	                            const char *buf,
+//	                            size_t len,
+//	                            uint64_t off);
+func (s *Striper) AioWrite(soid string, data []byte, offset uint64) (*Completion, error) {
+	c, err := newCompletion()
+	if err != nil {
+		return nil, err
+	}
+	csoid := C.CString(soid)
+	defer C.free(unsafe.Pointer(csoid))
+
+	ret := C.rados_striper_aio_write(
+		s.striper,
+		csoid,
+		c.comp,
+		c.pin(data),
+		C.size_t(len(data)),
+		C.uint64_t(offset))
+	if err := c.submitted(ret); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// AioWriteFull asynchronously writes len(data) bytes to the striped object,
+// replacing any existing content. The data buffer must not be modified until
+// the returned Completion is done.
+//
+// Implements:
+//
+//	int rados_striper_aio_write_full(rados_striper_t striper,
+//	                                 const char *soid,
+//	                                 rados_completion_t completion,
+//	                                 const char *buf,
+//	                                 size_t len);
+func (s *Striper) AioWriteFull(soid string, data []byte) (*Completion, error) {
+	c, err := newCompletion()
+	if err != nil {
+		return nil, err
+	}
+	csoid := C.CString(soid)
+	defer C.free(unsafe.Pointer(csoid))
+
+	ret := C.rados_striper_aio_write_full(
+		s.striper,
+		csoid,
+		c.comp,
+		c.pin(data),
+		C.size_t(len(data)))
+	if err := c.submitted(ret); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// AioAppend asynchronously appends len(data) bytes to the striped object.
+// The data buffer must not be modified until the returned Completion is done.
+//
+// Implements:
+//
+//	int rados_striper_aio_append(rados_striper_t striper,
+//	                             const char *soid,
+//	                             rados_completion_t completion,
+//	                             const char *buf,
+//	                             size_t len);
+func (s *Striper) AioAppend(soid string, data []byte) (*Completion, error) {
+	c, err := newCompletion()
+	if err != nil {
+		return nil, err
+	}
+	csoid := C.CString(soid)
+	defer C.free(unsafe.Pointer(csoid))
+
+	ret := C.rados_striper_aio_append(
+		s.striper,
+		csoid,
+		c.comp,
+		c.pin(data),
+		C.size_t(len(data)))
+	if err := c.submitted(ret); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// AioRead asynchronously reads up to len(data) bytes from the striped object
+// starting at byte offset offset. The number of bytes read is returned by the
+// Wait method of the returned Completion. The contents of data are undefined
+// until the Completion is done.
+//
+// Implements:
+//
+//	int rados_striper_aio_read(rados_striper_t striper,
+//	                           const char *soid,
+//	                           rados_completion_t completion,
+//	                           char *buf,
+//	                           const size_t len,
+//	                           uint64_t off);
+func (s *Striper) AioRead(soid string, data []byte, offset uint64) (*Completion, error) {
+	c, err := newCompletion()
+	if err != nil {
+		return nil, err
+	}
+	csoid := C.CString(soid)
+	defer C.free(unsafe.Pointer(csoid))
+
+	ret := C.rados_striper_aio_read(
+		s.striper,
+		csoid,
+		c.comp,
+		c.pin(data),
+		C.size_t(len(data)),
+		C.uint64_t(offset))
+	if err := c.submitted(ret); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// AioRemove asynchronously removes the striped object and all of the RADOS
+// objects it consists of.
+//
+// Implements:
+//
+//	int rados_striper_aio_remove(rados_striper_t striper,
+//	                             const char* soid,
+//	                             rados_completion_t completion);
+func (s *Striper) AioRemove(soid string) (*Completion, error) {
+	c, err := newCompletion()
+	if err != nil {
+		return nil, err
+	}
+	csoid := C.CString(soid)
+	defer C.free(unsafe.Pointer(csoid))
+
+	ret := C.rados_striper_aio_remove(s.striper, csoid, c.comp)
+	if err := c.submitted(ret); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// AioFlush blocks until all pending asynchronous writes of the Striper have
+// completed. Each asynchronous write of libradosstriper is internally split
+// into writes of the RADOS objects making up the striped object, AioFlush
+// waits for all of them.
+//
+// Implements:
+//
+//	void rados_striper_aio_flush(rados_striper_t striper);
+func (s *Striper) AioFlush() {
+	C.rados_striper_aio_flush(s.striper)
+}
+
+// StatCompletion is returned by AioStat. Once the operation is done the
+// Stat method returns the size and modification time of the striped object.
+type StatCompletion struct {
+	*Completion
+
+	stat StatInfo
+}
+
+// Stat waits for the operation to complete and returns the size and
+// modification time of the striped object.
+func (sc *StatCompletion) Stat() (StatInfo, error) {
+	if _, err := sc.Wait(); err != nil {
+		return StatInfo{}, err
+	}
+	return sc.stat, nil
+}
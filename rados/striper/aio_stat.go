@@ -0,0 +1,57 @@
+//go:build (octopus || pacific || quincy) && ceph_preview
+
+package striper
+
+// #cgo LDFLAGS: -lrados -lradosstriper
+// #include <stdlib.h>
+// #include <radosstriper/libradosstriper.h>
+import "C"
+
+import (
+	"unsafe"
+
+	"github.com/ceph/go-ceph/internal/cutil"
+)
+
+// AioStat asynchronously retrieves the size and modification time of the
+// striped object. This version of AioStat uses an older API that does not
+// provide time granularity below a second: the Nsec value of the
+// StatInfo.ModTime field will always be zero.
+//
+// Implements:
+//
+//	int rados_striper_aio_stat(rados_striper_t striper,
+//	                           const char* soid,
+//	                           rados_completion_t completion,
+//	                           uint64_t *psize,
+//	                           time_t *pmtime);
+func (s *Striper) AioStat(soid string) (*StatCompletion, error) {
+	c, err := newCompletion()
+	if err != nil {
+		return nil, err
+	}
+	sc := &StatCompletion{Completion: c}
+	cSize := (*C.uint64_t)(C.malloc(C.sizeof_uint64_t))
+	cMtime := (*C.time_t)(C.malloc(C.sizeof_time_t))
+	c.slots = append(c.slots,
+		cutil.CPtr(unsafe.Pointer(cSize)),
+		cutil.CPtr(unsafe.Pointer(cMtime)))
+	c.finish = func(ret C.int) error {
+		if ret < 0 {
+			return getError(ret)
+		}
+		sc.stat = StatInfo{
+			Size:    uint64(*cSize),
+			ModTime: Timespec{Sec: int64(*cMtime)},
+		}
+		return nil
+	}
+	csoid := C.CString(soid)
+	defer C.free(unsafe.Pointer(csoid))
+
+	ret := C.rados_striper_aio_stat(s.striper, csoid, c.comp, cSize, cMtime)
+	if err := c.submitted(ret); err != nil {
+		return nil, err
+	}
+	return sc, nil
+}
@@ -0,0 +1,76 @@
+//go:build ceph_preview
+
+package striper
+
+import (
+	"bytes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (suite *StriperTestSuite) TestAioReadWrite() {
+	ioctx := suite.defaultContext()
+	defer ioctx.Destroy()
+
+	striper, err := NewWithLayout(ioctx, Layout{65536, 4, 262144})
+	require.NoError(suite.T(), err)
+	defer striper.Destroy()
+
+	name := "TestAioReadWrite"
+	part := bytes.Repeat([]byte("0123456789abcdef"), 32768)
+	var comps []*Completion
+	for i := 0; i < 4; i++ {
+		c, err := striper.AioWrite(name, part, uint64(i*len(part)))
+		require.NoError(suite.T(), err)
+		comps = append(comps, c)
+	}
+	for _, c := range comps {
+		_, err := c.Wait()
+		assert.NoError(suite.T(), err)
+		assert.True(suite.T(), c.IsComplete())
+		c.Release()
+	}
+
+	c, err := striper.AioAppend(name, []byte("tail"))
+	require.NoError(suite.T(), err)
+	striper.AioFlush()
+	<-c.Done()
+	c.Release()
+
+	sc, err := striper.AioStat(name)
+	require.NoError(suite.T(), err)
+	st, err := sc.Stat()
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), 4*len(part)+4, st.Size)
+	assert.NotZero(suite.T(), st.ModTime.Sec)
+	sc.Release()
+
+	buf := make([]byte, len(part)+10)
+	c, err = striper.AioRead(name, buf, uint64(3*len(part)))
+	require.NoError(suite.T(), err)
+	n, err := c.Wait()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), len(part)+4, n)
+	assert.Equal(suite.T(), part, buf[:len(part)])
+	assert.Equal(suite.T(), "tail", string(buf[len(part):n]))
+	c.Release()
+
+	c, err = striper.AioWriteFull(name, []byte("short"))
+	require.NoError(suite.T(), err)
+	_, err = c.Wait()
+	assert.NoError(suite.T(), err)
+	c.Release()
+
+	c, err = striper.AioRemove(name)
+	require.NoError(suite.T(), err)
+	_, err = c.Wait()
+	assert.NoError(suite.T(), err)
+	c.Release()
+
+	sc, err = striper.AioStat(name)
+	require.NoError(suite.T(), err)
+	_, err = sc.Stat()
+	assert.Error(suite.T(), err)
+	sc.Release()
+}
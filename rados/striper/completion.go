@@ -0,0 +1,189 @@
+//go:build ceph_preview
+
+package striper
+
+/*
+#cgo LDFLAGS: -lrados -lradosstriper
+#include <stdlib.h>
+#include <radosstriper/libradosstriper.h>
+
+extern void striperCompletionCallback(rados_completion_t, uintptr_t);
+
+// inline wrapper to cast uintptr_t to void*
+static inline int wrap_rados_aio_create_completion2(uintptr_t arg,
+	rados_completion_t *pc) {
+		return rados_aio_create_completion2((void*)arg,
+			(rados_callback_t)striperCompletionCallback, pc);
+};
+*/
+import "C"
+
+import (
+	"sync"
+	"unsafe"
+
+	"github.com/ceph/go-ceph/internal/callbacks"
+	"github.com/ceph/go-ceph/internal/cutil"
+)
+
+// completions tracks the in-flight asynchronous operations.
+var completions = callbacks.New()
+
+// Completion represents an asynchronous operation on a striped object. The
+// result of the operation can be obtained by blocking on the Wait method, by
+// polling the IsComplete method or by receiving from the channel returned by
+// the Done method.
+//
+// Any Go buffers passed to the call that created the Completion are in use
+// by libradosstriper until the operation has completed and must not be
+// modified (or read, for read operations) before then.
+//
+// Once the result has been consumed the Release method must be called to free
+// the resources associated with the Completion.
+type Completion struct {
+	comp    C.rados_completion_t
+	cbIndex uintptr
+	done    chan struct{}
+
+	// finish is called, from the librados callback thread, with the return
+	// value of the operation. It may replace the error set on the
+	// completion.
+	finish func(ret C.int) error
+
+	// guards pin the Go buffers handed to libradosstriper for the lifetime
+	// of the operation. slots track C memory that is freed when the
+	// completion is released.
+	guards []*cutil.PtrGuard
+	slots  []cutil.CPtr
+
+	mutex    sync.Mutex
+	ret      C.int
+	err      error
+	released bool
+}
+
+func newCompletion() (*Completion, error) {
+	c := &Completion{
+		done: make(chan struct{}),
+	}
+	c.cbIndex = completions.Add(c)
+	ret := C.wrap_rados_aio_create_completion2(
+		C.uintptr_t(c.cbIndex), &c.comp)
+	if ret != 0 {
+		completions.Remove(c.cbIndex)
+		return nil, getError(ret)
+	}
+	return c, nil
+}
+
+// pin guards the Go buffer b for as long as libradosstriper may access it
+// and returns a pointer to the buffer that can be handed to it.
+func (c *Completion) pin(b []byte) *C.char {
+	if len(b) == 0 {
+		return nil
+	}
+	slot := cutil.Malloc(cutil.PtrSize)
+	c.slots = append(c.slots, slot)
+	c.guards = append(c.guards, cutil.NewPtrGuard(slot, unsafe.Pointer(&b[0])))
+	return *(**C.char)(unsafe.Pointer(slot))
+}
+
+func (c *Completion) unpin() {
+	for i := range c.guards {
+		c.guards[i].Release()
+	}
+	c.guards = nil
+}
+
+// submitted must be called with the return value of the libradosstriper
+// call that started the asynchronous operation. If the operation could not
+// be started the completion is released and an error is returned.
+func (c *Completion) submitted(ret C.int) error {
+	if ret == 0 {
+		return nil
+	}
+	// the callback will never fire, so finish up here
+	completions.Remove(c.cbIndex)
+	c.unpin()
+	close(c.done)
+	c.Release()
+	return getError(ret)
+}
+
+func (c *Completion) complete() {
+	ret := C.rados_aio_get_return_value(c.comp)
+	var err error
+	if ret < 0 {
+		err = getError(ret)
+	}
+	if c.finish != nil {
+		err = c.finish(ret)
+	}
+	c.unpin()
+
+	c.mutex.Lock()
+	c.ret = ret
+	c.err = err
+	c.mutex.Unlock()
+
+	completions.Remove(c.cbIndex)
+	close(c.done)
+}
+
+// Done returns a channel that is closed once the operation has completed.
+func (c *Completion) Done() <-chan struct{} {
+	return c.done
+}
+
+// Wait blocks until the operation has completed. It returns the non-negative
+// return value of the operation, for example the number of bytes read by a
+// read operation, or an error.
+func (c *Completion) Wait() (int, error) {
+	<-c.done
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return int(c.ret), nil
+}
+
+// IsComplete returns true if the operation has completed. The result of a
+// completed operation can be obtained by calling Wait without blocking.
+func (c *Completion) IsComplete() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Release frees the resources associated with the Completion. If the
+// operation is still in flight Release blocks until it has completed.
+//
+// Implements:
+//
+//	void rados_aio_release(rados_completion_t c);
+func (c *Completion) Release() {
+	<-c.done
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.released {
+		return
+	}
+	c.released = true
+	C.rados_aio_release(c.comp)
+	c.comp = nil
+	for i := range c.slots {
+		cutil.Free(c.slots[i])
+	}
+	c.slots = nil
+}
+
+//export striperCompletionCallback
+func striperCompletionCallback(_ C.rados_completion_t, index uintptr) {
+	v := completions.Lookup(index)
+	c := v.(*Completion)
+	c.complete()
+}
@@ -5,6 +5,14 @@ The Striper type supports synchronous operations to read and write data,
 as well as read and manipulate xattrs. Note that a striped object will
 consist of one or more objects in RADOS.
 
+Preview APIs add asynchronous operations returning a Completion and an Object
+type implementing the io interfaces. libradosstriper locks the first RADOS
+object of a striped object internally: reads and writes take a shared lock,
+while removing and truncating take an exclusive lock. Asynchronous writes to
+disjoint ranges of a striped object can therefore run in parallel without
+corrupting its layout. The locks are not exposed by the libradosstriper C
+API. Use AioFlush to wait for all pending writes of a Striper.
+
 There is no object list API in libradosstriper. Listing objects must be done
 using the base RADOS APIs. Striped objects will be stored in RADOS using the
 provided Striped Object ID (soid) suffixed by a dot (.) and a 16 byte
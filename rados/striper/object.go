@@ -0,0 +1,110 @@
+//go:build ceph_preview
+
+package striper
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrInvalidOffset is returned when seeking to, reading at or writing at a
+// negative offset of an Object.
+var ErrInvalidOffset = errors.New("invalid object offset")
+
+// Object provides access to the data of a striped object through the
+// interfaces of the io package. Object implements io.ReadWriteSeeker,
+// io.ReaderAt and io.WriterAt.
+//
+// ReadAt and WriteAt may be called concurrently, Read, Write and Seek must
+// not be called concurrently. libradosstriper spreads each call over the
+// RADOS objects making up the striped object, so large reads and writes are
+// performed in parallel.
+type Object struct {
+	s    *Striper
+	soid string
+	pos  int64
+}
+
+// Object returns an Object for the striped object soid.
+func (s *Striper) Object(soid string) *Object {
+	return &Object{s: s, soid: soid}
+}
+
+// ReadAt reads len(p) bytes of the striped object starting at offset off.
+// If the object ends before len(p) bytes were read, io.EOF is returned.
+func (o *Object) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, ErrInvalidOffset
+	}
+	n := 0
+	for n < len(p) {
+		m, err := o.s.Read(o.soid, p[n:], uint64(off)+uint64(n))
+		n += m
+		if err != nil {
+			return n, err
+		}
+		if m == 0 {
+			return n, io.EOF
+		}
+	}
+	return n, nil
+}
+
+// WriteAt writes len(p) bytes to the striped object starting at offset off.
+func (o *Object) WriteAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, ErrInvalidOffset
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if err := o.s.Write(o.soid, p, uint64(off)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Seek sets the offset for the next Read or Write. Seeking relative to the
+// end of the object stats the object.
+func (o *Object) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = o.pos + offset
+	case io.SeekEnd:
+		st, err := o.s.Stat(o.soid)
+		if err != nil {
+			return o.pos, err
+		}
+		pos = int64(st.Size) + offset
+	default:
+		return o.pos, ErrInvalidOffset
+	}
+	if pos < 0 {
+		return o.pos, ErrInvalidOffset
+	}
+	o.pos = pos
+	return pos, nil
+}
+
+// Read reads up to len(p) bytes from the current offset.
+func (o *Object) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	n, err := o.s.Read(o.soid, p, uint64(o.pos))
+	o.pos += int64(n)
+	if err == nil && n == 0 {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// Write writes len(p) bytes at the current offset.
+func (o *Object) Write(p []byte) (int, error) {
+	n, err := o.WriteAt(p, o.pos)
+	o.pos += int64(n)
+	return n, err
+}
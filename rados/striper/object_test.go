@@ -0,0 +1,57 @@
+//go:build ceph_preview
+
+package striper
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (suite *StriperTestSuite) TestObject() {
+	ioctx := suite.defaultContext()
+	defer ioctx.Destroy()
+
+	striper, err := NewWithLayout(ioctx, Layout{65536, 4, 262144})
+	require.NoError(suite.T(), err)
+	defer striper.Destroy()
+
+	data := bytes.Repeat([]byte("striped object data "), 50000)
+	o := striper.Object("TestObject")
+	n, err := io.Copy(o, bytes.NewReader(data))
+	require.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), len(data), n)
+
+	pos, err := o.Seek(0, io.SeekCurrent)
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), len(data), pos)
+	pos, err = o.Seek(-20, io.SeekEnd)
+	assert.NoError(suite.T(), err)
+	assert.EqualValues(suite.T(), len(data)-20, pos)
+	rest, err := io.ReadAll(o)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "striped object data ", string(rest))
+
+	_, err = o.Seek(0, io.SeekStart)
+	assert.NoError(suite.T(), err)
+	all, err := io.ReadAll(o)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), data, all)
+
+	_, err = o.WriteAt([]byte("STRIPED"), 300000)
+	assert.NoError(suite.T(), err)
+	buf := make([]byte, 7)
+	_, err = o.ReadAt(buf, 300000)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "STRIPED", string(buf))
+
+	buf = make([]byte, 100)
+	n2, err := o.ReadAt(buf, int64(len(data)-10))
+	assert.ErrorIs(suite.T(), err, io.EOF)
+	assert.Equal(suite.T(), 10, n2)
+
+	_, err = o.Seek(-1, io.SeekStart)
+	assert.ErrorIs(suite.T(), err, ErrInvalidOffset)
+}
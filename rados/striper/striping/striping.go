@@ -0,0 +1,187 @@
+//go:build ceph_preview
+
+/*
+Package striping maps byte ranges of striped data to the RADOS objects that
+store them, without calling into the Ceph libraries.
+
+Ceph stripes the data of striped objects of libradosstriper, RBD images and
+CephFS files over multiple RADOS objects in the same way. The data is split
+into stripe units that are distributed round-robin over StripeCount objects,
+an object set. Once the objects of a set hold ObjectSize bytes each, the
+next object set is used. For a Layout with a stripe unit of 1 MiB, a stripe
+count of 3 and an object size of 2 MiB the first 6 MiB are stored as:
+
+	object 0: units 0, 3
+	object 1: units 1, 4
+	object 2: units 2, 5
+
+and the following 6 MiB in objects 3 to 5.
+*/
+package striping
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ErrInvalidLayout is returned for layouts that Ceph would not accept.
+var ErrInvalidLayout = errors.New("invalid striping layout")
+
+// Layout defines how data is striped over RADOS objects.
+type Layout struct {
+	// StripeUnit is the number of consecutive bytes stored in one object.
+	StripeUnit uint64
+	// StripeCount is the number of objects the stripe units are spread over.
+	StripeCount uint64
+	// ObjectSize is the maximum size of an object. It must be a multiple of
+	// StripeUnit.
+	ObjectSize uint64
+}
+
+// Validate returns ErrInvalidLayout if a field of the layout is zero or the
+// object size is not a multiple of the stripe unit.
+func (l Layout) Validate() error {
+	switch {
+	case l.StripeUnit == 0 || l.StripeCount == 0 || l.ObjectSize == 0:
+		return fmt.Errorf("%w: zero stripe unit, stripe count or object size",
+			ErrInvalidLayout)
+	case l.ObjectSize%l.StripeUnit != 0:
+		return fmt.Errorf("%w: object size %d is not a multiple of stripe unit %d",
+			ErrInvalidLayout, l.ObjectSize, l.StripeUnit)
+	}
+	return nil
+}
+
+// Extent is a range of bytes of a single object.
+type Extent struct {
+	// ObjectNo is the index of the object.
+	ObjectNo uint64
+	// Offset is the offset of the range within the object.
+	Offset uint64
+	Length uint64
+	// LogicalOffset is the offset of the range within the striped data.
+	LogicalOffset uint64
+}
+
+// ToObject returns the object storing the byte at logical offset off and the
+// offset of the byte within that object. The layout must be valid.
+func (l Layout) ToObject(off uint64) (objectNo, objectOff uint64) {
+	stripesPerObject := l.ObjectSize / l.StripeUnit
+	blockNo := off / l.StripeUnit
+	stripeNo := blockNo / l.StripeCount
+	stripePos := blockNo % l.StripeCount
+	objectSetNo := stripeNo / stripesPerObject
+	objectNo = objectSetNo*l.StripeCount + stripePos
+	objectOff = (stripeNo%stripesPerObject)*l.StripeUnit + off%l.StripeUnit
+	return objectNo, objectOff
+}
+
+// ToLogical returns the logical offset of the byte stored at offset
+// objectOff of object objectNo. It is the inverse of ToObject. The layout
+// must be valid and objectOff must be less than ObjectSize.
+func (l Layout) ToLogical(objectNo, objectOff uint64) uint64 {
+	stripesPerObject := l.ObjectSize / l.StripeUnit
+	objectSetNo := objectNo / l.StripeCount
+	stripePos := objectNo % l.StripeCount
+	stripeNo := objectSetNo*stripesPerObject + objectOff/l.StripeUnit
+	blockNo := stripeNo*l.StripeCount + stripePos
+	return blockNo*l.StripeUnit + objectOff%l.StripeUnit
+}
+
+// MapExtent returns the object extents storing length bytes starting at
+// logical offset off, ordered by logical offset. Consecutive pieces that are
+// contiguous within the same object are merged into a single extent.
+func (l Layout) MapExtent(off, length uint64) ([]Extent, error) {
+	if err := l.Validate(); err != nil {
+		return nil, err
+	}
+	var extents []Extent
+	for length > 0 {
+		objectNo, objectOff := l.ToObject(off)
+		n := min(length, l.StripeUnit-off%l.StripeUnit)
+		if k := len(extents) - 1; k >= 0 &&
+			extents[k].ObjectNo == objectNo &&
+			extents[k].Offset+extents[k].Length == objectOff {
+			extents[k].Length += n
+		} else {
+			extents = append(extents, Extent{
+				ObjectNo:      objectNo,
+				Offset:        objectOff,
+				Length:        n,
+				LogicalOffset: off,
+			})
+		}
+		off += n
+		length -= n
+	}
+	return extents, nil
+}
+
+// Objects returns the indexes of the objects storing length bytes starting
+// at logical offset off in ascending order.
+func (l Layout) Objects(off, length uint64) ([]uint64, error) {
+	if err := l.Validate(); err != nil {
+		return nil, err
+	}
+	if length == 0 {
+		return nil, nil
+	}
+	setSize := l.ObjectSize * l.StripeCount
+	stripeSize := l.StripeUnit * l.StripeCount
+	end := off + length
+	var objects []uint64
+	for set := off / setSize; set <= (end-1)/setSize; set++ {
+		start := max(off, set*setSize)
+		stop := min(end, (set+1)*setSize)
+		if stop-start >= stripeSize {
+			// a full stripe touches every object of the set
+			for i := range l.StripeCount {
+				objects = append(objects, set*l.StripeCount+i)
+			}
+			continue
+		}
+		// less than a stripe touches at most StripeCount+1 units
+		var touched []uint64
+		for o := start; o < stop; o += l.StripeUnit - o%l.StripeUnit {
+			objectNo, _ := l.ToObject(o)
+			touched = append(touched, objectNo)
+		}
+		slices.Sort(touched)
+		objects = append(objects, slices.Compact(touched)...)
+	}
+	return objects, nil
+}
+
+// ObjectExtents returns the extents of MapExtent grouped by object. Within
+// an object the extents are ordered by their offset in the object.
+func (l Layout) ObjectExtents(off, length uint64) (map[uint64][]Extent, error) {
+	extents, err := l.MapExtent(off, length)
+	if err != nil {
+		return nil, err
+	}
+	m := map[uint64][]Extent{}
+	for _, e := range extents {
+		m[e.ObjectNo] = append(m[e.ObjectNo], e)
+	}
+	return m, nil
+}
+
+// StriperObjectName returns the name of the RADOS object with index
+// objectNo of the libradosstriper object soid.
+func StriperObjectName(soid string, objectNo uint64) string {
+	return fmt.Sprintf("%s.%016x", soid, objectNo)
+}
+
+// RBDObjectName returns the name of the RADOS object with index objectNo of
+// an RBD image with the given block name prefix, for example
+// "rbd_data.10076b8b4567".
+func RBDObjectName(blockNamePrefix string, objectNo uint64) string {
+	return fmt.Sprintf("%s.%016x", blockNamePrefix, objectNo)
+}
+
+// CephFSObjectName returns the name of the RADOS object with index objectNo
+// of the CephFS file with inode number ino.
+func CephFSObjectName(ino, objectNo uint64) string {
+	return fmt.Sprintf("%x.%08x", ino, objectNo)
+}
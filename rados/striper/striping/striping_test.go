@@ -0,0 +1,121 @@
+//go:build ceph_preview
+
+package striping
+
+import (
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Layout{1 << 20, 3, 4 << 20}.Validate())
+	assert.ErrorIs(t, Layout{0, 1, 1}.Validate(), ErrInvalidLayout)
+	assert.ErrorIs(t, Layout{1, 0, 1}.Validate(), ErrInvalidLayout)
+	assert.ErrorIs(t, Layout{1, 1, 0}.Validate(), ErrInvalidLayout)
+	assert.ErrorIs(t, Layout{3, 1, 10}.Validate(), ErrInvalidLayout)
+
+	_, err := Layout{3, 1, 10}.MapExtent(0, 1)
+	assert.ErrorIs(t, err, ErrInvalidLayout)
+	_, err = Layout{}.Objects(0, 1)
+	assert.ErrorIs(t, err, ErrInvalidLayout)
+}
+
+func TestMapExtent(t *testing.T) {
+	const mib = 1 << 20
+	l := Layout{StripeUnit: mib, StripeCount: 3, ObjectSize: 2 * mib}
+
+	exts, err := l.MapExtent(0, 7*mib)
+	require.NoError(t, err)
+	assert.Equal(t, []Extent{
+		{ObjectNo: 0, Offset: 0, Length: mib, LogicalOffset: 0},
+		{ObjectNo: 1, Offset: 0, Length: mib, LogicalOffset: mib},
+		{ObjectNo: 2, Offset: 0, Length: mib, LogicalOffset: 2 * mib},
+		{ObjectNo: 0, Offset: mib, Length: mib, LogicalOffset: 3 * mib},
+		{ObjectNo: 1, Offset: mib, Length: mib, LogicalOffset: 4 * mib},
+		{ObjectNo: 2, Offset: mib, Length: mib, LogicalOffset: 5 * mib},
+		{ObjectNo: 3, Offset: 0, Length: mib, LogicalOffset: 6 * mib},
+	}, exts)
+
+	exts, err = l.MapExtent(mib/2, mib)
+	require.NoError(t, err)
+	assert.Equal(t, []Extent{
+		{ObjectNo: 0, Offset: mib / 2, Length: mib / 2, LogicalOffset: mib / 2},
+		{ObjectNo: 1, Offset: 0, Length: mib / 2, LogicalOffset: mib},
+	}, exts)
+
+	exts, err = l.MapExtent(5, 0)
+	require.NoError(t, err)
+	assert.Empty(t, exts)
+
+	// a single stripe per set stores objects contiguously
+	l = Layout{StripeUnit: mib, StripeCount: 1, ObjectSize: 4 * mib}
+	exts, err = l.MapExtent(mib, 5*mib)
+	require.NoError(t, err)
+	assert.Equal(t, []Extent{
+		{ObjectNo: 0, Offset: mib, Length: 3 * mib, LogicalOffset: mib},
+		{ObjectNo: 1, Offset: 0, Length: 2 * mib, LogicalOffset: 4 * mib},
+	}, exts)
+
+	byObject, err := Layout{StripeUnit: mib, StripeCount: 2, ObjectSize: 2 * mib}.
+		ObjectExtents(0, 4*mib)
+	require.NoError(t, err)
+	assert.Len(t, byObject, 2)
+	assert.Equal(t, []Extent{
+		{ObjectNo: 1, Offset: 0, Length: mib, LogicalOffset: mib},
+		{ObjectNo: 1, Offset: mib, Length: mib, LogicalOffset: 3 * mib},
+	}, byObject[1])
+}
+
+func TestObjectNames(t *testing.T) {
+	assert.Equal(t, "foo.000000000000000a", StriperObjectName("foo", 10))
+	assert.Equal(t, "rbd_data.1234.0000000000000001", RBDObjectName("rbd_data.1234", 1))
+	assert.Equal(t, "10000000001.0000000f", CephFSObjectName(0x10000000001, 15))
+}
+
+// TestLayoutProperties checks the mappings against each other for random
+// layouts and ranges.
+func TestLayoutProperties(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	for range 500 {
+		su := uint64(1 + r.IntN(16))
+		l := Layout{
+			StripeUnit:  su,
+			StripeCount: uint64(1 + r.IntN(5)),
+			ObjectSize:  su * uint64(1+r.IntN(4)),
+		}
+		off := uint64(r.IntN(400))
+		length := uint64(r.IntN(400))
+
+		exts, err := l.MapExtent(off, length)
+		require.NoError(t, err)
+		var (
+			pos     = off
+			objects []uint64
+		)
+		for _, e := range exts {
+			// the extents cover the range in logical order
+			require.Equal(t, pos, e.LogicalOffset, "%+v", l)
+			require.NotZero(t, e.Length)
+			require.LessOrEqual(t, e.Offset+e.Length, l.ObjectSize)
+			for i := range e.Length {
+				objectNo, objectOff := l.ToObject(pos + i)
+				require.Equal(t, e.ObjectNo, objectNo, "%+v", l)
+				require.Equal(t, e.Offset+i, objectOff, "%+v", l)
+				require.Equal(t, pos+i, l.ToLogical(objectNo, objectOff), "%+v", l)
+			}
+			pos += e.Length
+			objects = append(objects, e.ObjectNo)
+		}
+		require.Equal(t, off+length, pos)
+
+		slices.Sort(objects)
+		objects = slices.Compact(objects)
+		got, err := l.Objects(off, length)
+		require.NoError(t, err)
+		require.Equal(t, objects, got, "%+v off=%d length=%d", l, off, length)
+	}
+}
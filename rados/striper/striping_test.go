@@ -0,0 +1,65 @@
+//go:build ceph_preview
+
+package striper
+
+import (
+	"math/rand/v2"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ceph/go-ceph/rados/striper/striping"
+)
+
+// TestStripingLayout checks that the pure Go striping calculations match
+// where libradosstriper stores the data.
+func (suite *StriperTestSuite) TestStripingLayout() {
+	ioctx := suite.defaultContext()
+	defer ioctx.Destroy()
+
+	layouts := []Layout{
+		{65536, 1, 262144},
+		{65536, 3, 131072},
+		{65536, 4, 262144},
+	}
+	r := rand.New(rand.NewPCG(3, 4))
+	for i, l := range layouts {
+		striper, err := NewWithLayout(ioctx, l)
+		require.NoError(suite.T(), err)
+		defer striper.Destroy()
+
+		soid := "TestStripingLayout" + string(rune('a'+i))
+		data := make([]byte, 1000000)
+		for j := range data {
+			data[j] = byte(r.IntN(256))
+		}
+		require.NoError(suite.T(), striper.WriteFull(soid, data))
+
+		sl := striping.Layout{
+			StripeUnit:  uint64(l.StripeUnit),
+			StripeCount: uint64(l.StripeCount),
+			ObjectSize:  uint64(l.ObjectSize),
+		}
+		off, length := uint64(12345), uint64(700000)
+		exts, err := sl.MapExtent(off, length)
+		require.NoError(suite.T(), err)
+		for _, e := range exts {
+			buf := make([]byte, e.Length)
+			n, err := ioctx.Read(striping.StriperObjectName(soid, e.ObjectNo), buf, e.Offset)
+			require.NoError(suite.T(), err)
+			require.EqualValues(suite.T(), e.Length, n)
+			assert.Equal(suite.T(),
+				data[e.LogicalOffset:e.LogicalOffset+e.Length], buf,
+				"layout %+v extent %+v", l, e)
+		}
+
+		objects, err := sl.Objects(0, uint64(len(data)))
+		require.NoError(suite.T(), err)
+		for _, objectNo := range objects {
+			_, err := ioctx.Stat(striping.StriperObjectName(soid, objectNo))
+			assert.NoError(suite.T(), err)
+		}
+		_, err = ioctx.Stat(striping.StriperObjectName(soid, objects[len(objects)-1]+1))
+		assert.Error(suite.T(), err)
+	}
+}
@@ -0,0 +1,75 @@
+//go:build ceph_preview
+
+package rados
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (suite *RadosTestSuite) TestWriteOpAppendTruncateZero() {
+	suite.SetupConnection()
+	ta := assert.New(suite.T())
+
+	oid := suite.GenObjectName()
+	op := CreateWriteOp()
+	defer op.Release()
+	op.WriteFull([]byte("hello"))
+	op.Append([]byte(" world!"))
+	op.Truncate(11)
+	op.Zero(0, 1)
+	err := op.Operate(suite.ioctx, oid, OperationNoFlag)
+	require.NoError(suite.T(), err)
+
+	buf := make([]byte, 32)
+	n, err := suite.ioctx.Read(oid, buf, 0)
+	ta.NoError(err)
+	ta.Equal([]byte("\x00ello world"), buf[:n])
+}
+
+func (suite *RadosTestSuite) TestWriteOpRmXattr() {
+	suite.SetupConnection()
+	ta := assert.New(suite.T())
+
+	oid := suite.GenObjectName()
+	op1 := CreateWriteOp()
+	defer op1.Release()
+	op1.Create(CreateIdempotent)
+	op1.SetXattr("a", []byte("1"))
+	op1.SetXattr("b", []byte("2"))
+	require.NoError(suite.T(), op1.Operate(suite.ioctx, oid, OperationNoFlag))
+
+	op2 := CreateWriteOp()
+	defer op2.Release()
+	op2.RmXattr("a")
+	require.NoError(suite.T(), op2.Operate(suite.ioctx, oid, OperationNoFlag))
+
+	xattrs, err := suite.ioctx.ListXattrs(oid)
+	ta.NoError(err)
+	ta.Equal(map[string][]byte{"b": []byte("2")}, xattrs)
+}
+
+func (suite *RadosTestSuite) TestWriteOpRmOmapRange() {
+	suite.SetupConnection()
+	ta := assert.New(suite.T())
+
+	oid := suite.GenObjectName()
+	op1 := CreateWriteOp()
+	defer op1.Release()
+	op1.SetOmap(map[string][]byte{
+		"a": []byte("1"),
+		"b": []byte("2"),
+		"c": []byte("3"),
+		"d": []byte("4"),
+	})
+	require.NoError(suite.T(), op1.Operate(suite.ioctx, oid, OperationNoFlag))
+
+	op2 := CreateWriteOp()
+	defer op2.Release()
+	op2.RmOmapRange("b", "d")
+	require.NoError(suite.T(), op2.Operate(suite.ioctx, oid, OperationNoFlag))
+
+	vals, err := suite.ioctx.GetAllOmapValues(oid, "", "", 10)
+	ta.NoError(err)
+	ta.Equal(map[string][]byte{"a": []byte("1"), "d": []byte("4")}, vals)
+}
@@ -0,0 +1,37 @@
+//go:build ceph_preview
+
+package rados
+
+// #cgo LDFLAGS: -lrados
+// #include <rados/librados.h>
+// #include <stdlib.h>
+//
+import "C"
+
+import (
+	"unsafe"
+)
+
+// RmOmapRange removes the omap keys in the range [keyBegin, keyEnd) from the
+// object.
+//
+// Implements:
+//
+//	void rados_write_op_omap_rm_range2(rados_write_op_t write_op,
+//	                                   const char *key_begin,
+//	                                   size_t key_begin_len,
+//	                                   const char *key_end,
+//	                                   size_t key_end_len)
+func (w *WriteOp) RmOmapRange(keyBegin, keyEnd string) {
+	cKeyBegin := C.CString(keyBegin)
+	defer C.free(unsafe.Pointer(cKeyBegin))
+	cKeyEnd := C.CString(keyEnd)
+	defer C.free(unsafe.Pointer(cKeyEnd))
+
+	C.rados_write_op_omap_rm_range2(
+		w.op,
+		cKeyBegin,
+		C.size_t(len(keyBegin)),
+		cKeyEnd,
+		C.size_t(len(keyEnd)))
+}
@@ -0,0 +1,31 @@
+//go:build ceph_preview
+
+package rados
+
+// #cgo LDFLAGS: -lrados
+// #include <rados/librados.h>
+// #include <stdlib.h>
+//
+import "C"
+
+// Truncate sets the size of the object to the given size. If the object is
+// extended the new space reads as zeros.
+//
+// Implements:
+//
+//	void rados_write_op_truncate(rados_write_op_t write_op,
+//	                             uint64_t offset);
+func (w *WriteOp) Truncate(size uint64) {
+	C.rados_write_op_truncate(w.op, C.uint64_t(size))
+}
+
+// Zero sets length bytes of the object, starting at offset, to zero.
+//
+// Implements:
+//
+//	void rados_write_op_zero(rados_write_op_t write_op,
+//	                         uint64_t offset,
+//	                         uint64_t len);
+func (w *WriteOp) Zero(offset, length uint64) {
+	C.rados_write_op_zero(w.op, C.uint64_t(offset), C.uint64_t(length))
+}
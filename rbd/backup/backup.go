@@ -0,0 +1,454 @@
+//go:build ceph_preview
+
+package backup
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ceph/go-ceph/rbd"
+)
+
+const (
+	defaultWorkers            = 4
+	defaultChunkSize          = 4 << 20
+	defaultRetryDelay         = time.Second
+	defaultCheckpointInterval = 10 * time.Second
+
+	// segmentSize is the length of the ranges of the image that are walked
+	// with one DiffIterate call, which bounds the number of extents held in
+	// memory.
+	segmentSize = 1 << 30
+)
+
+// ErrCheckpointMismatch is returned by Run if the checkpoint to resume from
+// was taken for a different backup.
+var ErrCheckpointMismatch = errors.New("checkpoint does not match the backup")
+
+// Options control a backup run. The zero value backs up all data of the
+// image with the default settings.
+type Options struct {
+	// FromSnap is the snapshot the backup is relative to. Only the extents
+	// that changed since the snapshot are backed up. If FromSnap is empty and
+	// FromSnapID is zero all allocated extents are backed up.
+	FromSnap string
+	// FromSnapID is the id of the snapshot the backup is relative to. If it
+	// is not zero it is used instead of FromSnap, and can refer to snapshots
+	// of any namespace, like group or mirror snapshots.
+	FromSnapID uint64
+	// IncludeParent includes the data of the parent of a cloned image.
+	IncludeParent bool
+
+	// Workers is the number of extents read concurrently. It defaults to 4.
+	Workers int
+	// ChunkSize is the maximum length of a read. Longer extents are split.
+	// It defaults to 4 MiB.
+	ChunkSize uint64
+	// MergeGap is the number of unchanged bytes that may separate two
+	// extents that are read with a single read. The unchanged data in
+	// between is passed to the sink too. By default only adjacent extents
+	// are merged.
+	MergeGap uint64
+	// BytesPerSecond limits the rate at which data is read from the image.
+	// Zero means no limit.
+	BytesPerSecond uint64
+
+	// Retries is the number of times a failed read or a failed call of the
+	// sink is retried before the run fails.
+	Retries int
+	// RetryDelay is the time to wait before a retry. It defaults to one
+	// second.
+	RetryDelay time.Duration
+
+	// Resume continues an interrupted run from a checkpoint it reported. The
+	// image and the options must be the same as those of that run.
+	Resume *Checkpoint
+	// Checkpoint, if set, is called with the progress of the run that can
+	// be resumed, whenever it advanced and CheckpointInterval has passed
+	// since the previous call, and once the run has completed. Returning an
+	// error aborts the run.
+	Checkpoint func(Checkpoint) error
+	// CheckpointInterval defaults to ten seconds.
+	CheckpointInterval time.Duration
+
+	// Progress, if set, is called after each extent has been passed to the
+	// sink.
+	Progress func(Progress)
+}
+
+// Checkpoint records how far a backup run got. It can be stored, for example
+// as JSON, to resume the run with Options.Resume after a crash.
+type Checkpoint struct {
+	FromSnap   string `json:"from_snap,omitempty"`
+	FromSnapID uint64 `json:"from_snap_id,omitempty"`
+	// Size is the size of the image.
+	Size uint64 `json:"size"`
+	// Offset is the offset of the image up to which all changed extents have
+	// been passed to the sink.
+	Offset uint64 `json:"offset"`
+}
+
+// Done returns true if the run has completed.
+func (c Checkpoint) Done() bool {
+	return c.Offset >= c.Size
+}
+
+// Progress reports the progress of a backup run.
+type Progress struct {
+	// Offset is the offset of the image up to which all changed extents
+	// have been passed to the sink.
+	Offset uint64
+	// Size is the size of the image.
+	Size uint64
+	// BytesRead is the number of bytes read from the image.
+	BytesRead uint64
+	// BytesWritten is the number of bytes passed to Sink.WriteData.
+	BytesWritten uint64
+	// BytesZeroed is the number of bytes passed to Sink.WriteZero.
+	BytesZeroed uint64
+}
+
+// Run backs up the image, which must be open at the snapshot to back up or
+// at the image head, to the sink. It returns once all changed extents have
+// been passed to the sink, ctx is done or an error occurred. The Checkpoint
+// and Progress functions of the options are not called concurrently.
+func Run(ctx context.Context, image *rbd.Image, sink Sink, o *Options) error {
+	if o == nil {
+		o = &Options{}
+	}
+	return run(ctx, newImageSource(image, o), sink, o, segmentSize)
+}
+
+// job is a range of the image that is read and passed to the sink by a
+// worker.
+type job struct {
+	off    uint64
+	length uint64
+	// zero is true if the range is known to be zeroed and is not read
+	zero bool
+}
+
+type engine struct {
+	src         source
+	sink        Sink
+	o           Options
+	segmentSize uint64
+	limiter     *limiter
+	checkpoint  Checkpoint
+
+	mutex sync.Mutex
+	// inflight holds the offsets of the jobs that have been dispatched but
+	// not completed
+	inflight       map[uint64]struct{}
+	dispatched     uint64
+	progress       Progress
+	lastCheckpoint time.Time
+	err            error
+}
+
+func run(ctx context.Context, src source, sink Sink, o *Options, segSize uint64) error {
+	size, err := src.size()
+	if err != nil {
+		return err
+	}
+	e := &engine{
+		src:         src,
+		sink:        sink,
+		o:           *o,
+		segmentSize: segSize,
+		limiter:     newLimiter(o.BytesPerSecond),
+		checkpoint: Checkpoint{
+			FromSnap:   o.FromSnap,
+			FromSnapID: o.FromSnapID,
+			Size:       size,
+		},
+		inflight:       map[uint64]struct{}{},
+		lastCheckpoint: time.Now(),
+	}
+	e.setDefaults()
+	if r := o.Resume; r != nil {
+		c := e.checkpoint
+		if r.FromSnap != c.FromSnap || r.FromSnapID != c.FromSnapID ||
+			r.Size != c.Size || r.Offset > c.Size {
+			return ErrCheckpointMismatch
+		}
+		e.checkpoint.Offset = r.Offset
+	}
+	e.dispatched = e.checkpoint.Offset
+	e.progress = Progress{Offset: e.checkpoint.Offset, Size: size}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	jobs := make(chan job, e.o.Workers)
+	var wg sync.WaitGroup
+	for range e.o.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, e.o.ChunkSize)
+			for j := range jobs {
+				if ctx.Err() != nil {
+					continue
+				}
+				if err := e.process(ctx, j, buf); err != nil {
+					e.fail(err)
+					cancel()
+				}
+			}
+		}()
+	}
+	err = e.dispatch(ctx, jobs, size)
+	if err != nil {
+		cancel()
+	}
+	close(jobs)
+	wg.Wait()
+
+	// an error of a worker caused the dispatcher to stop
+	if e.err != nil {
+		return e.err
+	}
+	if err != nil {
+		return err
+	}
+	// the workers skip the jobs left once ctx is done
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	// report the completed run, unless that has been done already
+	if e.o.Checkpoint != nil && e.checkpoint.Offset < size {
+		e.checkpoint.Offset = size
+		return e.o.Checkpoint(e.checkpoint)
+	}
+	return nil
+}
+
+func (e *engine) setDefaults() {
+	if e.o.Workers <= 0 {
+		e.o.Workers = defaultWorkers
+	}
+	if e.o.ChunkSize == 0 {
+		e.o.ChunkSize = defaultChunkSize
+	}
+	if e.o.RetryDelay == 0 {
+		e.o.RetryDelay = defaultRetryDelay
+	}
+	if e.o.CheckpointInterval == 0 {
+		e.o.CheckpointInterval = defaultCheckpointInterval
+	}
+}
+
+// dispatch walks the changed extents of the image, segment by segment, and
+// hands them to the workers.
+func (e *engine) dispatch(ctx context.Context, jobs chan<- job, size uint64) error {
+	for off := e.checkpoint.Offset; off < size; {
+		end := min(off+e.segmentSize, size)
+		var extents []job
+		err := e.retry(ctx, func() error {
+			extents = extents[:0]
+			return e.src.diff(off, end-off, func(o, l uint64, exists bool) {
+				extents = append(extents, job{off: o, length: l, zero: !exists})
+			})
+		})
+		if err != nil {
+			return err
+		}
+		for _, j := range e.coalesce(extents, off, end) {
+			e.mutex.Lock()
+			e.inflight[j.off] = struct{}{}
+			e.mutex.Unlock()
+			select {
+			case jobs <- j:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if err := e.segmentDone(end); err != nil {
+			return err
+		}
+		off = end
+	}
+	return nil
+}
+
+// coalesce turns the extents reported for the range from off to end into
+// jobs, merging extents and splitting them at ChunkSize.
+func (e *engine) coalesce(extents []job, off, end uint64) []job {
+	slices.SortFunc(extents, func(a, b job) int {
+		return cmp.Compare(a.off, b.off)
+	})
+	var jobs []job
+	for _, x := range extents {
+		// clip the extent to the range
+		xEnd := min(x.off+x.length, end)
+		x.off = max(x.off, off)
+		if x.off >= xEnd {
+			continue
+		}
+		x.length = xEnd - x.off
+		for x.length > 0 {
+			j := x
+			if !j.zero {
+				j.length = min(j.length, e.o.ChunkSize)
+			}
+			if n := len(jobs); n == 0 || !e.merge(&jobs[n-1], j) {
+				jobs = append(jobs, j)
+			}
+			x.off += j.length
+			x.length -= j.length
+		}
+	}
+	return jobs
+}
+
+// merge extends j to cover next if that is allowed.
+func (e *engine) merge(j *job, next job) bool {
+	end := j.off + j.length
+	if next.zero != j.zero || next.off < end {
+		return false
+	}
+	if j.zero {
+		// zeroed ranges are not read, only adjacent ones can be merged
+		if next.off != end {
+			return false
+		}
+	} else if next.off-end > e.o.MergeGap ||
+		next.off+next.length-j.off > e.o.ChunkSize {
+		return false
+	}
+	j.length = next.off + next.length - j.off
+	return true
+}
+
+// process reads the range of the job and passes it to the sink.
+func (e *engine) process(ctx context.Context, j job, buf []byte) error {
+	var p Progress
+	if j.zero {
+		err := e.retry(ctx, func() error {
+			return e.sink.WriteZero(j.off, j.length)
+		})
+		if err != nil {
+			return err
+		}
+		p.BytesZeroed = j.length
+		return e.done(j, p)
+	}
+
+	if err := e.limiter.wait(ctx, j.length); err != nil {
+		return err
+	}
+	data := buf[:j.length]
+	err := e.retry(ctx, func() error {
+		return e.src.readAt(data, j.off)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read %d bytes at offset %d: %w",
+			j.length, j.off, err)
+	}
+	p.BytesRead = j.length
+
+	// pass the data on in runs of blocks that are zero or not
+	for len(data) > 0 {
+		n := min(len(data), len(zeros))
+		zero := bytes.Equal(data[:n], zeros[:n])
+		for n < len(data) {
+			m := min(len(data)-n, len(zeros))
+			if bytes.Equal(data[n:n+m], zeros[:m]) != zero {
+				break
+			}
+			n += m
+		}
+		off := j.off + p.BytesWritten + p.BytesZeroed
+		run := data[:n]
+		if zero {
+			err = e.retry(ctx, func() error {
+				return e.sink.WriteZero(off, uint64(n))
+			})
+			p.BytesZeroed += uint64(n)
+		} else {
+			err = e.retry(ctx, func() error {
+				return e.sink.WriteData(off, run)
+			})
+			p.BytesWritten += uint64(n)
+		}
+		if err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return e.done(j, p)
+}
+
+// done records that the job has been completed.
+func (e *engine) done(j job, p Progress) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	delete(e.inflight, j.off)
+	e.progress.BytesRead += p.BytesRead
+	e.progress.BytesWritten += p.BytesWritten
+	e.progress.BytesZeroed += p.BytesZeroed
+	return e.report()
+}
+
+// segmentDone records that all jobs of the image up to end have been
+// dispatched.
+func (e *engine) segmentDone(end uint64) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.dispatched = end
+	return e.report()
+}
+
+// report reports the progress and, if due, a checkpoint. It must be called
+// with the mutex held.
+func (e *engine) report() error {
+	offset := e.dispatched
+	for off := range e.inflight {
+		offset = min(offset, off)
+	}
+	e.progress.Offset = offset
+	if e.o.Progress != nil {
+		e.o.Progress(e.progress)
+	}
+
+	if e.o.Checkpoint == nil || offset <= e.checkpoint.Offset ||
+		time.Since(e.lastCheckpoint) < e.o.CheckpointInterval {
+		return nil
+	}
+	e.checkpoint.Offset = offset
+	e.lastCheckpoint = time.Now()
+	return e.o.Checkpoint(e.checkpoint)
+}
+
+// fail records the first error of the workers.
+func (e *engine) fail(err error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.err == nil {
+		e.err = err
+	}
+}
+
+// retry calls fn until it succeeds, Retries retries have failed or ctx is
+// done.
+func (e *engine) retry(ctx context.Context, fn func() error) error {
+	for i := 0; ; i++ {
+		err := fn()
+		if err == nil || i >= e.o.Retries {
+			return err
+		}
+		timer := time.NewTimer(e.o.RetryDelay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
@@ -0,0 +1,297 @@
+//go:build ceph_preview
+
+package backup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memSource is an in-memory image with a fixed set of changed extents.
+type memSource struct {
+	data    []byte
+	extents []job
+
+	// readErrs is the number of reads that fail before reads succeed
+	readErrs atomic.Int32
+	reads    atomic.Int32
+	// minRead is the lowest offset read
+	mutex   sync.Mutex
+	minRead uint64
+}
+
+var errTest = errors.New("test error")
+
+func newMemSource(size int, extents ...job) *memSource {
+	return &memSource{
+		data:    make([]byte, size),
+		extents: extents,
+		minRead: uint64(size),
+	}
+}
+
+func (s *memSource) size() (uint64, error) {
+	return uint64(len(s.data)), nil
+}
+
+func (s *memSource) diff(
+	off, length uint64, fn func(off, length uint64, exists bool)) error {
+
+	for _, x := range s.extents {
+		if x.off < off+length && x.off+x.length > off {
+			fn(x.off, x.length, !x.zero)
+		}
+	}
+	return nil
+}
+
+func (s *memSource) readAt(b []byte, off uint64) error {
+	s.reads.Add(1)
+	if s.readErrs.Add(-1) >= 0 {
+		return errTest
+	}
+	s.mutex.Lock()
+	s.minRead = min(s.minRead, off)
+	s.mutex.Unlock()
+	copy(b, s.data[off:])
+	return nil
+}
+
+// memFile is an io.WriterAt in memory.
+type memFile struct {
+	mutex sync.Mutex
+	data  []byte
+	// failAfter is the number of writes that succeed before writes fail,
+	// if not negative
+	failAfter int
+}
+
+func newMemFile(size int) *memFile {
+	return &memFile{data: bytes.Repeat([]byte{0xff}, size), failAfter: -1}
+}
+
+func (f *memFile) WriteAt(b []byte, off int64) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.failAfter == 0 {
+		return 0, errTest
+	}
+	if f.failAfter > 0 {
+		f.failAfter--
+	}
+	return copy(f.data[off:], b), nil
+}
+
+// recordSink records the calls of the sink.
+type recordSink struct {
+	mutex sync.Mutex
+	data  []job
+	zero  []job
+}
+
+func (s *recordSink) WriteData(off uint64, data []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.data = append(s.data, job{off: off, length: uint64(len(data))})
+	return nil
+}
+
+func (s *recordSink) WriteZero(off, length uint64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.zero = append(s.zero, job{off: off, length: length, zero: true})
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	src := newMemSource(1<<20,
+		job{off: 0, length: 8192},
+		job{off: 65536, length: 4096, zero: true},
+		job{off: 200000, length: 300000},
+	)
+	copy(src.data, bytes.Repeat([]byte("a"), 8192))
+	copy(src.data[200000:], bytes.Repeat([]byte("b"), 300000))
+
+	f := newMemFile(1 << 20)
+	var last Progress
+	o := &Options{
+		Workers:   3,
+		ChunkSize: 65536,
+		Progress:  func(p Progress) { last = p },
+	}
+	require.NoError(t, run(context.Background(), src, &WriterAtSink{W: f}, o, 1<<18))
+
+	expected := bytes.Repeat([]byte{0xff}, 1<<20)
+	copy(expected, src.data[:8192])
+	copy(expected[65536:], make([]byte, 4096))
+	copy(expected[200000:], src.data[200000:500000])
+	assert.Equal(t, expected, f.data)
+
+	assert.Equal(t, Progress{
+		Offset:       1 << 20,
+		Size:         1 << 20,
+		BytesRead:    308192,
+		BytesWritten: 308192,
+		BytesZeroed:  4096,
+	}, last)
+}
+
+func TestRunZeros(t *testing.T) {
+	src := newMemSource(65536, job{off: 0, length: 6*4096 + 100})
+	copy(src.data, bytes.Repeat([]byte("x"), 4096))
+	copy(src.data[3*4096:], bytes.Repeat([]byte("y"), 4096))
+	src.data[6*4096+99] = 'z'
+
+	sink := &recordSink{}
+	require.NoError(t, run(context.Background(), src, sink, &Options{}, segmentSize))
+	assert.Equal(t, []job{
+		{off: 0, length: 4096},
+		{off: 3 * 4096, length: 4096},
+		{off: 6 * 4096, length: 100},
+	}, sink.data)
+	assert.Equal(t, []job{
+		{off: 4096, length: 2 * 4096, zero: true},
+		{off: 4 * 4096, length: 2 * 4096, zero: true},
+	}, sink.zero)
+}
+
+func TestCoalesce(t *testing.T) {
+	e := &engine{o: Options{ChunkSize: 1000, MergeGap: 10}}
+	extents := []job{
+		{off: 9000, length: 100},
+		{off: 0, length: 100},
+		{off: 100, length: 100},
+		// within MergeGap
+		{off: 205, length: 100},
+		// beyond MergeGap
+		{off: 400, length: 100},
+		// zeroed ranges are only merged if adjacent
+		{off: 500, length: 100, zero: true},
+		{off: 600, length: 5000, zero: true},
+		{off: 5605, length: 10, zero: true},
+		// split at ChunkSize
+		{off: 6000, length: 2500},
+		// clipped at the end of the range
+		{off: 9900, length: 200},
+	}
+	assert.Equal(t, []job{
+		{off: 0, length: 305},
+		{off: 400, length: 100},
+		{off: 500, length: 5100, zero: true},
+		{off: 5605, length: 10, zero: true},
+		{off: 6000, length: 1000},
+		{off: 7000, length: 1000},
+		{off: 8000, length: 500},
+		{off: 9000, length: 100},
+		{off: 9900, length: 100},
+	}, e.coalesce(extents, 0, 10000))
+
+	// clipped at the start of the range
+	assert.Equal(t, []job{{off: 50, length: 50}},
+		e.coalesce([]job{{off: 0, length: 100}}, 50, 10000))
+}
+
+func TestRunRetries(t *testing.T) {
+	src := newMemSource(65536, job{off: 0, length: 4096})
+	src.data[0] = 1
+
+	src.readErrs.Store(2)
+	o := &Options{Retries: 2, RetryDelay: time.Millisecond}
+	assert.NoError(t, run(context.Background(), src, &recordSink{}, o, segmentSize))
+	assert.EqualValues(t, 3, src.reads.Load())
+
+	src.readErrs.Store(2)
+	o.Retries = 1
+	err := run(context.Background(), src, &recordSink{}, o, segmentSize)
+	assert.ErrorIs(t, err, errTest)
+}
+
+func TestRunResume(t *testing.T) {
+	const size = 1 << 20
+	var extents []job
+	for off := uint64(0); off < size; off += 65536 {
+		extents = append(extents, job{off: off, length: 4096})
+	}
+	src := newMemSource(size, extents...)
+	for i := range src.data {
+		src.data[i] = byte(i%251 + 1)
+	}
+
+	f := newMemFile(size)
+	f.failAfter = 10
+	var checkpoints []Checkpoint
+	o := &Options{
+		FromSnap:           "snap",
+		Workers:            2,
+		CheckpointInterval: time.Nanosecond,
+		Checkpoint: func(c Checkpoint) error {
+			checkpoints = append(checkpoints, c)
+			return nil
+		},
+	}
+	err := run(context.Background(), src, &WriterAtSink{W: f}, o, 131072)
+	assert.ErrorIs(t, err, errTest)
+	require.NotEmpty(t, checkpoints)
+	c := checkpoints[len(checkpoints)-1]
+	assert.False(t, c.Done())
+	assert.Greater(t, c.Offset, uint64(0))
+	assert.Equal(t, "snap", c.FromSnap)
+	for i := 1; i < len(checkpoints); i++ {
+		assert.Greater(t, checkpoints[i].Offset, checkpoints[i-1].Offset)
+	}
+
+	t.Run("mismatch", func(t *testing.T) {
+		o := &Options{Resume: &Checkpoint{FromSnap: "other", Size: size}}
+		err := run(context.Background(), src, &recordSink{}, o, 131072)
+		assert.ErrorIs(t, err, ErrCheckpointMismatch)
+	})
+
+	f.failAfter = -1
+	src.minRead = size
+	checkpoints = nil
+	o.Resume = &c
+	require.NoError(t, run(context.Background(), src, &WriterAtSink{W: f}, o, 131072))
+	assert.GreaterOrEqual(t, src.minRead, c.Offset)
+	require.NotEmpty(t, checkpoints)
+	assert.True(t, checkpoints[len(checkpoints)-1].Done())
+	for _, x := range extents {
+		assert.Equal(t, src.data[x.off:x.off+x.length], f.data[x.off:x.off+x.length])
+	}
+}
+
+func TestRunCancel(t *testing.T) {
+	src := newMemSource(1<<20, job{off: 0, length: 1 << 20})
+	src.data[0] = 1
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := run(ctx, src, &recordSink{}, &Options{ChunkSize: 4096}, segmentSize)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestLimiter(t *testing.T) {
+	assert.Nil(t, newLimiter(0))
+	assert.NoError(t, (*limiter)(nil).wait(context.Background(), 1<<30))
+
+	// the first read is not delayed, the others have to wait for the
+	// bandwidth used by the reads before them
+	l := newLimiter(10 << 20)
+	start := time.Now()
+	for range 3 {
+		assert.NoError(t, l.wait(context.Background(), 1<<20))
+	}
+	elapsed := time.Since(start)
+	assert.GreaterOrEqual(t, elapsed, 190*time.Millisecond)
+	assert.Less(t, elapsed, time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, l.wait(ctx, 1<<20), context.Canceled)
+}
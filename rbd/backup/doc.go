@@ -0,0 +1,13 @@
+//go:build ceph_preview
+
+/*
+Package backup copies the changed data of an rbd image to a destination.
+
+Run walks the extents of an image that changed since a snapshot with
+DiffIterate, or all allocated extents for a full backup, and passes them to a
+Sink. Reads are spread over a number of workers, small extents are merged
+into larger reads, data that reads as zeros is passed on as zeroed ranges and
+the read bandwidth can be limited. An interrupted run can be resumed from a
+Checkpoint.
+*/
+package backup
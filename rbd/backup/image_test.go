@@ -0,0 +1,109 @@
+//go:build ceph_preview
+
+package backup
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ceph/go-ceph/internal/radostest"
+	"github.com/ceph/go-ceph/rbd"
+)
+
+const (
+	testImageSize  = uint64(1 << 24)
+	testImageOrder = 22
+)
+
+var testPool = radostest.Pool{NoNamespaces: true}
+
+func TestMain(m *testing.M) {
+	os.Exit(testPool.Run(m))
+}
+
+func TestRunImage(t *testing.T) {
+	ioctx := testPool.IOContext(t)
+
+	name := uuid.Must(uuid.NewV4()).String()
+	options := rbd.NewRbdImageOptions()
+	defer options.Destroy()
+	require.NoError(t, options.SetUint64(rbd.ImageOptionOrder, testImageOrder))
+	require.NoError(t, rbd.CreateImage(ioctx, name, testImageSize, options))
+	defer func() {
+		assert.NoError(t, rbd.RemoveImage(ioctx, name))
+	}()
+	image, err := rbd.OpenImage(ioctx, name, rbd.NoSnapshot)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, image.Close())
+	}()
+
+	_, err = image.WriteAt(bytes.Repeat([]byte("full"), 1<<18), 0)
+	require.NoError(t, err)
+	_, err = image.WriteAt(bytes.Repeat([]byte("full"), 1024), 1<<23)
+	require.NoError(t, err)
+	snap, err := image.CreateSnapshot("base")
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, snap.Remove())
+	}()
+	_, err = image.WriteAt(bytes.Repeat([]byte("incr"), 1024), 4096)
+	require.NoError(t, err)
+	_, err = image.WriteAt(make([]byte, 8192), 1<<23)
+	require.NoError(t, err)
+	_, err = image.WriteAt([]byte("end"), int64(testImageSize)-3)
+	require.NoError(t, err)
+
+	contents := func() []byte {
+		buf := make([]byte, testImageSize)
+		_, err := image.ReadAt(buf, 0)
+		require.NoError(t, err)
+		return buf
+	}
+	f := newMemFile(int(testImageSize))
+	f.data = make([]byte, testImageSize)
+	sink := &WriterAtSink{W: f, ZeroFilled: true}
+
+	// full backup of the snapshot
+	require.NoError(t, image.SetSnapshot("base"))
+	var p Progress
+	o := &Options{
+		ChunkSize:      1 << 20,
+		BytesPerSecond: 64 << 20,
+		Progress:       func(progress Progress) { p = progress },
+	}
+	require.NoError(t, Run(context.Background(), image, sink, o))
+	assert.Equal(t, contents(), f.data)
+	assert.Equal(t, testImageSize, p.Offset)
+	assert.Greater(t, p.BytesWritten, uint64(0))
+
+	// incremental backup of the head
+	require.NoError(t, image.SetSnapshot(rbd.NoSnapshot))
+	sink.ZeroFilled = false
+	var checkpoint Checkpoint
+	o = &Options{
+		FromSnap:  "base",
+		MergeGap:  1 << 16,
+		Workers:   2,
+		ChunkSize: 1 << 20,
+		Checkpoint: func(c Checkpoint) error {
+			checkpoint = c
+			return nil
+		},
+	}
+	require.NoError(t, Run(context.Background(), image, sink, o))
+	assert.Equal(t, contents(), f.data)
+	assert.True(t, checkpoint.Done())
+	assert.Equal(t, "base", checkpoint.FromSnap)
+
+	// resuming a completed backup does nothing
+	o.Resume = &checkpoint
+	f.failAfter = 0
+	assert.NoError(t, Run(context.Background(), image, sink, o))
+}
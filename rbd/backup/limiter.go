@@ -0,0 +1,55 @@
+//go:build ceph_preview
+
+package backup
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// limiter limits the rate of reads. Every read is scheduled after the reads
+// before it have used up their share of the bandwidth, so that the average
+// rate does not exceed the limit.
+type limiter struct {
+	mutex sync.Mutex
+	rate  float64 // bytes per second
+	next  time.Time
+}
+
+// newLimiter returns a limiter for bytesPerSecond, or nil if bytesPerSecond is
+// zero. A nil limiter does not limit.
+func newLimiter(bytesPerSecond uint64) *limiter {
+	if bytesPerSecond == 0 {
+		return nil
+	}
+	return &limiter{rate: float64(bytesPerSecond)}
+}
+
+// wait blocks until n bytes may be read or ctx is done.
+func (l *limiter) wait(ctx context.Context, n uint64) error {
+	if l == nil {
+		return nil
+	}
+	l.mutex.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	at := l.next
+	l.next = l.next.Add(time.Duration(float64(n) / l.rate * float64(time.Second)))
+	l.mutex.Unlock()
+
+	d := time.Until(at)
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
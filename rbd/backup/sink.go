@@ -0,0 +1,54 @@
+//go:build ceph_preview
+
+package backup
+
+import (
+	"io"
+)
+
+// Sink receives the changed extents of an image. The methods are called
+// concurrently by the workers of a run, for extents that do not overlap.
+type Sink interface {
+	// WriteData is called with data of the image starting at byte offset
+	// off. The data buffer is reused once WriteData returns.
+	WriteData(off uint64, data []byte) error
+	// WriteZero is called for length bytes of the image, starting at byte
+	// offset off, that read as zeros.
+	WriteZero(off, length uint64) error
+}
+
+// WriterAtSink is a Sink writing the extents to an io.WriterAt, for example
+// a file or a block device, at their offsets in the image.
+type WriterAtSink struct {
+	W io.WriterAt
+	// ZeroFilled must only be set if ranges of W that have not been written
+	// read as zeros, like those of a new sparse file. Zeroed extents are not
+	// written to W then.
+	ZeroFilled bool
+}
+
+// WriteData implements Sink.
+func (s *WriterAtSink) WriteData(off uint64, data []byte) error {
+	_, err := s.W.WriteAt(data, int64(off))
+	return err
+}
+
+// WriteZero implements Sink.
+func (s *WriterAtSink) WriteZero(off, length uint64) error {
+	if s.ZeroFilled {
+		return nil
+	}
+	for length > 0 {
+		n := min(length, uint64(len(zeros)))
+		if _, err := s.W.WriteAt(zeros[:n], int64(off)); err != nil {
+			return err
+		}
+		off += n
+		length -= n
+	}
+	return nil
+}
+
+// zeros is a block of zeros, the unit in which read data is checked for
+// zeros.
+var zeros = make([]byte, 4096)
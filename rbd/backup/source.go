@@ -0,0 +1,79 @@
+//go:build ceph_preview
+
+package backup
+
+import (
+	"io"
+
+	"github.com/ceph/go-ceph/rbd"
+)
+
+// source is the image a run reads from.
+type source interface {
+	size() (uint64, error)
+	// diff calls fn for the changed extents in the range of length bytes
+	// starting at byte offset off.
+	diff(off, length uint64, fn func(off, length uint64, exists bool)) error
+	// readAt fills b with the data starting at byte offset off.
+	readAt(b []byte, off uint64) error
+}
+
+type imageSource struct {
+	image         *rbd.Image
+	fromSnap      string
+	fromSnapID    uint64
+	includeParent rbd.DiffIncludeParent
+}
+
+func newImageSource(image *rbd.Image, o *Options) *imageSource {
+	s := &imageSource{
+		image:         image,
+		fromSnap:      o.FromSnap,
+		fromSnapID:    o.FromSnapID,
+		includeParent: rbd.ExcludeParent,
+	}
+	if o.IncludeParent {
+		s.includeParent = rbd.IncludeParent
+	}
+	return s
+}
+
+func (s *imageSource) size() (uint64, error) {
+	return s.image.GetSize()
+}
+
+func (s *imageSource) diff(
+	off, length uint64, fn func(off, length uint64, exists bool)) error {
+
+	cb := func(off, length uint64, exists int, _ interface{}) int {
+		fn(off, length, exists != 0)
+		return 0
+	}
+	if s.fromSnapID != 0 {
+		return s.image.DiffIterateByID(rbd.DiffIterateByIDConfig{
+			FromSnapID:    s.fromSnapID,
+			Offset:        off,
+			Length:        length,
+			IncludeParent: s.includeParent,
+			Callback:      cb,
+		})
+	}
+	return s.image.DiffIterate(rbd.DiffIterateConfig{
+		SnapName:      s.fromSnap,
+		Offset:        off,
+		Length:        length,
+		IncludeParent: s.includeParent,
+		Callback:      cb,
+	})
+}
+
+func (s *imageSource) readAt(b []byte, off uint64) error {
+	n, err := s.image.ReadAt(b, int64(off))
+	if n == len(b) {
+		return nil
+	}
+	if err == nil || err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,88 @@
+//go:build ceph_preview
+
+package rbd
+
+// #cgo LDFLAGS: -lrbd
+// #include <errno.h>
+// #include <stdlib.h>
+// #include <rbd/librbd.h>
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/ceph/go-ceph/rados"
+)
+
+// ErrCompareMismatch is wrapped by a CompareMismatchError, returned if the
+// contents of the image differ from the compare buffer of a compare and write
+// operation.
+var ErrCompareMismatch = getError(-C.EILSEQ)
+
+// CompareMismatchError is returned by CompareAndWrite and AioCompareAndWrite
+// if the contents of the image differ from the compare buffer. Nothing has
+// been written in that case.
+type CompareMismatchError struct {
+	// Offset is the offset in the image of the first byte that differs.
+	Offset uint64
+}
+
+// Error implements the error interface.
+func (e *CompareMismatchError) Error() string {
+	return fmt.Sprintf("%v at offset %d", ErrCompareMismatch, e.Offset)
+}
+
+// Unwrap returns ErrCompareMismatch.
+func (*CompareMismatchError) Unwrap() error {
+	return ErrCompareMismatch
+}
+
+// compareAndWriteError returns the error of a compare and write operation
+// that returned ret.
+func compareAndWriteError(ret C.ssize_t, mismatchOff *C.uint64_t) error {
+	if ret == -C.EILSEQ {
+		return &CompareMismatchError{Offset: uint64(*mismatchOff)}
+	}
+	return getError(C.int(ret))
+}
+
+// CompareAndWrite atomically compares the contents of the image starting at
+// byte offset off with cmp and, only if they are equal, writes buf in their
+// place. cmp and buf must have the same length. If the contents differ a
+// *CompareMismatchError is returned. On success the number of bytes written
+// is returned.
+//
+// Implements:
+//
+//	ssize_t rbd_compare_and_write(rbd_image_t image, uint64_t ofs,
+//	                              size_t len, const char *cmp_buf,
+//	                              const char *buf, uint64_t *mismatch_off,
+//	                              int op_flags);
+func (image *Image) CompareAndWrite(
+	cmp, buf []byte, off uint64, flags rados.OpFlags) (int, error) {
+
+	if err := image.validate(imageIsOpen); err != nil {
+		return 0, err
+	}
+	if len(cmp) != len(buf) {
+		return 0, getError(-C.EINVAL)
+	}
+	if len(buf) == 0 {
+		return 0, nil
+	}
+
+	var mismatchOff C.uint64_t
+	ret := C.rbd_compare_and_write(
+		image.image,
+		C.uint64_t(off),
+		C.size_t(len(buf)),
+		(*C.char)(unsafe.Pointer(&cmp[0])),
+		(*C.char)(unsafe.Pointer(&buf[0])),
+		&mismatchOff,
+		C.int(flags))
+	if ret < 0 {
+		return 0, compareAndWriteError(ret, &mismatchOff)
+	}
+	return int(ret), nil
+}
@@ -0,0 +1,54 @@
+//go:build ceph_preview
+
+package rbd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ceph/go-ceph/rados"
+)
+
+func TestCompareAndWrite(t *testing.T) {
+	img, cleanup := aioTestImage(t, testImageSize)
+	defer cleanup()
+
+	const off = 1 << 20
+	_, err := img.WriteAt([]byte("token-0001"), off)
+	require.NoError(t, err)
+
+	n, err := img.CompareAndWrite(
+		[]byte("token-0001"), []byte("token-0002"), off, rados.OpFlagNone)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, n)
+
+	// a stale token is rejected and the first differing byte is reported
+	_, err = img.CompareAndWrite(
+		[]byte("token-0001"), []byte("token-0003"), off, rados.OpFlagNone)
+	assert.ErrorIs(t, err, ErrCompareMismatch)
+	var mismatch *CompareMismatchError
+	if assert.ErrorAs(t, err, &mismatch) {
+		assert.EqualValues(t, off+9, mismatch.Offset)
+	}
+	buf := make([]byte, 10)
+	_, err = img.ReadAt(buf, off)
+	assert.NoError(t, err)
+	assert.Equal(t, "token-0002", string(buf))
+
+	_, err = img.CompareAndWrite([]byte("a"), []byte("ab"), off, rados.OpFlagNone)
+	assert.Error(t, err)
+	n, err = img.CompareAndWrite(nil, nil, off, rados.OpFlagNone)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	_, err = (&Image{}).CompareAndWrite([]byte("a"), []byte("b"), 0, rados.OpFlagNone)
+	assert.ErrorIs(t, err, ErrImageNotOpen)
+}
+
+func TestCompareMismatchError(t *testing.T) {
+	err := error(&CompareMismatchError{Offset: 42})
+	assert.ErrorIs(t, err, ErrCompareMismatch)
+	assert.Contains(t, err.Error(), "at offset 42")
+}
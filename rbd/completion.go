@@ -0,0 +1,280 @@
+//go:build ceph_preview
+
+package rbd
+
+/*
+#cgo LDFLAGS: -lrbd
+#include <stdlib.h>
+#include <sys/uio.h>
+#include <rbd/librbd.h>
+
+extern void rbdCompletionCallback(rbd_completion_t, uintptr_t);
+
+// inline wrapper to cast uintptr_t to void*
+static inline int wrap_rbd_aio_create_completion(uintptr_t arg,
+	rbd_completion_t *c) {
+		return rbd_aio_create_completion((void*)arg,
+			(rbd_callback_t)rbdCompletionCallback, c);
+};
+*/
+import "C"
+
+import (
+	"context"
+	"sync"
+	"unsafe"
+
+	"github.com/ceph/go-ceph/internal/callbacks"
+	"github.com/ceph/go-ceph/internal/cutil"
+)
+
+// rbdCompletions tracks the in-flight asynchronous operations.
+var rbdCompletions = callbacks.New()
+
+// Completion represents an asynchronous operation that has been submitted to
+// librbd. The result of the operation can be obtained by blocking on the Wait
+// method, by polling the IsComplete method, by receiving from the channel
+// returned by the Done method or by registering a function with OnComplete.
+//
+// Any Go buffers passed to the call that created the Completion are in use
+// by librbd until the operation has completed and must not be modified (or
+// read, for read operations) before then. The image must not be closed while
+// operations are in flight.
+//
+// Once the result has been consumed the Release method must be called to free
+// the resources associated with the Completion.
+type Completion struct {
+	comp    C.rbd_completion_t
+	cbIndex uintptr
+	done    chan struct{}
+
+	// cbuf is C memory used by the operation, freed on release
+	cbuf unsafe.Pointer
+
+	// finish is called, from the librbd callback thread, with the return
+	// value of the operation. It returns the result of the operation and may
+	// replace the error set on the completion.
+	finish func(ret C.ssize_t) (int, error)
+
+	// guards pin the Go buffers handed to librbd for the lifetime of the
+	// operation. slots track C memory, such as the locations the guards write
+	// to, that is freed when the completion is released.
+	guards []*cutil.PtrGuard
+	slots  []cutil.CPtr
+	iovec  *cutil.Iovec
+	// syncIovec is set for reads into iovec, whose buffers may be copies of
+	// the Go buffers that must be synced back once the data has been read
+	syncIovec bool
+
+	mutex    sync.Mutex
+	ret      C.ssize_t
+	n        int
+	err      error
+	onDone   func(*Completion)
+	released bool
+}
+
+// newCompletion returns a new completion. If size is greater than zero a C
+// buffer of that size is allocated for the operation.
+func newCompletion(size int) (*Completion, error) {
+	c := &Completion{
+		done: make(chan struct{}),
+	}
+	if size > 0 {
+		c.cbuf = C.malloc(C.size_t(size))
+	}
+	c.cbIndex = rbdCompletions.Add(c)
+	ret := C.wrap_rbd_aio_create_completion(C.uintptr_t(c.cbIndex), &c.comp)
+	if ret != 0 {
+		rbdCompletions.Remove(c.cbIndex)
+		C.free(c.cbuf)
+		return nil, getError(ret)
+	}
+	return c, nil
+}
+
+// pin guards the Go buffer b for as long as librbd may access it and returns
+// a pointer to the buffer that can be handed to librbd.
+func (c *Completion) pin(b []byte) *C.char {
+	if len(b) == 0 {
+		return nil
+	}
+	slot := cutil.Malloc(cutil.PtrSize)
+	c.slots = append(c.slots, slot)
+	c.guards = append(c.guards, cutil.NewPtrGuard(slot, unsafe.Pointer(&b[0])))
+	return *(**C.char)(unsafe.Pointer(slot))
+}
+
+// pinIovec guards the Go buffers of iov for as long as librbd may access
+// them and returns an iovec referencing them. Empty buffers are skipped.
+func (c *Completion) pinIovec(iov [][]byte) (*C.struct_iovec, C.int) {
+	bufs := make([][]byte, 0, len(iov))
+	for _, b := range iov {
+		if len(b) > 0 {
+			bufs = append(bufs, b)
+		}
+	}
+	if len(bufs) == 0 {
+		return nil, 0
+	}
+	v := cutil.ByteSlicesToIovec(bufs)
+	c.iovec = &v
+	return (*C.struct_iovec)(v.Pointer()), C.int(v.Len())
+}
+
+// unpin releases the guards of the buffers that were handed to librbd.
+func (c *Completion) unpin() {
+	for i := range c.guards {
+		c.guards[i].Release()
+	}
+	c.guards = nil
+	if c.iovec != nil {
+		c.iovec.Free()
+		c.iovec = nil
+	}
+}
+
+// submitted must be called with the return value of the librbd call that
+// started the asynchronous operation. If the operation could not be started
+// the completion is released and an error is returned.
+func (c *Completion) submitted(ret C.int) error {
+	if ret == 0 {
+		return nil
+	}
+	// the callback will never fire, so finish up here
+	rbdCompletions.Remove(c.cbIndex)
+	c.unpin()
+	close(c.done)
+	c.Release()
+	return getError(ret)
+}
+
+func (c *Completion) complete() {
+	ret := C.rbd_aio_get_return_value(c.comp)
+	n := int(ret)
+	var err error
+	if ret < 0 {
+		n, err = 0, getError(C.int(ret))
+	}
+	if c.finish != nil {
+		n, err = c.finish(ret)
+	}
+	if c.syncIovec && c.iovec != nil && ret > 0 {
+		c.iovec.Sync()
+	}
+	c.unpin()
+	rbdCompletions.Remove(c.cbIndex)
+
+	// done is closed with the mutex held, so that OnComplete either sees
+	// the operation completed or registers fn before it is picked up here
+	c.mutex.Lock()
+	c.ret = ret
+	c.n = n
+	c.err = err
+	onDone := c.onDone
+	close(c.done)
+	c.mutex.Unlock()
+
+	if onDone != nil {
+		onDone(c)
+	}
+}
+
+// Done returns a channel that is closed once the operation has completed.
+func (c *Completion) Done() <-chan struct{} {
+	return c.done
+}
+
+// Wait blocks until the operation has completed. It returns the non-negative
+// result of the operation, for example the number of bytes read by a read
+// operation, or an error. Unlike rbd_aio_wait_for_complete, Wait only blocks
+// the calling goroutine and not an OS thread.
+func (c *Completion) Wait() (int, error) {
+	<-c.done
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.n, c.err
+}
+
+// IsComplete returns true if the operation has completed. The result of a
+// completed operation can be obtained by calling Wait without blocking.
+func (c *Completion) IsComplete() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// OnComplete registers fn to be called once the operation has completed. fn
+// is called from the librbd callback thread, so it must not block and must
+// not wait for other operations of the image. If the operation has already
+// completed fn is called before OnComplete returns. Only one function can be
+// registered, a later call replaces the function of an earlier one.
+func (c *Completion) OnComplete(fn func(c *Completion)) {
+	c.mutex.Lock()
+	select {
+	case <-c.done:
+		c.mutex.Unlock()
+		fn(c)
+		return
+	default:
+	}
+	c.onDone = fn
+	c.mutex.Unlock()
+}
+
+// waitContext blocks until the operation has completed or ctx is done. In the
+// former case the return value of the operation is returned and the caller
+// must release the completion. In the latter case ctx.Err() is returned and
+// the completion is released once the operation has completed, after calling
+// abandon, if not nil, with the return value of the operation.
+func (c *Completion) waitContext(
+	ctx context.Context, abandon func(ret C.ssize_t)) (C.ssize_t, error) {
+
+	select {
+	case <-c.done:
+		return c.ret, nil
+	case <-ctx.Done():
+	}
+	go func() {
+		<-c.done
+		if abandon != nil {
+			abandon(c.ret)
+		}
+		c.Release()
+	}()
+	return 0, ctx.Err()
+}
+
+// Release frees the resources associated with the Completion. If the
+// operation is still in flight Release blocks until it has completed.
+//
+// Implements:
+//
+//	void rbd_aio_release(rbd_completion_t c);
+func (c *Completion) Release() {
+	<-c.done
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.released {
+		return
+	}
+	c.released = true
+	C.rbd_aio_release(c.comp)
+	c.comp = nil
+	C.free(c.cbuf)
+	c.cbuf = nil
+	for i := range c.slots {
+		cutil.Free(c.slots[i])
+	}
+	c.slots = nil
+}
+
+//export rbdCompletionCallback
+func rbdCompletionCallback(_ C.rbd_completion_t, index uintptr) {
+	v := rbdCompletions.Lookup(index)
+	c := v.(*Completion)
+	c.complete()
+}
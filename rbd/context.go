@@ -0,0 +1,183 @@
+//go:build ceph_preview
+
+package rbd
+
+// #cgo LDFLAGS: -lrbd
+// #include <stdlib.h>
+// #include <string.h>
+// #include <rados/librados.h>
+// #include <rbd/librbd.h>
+import "C"
+
+import (
+	"context"
+	"io"
+	"unsafe"
+
+	"github.com/ceph/go-ceph/rados"
+)
+
+// OpenImageContext opens an image like OpenImage, but gives up waiting once
+// ctx is done, returning ctx.Err(). An image that is opened after ctx is done
+// is closed again in the background.
+//
+// Implements:
+//
+//	int rbd_aio_open(rados_ioctx_t p, const char *name, rbd_image_t *image,
+//	                 const char *snap_name, rbd_completion_t c);
+func OpenImageContext(
+	ctx context.Context, ioctx *rados.IOContext, name, snapName string) (*Image, error) {
+
+	if ioctx == nil {
+		return nil, ErrNoIOContext
+	}
+	if name == "" {
+		return nil, ErrNoName
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+
+	var cSnapName *C.char
+	if snapName != NoSnapshot {
+		cSnapName = C.CString(snapName)
+		defer C.free(unsafe.Pointer(cSnapName))
+	}
+
+	// librbd writes the image handle when the open completes, which may be
+	// after we have stopped waiting for it
+	c, err := newCompletion(C.sizeof_rbd_image_t)
+	if err != nil {
+		return nil, err
+	}
+	cImage := (*C.rbd_image_t)(c.cbuf)
+	ret := C.rbd_aio_open(
+		cephIoctx(ioctx),
+		cName,
+		cImage,
+		cSnapName,
+		c.comp)
+	if err := c.submitted(ret); err != nil {
+		return nil, err
+	}
+
+	r, err := c.waitContext(ctx, func(r C.ssize_t) {
+		if r == 0 {
+			C.rbd_close(*cImage)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer c.Release()
+	if r != 0 {
+		return nil, getError(C.int(r))
+	}
+	return &Image{
+		ioctx: ioctx,
+		name:  name,
+		image: *cImage,
+	}, nil
+}
+
+// ReadAtContext reads data from the image like ReadAt, but gives up waiting
+// once ctx is done, returning ctx.Err(). The data is read into a private
+// buffer that is only copied into data if the read finishes in time, so data
+// may be reused as soon as ReadAtContext returns. The image must not be closed
+// while a read is still in flight.
+//
+// Implements:
+//
+//	int rbd_aio_read(rbd_image_t image, uint64_t off, size_t len, char *buf,
+//	                 rbd_completion_t c);
+func (image *Image) ReadAtContext(ctx context.Context, data []byte, off int64) (int, error) {
+	if err := image.validate(imageIsOpen); err != nil {
+		return 0, err
+	}
+	if len(data) == 0 {
+		return 0, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	c, err := newCompletion(len(data))
+	if err != nil {
+		return 0, err
+	}
+	ret := C.rbd_aio_read(
+		image.image,
+		C.uint64_t(off),
+		C.size_t(len(data)),
+		(*C.char)(c.cbuf),
+		c.comp)
+	if err := c.submitted(ret); err != nil {
+		return 0, err
+	}
+
+	r, err := c.waitContext(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer c.Release()
+	if r < 0 {
+		return 0, getError(C.int(r))
+	}
+	n := copy(data, unsafe.Slice((*byte)(c.cbuf), int(r)))
+	if n < len(data) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// WriteAtContext writes data to the image like WriteAt, but gives up waiting
+// once ctx is done, returning ctx.Err(). The data is copied into a private
+// buffer before the write is started, so data may be reused as soon as
+// WriteAtContext returns. A write that was given up on may or may not have
+// been applied to the image. The image must not be closed while a write is
+// still in flight.
+//
+// Implements:
+//
+//	int rbd_aio_write(rbd_image_t image, uint64_t off, size_t len,
+//	                  const char *buf, rbd_completion_t c);
+func (image *Image) WriteAtContext(ctx context.Context, data []byte, off int64) (int, error) {
+	if err := image.validate(imageIsOpen); err != nil {
+		return 0, err
+	}
+	if len(data) == 0 {
+		return 0, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	c, err := newCompletion(len(data))
+	if err != nil {
+		return 0, err
+	}
+	C.memcpy(c.cbuf, unsafe.Pointer(&data[0]), C.size_t(len(data)))
+	ret := C.rbd_aio_write(
+		image.image,
+		C.uint64_t(off),
+		C.size_t(len(data)),
+		(*C.char)(c.cbuf),
+		c.comp)
+	if err := c.submitted(ret); err != nil {
+		return 0, err
+	}
+
+	r, err := c.waitContext(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer c.Release()
+	if r < 0 {
+		return 0, getError(C.int(r))
+	}
+	// unlike rbd_write, a completed rbd_aio_write returns zero
+	return len(data), nil
+}
@@ -0,0 +1,90 @@
+//go:build ceph_preview
+
+package rbd
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageContext(t *testing.T) {
+	conn := radosConnect(t)
+	defer conn.Shutdown()
+
+	poolname := GetUUID()
+	require.NoError(t, conn.MakePool(poolname))
+	defer conn.DeletePool(poolname)
+
+	ioctx, err := conn.OpenIOContext(poolname)
+	require.NoError(t, err)
+	defer ioctx.Destroy()
+
+	name := GetUUID()
+	options := NewRbdImageOptions()
+	require.NoError(t, options.SetUint64(ImageOptionOrder, uint64(testImageOrder)))
+	require.NoError(t, CreateImage(ioctx, name, testImageSize, options))
+	defer func() { assert.NoError(t, RemoveImage(ioctx, name)) }()
+
+	t.Run("openCanceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		img, err := OpenImageContext(ctx, ioctx, name, NoSnapshot)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Nil(t, img)
+	})
+
+	t.Run("openMissing", func(t *testing.T) {
+		_, err := OpenImageContext(context.Background(), ioctx, GetUUID(), NoSnapshot)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("readWrite", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		img, err := OpenImageContext(ctx, ioctx, name, NoSnapshot)
+		require.NoError(t, err)
+		defer func() { assert.NoError(t, img.Close()) }()
+
+		data := []byte("context aware I/O")
+		n, err := img.WriteAtContext(ctx, data, 4096)
+		assert.NoError(t, err)
+		assert.Equal(t, len(data), n)
+
+		buf := make([]byte, len(data))
+		n, err = img.ReadAtContext(ctx, buf, 4096)
+		assert.NoError(t, err)
+		assert.Equal(t, len(data), n)
+		assert.Equal(t, data, buf)
+
+		// reading past the end of the image is short
+		n, err = img.ReadAtContext(ctx, buf, int64(testImageSize)-4)
+		assert.Equal(t, io.EOF, err)
+		assert.Equal(t, 4, n)
+	})
+
+	t.Run("readWriteCanceled", func(t *testing.T) {
+		img, err := OpenImage(ioctx, name, NoSnapshot)
+		require.NoError(t, err)
+		defer func() { assert.NoError(t, img.Close()) }()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err = img.WriteAtContext(ctx, []byte("nope"), 0)
+		assert.ErrorIs(t, err, context.Canceled)
+		_, err = img.ReadAtContext(ctx, make([]byte, 4), 0)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("closedImage", func(t *testing.T) {
+		img, err := OpenImage(ioctx, name, NoSnapshot)
+		require.NoError(t, err)
+		require.NoError(t, img.Close())
+		_, err = img.ReadAtContext(context.Background(), make([]byte, 4), 0)
+		assert.ErrorIs(t, err, ErrImageNotOpen)
+	})
+}
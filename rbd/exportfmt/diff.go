@@ -0,0 +1,421 @@
+//go:build ceph_preview
+
+package exportfmt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Version is the version of a stream format.
+type Version int
+
+const (
+	// V1 is the version of diff streams written by default by
+	// "rbd export-diff".
+	V1 = Version(1)
+	// V2 is the version of diff streams written by
+	// "rbd export-diff --export-format 2" and of the image streams written by
+	// "rbd export --export-format 2". Unlike V1 it records whether the
+	// snapshot the diff leads to is protected.
+	V2 = Version(2)
+)
+
+const (
+	diffBannerV1 = "rbd diff v1\n"
+	diffBannerV2 = "rbd diff v2\n"
+)
+
+// tags of the records of diff streams
+const (
+	tagFromSnap  = 'f'
+	tagToSnap    = 't'
+	tagProtected = 'p'
+	tagSize      = 's'
+	tagWrite     = 'w'
+	tagZero      = 'z'
+	tagEnd       = 'e'
+)
+
+// ErrInvalidStream is returned if a stream can not be parsed.
+var ErrInvalidStream = errors.New("invalid rbd export stream")
+
+func invalidf(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrInvalidStream, fmt.Sprintf(format, args...))
+}
+
+// DiffHeader describes the changes recorded in a diff stream.
+type DiffHeader struct {
+	// FromSnap is the snapshot the changes are relative to. It is empty if
+	// the diff contains all data of the image.
+	FromSnap string
+	// ToSnap is the snapshot the changes lead to. It is empty if the diff
+	// leads to the image head.
+	ToSnap string
+	// Protected is true if ToSnap is protected. It is only recorded in V2
+	// streams.
+	Protected bool
+	// Size is the size of the image at ToSnap.
+	Size uint64
+}
+
+// Extent is a changed range of the image in a diff stream.
+type Extent struct {
+	Offset uint64
+	Length uint64
+	// Zero is true if the range has been zeroed or discarded. Data is nil in
+	// that case.
+	Zero bool
+	Data []byte
+}
+
+// DiffWriter writes a diff stream.
+type DiffWriter struct {
+	w       io.Writer
+	version Version
+	buf     []byte
+	closed  bool
+}
+
+// NewDiffWriter writes the banner and the header of a diff stream of the
+// given version to w and returns a DiffWriter for the extents. Close must be
+// called after the last extent has been written.
+func NewDiffWriter(w io.Writer, version Version, h DiffHeader) (*DiffWriter, error) {
+	dw := &DiffWriter{w: w, version: version}
+	switch version {
+	case V1:
+		dw.buf = append(dw.buf, diffBannerV1...)
+	case V2:
+		dw.buf = append(dw.buf, diffBannerV2...)
+	default:
+		return nil, fmt.Errorf("unsupported diff stream version %d", version)
+	}
+	if h.FromSnap != "" {
+		dw.appendString(tagFromSnap, h.FromSnap)
+	}
+	if h.ToSnap != "" {
+		dw.appendString(tagToSnap, h.ToSnap)
+		if version == V2 {
+			// like rbd, record a length of 8 for the single byte
+			dw.appendTag(tagProtected, 8)
+			dw.buf = append(dw.buf, boolByte(h.Protected))
+		}
+	}
+	dw.appendTag(tagSize, 8)
+	dw.buf = binary.LittleEndian.AppendUint64(dw.buf, h.Size)
+	if err := dw.flush(); err != nil {
+		return nil, err
+	}
+	return dw, nil
+}
+
+func boolByte(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// appendTag appends a record tag and, for V2 streams, the length of the
+// record.
+func (dw *DiffWriter) appendTag(tag byte, length uint64) {
+	dw.buf = append(dw.buf, tag)
+	if dw.version == V2 {
+		dw.buf = binary.LittleEndian.AppendUint64(dw.buf, length)
+	}
+}
+
+func (dw *DiffWriter) appendString(tag byte, s string) {
+	dw.appendTag(tag, 4+uint64(len(s)))
+	dw.buf = binary.LittleEndian.AppendUint32(dw.buf, uint32(len(s)))
+	dw.buf = append(dw.buf, s...)
+}
+
+func (dw *DiffWriter) flush() error {
+	_, err := dw.w.Write(dw.buf)
+	dw.buf = dw.buf[:0]
+	return err
+}
+
+// WriteData records that the image contains data starting at byte offset
+// off.
+func (dw *DiffWriter) WriteData(off uint64, data []byte) error {
+	if dw.closed {
+		return io.ErrClosedPipe
+	}
+	dw.appendTag(tagWrite, 16+uint64(len(data)))
+	dw.buf = binary.LittleEndian.AppendUint64(dw.buf, off)
+	dw.buf = binary.LittleEndian.AppendUint64(dw.buf, uint64(len(data)))
+	if err := dw.flush(); err != nil {
+		return err
+	}
+	_, err := dw.w.Write(data)
+	return err
+}
+
+// WriteZero records that length bytes of the image starting at byte offset
+// off have been zeroed or discarded.
+func (dw *DiffWriter) WriteZero(off, length uint64) error {
+	if dw.closed {
+		return io.ErrClosedPipe
+	}
+	dw.appendTag(tagZero, 16)
+	dw.buf = binary.LittleEndian.AppendUint64(dw.buf, off)
+	dw.buf = binary.LittleEndian.AppendUint64(dw.buf, length)
+	return dw.flush()
+}
+
+// WriteExtent writes the extent with WriteData or WriteZero.
+func (dw *DiffWriter) WriteExtent(e *Extent) error {
+	if e.Zero {
+		return dw.WriteZero(e.Offset, e.Length)
+	}
+	return dw.WriteData(e.Offset, e.Data)
+}
+
+// Close ends the diff stream. It does not close the underlying writer.
+func (dw *DiffWriter) Close() error {
+	if dw.closed {
+		return nil
+	}
+	dw.closed = true
+	// the end tag has no length, not even in V2 streams
+	dw.buf = append(dw.buf, tagEnd)
+	return dw.flush()
+}
+
+// maxExtentLength limits the length of the data of extents, so that broken
+// streams do not lead to huge allocations. Extents written by rbd do not span
+// more than one object.
+const maxExtentLength = 1 << 30
+
+// maxStringLength limits the length of snapshot names and metadata.
+const maxStringLength = 1 << 24
+
+// streamReader reads the parts of a stream. The reader it wraps always
+// implements io.ByteReader, so that readers of embedded streams do not read
+// ahead.
+type streamReader struct {
+	r io.Reader
+}
+
+func newStreamReader(r io.Reader) streamReader {
+	if _, ok := r.(io.ByteReader); ok {
+		return streamReader{r}
+	}
+	return streamReader{bufio.NewReader(r)}
+}
+
+func (sr streamReader) full(n uint64) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(sr.r, b); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return b, nil
+}
+
+func (sr streamReader) byte() (byte, error) {
+	b, err := sr.r.(io.ByteReader).ReadByte()
+	return b, unexpectedEOF(err)
+}
+
+func (sr streamReader) uint32() (uint32, error) {
+	var b [4]byte
+	if _, err := io.ReadFull(sr.r, b[:]); err != nil {
+		return 0, unexpectedEOF(err)
+	}
+	return binary.LittleEndian.Uint32(b[:]), nil
+}
+
+func (sr streamReader) uint64() (uint64, error) {
+	var b [8]byte
+	if _, err := io.ReadFull(sr.r, b[:]); err != nil {
+		return 0, unexpectedEOF(err)
+	}
+	return binary.LittleEndian.Uint64(b[:]), nil
+}
+
+func (sr streamReader) string() (string, error) {
+	n, err := sr.uint32()
+	if err != nil {
+		return "", err
+	}
+	if n > maxStringLength {
+		return "", invalidf("string of %d bytes", n)
+	}
+	b, err := sr.full(uint64(n))
+	return string(b), err
+}
+
+func (sr streamReader) banner(banners ...string) (string, error) {
+	// all banners end with a newline
+	var line []byte
+	for len(line) < 32 {
+		b, err := sr.byte()
+		if err != nil {
+			return "", err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	for _, banner := range banners {
+		if string(line) == banner {
+			return banner, nil
+		}
+	}
+	return "", invalidf("unexpected banner %q", line)
+}
+
+func (sr streamReader) skip(n uint64) error {
+	_, err := io.CopyN(io.Discard, sr.r, int64(n))
+	return unexpectedEOF(err)
+}
+
+// unexpectedEOF turns io.EOF in the middle of a stream into
+// io.ErrUnexpectedEOF.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// DiffReader reads a diff stream.
+type DiffReader struct {
+	sr      streamReader
+	version Version
+	header  DiffHeader
+	// pending is the tag of the first extent, read while reading the header,
+	// or -1
+	pending       int
+	pendingLength uint64
+	done          bool
+}
+
+// NewDiffReader reads the banner and the header of a diff stream of any
+// version from r and returns a DiffReader for the extents. r is not read
+// past the end of the diff stream if it implements io.ByteReader.
+func NewDiffReader(r io.Reader) (*DiffReader, error) {
+	dr := &DiffReader{sr: newStreamReader(r), pending: -1}
+	banner, err := dr.sr.banner(diffBannerV1, diffBannerV2)
+	if err != nil {
+		return nil, err
+	}
+	dr.version = V1
+	if banner == diffBannerV2 {
+		dr.version = V2
+	}
+	for {
+		tag, length, err := dr.tag()
+		if err != nil {
+			return nil, err
+		}
+		switch tag {
+		case tagFromSnap:
+			dr.header.FromSnap, err = dr.sr.string()
+		case tagToSnap:
+			dr.header.ToSnap, err = dr.sr.string()
+		case tagProtected:
+			// rbd records a length of 8 for the single byte
+			var b byte
+			b, err = dr.sr.byte()
+			dr.header.Protected = b != 0
+		case tagSize:
+			dr.header.Size, err = dr.sr.uint64()
+		case tagWrite, tagZero, tagEnd:
+			dr.pending = int(tag)
+			dr.pendingLength = length
+			return dr, nil
+		default:
+			err = dr.unknown(tag, length)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// tag reads the tag of the next record and, for V2 streams, its length.
+func (dr *DiffReader) tag() (byte, uint64, error) {
+	tag, err := dr.sr.byte()
+	if err != nil || tag == tagEnd || dr.version == V1 {
+		return tag, 0, err
+	}
+	length, err := dr.sr.uint64()
+	return tag, length, err
+}
+
+// unknown skips a record with an unknown tag. Only V2 streams can be read
+// past such records.
+func (dr *DiffReader) unknown(tag byte, length uint64) error {
+	if dr.version == V1 {
+		return invalidf("unknown record %q", tag)
+	}
+	return dr.sr.skip(length)
+}
+
+// Version returns the version of the stream.
+func (dr *DiffReader) Version() Version {
+	return dr.version
+}
+
+// Header returns the header of the stream.
+func (dr *DiffReader) Header() DiffHeader {
+	return dr.header
+}
+
+// Next returns the next extent of the stream. It returns io.EOF once the end
+// of the stream has been reached.
+func (dr *DiffReader) Next() (*Extent, error) {
+	for !dr.done {
+		var (
+			tag    byte
+			length uint64
+			err    error
+		)
+		if dr.pending >= 0 {
+			tag, length = byte(dr.pending), dr.pendingLength
+			dr.pending = -1
+		} else if tag, length, err = dr.tag(); err != nil {
+			return nil, err
+		}
+		switch tag {
+		case tagWrite:
+			return dr.extent(false)
+		case tagZero:
+			return dr.extent(true)
+		case tagEnd:
+			dr.done = true
+		default:
+			if err := dr.unknown(tag, length); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return nil, io.EOF
+}
+
+func (dr *DiffReader) extent(zero bool) (*Extent, error) {
+	e := &Extent{Zero: zero}
+	var err error
+	if e.Offset, err = dr.sr.uint64(); err != nil {
+		return nil, err
+	}
+	if e.Length, err = dr.sr.uint64(); err != nil {
+		return nil, err
+	}
+	if !zero {
+		if e.Length > maxExtentLength {
+			return nil, invalidf("extent of %d bytes", e.Length)
+		}
+		if e.Data, err = dr.sr.full(e.Length); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
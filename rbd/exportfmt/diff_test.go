@@ -0,0 +1,195 @@
+//go:build ceph_preview
+
+package exportfmt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// le returns the little endian encoding of the values, which must be bytes,
+// uint32s, uint64s or strings.
+func le(values ...any) []byte {
+	var b []byte
+	for _, v := range values {
+		switch v := v.(type) {
+		case byte:
+			b = append(b, v)
+		case uint32:
+			b = binary.LittleEndian.AppendUint32(b, v)
+		case uint64:
+			b = binary.LittleEndian.AppendUint64(b, v)
+		case string:
+			b = append(b, v...)
+		default:
+			panic(v)
+		}
+	}
+	return b
+}
+
+var testHeader = DiffHeader{
+	FromSnap:  "s1",
+	ToSnap:    "s2",
+	Protected: true,
+	Size:      1 << 20,
+}
+
+func writeTestDiff(t *testing.T, w io.Writer, version Version) {
+	dw, err := NewDiffWriter(w, version, testHeader)
+	require.NoError(t, err)
+	require.NoError(t, dw.WriteData(4096, []byte("abc")))
+	require.NoError(t, dw.WriteZero(8192, 512))
+	require.NoError(t, dw.Close())
+}
+
+func TestDiffWriter(t *testing.T) {
+	t.Run("v1", func(t *testing.T) {
+		var buf bytes.Buffer
+		writeTestDiff(t, &buf, V1)
+		assert.Equal(t, le(
+			"rbd diff v1\n",
+			byte('f'), uint32(2), "s1",
+			byte('t'), uint32(2), "s2",
+			byte('s'), uint64(1<<20),
+			byte('w'), uint64(4096), uint64(3), "abc",
+			byte('z'), uint64(8192), uint64(512),
+			byte('e'),
+		), buf.Bytes())
+	})
+
+	t.Run("v2", func(t *testing.T) {
+		var buf bytes.Buffer
+		writeTestDiff(t, &buf, V2)
+		assert.Equal(t, le(
+			"rbd diff v2\n",
+			byte('f'), uint64(6), uint32(2), "s1",
+			byte('t'), uint64(6), uint32(2), "s2",
+			byte('p'), uint64(8), byte(1),
+			byte('s'), uint64(8), uint64(1<<20),
+			byte('w'), uint64(19), uint64(4096), uint64(3), "abc",
+			byte('z'), uint64(16), uint64(8192), uint64(512),
+			byte('e'),
+		), buf.Bytes())
+	})
+
+	t.Run("noSnaps", func(t *testing.T) {
+		var buf bytes.Buffer
+		dw, err := NewDiffWriter(&buf, V2, DiffHeader{Size: 4096})
+		require.NoError(t, err)
+		require.NoError(t, dw.Close())
+		assert.Equal(t, le(
+			"rbd diff v2\n",
+			byte('s'), uint64(8), uint64(4096),
+			byte('e'),
+		), buf.Bytes())
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		dw, err := NewDiffWriter(io.Discard, V1, DiffHeader{})
+		require.NoError(t, err)
+		assert.NoError(t, dw.Close())
+		assert.NoError(t, dw.Close())
+		assert.Error(t, dw.WriteData(0, []byte("x")))
+	})
+
+	t.Run("badVersion", func(t *testing.T) {
+		_, err := NewDiffWriter(io.Discard, Version(3), DiffHeader{})
+		assert.Error(t, err)
+	})
+}
+
+func readAll(t *testing.T, dr *DiffReader) []Extent {
+	var extents []Extent
+	for {
+		e, err := dr.Next()
+		if err == io.EOF {
+			return extents
+		}
+		require.NoError(t, err)
+		extents = append(extents, *e)
+	}
+}
+
+func TestDiffReader(t *testing.T) {
+	expected := []Extent{
+		{Offset: 4096, Length: 3, Data: []byte("abc")},
+		{Offset: 8192, Length: 512, Zero: true},
+	}
+
+	for _, version := range []Version{V1, V2} {
+		var buf bytes.Buffer
+		writeTestDiff(t, &buf, version)
+		dr, err := NewDiffReader(&buf)
+		require.NoError(t, err)
+		assert.Equal(t, version, dr.Version())
+		h := dr.Header()
+		if version == V1 {
+			// V1 streams do not record the protection status
+			h.Protected = true
+		}
+		assert.Equal(t, testHeader, h)
+		assert.Equal(t, expected, readAll(t, dr))
+		_, err = dr.Next()
+		assert.Equal(t, io.EOF, err)
+	}
+
+	t.Run("unknownV2", func(t *testing.T) {
+		data := le(
+			"rbd diff v2\n",
+			byte('s'), uint64(8), uint64(4096),
+			byte('X'), uint64(3), "???",
+			byte('w'), uint64(17), uint64(0), uint64(1), "a",
+			byte('Y'), uint64(0),
+			byte('e'),
+		)
+		dr, err := NewDiffReader(bytes.NewReader(data))
+		require.NoError(t, err)
+		assert.EqualValues(t, 4096, dr.Header().Size)
+		assert.Equal(t, []Extent{{Offset: 0, Length: 1, Data: []byte("a")}},
+			readAll(t, dr))
+	})
+
+	t.Run("unknownV1", func(t *testing.T) {
+		data := le("rbd diff v1\n", byte('X'))
+		_, err := NewDiffReader(bytes.NewReader(data))
+		assert.ErrorIs(t, err, ErrInvalidStream)
+	})
+
+	t.Run("badBanner", func(t *testing.T) {
+		_, err := NewDiffReader(bytes.NewReader([]byte("rbd diff v3\n")))
+		assert.ErrorIs(t, err, ErrInvalidStream)
+		_, err = NewDiffReader(bytes.NewReader(bytes.Repeat([]byte("x"), 64)))
+		assert.ErrorIs(t, err, ErrInvalidStream)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		var buf bytes.Buffer
+		writeTestDiff(t, &buf, V2)
+		data := buf.Bytes()
+		for _, n := range []int{0, 5, 20, len(data) - 20, len(data) - 1} {
+			dr, err := NewDiffReader(bytes.NewReader(data[:n]))
+			for err == nil {
+				_, err = dr.Next()
+			}
+			assert.Equal(t, io.ErrUnexpectedEOF, err, "length %d", n)
+		}
+	})
+
+	t.Run("hugeExtent", func(t *testing.T) {
+		data := le(
+			"rbd diff v1\n",
+			byte('s'), uint64(4096),
+			byte('w'), uint64(0), uint64(1<<40),
+		)
+		dr, err := NewDiffReader(bytes.NewReader(data))
+		require.NoError(t, err)
+		_, err = dr.Next()
+		assert.ErrorIs(t, err, ErrInvalidStream)
+	})
+}
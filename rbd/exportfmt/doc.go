@@ -0,0 +1,18 @@
+//go:build ceph_preview
+
+/*
+Package exportfmt reads and writes the stream formats of the rbd export,
+export-diff, import and import-diff commands.
+
+A diff stream, as written by "rbd export-diff", contains the changes of an
+image between two snapshots. An image stream, as written by
+"rbd export --export-format 2", contains the properties and metadata of an
+image followed by one diff stream per snapshot and one for the image head.
+The version 1 export format is the raw image data and needs no support from
+this package.
+
+DiffWriter, DiffReader, ImageWriter and ImageReader work on the streams
+alone. ExportDiff, Export, ImportDiff and Import drive them with an rbd
+image, producing streams that are compatible with the rbd command line tool.
+*/
+package exportfmt
@@ -0,0 +1,212 @@
+//go:build ceph_preview
+
+package exportfmt
+
+import (
+	"bytes"
+	"io"
+	"sort"
+
+	"github.com/ceph/go-ceph/rbd"
+)
+
+// maxReadLength limits the length of the reads of changed extents, larger
+// extents are split.
+const maxReadLength = 1 << 22
+
+// ExportDiff writes a diff stream of the given version with the changes of
+// the image between the snapshot fromSnap and toSnap. The image must be
+// open at toSnap, or at the image head if toSnap is empty. If fromSnap is
+// empty the stream contains all data of the image.
+//
+// Similar To:
+//
+//	rbd export-diff --from-snap <fromSnap> <image>@<toSnap> --export-format <version>
+func ExportDiff(w io.Writer, image *rbd.Image, fromSnap, toSnap string, version Version) error {
+	h, err := diffHeader(image, fromSnap, toSnap, version)
+	if err != nil {
+		return err
+	}
+	dw, err := NewDiffWriter(w, version, h)
+	if err != nil {
+		return err
+	}
+	if err := exportExtents(dw, image, fromSnap, h.Size); err != nil {
+		return err
+	}
+	return dw.Close()
+}
+
+// diffHeader returns the header of a diff stream of the image, which is open
+// at toSnap.
+func diffHeader(image *rbd.Image, fromSnap, toSnap string, version Version) (DiffHeader, error) {
+	h := DiffHeader{FromSnap: fromSnap, ToSnap: toSnap}
+	var err error
+	if h.Size, err = image.GetSize(); err != nil {
+		return h, err
+	}
+	if toSnap != "" && version == V2 {
+		if h.Protected, err = image.GetSnapshot(toSnap).IsProtected(); err != nil {
+			return h, err
+		}
+	}
+	return h, nil
+}
+
+// exportExtents writes the extents of the image changed since fromSnap.
+func exportExtents(dw *DiffWriter, image *rbd.Image, fromSnap string, size uint64) error {
+	if size == 0 {
+		return nil
+	}
+	var extents []Extent
+	// like rbd, include the data a clone inherits from its parent
+	err := image.DiffIterate(rbd.DiffIterateConfig{
+		SnapName:      fromSnap,
+		Offset:        0,
+		Length:        size,
+		IncludeParent: rbd.IncludeParent,
+		Callback: func(off, length uint64, exists int, _ interface{}) int {
+			extents = append(extents, Extent{
+				Offset: off,
+				Length: length,
+				Zero:   exists == 0,
+			})
+			return 0
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	var buf []byte
+	for _, e := range extents {
+		if e.Zero {
+			if err := dw.WriteZero(e.Offset, e.Length); err != nil {
+				return err
+			}
+			continue
+		}
+		for off, end := e.Offset, e.Offset+e.Length; off < end; {
+			n := min(end-off, maxReadLength)
+			if uint64(cap(buf)) < n {
+				buf = make([]byte, n)
+			}
+			data := buf[:n]
+			if _, err := image.ReadAt(data, int64(off)); err != nil {
+				return err
+			}
+			// like rbd, record extents that read as zeros as zeroed
+			if isZero(data) {
+				err = dw.WriteZero(off, n)
+			} else {
+				err = dw.WriteData(off, data)
+			}
+			if err != nil {
+				return err
+			}
+			off += n
+		}
+	}
+	return nil
+}
+
+func isZero(b []byte) bool {
+	for len(b) > 0 {
+		n := min(len(b), len(zeros))
+		if !bytes.Equal(b[:n], zeros[:n]) {
+			return false
+		}
+		b = b[n:]
+	}
+	return true
+}
+
+var zeros = make([]byte, 4096)
+
+// Export writes an image stream with the properties, metadata, snapshots and
+// data of the image. The image must be open at the image head. It is set to
+// each of its snapshots in turn and back to the head when Export returns.
+//
+// Similar To:
+//
+//	rbd export --export-format 2 <image> -
+func Export(w io.Writer, image *rbd.Image) error {
+	h, err := imageHeader(image)
+	if err != nil {
+		return err
+	}
+	snaps, err := image.GetSnapshotNames()
+	if err != nil {
+		return err
+	}
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].Id < snaps[j].Id })
+
+	iw, err := NewImageWriter(w, h, uint64(len(snaps))+1)
+	if err != nil {
+		return err
+	}
+	err = exportDiffs(iw, image, snaps)
+	if serr := image.SetSnapshot(rbd.NoSnapshot); err == nil {
+		err = serr
+	}
+	return err
+}
+
+// exportDiffs writes the diff streams of an image stream, one for each of the
+// snapshots and one for the image head.
+func exportDiffs(iw *ImageWriter, image *rbd.Image, snaps []rbd.SnapInfo) error {
+	fromSnap := ""
+	for _, snap := range snaps {
+		if err := image.SetSnapshot(snap.Name); err != nil {
+			return err
+		}
+		if err := exportImageDiff(iw, image, fromSnap, snap.Name); err != nil {
+			return err
+		}
+		fromSnap = snap.Name
+	}
+	if err := image.SetSnapshot(rbd.NoSnapshot); err != nil {
+		return err
+	}
+	return exportImageDiff(iw, image, fromSnap, "")
+}
+
+func imageHeader(image *rbd.Image) (ImageHeader, error) {
+	var (
+		h   ImageHeader
+		err error
+	)
+	info, err := image.Stat()
+	if err != nil {
+		return h, err
+	}
+	h.Order = uint64(info.Order)
+	if h.Features, err = image.GetFeatures(); err != nil {
+		return h, err
+	}
+	if h.StripeUnit, err = image.GetStripeUnit(); err != nil {
+		return h, err
+	}
+	if h.StripeCount, err = image.GetStripeCount(); err != nil {
+		return h, err
+	}
+	if h.Metadata, err = image.ListMetadata(); err != nil {
+		return h, err
+	}
+	return h, nil
+}
+
+func exportImageDiff(iw *ImageWriter, image *rbd.Image, fromSnap, toSnap string) error {
+	h, err := diffHeader(image, fromSnap, toSnap, V2)
+	if err != nil {
+		return err
+	}
+	dw, err := iw.NextDiff(h)
+	if err != nil {
+		return err
+	}
+	if err := exportExtents(dw, image, fromSnap, h.Size); err != nil {
+		return err
+	}
+	return dw.Close()
+}
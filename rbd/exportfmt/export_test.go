@@ -0,0 +1,232 @@
+//go:build ceph_preview
+
+package exportfmt
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ceph/go-ceph/internal/radostest"
+	"github.com/ceph/go-ceph/rados"
+	"github.com/ceph/go-ceph/rbd"
+)
+
+var testPool = radostest.Pool{NoNamespaces: true}
+
+func TestMain(m *testing.M) {
+	os.Exit(testPool.Run(m))
+}
+
+const (
+	testImageSize  = uint64(1 << 22)
+	testImageOrder = 22
+)
+
+func createImage(t *testing.T, ioctx *rados.IOContext, size uint64) *rbd.Image {
+	name := uuid.Must(uuid.NewV4()).String()
+	options := rbd.NewRbdImageOptions()
+	defer options.Destroy()
+	require.NoError(t, options.SetUint64(rbd.ImageOptionOrder, testImageOrder))
+	require.NoError(t, rbd.CreateImage(ioctx, name, size, options))
+	return openImage(t, ioctx, name)
+}
+
+func openImage(t *testing.T, ioctx *rados.IOContext, name string) *rbd.Image {
+	image, err := rbd.OpenImage(ioctx, name, rbd.NoSnapshot)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		snaps, err := image.GetSnapshotNames()
+		assert.NoError(t, err)
+		for _, s := range snaps {
+			snap := image.GetSnapshot(s.Name)
+			if protected, _ := snap.IsProtected(); protected {
+				assert.NoError(t, snap.Unprotect())
+			}
+			assert.NoError(t, snap.Remove())
+		}
+		assert.NoError(t, image.Close())
+		assert.NoError(t, rbd.RemoveImage(ioctx, name))
+	})
+	return image
+}
+
+// contents returns the contents of the image at the snapshot snapName.
+func contents(t *testing.T, image *rbd.Image, snapName string) []byte {
+	require.NoError(t, image.SetSnapshot(snapName))
+	defer func() {
+		require.NoError(t, image.SetSnapshot(rbd.NoSnapshot))
+	}()
+	size, err := image.GetSize()
+	require.NoError(t, err)
+	buf := make([]byte, size)
+	_, err = image.ReadAt(buf, 0)
+	require.NoError(t, err)
+	return buf
+}
+
+// fillTestImage writes data to the image, creates the protected snapshot
+// "s1" and changes the image afterwards.
+func fillTestImage(t *testing.T, image *rbd.Image) {
+	_, err := image.WriteAt(bytes.Repeat([]byte("s1"), 2048), 0)
+	require.NoError(t, err)
+	_, err = image.WriteAt(bytes.Repeat([]byte("s1"), 2048), 1<<20)
+	require.NoError(t, err)
+	snap, err := image.CreateSnapshot("s1")
+	require.NoError(t, err)
+	require.NoError(t, snap.Protect())
+
+	require.NoError(t, image.Resize(2*testImageSize))
+	_, err = image.WriteAt([]byte("head"), int64(testImageSize)+100)
+	require.NoError(t, err)
+	_, err = image.Discard(1<<20, 4096)
+	require.NoError(t, err)
+	require.NoError(t, image.SetMetadata("key", "value"))
+}
+
+func TestExportDiff(t *testing.T) {
+	ioctx := testPool.IOContext(t)
+	src := createImage(t, ioctx, testImageSize)
+	fillTestImage(t, src)
+
+	for _, version := range []Version{V1, V2} {
+		dst := createImage(t, ioctx, 0)
+
+		var buf bytes.Buffer
+		require.NoError(t, src.SetSnapshot("s1"))
+		require.NoError(t, ExportDiff(&buf, src, "", "s1", version))
+		require.NoError(t, src.SetSnapshot(rbd.NoSnapshot))
+		require.NoError(t, ImportDiff(&buf, dst))
+
+		protected, err := dst.GetSnapshot("s1").IsProtected()
+		assert.NoError(t, err)
+		assert.Equal(t, version == V2, protected)
+		assert.Equal(t, contents(t, src, "s1"), contents(t, dst, "s1"))
+
+		buf.Reset()
+		require.NoError(t, ExportDiff(&buf, src, "s1", "", version))
+		data := buf.Bytes()
+		require.NoError(t, ImportDiff(bytes.NewReader(data), dst))
+		assert.Equal(t, contents(t, src, ""), contents(t, dst, ""))
+
+		// the diff can not be applied twice, as it leads to a snapshot that
+		// already exists
+		require.NoError(t, src.SetSnapshot("s1"))
+		buf.Reset()
+		require.NoError(t, ExportDiff(&buf, src, "", "s1", version))
+		require.NoError(t, src.SetSnapshot(rbd.NoSnapshot))
+		assert.ErrorIs(t, ImportDiff(&buf, dst), rbd.ErrExist)
+	}
+
+	t.Run("missingFromSnap", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, ExportDiff(&buf, src, "s1", "", V1))
+		dst := createImage(t, ioctx, 0)
+		assert.ErrorIs(t, ImportDiff(&buf, dst), rbd.ErrNotExist)
+	})
+}
+
+func TestExport(t *testing.T) {
+	ioctx := testPool.IOContext(t)
+	src := createImage(t, ioctx, testImageSize)
+	fillTestImage(t, src)
+
+	var buf bytes.Buffer
+	require.NoError(t, Export(&buf, src))
+	// the image is back at the head
+	size, err := src.GetSize()
+	assert.NoError(t, err)
+	assert.Equal(t, 2*testImageSize, size)
+
+	name := uuid.Must(uuid.NewV4()).String()
+	require.NoError(t, Import(&buf, ioctx, name))
+	dst := openImage(t, ioctx, name)
+
+	srcFeatures, err := src.GetFeatures()
+	assert.NoError(t, err)
+	dstFeatures, err := dst.GetFeatures()
+	assert.NoError(t, err)
+	assert.Equal(t, srcFeatures, dstFeatures)
+	info, err := dst.Stat()
+	assert.NoError(t, err)
+	assert.Equal(t, testImageOrder, info.Order)
+	value, err := dst.GetMetadata("key")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", value)
+
+	snaps, err := dst.GetSnapshotNames()
+	assert.NoError(t, err)
+	if assert.Len(t, snaps, 1) {
+		assert.Equal(t, "s1", snaps[0].Name)
+	}
+	protected, err := dst.GetSnapshot("s1").IsProtected()
+	assert.NoError(t, err)
+	assert.True(t, protected)
+	assert.Equal(t, contents(t, src, "s1"), contents(t, dst, "s1"))
+	assert.Equal(t, contents(t, src, ""), contents(t, dst, ""))
+
+	t.Run("exists", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, Export(&buf, src))
+		assert.Error(t, Import(&buf, ioctx, name))
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, Export(&buf, src))
+		data := buf.Bytes()
+		name := uuid.Must(uuid.NewV4()).String()
+		err := Import(bytes.NewReader(data[:len(data)-100]), ioctx, name)
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		// the partially imported image has been removed
+		_, err = rbd.OpenImage(ioctx, name, rbd.NoSnapshot)
+		assert.ErrorIs(t, err, rbd.ErrNotFound)
+	})
+}
+
+func TestExportClone(t *testing.T) {
+	ioctx := testPool.IOContext(t)
+	parent := createImage(t, ioctx, testImageSize)
+	_, err := parent.WriteAt(bytes.Repeat([]byte("parent"), 1024), 0)
+	require.NoError(t, err)
+	_, err = parent.WriteAt(bytes.Repeat([]byte("parent"), 1024), 1<<20)
+	require.NoError(t, err)
+	snap, err := parent.CreateSnapshot("base")
+	require.NoError(t, err)
+	require.NoError(t, snap.Protect())
+
+	name := uuid.Must(uuid.NewV4()).String()
+	options := rbd.NewRbdImageOptions()
+	defer options.Destroy()
+	require.NoError(t, options.SetUint64(rbd.ImageOptionOrder, testImageOrder))
+	require.NoError(t, rbd.CloneImage(ioctx, parent.GetName(), "base", ioctx, name, options))
+	clone := openImage(t, ioctx, name)
+	_, err = clone.WriteAt([]byte("clone"), 4096)
+	require.NoError(t, err)
+
+	// the data inherited from the parent is part of the streams
+	expected := contents(t, clone, "")
+	assert.Equal(t, []byte("parent"), expected[1<<20:1<<20+6])
+
+	t.Run("exportDiff", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, ExportDiff(&buf, clone, "", "", V1))
+		dst := createImage(t, ioctx, 0)
+		require.NoError(t, ImportDiff(&buf, dst))
+		assert.Equal(t, expected, contents(t, dst, ""))
+	})
+
+	t.Run("export", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, Export(&buf, clone))
+		name := uuid.Must(uuid.NewV4()).String()
+		require.NoError(t, Import(&buf, ioctx, name))
+		dst := openImage(t, ioctx, name)
+		assert.Equal(t, expected, contents(t, dst, ""))
+	})
+}
@@ -0,0 +1,180 @@
+//go:build ceph_preview
+
+package exportfmt
+
+import (
+	"encoding/binary"
+	"io"
+	"slices"
+)
+
+const (
+	imageBannerV2      = "rbd image v2\n"
+	imageDiffsBannerV2 = "rbd image diffs v2\n"
+)
+
+// tags of the records of the header of image streams
+const (
+	tagOrder       = 'O'
+	tagFeatures    = 'T'
+	tagStripeUnit  = 'U'
+	tagStripeCount = 'C'
+	tagMeta        = 'M'
+	tagImageEnd    = 'E'
+)
+
+// ImageHeader describes the image of an image stream.
+type ImageHeader struct {
+	Order       uint64
+	Features    uint64
+	StripeUnit  uint64
+	StripeCount uint64
+	// Metadata are the image metadata key/value pairs.
+	Metadata map[string]string
+}
+
+// ImageWriter writes an image stream.
+type ImageWriter struct {
+	w       io.Writer
+	pending uint64
+}
+
+// NewImageWriter writes the banner and the header of an image stream to w.
+// The stream must be completed by writing numDiffs diff streams with
+// NextDiff, one for each snapshot of the image, oldest first, and one for the
+// image head.
+func NewImageWriter(w io.Writer, h ImageHeader, numDiffs uint64) (*ImageWriter, error) {
+	buf := []byte(imageBannerV2)
+	for _, r := range []struct {
+		tag   byte
+		value uint64
+	}{
+		{tagOrder, h.Order},
+		{tagFeatures, h.Features},
+		{tagStripeUnit, h.StripeUnit},
+		{tagStripeCount, h.StripeCount},
+	} {
+		buf = append(buf, r.tag)
+		buf = binary.LittleEndian.AppendUint64(buf, 8)
+		buf = binary.LittleEndian.AppendUint64(buf, r.value)
+	}
+	keys := make([]string, 0, len(h.Metadata))
+	for k := range h.Metadata {
+		keys = append(keys, k)
+	}
+	// like rbd, write the metadata sorted by key
+	slices.Sort(keys)
+	for _, k := range keys {
+		v := h.Metadata[k]
+		buf = append(buf, tagMeta)
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(8+len(k)+len(v)))
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(k)))
+		buf = append(buf, k...)
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(v)))
+		buf = append(buf, v...)
+	}
+	buf = append(buf, tagImageEnd)
+	buf = append(buf, imageDiffsBannerV2...)
+	buf = binary.LittleEndian.AppendUint64(buf, numDiffs)
+	if _, err := w.Write(buf); err != nil {
+		return nil, err
+	}
+	return &ImageWriter{w: w, pending: numDiffs}, nil
+}
+
+// NextDiff starts the next V2 diff stream of the image stream. The returned
+// DiffWriter must be closed before NextDiff is called again.
+func (iw *ImageWriter) NextDiff(h DiffHeader) (*DiffWriter, error) {
+	if iw.pending == 0 {
+		return nil, invalidf("all diffs have been written")
+	}
+	iw.pending--
+	return NewDiffWriter(iw.w, V2, h)
+}
+
+// ImageReader reads an image stream.
+type ImageReader struct {
+	sr      streamReader
+	header  ImageHeader
+	pending uint64
+	diff    *DiffReader
+}
+
+// NewImageReader reads the banner and the header of an image stream from r.
+func NewImageReader(r io.Reader) (*ImageReader, error) {
+	ir := &ImageReader{sr: newStreamReader(r)}
+	if _, err := ir.sr.banner(imageBannerV2); err != nil {
+		return nil, err
+	}
+	h := &ir.header
+	for done := false; !done; {
+		tag, err := ir.sr.byte()
+		if err != nil {
+			return nil, err
+		}
+		if tag == tagImageEnd {
+			break
+		}
+		length, err := ir.sr.uint64()
+		if err != nil {
+			return nil, err
+		}
+		switch tag {
+		case tagOrder:
+			h.Order, err = ir.sr.uint64()
+		case tagFeatures:
+			h.Features, err = ir.sr.uint64()
+		case tagStripeUnit:
+			h.StripeUnit, err = ir.sr.uint64()
+		case tagStripeCount:
+			h.StripeCount, err = ir.sr.uint64()
+		case tagMeta:
+			var k, v string
+			if k, err = ir.sr.string(); err == nil {
+				v, err = ir.sr.string()
+			}
+			if h.Metadata == nil {
+				h.Metadata = map[string]string{}
+			}
+			h.Metadata[k] = v
+		default:
+			err = ir.sr.skip(length)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if _, err := ir.sr.banner(imageDiffsBannerV2); err != nil {
+		return nil, err
+	}
+	n, err := ir.sr.uint64()
+	if err != nil {
+		return nil, err
+	}
+	ir.pending = n
+	return ir, nil
+}
+
+// Header returns the header of the stream.
+func (ir *ImageReader) Header() ImageHeader {
+	return ir.header
+}
+
+// NextDiff returns a reader for the next diff stream of the image stream. The
+// previous diff stream must have been read to its end. NextDiff returns
+// io.EOF once all diff streams have been read.
+func (ir *ImageReader) NextDiff() (*DiffReader, error) {
+	if ir.diff != nil && !ir.diff.done {
+		return nil, invalidf("previous diff has not been read")
+	}
+	if ir.pending == 0 {
+		return nil, io.EOF
+	}
+	ir.pending--
+	dr, err := NewDiffReader(ir.sr.r)
+	if err != nil {
+		return nil, err
+	}
+	ir.diff = dr
+	return dr, nil
+}
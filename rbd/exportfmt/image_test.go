@@ -0,0 +1,143 @@
+//go:build ceph_preview
+
+package exportfmt
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageWriter(t *testing.T) {
+	var buf bytes.Buffer
+	h := ImageHeader{
+		Order:       22,
+		Features:    1,
+		StripeUnit:  1 << 22,
+		StripeCount: 1,
+		Metadata:    map[string]string{"b": "2", "a": "1"},
+	}
+	iw, err := NewImageWriter(&buf, h, 1)
+	require.NoError(t, err)
+	dw, err := iw.NextDiff(DiffHeader{Size: 4096})
+	require.NoError(t, err)
+	require.NoError(t, dw.Close())
+	_, err = iw.NextDiff(DiffHeader{})
+	assert.Error(t, err)
+
+	assert.Equal(t, le(
+		"rbd image v2\n",
+		byte('O'), uint64(8), uint64(22),
+		byte('T'), uint64(8), uint64(1),
+		byte('U'), uint64(8), uint64(1<<22),
+		byte('C'), uint64(8), uint64(1),
+		byte('M'), uint64(10), uint32(1), "a", uint32(1), "1",
+		byte('M'), uint64(10), uint32(1), "b", uint32(1), "2",
+		byte('E'),
+		"rbd image diffs v2\n",
+		uint64(1),
+		"rbd diff v2\n",
+		byte('s'), uint64(8), uint64(4096),
+		byte('e'),
+	), buf.Bytes())
+}
+
+func writeTestImage(t *testing.T) ([]byte, ImageHeader) {
+	var buf bytes.Buffer
+	h := ImageHeader{
+		Order:       22,
+		Features:    61,
+		StripeUnit:  1 << 22,
+		StripeCount: 1,
+		Metadata:    map[string]string{"conf_rbd_cache": "false"},
+	}
+	iw, err := NewImageWriter(&buf, h, 2)
+	require.NoError(t, err)
+
+	dw, err := iw.NextDiff(DiffHeader{ToSnap: "s1", Size: 8192})
+	require.NoError(t, err)
+	require.NoError(t, dw.WriteData(0, []byte("snap")))
+	require.NoError(t, dw.Close())
+
+	dw, err = iw.NextDiff(DiffHeader{FromSnap: "s1", Size: 16384})
+	require.NoError(t, err)
+	require.NoError(t, dw.WriteData(8192, []byte("head")))
+	require.NoError(t, dw.WriteZero(0, 4096))
+	require.NoError(t, dw.Close())
+	return buf.Bytes(), h
+}
+
+func TestImageReader(t *testing.T) {
+	data, h := writeTestImage(t)
+
+	// the diff streams must be read from where the image stream is, also if
+	// the reader does not implement io.ByteReader
+	readers := map[string]io.Reader{
+		"byteReader": bytes.NewReader(data),
+		"oneByte":    iotest.OneByteReader(bytes.NewReader(data)),
+		"halfReader": iotest.HalfReader(bytes.NewReader(data)),
+	}
+	for name, r := range readers {
+		t.Run(name, func(t *testing.T) {
+			ir, err := NewImageReader(r)
+			require.NoError(t, err)
+			assert.Equal(t, h, ir.Header())
+
+			dr, err := ir.NextDiff()
+			require.NoError(t, err)
+			assert.Equal(t, DiffHeader{ToSnap: "s1", Size: 8192}, dr.Header())
+			assert.Equal(t, []Extent{{Offset: 0, Length: 4, Data: []byte("snap")}},
+				readAll(t, dr))
+
+			dr, err = ir.NextDiff()
+			require.NoError(t, err)
+			assert.Equal(t, DiffHeader{FromSnap: "s1", Size: 16384}, dr.Header())
+			assert.Equal(t, []Extent{
+				{Offset: 8192, Length: 4, Data: []byte("head")},
+				{Offset: 0, Length: 4096, Zero: true},
+			}, readAll(t, dr))
+
+			_, err = ir.NextDiff()
+			assert.Equal(t, io.EOF, err)
+		})
+	}
+
+	t.Run("unread", func(t *testing.T) {
+		ir, err := NewImageReader(bytes.NewReader(data))
+		require.NoError(t, err)
+		_, err = ir.NextDiff()
+		require.NoError(t, err)
+		_, err = ir.NextDiff()
+		assert.ErrorIs(t, err, ErrInvalidStream)
+	})
+
+	t.Run("unknownRecord", func(t *testing.T) {
+		data := le(
+			"rbd image v2\n",
+			byte('O'), uint64(8), uint64(20),
+			byte('X'), uint64(2), "??",
+			byte('E'),
+			"rbd image diffs v2\n",
+			uint64(0),
+		)
+		ir, err := NewImageReader(bytes.NewReader(data))
+		require.NoError(t, err)
+		assert.Equal(t, ImageHeader{Order: 20}, ir.Header())
+		_, err = ir.NextDiff()
+		assert.Equal(t, io.EOF, err)
+	})
+
+	t.Run("diffBanner", func(t *testing.T) {
+		_, err := NewImageReader(bytes.NewReader([]byte("rbd diff v2\n")))
+		assert.ErrorIs(t, err, ErrInvalidStream)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		_, err := NewImageReader(bytes.NewReader(data[:40]))
+		assert.Equal(t, io.ErrUnexpectedEOF, err)
+	})
+}
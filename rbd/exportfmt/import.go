@@ -0,0 +1,204 @@
+//go:build ceph_preview
+
+package exportfmt
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ceph/go-ceph/rados"
+	"github.com/ceph/go-ceph/rbd"
+)
+
+// ImportDiff applies a diff stream of any version to the image, which must
+// be open at the image head. If the stream is relative to a snapshot it must
+// exist, and the snapshot the stream leads to, if any, must not exist yet.
+// It is created, and protected if the stream records it as protected, once
+// all changes have been applied.
+//
+// Similar To:
+//
+//	rbd import-diff - <image>
+func ImportDiff(r io.Reader, image *rbd.Image) error {
+	dr, err := NewDiffReader(r)
+	if err != nil {
+		return err
+	}
+	return applyDiff(dr, image)
+}
+
+func applyDiff(dr *DiffReader, image *rbd.Image) error {
+	h := dr.Header()
+	if err := checkSnapshots(image, h); err != nil {
+		return err
+	}
+	size, err := image.GetSize()
+	if err != nil {
+		return err
+	}
+	if size != h.Size {
+		if err := image.Resize(h.Size); err != nil {
+			return err
+		}
+	}
+	for {
+		e, err := dr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if e.Zero {
+			err = zeroRange(image, e.Offset, e.Length)
+		} else {
+			_, err = image.WriteAt(e.Data, int64(e.Offset))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if h.ToSnap == "" {
+		return nil
+	}
+	snap, err := image.CreateSnapshot(h.ToSnap)
+	if err != nil {
+		return err
+	}
+	if h.Protected {
+		return snap.Protect()
+	}
+	return nil
+}
+
+// zeroRange zeroes length bytes of the image starting at byte offset off.
+// Unlike a discard, writing zeros is never skipped for parts of objects.
+// librbd turns the write into a discard where it can.
+func zeroRange(image *rbd.Image, off, length uint64) error {
+	rest := length % uint64(len(zeros))
+	if n := length - rest; n > 0 {
+		if _, err := image.WriteSame(off, n, zeros, rados.OpFlagNone); err != nil {
+			return err
+		}
+	}
+	if rest > 0 {
+		_, err := image.WriteAt(zeros[:rest], int64(off+length-rest))
+		return err
+	}
+	return nil
+}
+
+// checkSnapshots checks that the snapshots of the image match the header of
+// a diff stream before it is applied.
+func checkSnapshots(image *rbd.Image, h DiffHeader) error {
+	snaps, err := image.GetSnapshotNames()
+	if err != nil {
+		return err
+	}
+	fromFound := h.FromSnap == ""
+	for _, snap := range snaps {
+		switch snap.Name {
+		case h.FromSnap:
+			fromFound = true
+		case h.ToSnap:
+			return fmt.Errorf("snapshot %q: %w", h.ToSnap, rbd.ErrExist)
+		}
+	}
+	if !fromFound {
+		return fmt.Errorf("snapshot %q: %w", h.FromSnap, rbd.ErrNotExist)
+	}
+	return nil
+}
+
+// Import creates the image name from an image stream, with the properties,
+// metadata, snapshots and data recorded in the stream. If the import fails
+// the image is removed again.
+//
+// Similar To:
+//
+//	rbd import --export-format 2 - <name>
+func Import(r io.Reader, ioctx *rados.IOContext, name string) error {
+	ir, err := NewImageReader(r)
+	if err != nil {
+		return err
+	}
+	h := ir.Header()
+
+	options := rbd.NewRbdImageOptions()
+	defer options.Destroy()
+	for _, o := range []struct {
+		option rbd.ImageOption
+		value  uint64
+	}{
+		{rbd.ImageOptionOrder, h.Order},
+		{rbd.ImageOptionFeatures, h.Features},
+		{rbd.ImageOptionStripeUnit, h.StripeUnit},
+		{rbd.ImageOptionStripeCount, h.StripeCount},
+	} {
+		if err := options.SetUint64(o.option, o.value); err != nil {
+			return err
+		}
+	}
+	// the size is set by the diff streams
+	if err := rbd.CreateImage(ioctx, name, 0, options); err != nil {
+		return err
+	}
+	image, err := rbd.OpenImage(ioctx, name, rbd.NoSnapshot)
+	if err == nil {
+		err = importImage(ir, image)
+		if cerr := image.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		return errors.Join(err, removeImage(ioctx, name))
+	}
+	return nil
+}
+
+func importImage(ir *ImageReader, image *rbd.Image) error {
+	for k, v := range ir.Header().Metadata {
+		if err := image.SetMetadata(k, v); err != nil {
+			return err
+		}
+	}
+	for {
+		dr, err := ir.NextDiff()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := applyDiff(dr, image); err != nil {
+			return err
+		}
+	}
+}
+
+// removeImage removes an image left behind by a failed import, together with
+// the snapshots already imported.
+func removeImage(ioctx *rados.IOContext, name string) error {
+	image, err := rbd.OpenImage(ioctx, name, rbd.NoSnapshot)
+	if err != nil {
+		return err
+	}
+	snaps, err := image.GetSnapshotNames()
+	for i := 0; err == nil && i < len(snaps); i++ {
+		snap := image.GetSnapshot(snaps[i].Name)
+		if protected, _ := snap.IsProtected(); protected {
+			err = snap.Unprotect()
+		}
+		if err == nil {
+			err = snap.Remove()
+		}
+	}
+	if cerr := image.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return rbd.RemoveImage(ioctx, name)
+}
@@ -0,0 +1,300 @@
+//go:build ceph_preview
+
+package rbd
+
+// #cgo LDFLAGS: -lrbd
+// #include <errno.h>
+// #include <stdlib.h>
+// #include <sys/uio.h>
+// #include <rbd/librbd.h>
+import "C"
+
+import (
+	"io"
+
+	"github.com/ceph/go-ceph/rados"
+)
+
+// AioReadAt asynchronously reads up to len(data) bytes from the image starting
+// at byte offset off. The number of bytes read is returned by the Wait method
+// of the returned Completion, together with io.EOF if the read was short
+// because it reached the end of the image. The contents of data are undefined
+// until the Completion is done.
+//
+// Implements:
+//
+//	int rbd_aio_read(rbd_image_t image, uint64_t off, size_t len, char *buf,
+//	                 rbd_completion_t c);
+func (image *Image) AioReadAt(data []byte, off int64) (*Completion, error) {
+	if err := image.validate(imageIsOpen); err != nil {
+		return nil, err
+	}
+	c, err := newCompletion(0)
+	if err != nil {
+		return nil, err
+	}
+	c.finish = readResult(len(data))
+	ret := C.rbd_aio_read(
+		image.image,
+		C.uint64_t(off),
+		C.size_t(len(data)),
+		c.pin(data),
+		c.comp)
+	if err := c.submitted(ret); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// AioReadv asynchronously reads from the image starting at byte offset off
+// into the buffers of iov, filling one buffer after the other. The result is
+// returned like for AioReadAt.
+//
+// Implements:
+//
+//	int rbd_aio_readv(rbd_image_t image, const struct iovec *iov, int iovcnt,
+//	                  uint64_t off, rbd_completion_t c);
+func (image *Image) AioReadv(iov [][]byte, off int64) (*Completion, error) {
+	if err := image.validate(imageIsOpen); err != nil {
+		return nil, err
+	}
+	c, err := newCompletion(0)
+	if err != nil {
+		return nil, err
+	}
+	c.finish = readResult(iovecLen(iov))
+	c.syncIovec = true
+	cIov, cIovCnt := c.pinIovec(iov)
+	ret := C.rbd_aio_readv(
+		image.image,
+		cIov,
+		cIovCnt,
+		C.uint64_t(off),
+		c.comp)
+	if err := c.submitted(ret); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// AioWriteAt asynchronously writes len(data) bytes to the image starting at
+// byte offset off. The data buffer must not be modified until the returned
+// Completion is done. On success Wait returns len(data).
+//
+// Implements:
+//
+//	int rbd_aio_write(rbd_image_t image, uint64_t off, size_t len,
+//	                  const char *buf, rbd_completion_t c);
+func (image *Image) AioWriteAt(data []byte, off int64) (*Completion, error) {
+	if err := image.validate(imageIsOpen); err != nil {
+		return nil, err
+	}
+	c, err := newCompletion(0)
+	if err != nil {
+		return nil, err
+	}
+	c.finish = writeResult(len(data))
+	ret := C.rbd_aio_write(
+		image.image,
+		C.uint64_t(off),
+		C.size_t(len(data)),
+		c.pin(data),
+		c.comp)
+	if err := c.submitted(ret); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// AioWritev asynchronously writes the buffers of iov, one after the other, to
+// the image starting at byte offset off. The buffers must not be modified
+// until the returned Completion is done. On success Wait returns the total
+// length of the buffers.
+//
+// Implements:
+//
+//	int rbd_aio_writev(rbd_image_t image, const struct iovec *iov,
+//	                   int iovcnt, uint64_t off, rbd_completion_t c);
+func (image *Image) AioWritev(iov [][]byte, off int64) (*Completion, error) {
+	if err := image.validate(imageIsOpen); err != nil {
+		return nil, err
+	}
+	c, err := newCompletion(0)
+	if err != nil {
+		return nil, err
+	}
+	c.finish = writeResult(iovecLen(iov))
+	cIov, cIovCnt := c.pinIovec(iov)
+	ret := C.rbd_aio_writev(
+		image.image,
+		cIov,
+		cIovCnt,
+		C.uint64_t(off),
+		c.comp)
+	if err := c.submitted(ret); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// AioDiscard asynchronously discards length bytes of the image starting at
+// byte offset ofs, see Discard.
+//
+// Implements:
+//
+//	int rbd_aio_discard(rbd_image_t image, uint64_t off, uint64_t len,
+//	                    rbd_completion_t c);
+func (image *Image) AioDiscard(ofs, length uint64) (*Completion, error) {
+	if err := image.validate(imageIsOpen); err != nil {
+		return nil, err
+	}
+	c, err := newCompletion(0)
+	if err != nil {
+		return nil, err
+	}
+	ret := C.rbd_aio_discard(
+		image.image,
+		C.uint64_t(ofs),
+		C.uint64_t(length),
+		c.comp)
+	if err := c.submitted(ret); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// AioWriteSame asynchronously writes data repeatedly to the image starting at
+// byte offset ofs until n bytes have been written, see WriteSame. The data
+// buffer must not be modified until the returned Completion is done. On
+// success Wait returns n.
+//
+// Implements:
+//
+//	int rbd_aio_writesame(rbd_image_t image, uint64_t off, size_t len,
+//	                      const char *buf, size_t data_len,
+//	                      rbd_completion_t c, int op_flags);
+func (image *Image) AioWriteSame(
+	ofs, n uint64, data []byte, flags rados.OpFlags) (*Completion, error) {
+
+	if err := image.validate(imageIsOpen); err != nil {
+		return nil, err
+	}
+	c, err := newCompletion(0)
+	if err != nil {
+		return nil, err
+	}
+	c.finish = writeResult(int(n))
+	ret := C.rbd_aio_writesame(
+		image.image,
+		C.uint64_t(ofs),
+		C.size_t(n),
+		c.pin(data),
+		C.size_t(len(data)),
+		c.comp,
+		C.int(flags))
+	if err := c.submitted(ret); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// AioCompareAndWrite asynchronously compares the contents of the image
+// starting at byte offset off with cmp and, only if they are equal, writes
+// buf in their place, see CompareAndWrite. cmp and buf must not be modified
+// until the returned Completion is done. If the contents differ Wait returns
+// a *CompareMismatchError. On success Wait returns len(buf).
+//
+// Implements:
+//
+//	int rbd_aio_compare_and_write(rbd_image_t image, uint64_t off,
+//	                              size_t len, const char *cmp_buf,
+//	                              const char *buf, rbd_completion_t c,
+//	                              uint64_t *mismatch_off, int op_flags);
+func (image *Image) AioCompareAndWrite(
+	cmp, buf []byte, off uint64, flags rados.OpFlags) (*Completion, error) {
+
+	if err := image.validate(imageIsOpen); err != nil {
+		return nil, err
+	}
+	if len(cmp) != len(buf) {
+		return nil, getError(-C.EINVAL)
+	}
+	// librbd writes the mismatch offset when the operation completes
+	c, err := newCompletion(C.sizeof_uint64_t)
+	if err != nil {
+		return nil, err
+	}
+	mismatchOff := (*C.uint64_t)(c.cbuf)
+	c.finish = func(ret C.ssize_t) (int, error) {
+		if ret < 0 {
+			return 0, compareAndWriteError(ret, mismatchOff)
+		}
+		return len(buf), nil
+	}
+	ret := C.rbd_aio_compare_and_write(
+		image.image,
+		C.uint64_t(off),
+		C.size_t(len(buf)),
+		c.pin(cmp),
+		c.pin(buf),
+		c.comp,
+		mismatchOff,
+		C.int(flags))
+	if err := c.submitted(ret); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// AioFlush asynchronously flushes all cached writes of the image to storage.
+//
+// Implements:
+//
+//	int rbd_aio_flush(rbd_image_t image, rbd_completion_t c);
+func (image *Image) AioFlush() (*Completion, error) {
+	if err := image.validate(imageIsOpen); err != nil {
+		return nil, err
+	}
+	c, err := newCompletion(0)
+	if err != nil {
+		return nil, err
+	}
+	ret := C.rbd_aio_flush(image.image, c.comp)
+	if err := c.submitted(ret); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func iovecLen(iov [][]byte) int {
+	n := 0
+	for _, b := range iov {
+		n += len(b)
+	}
+	return n
+}
+
+// readResult returns a finish function for reads of size bytes that reports
+// short reads like ReadAt.
+func readResult(size int) func(C.ssize_t) (int, error) {
+	return func(ret C.ssize_t) (int, error) {
+		if ret < 0 {
+			return 0, getError(C.int(ret))
+		}
+		if int(ret) < size {
+			return int(ret), io.EOF
+		}
+		return int(ret), nil
+	}
+}
+
+// writeResult returns a finish function for writes of size bytes. Unlike
+// the synchronous calls, completed librbd write operations return zero.
+func writeResult(size int) func(C.ssize_t) (int, error) {
+	return func(ret C.ssize_t) (int, error) {
+		if ret < 0 {
+			return 0, getError(C.int(ret))
+		}
+		return size, nil
+	}
+}
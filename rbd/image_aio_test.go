@@ -0,0 +1,323 @@
+//go:build ceph_preview
+
+package rbd
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ceph/go-ceph/rados"
+)
+
+// aioTestImage creates a pool with an image and returns the opened image and
+// a function cleaning up both.
+func aioTestImage(tb testing.TB, size uint64) (*Image, func()) {
+	conn := radosConnect(tb)
+	poolname := GetUUID()
+	require.NoError(tb, conn.MakePool(poolname))
+	ioctx, err := conn.OpenIOContext(poolname)
+	require.NoError(tb, err)
+
+	name := GetUUID()
+	options := NewRbdImageOptions()
+	defer options.Destroy()
+	require.NoError(tb, options.SetUint64(ImageOptionOrder, uint64(testImageOrder)))
+	require.NoError(tb, CreateImage(ioctx, name, size, options))
+	img, err := OpenImage(ioctx, name, NoSnapshot)
+	require.NoError(tb, err)
+
+	return img, func() {
+		assert.NoError(tb, img.Close())
+		assert.NoError(tb, RemoveImage(ioctx, name))
+		ioctx.Destroy()
+		assert.NoError(tb, conn.DeletePool(poolname))
+		conn.Shutdown()
+	}
+}
+
+// waitAio waits for the operation submitted by an asynchronous call and
+// returns its result, or the error of the call.
+func waitAio(c *Completion, err error) (int, error) {
+	if err != nil {
+		return 0, err
+	}
+	defer c.Release()
+	return c.Wait()
+}
+
+func TestImageAio(t *testing.T) {
+	img, cleanup := aioTestImage(t, testImageSize)
+	defer cleanup()
+
+	t.Run("writeRead", func(t *testing.T) {
+		data := []byte("asynchronous image I/O")
+		n, err := waitAio(img.AioWriteAt(data, 4096))
+		assert.NoError(t, err)
+		assert.Equal(t, len(data), n)
+
+		buf := make([]byte, len(data))
+		n, err = waitAio(img.AioReadAt(buf, 4096))
+		assert.NoError(t, err)
+		assert.Equal(t, len(data), n)
+		assert.Equal(t, data, buf)
+
+		// reading past the end of the image is short
+		n, err = waitAio(img.AioReadAt(buf, int64(testImageSize)-4))
+		assert.Equal(t, io.EOF, err)
+		assert.Equal(t, 4, n)
+	})
+
+	t.Run("vectors", func(t *testing.T) {
+		iov := [][]byte{[]byte("one "), nil, []byte("two "), []byte("three")}
+		n, err := waitAio(img.AioWritev(iov, 8192))
+		assert.NoError(t, err)
+		assert.Equal(t, 13, n)
+
+		out := [][]byte{make([]byte, 8), {}, make([]byte, 5)}
+		n, err = waitAio(img.AioReadv(out, 8192))
+		assert.NoError(t, err)
+		assert.Equal(t, 13, n)
+		assert.Equal(t, "one two ", string(out[0]))
+		assert.Equal(t, "three", string(out[2]))
+	})
+
+	t.Run("readv", func(t *testing.T) {
+		// the data must reach the Go buffers also if the iovec holds
+		// copies of them, as in no_ptrguard builds
+		data := []byte("0123456789abcdefghij")
+		_, err := img.WriteAt(data, 12288)
+		require.NoError(t, err)
+
+		out := [][]byte{make([]byte, 3), make([]byte, 10), nil, make([]byte, 7)}
+		c, err := img.AioReadv(out, 12288)
+		require.NoError(t, err)
+		<-c.Done()
+		n, err := waitAio(c, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, 20, n)
+		assert.Equal(t, "012", string(out[0]))
+		assert.Equal(t, "3456789abc", string(out[1]))
+		assert.Equal(t, "defghij", string(out[3]))
+
+		// a short read at the end of the image fills the leading buffers
+		_, err = img.WriteAt([]byte("tail"), int64(testImageSize)-4)
+		require.NoError(t, err)
+		out = [][]byte{make([]byte, 2), make([]byte, 4)}
+		n, err = waitAio(img.AioReadv(out, int64(testImageSize)-4))
+		assert.Equal(t, io.EOF, err)
+		assert.Equal(t, 4, n)
+		assert.Equal(t, "ta", string(out[0]))
+		assert.Equal(t, "il", string(out[1][:2]))
+	})
+
+	t.Run("writeSameDiscard", func(t *testing.T) {
+		n, err := waitAio(img.AioWriteSame(16384, 1024, []byte("abcd"), rados.OpFlagNone))
+		assert.NoError(t, err)
+		assert.Equal(t, 1024, n)
+		buf := make([]byte, 1024)
+		_, err = img.ReadAt(buf, 16384)
+		assert.NoError(t, err)
+		assert.Equal(t, bytes.Repeat([]byte("abcd"), 256), buf)
+
+		_, err = waitAio(img.AioDiscard(16384, 1024))
+		assert.NoError(t, err)
+		_, err = img.ReadAt(buf, 16384)
+		assert.NoError(t, err)
+		assert.Equal(t, make([]byte, 1024), buf)
+	})
+
+	t.Run("compareAndWrite", func(t *testing.T) {
+		_, err := img.WriteAt([]byte("old!"), 32768)
+		require.NoError(t, err)
+
+		n, err := waitAio(img.AioCompareAndWrite(
+			[]byte("old!"), []byte("new!"), 32768, rados.OpFlagNone))
+		assert.NoError(t, err)
+		assert.Equal(t, 4, n)
+
+		_, err = waitAio(img.AioCompareAndWrite(
+			[]byte("old!"), []byte("bad!"), 32768, rados.OpFlagNone))
+		var mismatch *CompareMismatchError
+		if assert.ErrorAs(t, err, &mismatch) {
+			assert.EqualValues(t, 32768, mismatch.Offset)
+		}
+		buf := make([]byte, 4)
+		_, err = img.ReadAt(buf, 32768)
+		assert.NoError(t, err)
+		assert.Equal(t, "new!", string(buf))
+
+		_, err = img.AioCompareAndWrite([]byte("a"), []byte("ab"), 0, rados.OpFlagNone)
+		assert.Error(t, err)
+	})
+
+	t.Run("flush", func(t *testing.T) {
+		_, err := waitAio(img.AioFlush())
+		assert.NoError(t, err)
+	})
+
+	t.Run("callback", func(t *testing.T) {
+		c, err := img.AioWriteAt([]byte("callback"), 65536)
+		require.NoError(t, err)
+		defer c.Release()
+		results := make(chan int, 2)
+		c.OnComplete(func(c *Completion) {
+			n, _ := c.Wait()
+			results <- n
+		})
+		<-c.Done()
+		assert.True(t, c.IsComplete())
+		assert.Equal(t, 8, <-results)
+
+		// registering on a completed operation calls fn right away
+		c.OnComplete(func(c *Completion) {
+			results <- -1
+		})
+		assert.Equal(t, -1, <-results)
+	})
+
+	t.Run("many", func(t *testing.T) {
+		const count = 64
+		const blockSize = 4096
+		comps := make([]*Completion, 0, count)
+		bufs := make([][]byte, count)
+		for i := range count {
+			bufs[i] = bytes.Repeat([]byte{byte(i)}, blockSize)
+			c, err := img.AioWriteAt(bufs[i], int64(i*blockSize))
+			require.NoError(t, err)
+			comps = append(comps, c)
+		}
+		for _, c := range comps {
+			n, err := c.Wait()
+			assert.NoError(t, err)
+			assert.Equal(t, blockSize, n)
+			c.Release()
+		}
+		buf := make([]byte, count*blockSize)
+		_, err := img.ReadAt(buf, 0)
+		assert.NoError(t, err)
+		for i := range count {
+			assert.Equal(t, bufs[i], buf[i*blockSize:(i+1)*blockSize])
+		}
+	})
+
+	t.Run("closedImage", func(t *testing.T) {
+		closed := &Image{}
+		_, err := closed.AioReadAt(make([]byte, 4), 0)
+		assert.ErrorIs(t, err, ErrImageNotOpen)
+		_, err = closed.AioWriteAt([]byte("nope"), 0)
+		assert.ErrorIs(t, err, ErrImageNotOpen)
+		_, err = closed.AioFlush()
+		assert.ErrorIs(t, err, ErrImageNotOpen)
+	})
+}
+
+const (
+	benchBlockSize  = 4096
+	benchQueueDepth = 32
+	benchImageSize  = uint64(1 << 26)
+)
+
+func BenchmarkImageWriteSync(b *testing.B) {
+	img, cleanup := aioTestImage(b, benchImageSize)
+	defer cleanup()
+	data := make([]byte, benchBlockSize)
+	blocks := int(benchImageSize / benchBlockSize)
+
+	b.SetBytes(benchBlockSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := img.WriteAt(data, int64(i%blocks)*benchBlockSize)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkImageWriteAio(b *testing.B) {
+	img, cleanup := aioTestImage(b, benchImageSize)
+	defer cleanup()
+	data := make([]byte, benchBlockSize)
+	blocks := int(benchImageSize / benchBlockSize)
+
+	// keep benchQueueDepth writes in flight
+	inflight := make(chan *Completion, benchQueueDepth)
+	reap := func() {
+		c := <-inflight
+		_, err := c.Wait()
+		c.Release()
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.SetBytes(benchBlockSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if len(inflight) == benchQueueDepth {
+			reap()
+		}
+		c, err := img.AioWriteAt(data, int64(i%blocks)*benchBlockSize)
+		if err != nil {
+			b.Fatal(err)
+		}
+		inflight <- c
+	}
+	for len(inflight) > 0 {
+		reap()
+	}
+}
+
+func BenchmarkImageReadSync(b *testing.B) {
+	img, cleanup := aioTestImage(b, benchImageSize)
+	defer cleanup()
+	data := make([]byte, benchBlockSize)
+	blocks := int(benchImageSize / benchBlockSize)
+
+	b.SetBytes(benchBlockSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := img.ReadAt(data, int64(i%blocks)*benchBlockSize)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkImageReadAio(b *testing.B) {
+	img, cleanup := aioTestImage(b, benchImageSize)
+	defer cleanup()
+	blocks := int(benchImageSize / benchBlockSize)
+	// every in-flight read needs its own buffer
+	bufs := make([][]byte, benchQueueDepth)
+	for i := range bufs {
+		bufs[i] = make([]byte, benchBlockSize)
+	}
+
+	inflight := make(chan *Completion, benchQueueDepth)
+	reap := func() {
+		c := <-inflight
+		_, err := c.Wait()
+		c.Release()
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.SetBytes(benchBlockSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if len(inflight) == benchQueueDepth {
+			reap()
+		}
+		c, err := img.AioReadAt(bufs[i%benchQueueDepth], int64(i%blocks)*benchBlockSize)
+		if err != nil {
+			b.Fatal(err)
+		}
+		inflight <- c
+	}
+	for len(inflight) > 0 {
+		reap()
+	}
+}
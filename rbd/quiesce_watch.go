@@ -0,0 +1,161 @@
+//go:build !nautilus && ceph_preview
+
+package rbd
+
+/*
+#cgo LDFLAGS: -lrbd
+#include <errno.h>
+#include <rbd/librbd.h>
+
+extern void quiesceCallback(uintptr_t);
+extern void unquiesceCallback(uintptr_t);
+
+// inline wrapper to cast uintptr_t to void*
+static inline int wrap_rbd_quiesce_watch(rbd_image_t image, uintptr_t arg,
+	uint64_t *handle) {
+		return rbd_quiesce_watch(image, (void*)quiesceCallback,
+			(void*)unquiesceCallback, (void*)arg, handle);
+	};
+*/
+import "C"
+
+import (
+	"errors"
+
+	"github.com/ceph/go-ceph/internal/callbacks"
+)
+
+// quiesceWatches tracks the active quiesce watches
+var quiesceWatches = callbacks.New()
+
+// QuiesceWatch represents an ongoing watch for quiesce requests of an image.
+type QuiesceWatch struct {
+	image     *Image
+	quiesce   func() error
+	unquiesce func() error
+	handle    C.uint64_t
+	cbIndex   uintptr
+	// events carries the requests, true for quiesce and false for
+	// unquiesce, to the goroutine calling the functions
+	events chan bool
+	done   chan struct{}
+}
+
+// QuiesceWatch registers functions that are called when a client creating a
+// snapshot of the image asks for the I/O to the image to be quiesced, and
+// when the I/O can be resumed after the snapshot has been created. The
+// functions are called one after the other on a goroutine of the watch.
+//
+// The result of quiesce is sent to the creator of the snapshot. If it is an
+// error the snapshot is not created, unless the creator ignores quiesce
+// errors. The error code of errors implementing ErrorCode is sent, EIO for
+// any other error. The error returned by unquiesce is discarded as librbd
+// does not expect a result. A nil function does nothing and succeeds.
+//
+// The watch must be removed with Unwatch before the image is closed.
+//
+// Implements:
+//
+//	int rbd_quiesce_watch(rbd_image_t image,
+//	                      rbd_update_callback_t quiesce_cb,
+//	                      rbd_update_callback_t unquiesce_cb,
+//	                      void *arg, uint64_t *handle);
+//	void rbd_quiesce_complete(rbd_image_t image, uint64_t handle, int r);
+func (image *Image) QuiesceWatch(quiesce, unquiesce func() error) (*QuiesceWatch, error) {
+	if err := image.validate(imageIsOpen); err != nil {
+		return nil, err
+	}
+	w := &QuiesceWatch{
+		image:     image,
+		quiesce:   quiesce,
+		unquiesce: unquiesce,
+		events:    make(chan bool, 2),
+		done:      make(chan struct{}),
+	}
+	w.cbIndex = quiesceWatches.Add(w)
+
+	ret := C.wrap_rbd_quiesce_watch(
+		image.image,
+		C.uintptr_t(w.cbIndex),
+		&w.handle)
+	if ret != 0 {
+		quiesceWatches.Remove(w.cbIndex)
+		return nil, getError(ret)
+	}
+	// the handle is needed to complete requests, so they are only handled
+	// once it has been set
+	go w.run()
+	return w, nil
+}
+
+func (w *QuiesceWatch) run() {
+	defer close(w.done)
+	for quiesce := range w.events {
+		if !quiesce {
+			if w.unquiesce != nil {
+				_ = w.unquiesce()
+			}
+			continue
+		}
+		var err error
+		if w.quiesce != nil {
+			err = w.quiesce()
+		}
+		C.rbd_quiesce_complete(w.image.image, w.handle, C.int(quiesceResult(err)))
+	}
+}
+
+// quiesceResult returns the result code of a quiesce request for err.
+func quiesceResult(err error) int {
+	if err == nil {
+		return 0
+	}
+	var ec interface{ ErrorCode() int }
+	if errors.As(err, &ec) {
+		if code := ec.ErrorCode(); code < 0 {
+			return code
+		} else if code > 0 {
+			return -code
+		}
+	}
+	return -C.EIO
+}
+
+// Unwatch un-registers the quiesce watch. Once it returns the functions of
+// the watch are no longer called.
+//
+// Implements:
+//
+//	int rbd_quiesce_unwatch(rbd_image_t image, uint64_t handle);
+func (w *QuiesceWatch) Unwatch() error {
+	if w.image == nil {
+		return ErrImageNotOpen
+	}
+	if err := w.image.validate(imageIsOpen); err != nil {
+		return err
+	}
+	ret := C.rbd_quiesce_unwatch(w.image.image, w.handle)
+	if ret != 0 {
+		return getError(ret)
+	}
+	quiesceWatches.Remove(w.cbIndex)
+	close(w.events)
+	<-w.done
+	return nil
+}
+
+func quiesceNotify(index uintptr, quiesce bool) {
+	v := quiesceWatches.Lookup(index)
+	w := v.(*QuiesceWatch)
+	w.events <- quiesce
+}
+
+//export quiesceCallback
+func quiesceCallback(index uintptr) {
+	quiesceNotify(index, true)
+}
+
+//export unquiesceCallback
+func unquiesceCallback(index uintptr) {
+	quiesceNotify(index, false)
+}
@@ -0,0 +1,107 @@
+//go:build !nautilus && ceph_preview
+
+package rbd
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuiesceWatch(t *testing.T) {
+	conn := radosConnect(t)
+	require.NotNil(t, conn)
+	defer conn.Shutdown()
+
+	poolname := GetUUID()
+	err := conn.MakePool(poolname)
+	require.NoError(t, err)
+	defer conn.DeletePool(poolname)
+
+	ioctx, err := conn.OpenIOContext(poolname)
+	require.NoError(t, err)
+	defer ioctx.Destroy()
+
+	name := GetUUID()
+	options := NewRbdImageOptions()
+	err = CreateImage(ioctx, name, testImageSize, options)
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, RemoveImage(ioctx, name)) }()
+
+	image, err := OpenImage(ioctx, name, NoSnapshot)
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, image.Close()) }()
+
+	// snapshots are created through another handle of the image, like a
+	// different client would
+	creator, err := OpenImage(ioctx, name, NoSnapshot)
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, creator.Close()) }()
+
+	t.Run("imageNotOpen", func(t *testing.T) {
+		_, err := (&Image{}).QuiesceWatch(nil, nil)
+		assert.Equal(t, ErrImageNotOpen, err)
+	})
+
+	t.Run("snapshot", func(t *testing.T) {
+		var quiesced, unquiesced atomic.Int32
+		w, err := image.QuiesceWatch(
+			func() error {
+				assert.Equal(t, quiesced.Load(), unquiesced.Load())
+				quiesced.Add(1)
+				return nil
+			},
+			func() error {
+				unquiesced.Add(1)
+				return nil
+			})
+		require.NoError(t, err)
+
+		snap, err := creator.CreateSnapshot("quiesced")
+		require.NoError(t, err)
+		defer func() { assert.NoError(t, snap.Remove()) }()
+		assert.EqualValues(t, 1, quiesced.Load())
+		assert.Eventually(t, func() bool {
+			return unquiesced.Load() == 1
+		}, 5*time.Second, 10*time.Millisecond)
+
+		require.NoError(t, w.Unwatch())
+		snap2, err := creator.CreateSnapshot("unwatched")
+		require.NoError(t, err)
+		defer func() { assert.NoError(t, snap2.Remove()) }()
+		assert.EqualValues(t, 1, quiesced.Load())
+		assert.EqualValues(t, 1, unquiesced.Load())
+	})
+
+	t.Run("quiesceError", func(t *testing.T) {
+		w, err := image.QuiesceWatch(
+			func() error { return errors.New("can not freeze") },
+			nil)
+		require.NoError(t, err)
+		defer func() { assert.NoError(t, w.Unwatch()) }()
+
+		_, err = creator.CreateSnapshot("failed")
+		assert.Error(t, err)
+		snaps, err := creator.GetSnapshotNames()
+		assert.NoError(t, err)
+		assert.Len(t, snaps, 0)
+	})
+
+	t.Run("badWatch", func(t *testing.T) {
+		w := &QuiesceWatch{}
+		assert.Error(t, w.Unwatch())
+	})
+}
+
+func TestQuiesceResult(t *testing.T) {
+	assert.Equal(t, 0, quiesceResult(nil))
+	assert.Equal(t, -int(syscall.EIO), quiesceResult(errors.New("no code")))
+	assert.Equal(t, -int(syscall.EEXIST),
+		quiesceResult(fmt.Errorf("wrapped: %w", ErrExist)))
+}
@@ -32,7 +32,7 @@ func TestVersion(t *testing.T) {
 	assert.False(t, patch < 0 || patch > 1000, "invalid patch")
 }
 
-func radosConnect(t *testing.T) *rados.Conn {
+func radosConnect(t testing.TB) *rados.Conn {
 	conn, err := rados.NewConn()
 	require.NoError(t, err)
 	err = conn.ReadDefaultConfigFile()
@@ -41,7 +41,7 @@ func radosConnect(t *testing.T) *rados.Conn {
 	return conn
 }
 
-func radosConnectConfig(t *testing.T, p string) *rados.Conn {
+func radosConnectConfig(t testing.TB, p string) *rados.Conn {
 	conn, err := rados.NewConn()
 	require.NoError(t, err)
 	err = conn.ReadConfigFile(p)
@@ -50,7 +50,7 @@ func radosConnectConfig(t *testing.T, p string) *rados.Conn {
 	return conn
 }
 
-func waitForRadosConn(t *testing.T, conn *rados.Conn) {
+func waitForRadosConn(t testing.TB, conn *rados.Conn) {
 	var err error
 	timeout := time.After(time.Second * 15)
 	ch := make(chan error)